@@ -0,0 +1,18 @@
+//go:build linux
+
+package boomer
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// setCPUAffinity pins the OS thread of the calling goroutine to cpus, the goroutine must be locked to its thread.
+func setCPUAffinity(cpus []int) error {
+	var set unix.CPUSet
+	set.Zero()
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	// pid 0 means the calling thread
+	return unix.SchedSetaffinity(0, &set)
+}
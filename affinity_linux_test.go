@@ -0,0 +1,96 @@
+//go:build linux
+
+package boomer
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"golang.org/x/sys/unix"
+)
+
+// currentProcessor returns the CPU which the calling thread last ran on, the 39th field of /proc/thread-self/stat.
+// /proc/self/stat has the same field, but for the main thread of the process.
+func currentProcessor() (int, error) {
+	stat, err := os.ReadFile("/proc/thread-self/stat")
+	if err != nil {
+		return 0, err
+	}
+	// the fields after the command name, which is in parentheses, start from the 3rd one
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return strconv.Atoi(fields[39-3])
+}
+
+var _ = Describe("Test CPU affinity", func() {
+
+	It("test pin users to CPUs", func() {
+		var allowed unix.CPUSet
+		Expect(unix.SchedGetaffinity(0, &allowed)).To(Succeed())
+		cpu := 0
+		for !allowed.IsSet(cpu) {
+			cpu++
+		}
+
+		// the assertions are made after the test, because the panics in the tasks are recovered by the runner
+		var lock sync.Mutex
+		processors := make(map[int]bool)
+		var affinities []unix.CPUSet
+		b := NewStandaloneBoomer(2, 2).WithCPUAffinity([]int{cpu}).WithRunTime(300 * time.Millisecond).
+			WithLogger(log.New(io.Discard, "", 0))
+		b.Run(&Task{
+			Name: "foo",
+			Fn: func() {
+				var set unix.CPUSet
+				unix.SchedGetaffinity(0, &set)
+				processor, err := currentProcessor()
+				lock.Lock()
+				affinities = append(affinities, set)
+				if err == nil {
+					processors[processor] = true
+				}
+				lock.Unlock()
+				time.Sleep(10 * time.Millisecond)
+			},
+		})
+		// the users may still be running the task after Run returns
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(processors).To(Equal(map[int]bool{cpu: true}))
+		var expected unix.CPUSet
+		expected.Set(cpu)
+		Expect(affinities).NotTo(BeEmpty())
+		for _, set := range affinities {
+			Expect(set).To(Equal(expected))
+		}
+
+		// the thread running the test isn't pinned
+		var set unix.CPUSet
+		Expect(unix.SchedGetaffinity(0, &set)).To(Succeed())
+		Expect(set.Count()).To(Equal(allowed.Count()))
+	})
+
+	It("test pin users to unavailable CPUs", func() {
+		buf := gbytes.NewBuffer()
+		r := newLocalRunner(nil, nil, 1, 1)
+		r.setLogger(log.New(buf, "", 0))
+		NewStandaloneBoomer(1, 1).WithCPUAffinity([]int{1023}).setupRunner(&r.runner)
+		done := make(chan bool)
+		go func() {
+			r.pinToCPUs()
+			r.pinToCPUs()
+			close(done)
+		}()
+		Eventually(done).Should(BeClosed())
+		Expect(buf).To(gbytes.Say(`Failed to pin the users to CPUs \[1023\], invalid argument`))
+		Expect(buf).NotTo(gbytes.Say("Failed"))
+	})
+})
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package boomer
+
+import (
+	"errors"
+)
+
+// setCPUAffinity is not supported on other platforms.
+func setCPUAffinity(cpus []int) error {
+	return errors.New("CPU affinity is only supported on linux")
+}
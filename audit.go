@@ -0,0 +1,86 @@
+package boomer
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// The actions of the audit log.
+const (
+	auditActionStart = "start"
+	auditActionStop  = "stop"
+	auditActionScale = "scale"
+)
+
+// The initiators of the audited actions.
+const (
+	// auditByProgram is an action called by the program, like Boomer.Run and Boomer.Quit.
+	auditByProgram = "programmatic"
+	// auditBySignal is an action triggered by SIGINT or SIGTERM, which are handled by the package level Run.
+	auditBySignal = "signal"
+	// auditByMaster is an action requested by the locust master in distributed mode.
+	auditByMaster = "master"
+	// auditByRemoteConfig is an action applied from the remote config, see Boomer.WithRemoteConfig.
+	auditByRemoteConfig = "remote_config"
+	// auditByRunner is an action taken by the runner itself, like stopping the test when the time limit is reached.
+	auditByRunner = "runner"
+)
+
+// auditEntry is a line of the audit log.
+type auditEntry struct {
+	Timestamp    time.Time              `json:"timestamp"`
+	Action       string                 `json:"action"`
+	Initiator    string                 `json:"initiator"`
+	Parameters   map[string]interface{} `json:"parameters"`
+	Outcome      string                 `json:"outcome"`
+	ErrorMessage string                 `json:"error_message,omitempty"`
+}
+
+// auditLog writes the audit entries to w as newline-delimited JSON, the writes are serialized.
+type auditLog struct {
+	w    io.Writer
+	lock sync.Mutex
+}
+
+// audit writes an entry of the action to the audit log, if it's enabled. err is the error of the action, if it fails.
+func (r *runner) audit(action, initiator string, parameters map[string]interface{}, err error) {
+	if r.auditLog == nil {
+		return
+	}
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+	entry := &auditEntry{
+		Timestamp:  time.Now(),
+		Action:     action,
+		Initiator:  initiator,
+		Parameters: parameters,
+		Outcome:    "success",
+	}
+	if err != nil {
+		entry.Outcome = "error"
+		entry.ErrorMessage = err.Error()
+	}
+	line, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		r.logger.Printf("Error writing audit log, %v\n", marshalErr)
+		return
+	}
+	line = append(line, '\n')
+
+	l := r.auditLog
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, err := l.w.Write(line); err != nil {
+		r.logger.Printf("Error writing audit log, %v\n", err)
+		return
+	}
+	// e.g. *bufio.Writer
+	if f, ok := l.w.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			r.logger.Printf("Error flushing audit log, %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package boomer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// lockedBuffer is a bytes.Buffer which can be written by the runner and read by the test at the same time.
+type lockedBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk is full")
+}
+
+var _ = Describe("Test audit log", func() {
+
+	parseAuditLog := func(text string) []map[string]interface{} {
+		var entries []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+			if line == "" {
+				continue
+			}
+			entry := make(map[string]interface{})
+			Expect(json.Unmarshal([]byte(line), &entry)).To(Succeed())
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+
+	It("test audit entry", func() {
+		buf := &bytes.Buffer{}
+		r := newLocalRunner(nil, nil, 1, 1)
+		NewStandaloneBoomer(1, 1).WithAuditLog(buf).setupRunner(&r.runner)
+
+		r.audit(auditActionScale, auditByProgram, map[string]interface{}{"users": 10}, nil)
+		r.audit(auditActionStop, auditByProgram, nil, errors.New("something is wrong"))
+		entries := parseAuditLog(buf.String())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0]).To(HaveKeyWithValue("action", "scale"))
+		Expect(entries[0]).To(HaveKeyWithValue("initiator", "programmatic"))
+		Expect(entries[0]).To(HaveKeyWithValue("parameters", map[string]interface{}{"users": float64(10)}))
+		Expect(entries[0]).To(HaveKeyWithValue("outcome", "success"))
+		Expect(entries[0]).NotTo(HaveKey("error_message"))
+		timestamp, err := time.Parse(time.RFC3339Nano, entries[0]["timestamp"].(string))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(timestamp).To(BeTemporally("~", time.Now(), time.Second))
+		Expect(entries[1]).To(HaveKeyWithValue("parameters", map[string]interface{}{}))
+		Expect(entries[1]).To(HaveKeyWithValue("outcome", "error"))
+		Expect(entries[1]).To(HaveKeyWithValue("error_message", "something is wrong"))
+
+		// disabled
+		buf.Reset()
+		NewStandaloneBoomer(1, 1).WithAuditLog(buf).WithAuditLog(nil).setupRunner(&r.runner)
+		r.audit(auditActionScale, auditByProgram, nil, nil)
+		Expect(buf.Len()).To(BeZero())
+	})
+
+	It("test audit log is flushed", func() {
+		buf := &bytes.Buffer{}
+		logs := gbytes.NewBuffer()
+		r := newLocalRunner(nil, nil, 1, 1)
+		r.setLogger(log.New(logs, "", 0))
+		NewStandaloneBoomer(1, 1).WithAuditLog(bufio.NewWriter(buf)).setupRunner(&r.runner)
+		r.audit(auditActionStart, auditByProgram, nil, nil)
+		Expect(parseAuditLog(buf.String())).To(HaveLen(1))
+
+		NewStandaloneBoomer(1, 1).WithAuditLog(failingWriter{}).setupRunner(&r.runner)
+		r.audit(auditActionStart, auditByProgram, nil, nil)
+		Expect(logs).To(gbytes.Say("Error writing audit log, disk is full"))
+	})
+
+	It("test standalone actions", func() {
+		buf := &lockedBuffer{}
+		b := NewStandaloneBoomer(2, 10).WithAuditLog(buf).WithRunTime(200 * time.Millisecond).
+			WithLogger(log.New(io.Discard, "", 0))
+		b.Run(&Task{
+			Name: "foo",
+			Fn: func() {
+				time.Sleep(10 * time.Millisecond)
+			},
+		})
+
+		entries := parseAuditLog(buf.String())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0]).To(HaveKeyWithValue("action", "start"))
+		Expect(entries[0]).To(HaveKeyWithValue("initiator", "programmatic"))
+		Expect(entries[0]).To(HaveKeyWithValue("parameters", map[string]interface{}{"users": float64(2), "spawn_rate": float64(10)}))
+		Expect(entries[1]).To(HaveKeyWithValue("action", "stop"))
+		Expect(entries[1]).To(HaveKeyWithValue("initiator", "runner"))
+		Expect(entries[1]).To(HaveKeyWithValue("parameters", map[string]interface{}{"reason": "time limit", "run_time": "200ms"}))
+
+		// quit by the program or a signal
+		b = NewStandaloneBoomer(1, 1).WithAuditLog(buf)
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+		b.setupRunner(&b.localRunner.runner)
+		b.Quit()
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+		b.setupRunner(&b.localRunner.runner)
+		b.quit(auditBySignal)
+		// aborted by the runner
+		b.localRunner.abortWithError(ErrIdleTimeout)
+
+		entries = parseAuditLog(buf.String())
+		Expect(entries).To(HaveLen(5))
+		Expect(entries[2]).To(HaveKeyWithValue("action", "stop"))
+		Expect(entries[2]).To(HaveKeyWithValue("initiator", "programmatic"))
+		Expect(entries[3]).To(HaveKeyWithValue("action", "stop"))
+		Expect(entries[3]).To(HaveKeyWithValue("initiator", "signal"))
+		Expect(entries[4]).To(HaveKeyWithValue("action", "stop"))
+		Expect(entries[4]).To(HaveKeyWithValue("initiator", "runner"))
+		Expect(entries[4]).To(HaveKeyWithValue("parameters", map[string]interface{}{"reason": ErrIdleTimeout.Error()}))
+	})
+
+	It("test actions of master", func() {
+		buf := &lockedBuffer{}
+		runner := newSlaveRunner("localhost", 5557, []*Task{{Name: "foo", Fn: func() { time.Sleep(time.Second) }}}, nil)
+		runner.client = newClient("localhost", 5557, runner.nodeID)
+		runner.setLogger(log.New(io.Discard, "", 0))
+		runner.state = stateInit
+		NewBoomer("localhost", 5557).WithAuditLog(buf).setupRunner(&runner.runner)
+		runner.stats.start()
+		defer runner.shutdown()
+
+		runner.onMessage(newGenericMessage("spawn", map[string]interface{}{
+			"user_classes_count": map[interface{}]interface{}{"Dummy": int64(2)},
+			"timestamp":          1,
+		}, runner.nodeID))
+		runner.onMessage(newGenericMessage("spawn", map[string]interface{}{
+			"user_classes_count": map[interface{}]interface{}{"Dummy": int64(4)},
+			"spawn_rate":         float64(2),
+			"timestamp":          2,
+		}, runner.nodeID))
+		runner.onMessage(newGenericMessage("stop", nil, runner.nodeID))
+		runner.state = stateRunning
+		runner.onMessage(newGenericMessage("quit", nil, runner.nodeID))
+
+		entries := parseAuditLog(buf.String())
+		Expect(entries).To(HaveLen(4))
+		Expect(entries[0]).To(HaveKeyWithValue("action", "start"))
+		Expect(entries[0]).To(HaveKeyWithValue("initiator", "master"))
+		Expect(entries[0]).To(HaveKeyWithValue("parameters", map[string]interface{}{"users": float64(2), "spawn_rate": float64(2)}))
+		Expect(entries[1]).To(HaveKeyWithValue("action", "scale"))
+		Expect(entries[1]).To(HaveKeyWithValue("parameters", map[string]interface{}{"users": float64(4), "spawn_rate": float64(2)}))
+		Expect(entries[2]).To(HaveKeyWithValue("action", "stop"))
+		Expect(entries[2]).To(HaveKeyWithValue("parameters", map[string]interface{}{"message": "stop"}))
+		Expect(entries[3]).To(HaveKeyWithValue("action", "stop"))
+		Expect(entries[3]).To(HaveKeyWithValue("initiator", "master"))
+		Expect(entries[3]).To(HaveKeyWithValue("parameters", map[string]interface{}{"message": "quit"}))
+	})
+})
@@ -1,17 +1,39 @@
 package boomer
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"io"
 	"log"
+	"math/rand"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+
+	"golang.org/x/time/rate"
 )
 
 var defaultBoomer = &Boomer{logger: log.Default()}
 
+// ErrStatsIntervalTooShort is logged by WithStatsSnapshotInterval and WithJitteredStatsInterval
+// if the interval is shorter than 100ms.
+var ErrStatsIntervalTooShort = errors.New("boomer: the stats interval must be at least 100ms, shorter intervals cost too much")
+
+// ErrStatsJitterTooLarge is logged by WithJitteredStatsInterval if the interval may be shorter than 100ms.
+var ErrStatsJitterTooLarge = errors.New("boomer: the stats interval minus the jitter must be at least 100ms")
+
+// ErrNotStandaloneMode is returned by RunN if boomer is not running in standalone mode.
+var ErrNotStandaloneMode = errors.New("boomer: only supported in standalone mode")
+
 // Mode is the running mode of boomer, both standalone and distributed are supported.
 type Mode int
 
@@ -32,9 +54,55 @@ type Boomer struct {
 	rateLimiter RateLimiter
 	slaveRunner *slaveRunner
 
-	localRunner *localRunner
-	spawnCount  int
-	spawnRate   float64
+	localRunner      *localRunner
+	spawnCount       int
+	spawnRate        float64
+	spawnDoubleEvery time.Duration
+	runTime          time.Duration
+
+	progressReporter func(elapsed, remaining time.Duration, progress float64)
+
+	maxMemoryUsage      uint64
+	memoryCheckInterval time.Duration
+
+	concurrencyLimit int
+
+	testName string
+
+	zeroCountEndpoints bool
+	// the methods of the endpoints registered by WithZeroCountEndpoint, keyed by name
+	zeroCountMethods  map[string]string
+	correlationWindow int
+
+	failOnFirstTaskError bool
+	errGroupLimit        int
+	abortOnOutputError   bool
+	outputErrorHandler   func(output Output, err error)
+	maxTaskExecutions    int64
+	warmUpExecutions     int64
+	maxRequestsPerUser   int64
+	statsFlushSignals    []os.Signal
+
+	responseTimePrecision *int
+	rpsWindowSecs         int64
+
+	outputDirectory   string
+	outputMiddlewares []func(next Output) Output
+
+	remoteConfig *remoteConfig
+
+	taskSetupTimeout time.Duration
+
+	resourceMonitorInterval time.Duration
+
+	failureWindow     time.Duration
+	maxWindowFailures int64
+	stopOnSpike       bool
+
+	statsInterval time.Duration
+	statsJitter   time.Duration
+
+	customMetricAggregators map[string]func(prev, current *CustomMetricEntry) *CustomMetricEntry
 
 	cpuProfileFile     string
 	cpuProfileDuration time.Duration
@@ -42,7 +110,52 @@ type Boomer struct {
 	memoryProfileFile     string
 	memoryProfileDuration time.Duration
 
-	outputs []Output
+	outputs      []Output
+	outputFanout int
+
+	taskRateLimiters  map[taskEndpoint]*rate.Limiter
+	spawnRateLimit    float64
+	spawnBurst        int
+	userDataFactories map[string]func() interface{}
+	dataFeed          *CSVDataFeed
+	consistentHashing func(userID int, requestType, name string) int
+
+	maxErrorsTracked int
+	concurrentStats  bool
+	serializer       *statsSerializer
+	endpointGrouping func(requestType, name string) (string, string)
+
+	endpointBlacklist []*regexp.Regexp
+	excludedRequests  int64
+
+	randomSeed    int64
+	randomSeedSet bool
+	cryptoRandom  bool
+
+	tagSeparator  string
+	maxTagValues  int
+	tagValues     map[string]map[string]bool // tag strings seen per request type and name
+	tagValuesLock sync.Mutex
+
+	debugLogger *log.Logger // nil means debug mode is off
+
+	clientID string // the identity of the worker in distributed mode, generated if it's empty
+
+	cpuAffinity []int
+
+	stuckThreshold time.Duration
+
+	thinkTimeJitter    time.Duration
+	initialSpreadDelay time.Duration
+
+	maxIdleTime time.Duration
+	stopOnIdle  bool
+
+	auditLog *auditLog
+
+	goroutineStackTrace bool
+
+	taskExceptionCallbacks []func(requestType, name string, err interface{}, stack []byte)
 
 	logger *log.Logger
 }
@@ -83,6 +196,632 @@ func (b *Boomer) WithLogger(logger *log.Logger) *Boomer {
 	return b
 }
 
+// WithTaskRateLimit limits the tasks with the RequestType requestType and the Name name to rps executions per second,
+// other tasks run freely, including the ones with the same name and another request type.
+// It must be called before the test is started.
+func (b *Boomer) WithTaskRateLimit(requestType, name string, rps float64) *Boomer {
+	if rps <= 0 {
+		b.logger.Printf("Invalid rate limit %v for %s %s, ignored!\n", rps, requestType, name)
+		return b
+	}
+	if b.taskRateLimiters == nil {
+		b.taskRateLimiters = make(map[taskEndpoint]*rate.Limiter)
+	}
+	b.taskRateLimiters[taskEndpoint{requestType: requestType, name: name}] = rate.NewLimiter(rate.Limit(rps), 1)
+	b.logger.Printf("The RPS of %s %s is limited to %v\n", requestType, name, rps)
+	return b
+}
+
+// WithSpawnRateLimit spawns at most tokensPerSecond users per second with a token bucket,
+// to avoid a thundering herd of users skewing the early stats. The bucket is full at the beginning,
+// so the first users are spawned at once, up to the burst size set by WithSpawnBurst, which is 1 by default.
+// The users are spawned in the background, a new spawning or stopping the users cancels the users not spawned yet.
+// In distributed mode, the spawn rate of the master replaces tokensPerSecond.
+func (b *Boomer) WithSpawnRateLimit(tokensPerSecond float64) *Boomer {
+	if tokensPerSecond <= 0 {
+		b.logger.Printf("Invalid spawn rate limit %v, ignored!\n", tokensPerSecond)
+		return b
+	}
+	b.spawnRateLimit = tokensPerSecond
+	return b
+}
+
+// WithSpawnBurst sets the burst size of the token bucket of WithSpawnRateLimit.
+func (b *Boomer) WithSpawnBurst(n int) *Boomer {
+	b.spawnBurst = n
+	return b
+}
+
+// WithTaskSetupTimeout records a failure with the exception "task setup timeout" if a task hangs before sending
+// its first request, e.g. waiting for a database lock, where the timeout of the HTTP client doesn't help.
+// The setup ends when BoomerTransport sends a request with the context of the task, or when the task calls
+// TaskSetupDone, otherwise the whole task must return in d. The context of the timed out task is canceled,
+// and the user moves on to the next task without waiting for it, unless as many timed out tasks as users are
+// still running. The timed out task keeps its concurrency slot until it returns, and the results it records
+// afterwards are dropped, see TaskTimedOut. Zero means no limit.
+func (b *Boomer) WithTaskSetupTimeout(d time.Duration) *Boomer {
+	b.taskSetupTimeout = d
+	return b
+}
+
+// WithRemoteConfig polls a JSON config from endpoint with HTTP GET every pollInterval, and applies the changes
+// during the test, so long-running tests can be adjusted without restarting, e.g.
+//
+//	{"users": 100, "spawn_rate": 10, "think_time_ms": 500, "target_rps": 200}
+//
+// think_time_ms is the time each user sleeps between the tasks, and target_rps limits the task executions per second
+// across all the users, zero means no limit. Absent fields are left unchanged. users and spawn_rate only take
+// effect in standalone mode, the users are spawned by the master in distributed mode. spawn_rate changes the rate
+// of WithSpawnRateLimit, the users are spawned at once without it.
+// If pollInterval <= 0, it's 10 seconds.
+func (b *Boomer) WithRemoteConfig(endpoint string, pollInterval time.Duration) *Boomer {
+	if pollInterval <= 0 {
+		pollInterval = defaultRemoteConfigPollInterval
+	}
+	if b.remoteConfig == nil {
+		b.remoteConfig = &remoteConfig{client: &http.Client{}}
+	}
+	b.remoteConfig.endpoint = endpoint
+	b.remoteConfig.pollInterval = pollInterval
+	return b
+}
+
+// WithRemoteConfigAuthHeader sets a header, like "Authorization", in the requests of WithRemoteConfig.
+func (b *Boomer) WithRemoteConfigAuthHeader(key, value string) *Boomer {
+	if b.remoteConfig == nil {
+		b.remoteConfig = &remoteConfig{client: &http.Client{}, pollInterval: defaultRemoteConfigPollInterval}
+	}
+	b.remoteConfig.authKey = key
+	b.remoteConfig.authValue = value
+	return b
+}
+
+// WithUserData registers a factory of per-user data.
+// The factory is called once for each spawned goroutine, and the result can be retrieved in
+// Task.FnWithContext with UserDataFromContext(ctx, key).
+// Calls to factories are serialized, so a factory can safely pop items from a shared slice.
+// It must be called before the test is started.
+func (b *Boomer) WithUserData(key string, factory func() interface{}) *Boomer {
+	if factory == nil {
+		return b
+	}
+	if b.userDataFactories == nil {
+		b.userDataFactories = make(map[string]func() interface{})
+	}
+	b.userDataFactories[key] = factory
+	return b
+}
+
+// WithCSVDataFeed loads test data from a csv file, which can be retrieved in Task.FnWithContext
+// with DataFeedFromContext(ctx). If the file can't be loaded, it will not take effect.
+// It must be called before the test is started.
+func (b *Boomer) WithCSVDataFeed(path string, mode DataFeedMode) *Boomer {
+	feed, err := NewCSVDataFeed(path, mode)
+	if err != nil {
+		b.logger.Printf("Failed to load data feed from %s, %v\n", path, err)
+		return b
+	}
+	b.dataFeed = feed
+	return b
+}
+
+// WithExponentialSpawnRate starts with one user and doubles the number of users every doubleEvery,
+// until the spawn count is reached. It's much faster than spawning users linearly.
+// It only works in standalone mode, spawning is controlled by master in distributed mode.
+func (b *Boomer) WithExponentialSpawnRate(doubleEvery time.Duration) *Boomer {
+	b.spawnDoubleEvery = doubleEvery
+	return b
+}
+
+// WithCustomSerializer replaces the JSON serialization used to convert stats before they are passed to outputs.
+// marshal receives the stats entry reported by runner, and unmarshal populates a *statsEntry from its result.
+// The serializer is used by the outputs of this Boomer only. If either function is nil, it will not take effect.
+func (b *Boomer) WithCustomSerializer(marshal func(interface{}) ([]byte, error), unmarshal func([]byte, interface{}) error) *Boomer {
+	if marshal == nil || unmarshal == nil {
+		return b
+	}
+	b.serializer = &statsSerializer{marshal: marshal, unmarshal: unmarshal}
+	return b
+}
+
+// WithMaxErrorsTracked limits the number of unique errors tracked in each report interval.
+// When the limit is reached, new errors are counted as "[truncated]" of their endpoints. Zero means no limit.
+func (b *Boomer) WithMaxErrorsTracked(n int) *Boomer {
+	b.maxErrorsTracked = n
+	return b
+}
+
+// WithConcurrentStats records the requests to a ConcurrentStatsStore, which is merged at each report interval,
+// instead of sending them to the stats goroutine one by one. It scales better when many users record at a high rate.
+func (b *Boomer) WithConcurrentStats(enabled bool) *Boomer {
+	b.concurrentStats = enabled
+	return b
+}
+
+// WithEndpointGrouping normalizes the request type and name before they are recorded,
+// so that requests to URLs like /users/123 can be grouped into one stats entry like /users/{id}.
+func (b *Boomer) WithEndpointGrouping(fn func(requestType, name string) (newType, newName string)) *Boomer {
+	b.endpointGrouping = fn
+	return b
+}
+
+// WithEndpointBlacklist excludes the requests matching any of the patterns from the stats,
+// e.g. health checks or metrics endpoints, which would pollute the results.
+// The patterns match "method:name", like "GET:/health*", where "*" matches any characters and "?" matches one.
+// Patterns prefixed with "re:" are regular expressions, like "re:^GET:/(health|ping)$".
+// Invalid patterns are ignored. The number of excluded requests is returned by ExcludedRequestCount.
+func (b *Boomer) WithEndpointBlacklist(patterns []string) *Boomer {
+	for _, pattern := range patterns {
+		var expr string
+		if strings.HasPrefix(pattern, "re:") {
+			expr = strings.TrimPrefix(pattern, "re:")
+		} else {
+			expr = "^" + strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(pattern)) + "$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			b.logger.Printf("Invalid endpoint blacklist pattern %s, ignored! error: %v\n", pattern, err)
+			continue
+		}
+		b.endpointBlacklist = append(b.endpointBlacklist, re)
+	}
+	return b
+}
+
+// excluded returns true if the request matches the patterns of WithEndpointBlacklist, and counts it.
+func (b *Boomer) excluded(requestType, name string) bool {
+	if len(b.endpointBlacklist) == 0 {
+		return false
+	}
+	endpoint := requestType + ":" + name
+	for _, re := range b.endpointBlacklist {
+		if re.MatchString(endpoint) {
+			atomic.AddInt64(&b.excludedRequests, 1)
+			return true
+		}
+	}
+	return false
+}
+
+// ExcludedRequestCount returns the number of requests excluded by WithEndpointBlacklist.
+func (b *Boomer) ExcludedRequestCount() int64 {
+	return atomic.LoadInt64(&b.excludedRequests)
+}
+
+// NamePattern replaces the parts of a name matching Regex with Replacement.
+// Replacement can refer to submatches like regexp.Regexp.ReplaceAllString.
+type NamePattern struct {
+	Regex       *regexp.Regexp
+	Replacement string
+}
+
+// WithURLNormalization groups endpoints by replacing names with patterns, which are applied in order.
+func (b *Boomer) WithURLNormalization(patterns []NamePattern) *Boomer {
+	return b.WithEndpointGrouping(func(requestType, name string) (string, string) {
+		for _, pattern := range patterns {
+			name = pattern.Regex.ReplaceAllString(name, pattern.Replacement)
+		}
+		return requestType, name
+	})
+}
+
+// WithTagSeparator sets the separator between the name and tags of stats recorded by RecordSuccessWithTags.
+// The default separator is "|".
+func (b *Boomer) WithTagSeparator(sep string) *Boomer {
+	b.tagSeparator = sep
+	return b
+}
+
+// WithMaxTagValues limits the number of unique tag combinations of each request type and name.
+// Tags exceeding the limit are recorded as "<other>". Zero means no limit.
+func (b *Boomer) WithMaxTagValues(n int) *Boomer {
+	b.maxTagValues = n
+	return b
+}
+
+// WithRunTime stops the test after d.
+// It only works in standalone mode, the test is stopped by master in distributed mode.
+func (b *Boomer) WithRunTime(d time.Duration) *Boomer {
+	b.runTime = d
+	return b
+}
+
+// WithWarmUp excludes the stats of the first m task executions from the outputs and the report, e.g. to fill
+// connection pools and caches before measuring. It only works in standalone mode, and the warm-up executions are
+// not counted in the n of RunN. The stats are cleared when all the warm-up executions return, so with concurrent
+// users, the executions overlapping the end of the warm-up may be excluded too.
+func (b *Boomer) WithWarmUp(m int64) *Boomer {
+	if m < 0 {
+		b.logger.Printf("Invalid warm-up executions %d, ignored!\n", m)
+		return b
+	}
+	b.warmUpExecutions = m
+	return b
+}
+
+// WithProgressReporter registers fn to be called on each stats interval, in the same goroutine as Output.OnEvent,
+// and once more when the test is stopped.
+// elapsed is the time since the test is started, remaining is the time until the run time is reached,
+// and progress is elapsed/runTime. If run time is not set, remaining is -1 and progress is 0.
+func (b *Boomer) WithProgressReporter(fn func(elapsed, remaining time.Duration, progress float64)) *Boomer {
+	b.progressReporter = fn
+	return b
+}
+
+// WithConsistentHashing sticks each user to one task, to keep sessions consistent, e.g. always hitting the same
+// database shard. fn is called once per user with the id of the user, requestType "user" and the id in decimal as
+// name, and returns the index of the task in the task list passed to Run. The index is taken modulo the number of
+// tasks, so fn may return any non-negative hash, e.g. of the name, and task weights are ignored.
+// A SequentialTaskSet run with the context of the user sticks the user to one of its tasks in the same way, fn is
+// called with requestType "taskset" and the name of the task set, and the index is into the tasks of the set.
+// The id of the user can be retrieved in Task.FnWithContext with UserIDFromContext(ctx).
+func (b *Boomer) WithConsistentHashing(fn func(userID int, requestType, name string) int) *Boomer {
+	b.consistentHashing = fn
+	return b
+}
+
+// WithRandomSeed seeds the random source of the runner, to make test runs reproducible.
+// It's used for the think time jitter, the stats interval jitter, and WeighingTaskSet.RunWithContext.
+// The seed is logged when the test is started.
+func (b *Boomer) WithRandomSeed(seed int64) *Boomer {
+	b.randomSeed = seed
+	b.randomSeedSet = true
+	return b
+}
+
+// WithCryptoRandom switches the random source of the runner to crypto/rand, which can't be seeded.
+// It's rarely needed for load testing.
+func (b *Boomer) WithCryptoRandom(enabled bool) *Boomer {
+	b.cryptoRandom = enabled
+	return b
+}
+
+// newRandom returns the random source of the runner according to the options, or the default one if none is set.
+func (b *Boomer) newRandom() *rand.Rand {
+	if b.cryptoRandom {
+		b.logger.Println("Random source is crypto/rand")
+		return rand.New(cryptoSource{})
+	}
+	if b.randomSeedSet {
+		b.logger.Println("Random seed is", b.randomSeed)
+		return rand.New(newLockedSource(b.randomSeed))
+	}
+	return random
+}
+
+// WithMaxMemoryUsage stops the test if the heap in use exceeds bytes, to avoid running out of memory
+// when tasks leak memory. EVENT_MEMORY_LIMIT_EXCEEDED is published before the test is stopped.
+// In distributed mode, the worker stops all the goroutines and quits.
+// Err returns an error wrapping ErrMemoryLimitExceeded after Run returns.
+func (b *Boomer) WithMaxMemoryUsage(bytes uint64) *Boomer {
+	b.maxMemoryUsage = bytes
+	return b
+}
+
+// WithResourceMonitor records the heap, the number of goroutines and the CPU time of the process every interval,
+// as the custom metrics ProcessHeapAllocMetric, ProcessHeapSysMetric, ProcessGoroutinesMetric
+// and ProcessCPUSecondsMetric, so they can be correlated with the request rates in the outputs.
+// The latest values are reported by default, see WithAggregationStrategy. The CPU time is only recorded on unix.
+func (b *Boomer) WithResourceMonitor(interval time.Duration) *Boomer {
+	b.resourceMonitorInterval = interval
+	return b
+}
+
+// WithDebugMode logs the internal state of boomer, for diagnosing boomer itself: users started and stopped,
+// tasks picked by the users, the time taken to dispatch the stats and by each output, and long waits for locks.
+// The debug logs are written to stderr with a "[boomer debug] " prefix, separated from the logger of boomer,
+// see WithDebugLogger. Debug mode is off by default, and costs nothing then.
+func (b *Boomer) WithDebugMode(enabled bool) *Boomer {
+	if !enabled {
+		b.debugLogger = nil
+	} else if b.debugLogger == nil {
+		b.debugLogger = newDebugLogger()
+	}
+	return b
+}
+
+// WithDebugLogger enables debug mode with logger, see WithDebugMode.
+// If the logger is nil, it will not take effect.
+func (b *Boomer) WithDebugLogger(logger *log.Logger) *Boomer {
+	if logger != nil {
+		b.debugLogger = logger
+	}
+	return b
+}
+
+// WithStuckDetector logs the users which have been executing the same task for longer than threshold,
+// e.g. stuck in an infinite loop or blocked on an unreachable resource, with the task name, user id and duration.
+// The users are checked every half of threshold, and each stuck task execution is logged once.
+// The number of stuck task executions is reported as stuck_detector_triggered to the outputs.
+func (b *Boomer) WithStuckDetector(threshold time.Duration) *Boomer {
+	b.stuckThreshold = threshold
+	return b
+}
+
+// WithAuditLog writes every start, stop and scale of the test to w as newline-delimited JSON, for compliance.
+// Each line has the timestamp, the action, the initiator, the parameters of the action, the outcome,
+// and the error message if the action fails. The initiator is "programmatic" for Run and Quit,
+// "signal" for SIGINT and SIGTERM handled by the package level Run, "master" for the messages of the locust master,
+// "remote_config" for WithRemoteConfig, and "runner" if the test is stopped by boomer itself, e.g. WithRunTime.
+// If w has a Flush method, like *bufio.Writer, it's flushed after each line. If w is nil, the audit log is disabled.
+func (b *Boomer) WithAuditLog(w io.Writer) *Boomer {
+	if w == nil {
+		b.auditLog = nil
+		return b
+	}
+	b.auditLog = &auditLog{w: w}
+	return b
+}
+
+// WithMaxIdleTime publishes EVENT_IDLE_TIMEOUT with an IdleTimeoutEvent, and logs a warning, if no requests
+// are recorded for longer than d, e.g. all the users are blocked. The requests are counted every stats interval,
+// so the timeout fires within d plus the stats interval. It fires again only after the requests resume.
+// Zero means disabled, which is the default.
+func (b *Boomer) WithMaxIdleTime(d time.Duration) *Boomer {
+	b.maxIdleTime = d
+	return b
+}
+
+// WithStopOnIdle stops the test on the idle timeout of WithMaxIdleTime, and Err returns an error wrapping ErrIdleTimeout.
+func (b *Boomer) WithStopOnIdle(enabled bool) *Boomer {
+	b.stopOnIdle = enabled
+	return b
+}
+
+// WithGoroutineStackTrace dumps the stacks of all the goroutines when the test is aborted, e.g. by WithStopOnSpike,
+// WithStopOnIdle or WithMaxMemoryUsage, to show what the users were doing. The stacks are written to
+// "goroutines_{timestamp}.txt" in the directory set by WithOutputDirectory, or logged if it isn't set.
+// Tests stopped by WithRunTime, Quit or the master are not dumped.
+func (b *Boomer) WithGoroutineStackTrace(enabled bool) *Boomer {
+	b.goroutineStackTrace = enabled
+	return b
+}
+
+// WithTaskExceptionCallback registers fn to be called when a task panics, e.g. to report the panic to an error tracker.
+// fn is called with "task" and the task name, the recovered value and the stack trace. It can be called multiple times
+// to register more callbacks. Each callback runs in its own goroutine, so a slow one doesn't slow down the users,
+// and its panics are recovered and logged. Whether there are callbacks or not, the panic is recorded as a failure
+// of the task and the stack trace is printed to stderr. If fn is nil, it will not take effect.
+func (b *Boomer) WithTaskExceptionCallback(fn func(requestType, name string, err interface{}, stack []byte)) *Boomer {
+	if fn != nil {
+		b.taskExceptionCallbacks = append(b.taskExceptionCallbacks, fn)
+	}
+	return b
+}
+
+// WithUserThinkTimeJitter offsets the think time of each user by a random duration in ±jitter, uniformly distributed,
+// so the users with the same think time don't synchronize and send the requests in bursts.
+// The think time is set by WithRemoteConfig, the users sleep for up to jitter between the tasks if it isn't set.
+func (b *Boomer) WithUserThinkTimeJitter(jitter time.Duration) *Boomer {
+	b.thinkTimeJitter = jitter
+	return b
+}
+
+// WithInitialSpreadDelay staggers the start of the users spawned together, by spread/users apart,
+// instead of starting all of them at once. The users are still counted as spawned immediately.
+func (b *Boomer) WithInitialSpreadDelay(spread time.Duration) *Boomer {
+	b.initialSpreadDelay = spread
+	return b
+}
+
+// WithCPUAffinity locks every user goroutine to an OS thread, which only runs on cpus,
+// to reduce the latency variability caused by migrating across CPUs on NUMA machines.
+// It's only supported on linux, a warning is logged and it has no effect on other platforms,
+// or if none of cpus is available to the process.
+func (b *Boomer) WithCPUAffinity(cpus []int) *Boomer {
+	b.cpuAffinity = cpus
+	return b
+}
+
+// WithMemoryCheckInterval sets how often the heap in use is checked by WithMaxMemoryUsage.
+// The default interval is 30 seconds.
+func (b *Boomer) WithMemoryCheckInterval(d time.Duration) *Boomer {
+	b.memoryCheckInterval = d
+	return b
+}
+
+// WithWindowedFailureThreshold detects failure spikes, which are missed by the failure ratio of a report interval.
+// If more than maxFailures failures are recorded within window, EVENT_FAILURE_SPIKE is published with
+// a FailureSpikeEvent and a warning is logged, at most once per window. The failures are counted per second,
+// so window is rounded up to seconds. See also WithStopOnSpike.
+func (b *Boomer) WithWindowedFailureThreshold(window time.Duration, maxFailures int64) *Boomer {
+	if window <= 0 || maxFailures < 0 {
+		b.logger.Printf("Invalid failure threshold %d within %v, ignored!\n", maxFailures, window)
+		return b
+	}
+	b.failureWindow = window
+	b.maxWindowFailures = maxFailures
+	return b
+}
+
+// WithStopOnSpike stops the test on a failure spike detected by WithWindowedFailureThreshold.
+// Err returns an error wrapping ErrFailureSpike after Run returns.
+func (b *Boomer) WithStopOnSpike(enabled bool) *Boomer {
+	b.stopOnSpike = enabled
+	return b
+}
+
+// WithConcurrencyLimit bounds the number of tasks running at the same time across all the users.
+// When n tasks are running, the other users block until one of them returns.
+// It prevents thundering-herd scenarios during spawning, zero means no limit.
+func (b *Boomer) WithConcurrencyLimit(n int) *Boomer {
+	b.concurrencyLimit = n
+	return b
+}
+
+// CurrentConcurrency returns the number of tasks running at the moment.
+func (b *Boomer) CurrentConcurrency() int {
+	switch b.mode {
+	case DistributedMode:
+		if b.slaveRunner != nil {
+			return int(atomic.LoadInt32(&b.slaveRunner.concurrency))
+		}
+	case StandaloneMode:
+		if b.localRunner != nil {
+			return int(atomic.LoadInt32(&b.localRunner.concurrency))
+		}
+	}
+	return 0
+}
+
+// WithTestName sets the name of the test, which appears in the outputs, to tell different scenarios apart.
+// If not set, the process name is used.
+func (b *Boomer) WithTestName(name string) *Boomer {
+	b.testName = name
+	return b
+}
+
+// WithZeroCountEndpoints reports the names of all the tasks, even if there are no requests,
+// to keep the dashboards consistent across test runs. The method of a task is unknown until it's recorded,
+// use WithZeroCountEndpoint to register the method in advance.
+func (b *Boomer) WithZeroCountEndpoints(enabled bool) *Boomer {
+	b.zeroCountEndpoints = enabled
+	return b
+}
+
+// WithZeroCountEndpoint registers an endpoint with its method, which is reported even if there are no requests.
+// It enables WithZeroCountEndpoints.
+func (b *Boomer) WithZeroCountEndpoint(method, name string) *Boomer {
+	if b.zeroCountMethods == nil {
+		b.zeroCountMethods = make(map[string]string)
+	}
+	b.zeroCountMethods[name] = method
+	b.zeroCountEndpoints = true
+	return b
+}
+
+// WithFailOnFirstTaskError stops the test when a task returns an error from Task.FnWithError,
+// which is useful for correctness testing, where any unexpected error should abort the run.
+// The first error can be retrieved by Err after Run returns. By default, the errors are ignored.
+func (b *Boomer) WithFailOnFirstTaskError(enabled bool) *Boomer {
+	b.failOnFirstTaskError = enabled
+	return b
+}
+
+// WithErrGroupLimit runs the users in an errgroup.Group of golang.org/x/sync/errgroup, which runs at most n of them
+// at the same time, so the number of users is capped to n. With WithFailOnFirstTaskError, the first error returned by
+// Task.FnWithError ends its user and cancels the contexts of the other users through the group, and the error returned
+// by Wait of the group aborts the test, see Err. It must be called before the test is started.
+func (b *Boomer) WithErrGroupLimit(n int) *Boomer {
+	if n <= 0 {
+		b.logger.Printf("Invalid error group limit %d, ignored!\n", n)
+		return b
+	}
+	b.errGroupLimit = n
+	return b
+}
+
+// WithAbortOnOutputError stops the test when an output which implements ErrorOutput returns an error,
+// which is useful for critical outputs, e.g. audit logs, which must not swallow errors silently.
+// The first error wins, and can be retrieved by Err after Run returns. By default, the errors are only passed to WithOutputErrorHandler, or logged.
+func (b *Boomer) WithAbortOnOutputError(enabled bool) *Boomer {
+	b.abortOnOutputError = enabled
+	return b
+}
+
+// WithOutputErrorHandler calls fn with the output and the error when an output which implements ErrorOutput
+// returns an error, e.g. to count the errors or send an alert. Panics in fn are recovered.
+// If fn is nil, which is the default, the errors are logged.
+func (b *Boomer) WithOutputErrorHandler(fn func(output Output, err error)) *Boomer {
+	b.outputErrorHandler = fn
+	return b
+}
+
+// WithMaxRequestsPerUser makes each user execute at most n tasks before exiting, for run-once scenarios,
+// e.g. provisioning exactly n resources per user. The test is stopped after all the users exit,
+// so u users execute at most u * n tasks in total.
+// If n <= 0, which is the default, the users run until the test is stopped.
+func (b *Boomer) WithMaxRequestsPerUser(n int64) *Boomer {
+	b.maxRequestsPerUser = n
+	return b
+}
+
+// WithStatsFlushOnSignal reports the current stats to the outputs on receipt of the signals, outside the normal interval,
+// so operators can capture a snapshot during a long-running test without stopping it, e.g. kill -USR1 <pid>.
+// If no signals are given, SIGUSR1 is used, which is not available on Windows.
+func (b *Boomer) WithStatsFlushOnSignal(signals ...os.Signal) *Boomer {
+	if len(signals) == 0 {
+		signals = defaultStatsFlushSignals
+	}
+	b.statsFlushSignals = signals
+	return b
+}
+
+// WithResponseTimePrecision rounds the average response times to decimalPlaces in all the outputs,
+// e.g. 0 reports whole milliseconds. It's 2 by default, and must be between 0 and 9.
+func (b *Boomer) WithResponseTimePrecision(decimalPlaces int) *Boomer {
+	if decimalPlaces < 0 || decimalPlaces > 9 {
+		b.logger.Printf("Invalid response time precision %d, ignored!\n", decimalPlaces)
+		return b
+	}
+	b.responseTimePrecision = &decimalPlaces
+	return b
+}
+
+// WithRPSWindow averages the current rps and fails/sec in all the outputs over the last window,
+// which is 10 seconds by default. It's rounded down to whole seconds, and must be at least 1 second.
+func (b *Boomer) WithRPSWindow(window time.Duration) *Boomer {
+	if window < time.Second {
+		b.logger.Printf("Invalid rps window %v, ignored!\n", window)
+		return b
+	}
+	b.rpsWindowSecs = int64(window / time.Second)
+	return b
+}
+
+// WithOutputDirectory sets the base directory of the file-based outputs, like TraceOutput.
+// An output created with an empty path writes to its default filename in the directory, e.g. "{testName}_trace.out",
+// and a relative path is resolved against the directory. Absolute paths are kept as they are.
+// The directory is created when the test starts if it doesn't exist.
+func (b *Boomer) WithOutputDirectory(path string) *Boomer {
+	b.outputDirectory = path
+	return b
+}
+
+// Err returns the error which aborts the test, e.g. ErrMemoryLimitExceeded or the first error returned
+// by Task.FnWithError if WithFailOnFirstTaskError is enabled. It returns nil if the test isn't aborted.
+func (b *Boomer) Err() error {
+	switch b.mode {
+	case DistributedMode:
+		if b.slaveRunner != nil {
+			return b.slaveRunner.err()
+		}
+	case StandaloneMode:
+		if b.localRunner != nil {
+			return b.localRunner.err()
+		}
+	}
+	return nil
+}
+
+// WithRequestCorrelation calculates the lag-1 autocorrelation of the last windowSize response times per endpoint.
+// A positive value means slow responses cluster together, e.g. during auto-scaling or cache warm-up,
+// and a negative value means slow responses alternate with fast ones.
+func (b *Boomer) WithRequestCorrelation(windowSize int) *Boomer {
+	b.correlationWindow = windowSize
+	return b
+}
+
+// WithAggregationStrategy sets how the values of the custom metric are aggregated, within a report interval
+// and across the intervals. AverageAggregation is used by default.
+func (b *Boomer) WithAggregationStrategy(name string, strategy AggregationStrategy) *Boomer {
+	return b.WithCustomMetricAggregator(name, strategy.aggregate)
+}
+
+// WithCustomMetricAggregator gives complete control of how the values of the custom metric are aggregated.
+// fn merges current into prev and returns the result, prev is nil for the first value.
+// If fn is nil, it will not take effect.
+func (b *Boomer) WithCustomMetricAggregator(name string, fn func(prev, current *CustomMetricEntry) *CustomMetricEntry) *Boomer {
+	if fn == nil {
+		return b
+	}
+	if b.customMetricAggregators == nil {
+		b.customMetricAggregators = make(map[string]func(prev, current *CustomMetricEntry) *CustomMetricEntry)
+	}
+	b.customMetricAggregators[name] = fn
+	return b
+}
+
 // SetRateLimiter allows user to use their own rate limiter.
 // It must be called before the test is started.
 func (b *Boomer) SetRateLimiter(rateLimiter RateLimiter) {
@@ -106,6 +845,34 @@ func (b *Boomer) AddOutput(o Output) {
 	b.outputs = append(b.outputs, o)
 }
 
+// WithOutputFanout calls at most concurrency outputs at the same time, when the stats are reported.
+// By default, all the outputs are called in parallel, with a goroutine for each of them.
+// If concurrency isn't positive, it will not take effect.
+func (b *Boomer) WithOutputFanout(concurrency int) *Boomer {
+	if concurrency > 0 {
+		b.outputFanout = concurrency
+	}
+	return b
+}
+
+// WithOutputMiddleware decorates every output with fn when the test starts, like http.Handler middlewares.
+// Middlewares are applied in registration order, so the first one wraps outermost and sees the data first.
+// fn must return an Output, which usually calls next. If fn is nil, it will not take effect.
+func (b *Boomer) WithOutputMiddleware(fn func(next Output) Output) *Boomer {
+	if fn != nil {
+		b.outputMiddlewares = append(b.outputMiddlewares, fn)
+	}
+	return b
+}
+
+// applyOutputMiddlewares wraps o with the middlewares, the first one is the outermost.
+func (b *Boomer) applyOutputMiddlewares(o Output) Output {
+	for i := len(b.outputMiddlewares) - 1; i >= 0; i-- {
+		o = b.outputMiddlewares[i](o)
+	}
+	return o
+}
+
 // EnableCPUProfile will start cpu profiling after run.
 func (b *Boomer) EnableCPUProfile(cpuProfileFile string, duration time.Duration) {
 	b.cpuProfileFile = cpuProfileFile
@@ -137,17 +904,26 @@ func (b *Boomer) Run(tasks ...*Task) {
 	case DistributedMode:
 		b.slaveRunner = newSlaveRunner(b.masterHost, b.masterPort, tasks, b.rateLimiter)
 		b.slaveRunner.setLogger(b.logger)
+		if b.clientID != "" {
+			b.slaveRunner.nodeID = b.clientID
+		}
+		b.setupRunner(&b.slaveRunner.runner)
 		b.logger.Println("new slave runner")
 		for _, o := range b.outputs {
-			b.slaveRunner.addOutput(o)
+			b.slaveRunner.addOutput(b.applyOutputMiddlewares(o))
 		}
 		b.slaveRunner.run()
 	case StandaloneMode:
 		b.localRunner = newLocalRunner(tasks, b.rateLimiter, b.spawnCount, b.spawnRate)
 		b.localRunner.setLogger(b.logger)
+		b.setupRunner(&b.localRunner.runner)
+		b.localRunner.spawnDoubleEvery = b.spawnDoubleEvery
+		b.localRunner.runTime = b.runTime
+		b.localRunner.maxTaskExecutions = b.maxTaskExecutions
+		b.localRunner.warmUpExecutions = b.warmUpExecutions
 		b.logger.Println("new local runner")
 		for _, o := range b.outputs {
-			b.localRunner.addOutput(o)
+			b.localRunner.addOutput(b.applyOutputMiddlewares(o))
 		}
 		b.localRunner.run()
 	default:
@@ -155,11 +931,189 @@ func (b *Boomer) Run(tasks ...*Task) {
 	}
 }
 
+const (
+	minStatsInterval   = 100 * time.Millisecond
+	staleStatsInterval = 60 * time.Second
+)
+
+// WithStatsSnapshotInterval sets how often the stats are reported to the outputs, and to the master
+// in distributed mode. The default interval is 3 seconds. An interval shorter than 100ms is ignored,
+// and a warning is logged if d is longer than 60s, because the stats may be stale.
+func (b *Boomer) WithStatsSnapshotInterval(d time.Duration) *Boomer {
+	return b.WithJitteredStatsInterval(d, 0)
+}
+
+// WithJitteredStatsInterval is like WithStatsSnapshotInterval, but adds a random jitter in [-jitter, +jitter]
+// to every interval, which prevents many workers from pushing to a Prometheus Pushgateway at the same time.
+// It's ignored if the interval may be shorter than 100ms.
+func (b *Boomer) WithJitteredStatsInterval(base, jitter time.Duration) *Boomer {
+	if jitter < 0 {
+		jitter = -jitter
+	}
+	if err := validateStatsInterval(base, jitter); err != nil {
+		b.logger.Printf("Invalid stats interval %v with jitter %v, ignored! error: %v\n", base, jitter, err)
+		return b
+	}
+	if base > staleStatsInterval {
+		b.logger.Printf("The stats interval %v is longer than %v, the stats may be stale\n", base, staleStatsInterval)
+	}
+	b.statsInterval = base
+	b.statsJitter = jitter
+	return b
+}
+
+// validateStatsInterval returns ErrStatsIntervalTooShort or ErrStatsJitterTooLarge if the interval may be shorter than 100ms.
+func validateStatsInterval(base, jitter time.Duration) error {
+	if base < minStatsInterval {
+		return ErrStatsIntervalTooShort
+	}
+	if base-jitter < minStatsInterval {
+		return ErrStatsJitterTooLarge
+	}
+	return nil
+}
+
+// RunN runs the tasks in standalone mode until they are executed n times in total, and returns the report.
+// Unlike a time limit, the same work is done regardless of the speed of the system,
+// which makes the results of CPU or memory profiling comparable.
+// If n <= 0, the tasks run until Quit is called, like Run.
+func (b *Boomer) RunN(n int64, tasks ...*Task) (*TestReport, error) {
+	if b.mode != StandaloneMode {
+		return nil, ErrNotStandaloneMode
+	}
+	b.maxTaskExecutions = n
+	b.Run(tasks...)
+	return b.localRunner.report, b.Err()
+}
+
+// setupRunner passes the options of Boomer to the runner.
+func (b *Boomer) setupRunner(r *runner) {
+	if b.debugLogger != nil {
+		r.setDebugLogger(b.debugLogger)
+	}
+	r.taskRateLimiters = b.taskRateLimiters
+	r.userDataFactories = b.userDataFactories
+	r.dataFeed = b.dataFeed
+	r.consistentHashing = b.consistentHashing
+	r.serializer = b.serializer
+	r.random = b.newRandom()
+	r.stats.random = r.random
+	r.stats.maxErrorsTracked = b.maxErrorsTracked
+	if b.concurrentStats {
+		r.stats.store = NewConcurrentStatsStore()
+	}
+	if b.resourceMonitorInterval > 0 {
+		// the resource usage is a gauge, unless the user aggregates it in another way
+		for _, name := range processMetrics {
+			if _, ok := b.customMetricAggregators[name]; !ok {
+				b.WithAggregationStrategy(name, LastAggregation)
+			}
+		}
+	}
+	r.stats.customMetricAggregators = b.customMetricAggregators
+	r.resourceMonitorInterval = b.resourceMonitorInterval
+	r.stats.correlationWindow = b.correlationWindow
+	r.stats.setRPSWindow(b.rpsWindowSecs)
+	r.stats.reportInterval = b.statsInterval
+	r.stats.reportJitter = b.statsJitter
+	r.failOnFirstTaskError = b.failOnFirstTaskError
+	r.errGroupLimit = b.errGroupLimit
+	r.abortOnOutputError = b.abortOnOutputError
+	r.outputErrorHandler = b.outputErrorHandler
+	r.maxRequestsPerUser = b.maxRequestsPerUser
+	r.statsFlushSignals = b.statsFlushSignals
+	r.responseTimePrecision = b.responseTimePrecision
+	r.rpsWindowSecs = b.rpsWindowSecs
+	if b.spawnRateLimit > 0 {
+		burst := b.spawnBurst
+		if burst <= 0 {
+			burst = 1
+		}
+		r.spawnLimiter = rate.NewLimiter(rate.Limit(b.spawnRateLimit), burst)
+	}
+	if b.remoteConfig != nil && b.remoteConfig.endpoint != "" {
+		r.remoteConfig = b.remoteConfig
+		r.targetRPSLimiter = rate.NewLimiter(rate.Inf, 1)
+	}
+	r.taskSetupTimeout = b.taskSetupTimeout
+	r.cpuAffinity = b.cpuAffinity
+	r.stuckThreshold = b.stuckThreshold
+	r.thinkTimeJitter = b.thinkTimeJitter
+	r.initialSpreadDelay = b.initialSpreadDelay
+	r.taskExceptionCallbacks = b.taskExceptionCallbacks
+	r.maxIdleTime = b.maxIdleTime
+	r.auditLog = b.auditLog
+	r.stopOnIdle = b.stopOnIdle
+	r.goroutineStackTrace = b.goroutineStackTrace
+	r.outputDirectory = b.outputDirectory
+	if b.outputFanout > 0 {
+		r.outputFanout = make(chan struct{}, b.outputFanout)
+	}
+	if b.failureWindow > 0 {
+		r.stats.failureSpike = newFailureSpikeDetector(b.failureWindow, b.maxWindowFailures)
+		r.stats.onFailureSpike = r.onFailureSpike
+		r.stopOnSpike = b.stopOnSpike
+	}
+	r.progressReporter = b.progressReporter
+	r.maxMemoryUsage = b.maxMemoryUsage
+	r.memoryCheckInterval = b.memoryCheckInterval
+	if b.concurrencyLimit > 0 {
+		r.concurrencySemaphore = make(chan struct{}, b.concurrencyLimit)
+	}
+	r.testName = b.testName
+	if r.testName == "" {
+		r.testName = filepath.Base(os.Args[0])
+	}
+	b.setupOutputDirectory(r.testName)
+	if b.zeroCountEndpoints {
+		r.stats.zeroCountEndpoints = make(map[string]string)
+		for _, task := range r.tasks {
+			if task.Name != "" {
+				r.stats.zeroCountEndpoints[task.Name] = ""
+			}
+		}
+		for name, method := range b.zeroCountMethods {
+			r.stats.zeroCountEndpoints[name] = method
+		}
+	}
+}
+
+// setupOutputDirectory creates the output directory, and passes it to the file-based outputs.
+func (b *Boomer) setupOutputDirectory(testName string) {
+	if b.outputDirectory == "" {
+		return
+	}
+	if err := os.MkdirAll(b.outputDirectory, 0o755); err != nil {
+		b.logger.Printf("Error creating output directory %s, %v\n", b.outputDirectory, err)
+		return
+	}
+	for _, o := range b.outputs {
+		if named, ok := o.(*NamedOutput); ok {
+			o = named.Output
+		}
+		if f, ok := o.(fileOutput); ok {
+			f.setOutputDirectory(b.outputDirectory, testName)
+		}
+	}
+}
+
 // RecordSuccess reports a success.
 func (b *Boomer) RecordSuccess(requestType, name string, responseTime int64, responseLength int64) {
+	if b.excluded(requestType, name) {
+		return
+	}
+	requestType, name = b.groupEndpoint(requestType, name)
+	b.recordSuccess(requestType, name, responseTime, responseLength)
+}
+
+func (b *Boomer) recordSuccess(requestType, name string, responseTime int64, responseLength int64) {
 	if b.localRunner == nil && b.slaveRunner == nil {
 		return
 	}
+	if store := b.statsStore(); store != nil {
+		store.Record(requestType, name, responseTime, responseLength, false, "")
+		return
+	}
 	switch b.mode {
 	case DistributedMode:
 		b.slaveRunner.stats.requestSuccessChan <- &requestSuccess{
@@ -178,11 +1132,103 @@ func (b *Boomer) RecordSuccess(requestType, name string, responseTime int64, res
 	}
 }
 
+// RecordSuccessWithTags reports a success, which is tracked separately by tags.
+// The tags are sorted, formatted as "key=value,..." and appended to name with the tag separator.
+func (b *Boomer) RecordSuccessWithTags(requestType, name string, responseTime int64, responseLength int64, tags map[string]string) {
+	if b.excluded(requestType, name) {
+		return
+	}
+	requestType, name = b.groupEndpoint(requestType, name)
+	b.recordSuccess(requestType, b.taggedName(requestType, name, tags), responseTime, responseLength)
+}
+
+// statsStore returns the ConcurrentStatsStore of the runner, or nil if WithConcurrentStats isn't enabled.
+func (b *Boomer) statsStore() *ConcurrentStatsStore {
+	switch b.mode {
+	case DistributedMode:
+		return b.slaveRunner.stats.store
+	case StandaloneMode:
+		return b.localRunner.stats.store
+	}
+	return nil
+}
+
+// groupEndpoint normalizes the request type and name with the function set by WithEndpointGrouping.
+func (b *Boomer) groupEndpoint(requestType, name string) (string, string) {
+	if b.endpointGrouping == nil {
+		return requestType, name
+	}
+	return b.endpointGrouping(requestType, name)
+}
+
+func (b *Boomer) taggedName(requestType, name string, tags map[string]string) string {
+	if len(tags) == 0 {
+		return name
+	}
+
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	tagString := strings.Join(pairs, ",")
+
+	if b.maxTagValues > 0 {
+		b.tagValuesLock.Lock()
+		if b.tagValues == nil {
+			b.tagValues = make(map[string]map[string]bool)
+		}
+		seen, ok := b.tagValues[requestType+name]
+		if !ok {
+			seen = make(map[string]bool)
+			b.tagValues[requestType+name] = seen
+		}
+		if !seen[tagString] {
+			if len(seen) < b.maxTagValues {
+				seen[tagString] = true
+			} else {
+				tagString = "<other>"
+			}
+		}
+		b.tagValuesLock.Unlock()
+	}
+
+	sep := b.tagSeparator
+	if sep == "" {
+		sep = "|"
+	}
+	return name + sep + tagString
+}
+
 // RecordFailure reports a failure.
 func (b *Boomer) RecordFailure(requestType, name string, responseTime int64, exception string) {
+	if b.excluded(requestType, name) {
+		return
+	}
+	requestType, name = b.groupEndpoint(requestType, name)
+	b.recordFailure(requestType, name, responseTime, exception, nil)
+}
+
+// RecordFailureWithDetails reports a failure like RecordFailure, with details which are not part of the error
+// message, e.g. the request id, so the failures are still aggregated by the error message.
+// The errors in the report data keep the details of their last failure as "details".
+// The details are dropped if WithConcurrentStats is enabled, and details must not be modified after the call.
+func (b *Boomer) RecordFailureWithDetails(requestType, name string, responseTime int64, exception string, details map[string]string) {
+	if b.excluded(requestType, name) {
+		return
+	}
+	requestType, name = b.groupEndpoint(requestType, name)
+	b.recordFailure(requestType, name, responseTime, exception, details)
+}
+
+func (b *Boomer) recordFailure(requestType, name string, responseTime int64, exception string, details map[string]string) {
 	if b.localRunner == nil && b.slaveRunner == nil {
 		return
 	}
+	if store := b.statsStore(); store != nil {
+		store.Record(requestType, name, responseTime, 0, true, exception)
+		return
+	}
 	switch b.mode {
 	case DistributedMode:
 		b.slaveRunner.stats.requestFailureChan <- &requestFailure{
@@ -190,6 +1236,7 @@ func (b *Boomer) RecordFailure(requestType, name string, responseTime int64, exc
 			name:         name,
 			responseTime: responseTime,
 			error:        exception,
+			details:      details,
 		}
 	case StandaloneMode:
 		b.localRunner.stats.requestFailureChan <- &requestFailure{
@@ -197,10 +1244,24 @@ func (b *Boomer) RecordFailure(requestType, name string, responseTime int64, exc
 			name:         name,
 			responseTime: responseTime,
 			error:        exception,
+			details:      details,
 		}
 	}
 }
 
+// RecordCustomMetric reports a value of the custom metric, which is aggregated and reported to the outputs.
+func (b *Boomer) RecordCustomMetric(name string, value float64) {
+	if b.localRunner == nil && b.slaveRunner == nil {
+		return
+	}
+	switch b.mode {
+	case DistributedMode:
+		b.slaveRunner.stats.customMetricChan <- &customMetric{name: name, value: value}
+	case StandaloneMode:
+		b.localRunner.stats.customMetricChan <- &customMetric{name: name, value: value}
+	}
+}
+
 func (b *Boomer) SendCustomMessage(messageType string, data interface{}) {
 	if b.localRunner == nil && b.slaveRunner == nil {
 		return
@@ -215,22 +1276,31 @@ func (b *Boomer) SendCustomMessage(messageType string, data interface{}) {
 
 // Quit will send a quit message to the master.
 func (b *Boomer) Quit() {
+	b.quit(auditByProgram)
+}
+
+// quit is Quit, the initiator is written to the audit log.
+func (b *Boomer) quit(initiator string) {
 	Events.Publish(EVENT_QUIT)
 	var ticker = time.NewTicker(3 * time.Second)
 
 	switch b.mode {
 	case DistributedMode:
 		// wait for quit message is sent to master
+		var err error
 		select {
 		case <-b.slaveRunner.client.disconnectedChannel():
 			break
 		case <-ticker.C:
 			b.logger.Println("Timeout waiting for sending quit message to master, boomer will quit any way.")
+			err = errors.New("timeout waiting for sending quit message to master")
 			break
 		}
 		b.slaveRunner.shutdown()
+		b.slaveRunner.audit(auditActionStop, initiator, nil, err)
 	case StandaloneMode:
 		b.localRunner.shutdown()
+		b.localRunner.audit(auditActionStop, initiator, nil, nil)
 	}
 }
 
@@ -244,7 +1314,7 @@ func runTasksForTest(tasks ...*Task) {
 			for _, name := range taskNames {
 				if name == task.Name {
 					log.Println("Running " + task.Name)
-					task.Fn()
+					task.run(context.Background())
 				}
 			}
 		}
@@ -292,7 +1362,7 @@ func Run(tasks ...*Task) {
 	select {
 	case <-c:
 		quitByMe = true
-		defaultBoomer.Quit()
+		defaultBoomer.quit(auditBySignal)
 	case <-quitChan:
 	}
 
@@ -305,8 +1375,26 @@ func RecordSuccess(requestType, name string, responseTime int64, responseLength
 	defaultBoomer.RecordSuccess(requestType, name, responseTime, responseLength)
 }
 
+// RecordSuccessWithTags reports a success, which is tracked separately by tags.
+// It's a convenience function to use the defaultBoomer.
+func RecordSuccessWithTags(requestType, name string, responseTime int64, responseLength int64, tags map[string]string) {
+	defaultBoomer.RecordSuccessWithTags(requestType, name, responseTime, responseLength, tags)
+}
+
+// RecordCustomMetric reports a value of the custom metric.
+// It's a convenience function to use the defaultBoomer.
+func RecordCustomMetric(name string, value float64) {
+	defaultBoomer.RecordCustomMetric(name, value)
+}
+
 // RecordFailure reports a failure.
 // It's a convenience function to use the defaultBoomer.
 func RecordFailure(requestType, name string, responseTime int64, exception string) {
 	defaultBoomer.RecordFailure(requestType, name, responseTime, exception)
 }
+
+// RecordFailureWithDetails reports a failure with details, e.g. the request id.
+// It's a convenience function to use the defaultBoomer.
+func RecordFailureWithDetails(requestType, name string, responseTime int64, exception string, details map[string]string) {
+	defaultBoomer.RecordFailureWithDetails(requestType, name, responseTime, exception, details)
+}
@@ -1,17 +1,25 @@
 package boomer
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"math"
 	"os"
+	"path/filepath"
+	"regexp"
 	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/myzhan/gomq/zmtp"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("Test Boomer", func() {
@@ -37,6 +45,279 @@ var _ = Describe("Test Boomer", func() {
 		Expect(b.rateLimiter).NotTo(BeNil())
 	})
 
+	It("test with task rate limit", func() {
+		b := NewStandaloneBoomer(100, 10)
+		b.WithTaskRateLimit("http", "foo", 10).WithTaskRateLimit("http", "bar", 0).WithTaskRateLimit("grpc", "foo", 5)
+		Expect(b.taskRateLimiters).To(HaveLen(2))
+		Expect(b.taskRateLimiters).To(HaveKey(taskEndpoint{requestType: "http", name: "foo"}))
+		Expect(b.taskRateLimiters).To(HaveKey(taskEndpoint{requestType: "grpc", name: "foo"}))
+	})
+
+	It("test with error group limit", func() {
+		b := NewStandaloneBoomer(100, 10).WithLogger(log.New(io.Discard, "", 0))
+		Expect(b.WithErrGroupLimit(0).errGroupLimit).To(BeZero())
+		Expect(b.WithErrGroupLimit(10).errGroupLimit).To(Equal(10))
+	})
+
+	It("test with exponential spawn rate", func() {
+		b := NewStandaloneBoomer(100, 10).WithExponentialSpawnRate(time.Second)
+		Expect(b.spawnDoubleEvery).To(Equal(time.Second))
+	})
+
+	It("test with concurrency limit", func() {
+		b := NewStandaloneBoomer(100, 10).WithConcurrencyLimit(5)
+		Expect(b.CurrentConcurrency()).To(BeZero())
+
+		b.localRunner = newLocalRunner(nil, nil, 100, 10)
+		b.setupRunner(&b.localRunner.runner)
+		Expect(cap(b.localRunner.concurrencySemaphore)).To(Equal(5))
+	})
+
+	It("test with test name", func() {
+		b := NewStandaloneBoomer(100, 10)
+		b.localRunner = newLocalRunner(nil, nil, 100, 10)
+		b.setupRunner(&b.localRunner.runner)
+		Expect(b.localRunner.testName).To(Equal(filepath.Base(os.Args[0])))
+
+		b.WithTestName("checkout")
+		b.setupRunner(&b.localRunner.runner)
+		data := map[string]interface{}{}
+		b.localRunner.setReportData(data)
+		Expect(data["test_name"]).To(Equal("checkout"))
+	})
+
+	It("test run n", func() {
+		calls := int64(0)
+		b := NewStandaloneBoomer(1, 100).WithTestName("run-n").WithLogger(log.New(io.Discard, "", 0))
+		task := &Task{
+			Name: "foo",
+			Fn: func() {
+				atomic.AddInt64(&calls, 1)
+				b.RecordSuccess("http", "foo", 10, 100)
+			},
+		}
+		report, err := b.RunN(50, task)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(atomic.LoadInt64(&calls)).To(BeEquivalentTo(50))
+		Expect(report.TestName).To(Equal("run-n"))
+		Expect(report.TotalTaskExecutions).To(BeEquivalentTo(50))
+		Expect(report.TotalRequests).To(BeEquivalentTo(50))
+		Expect(report.Stats).To(HaveKey("foohttp"))
+		Expect(report.Stats["foohttp"].NumRequests).To(BeEquivalentTo(50))
+		Expect(report.EndTime).To(BeTemporally(">=", report.StartTime))
+	})
+
+	It("test run n with warm-up", func() {
+		calls := int64(0)
+		b := NewStandaloneBoomer(1, 100).WithWarmUp(10).WithLogger(log.New(io.Discard, "", 0))
+		task := &Task{
+			Name: "foo",
+			Fn: func() {
+				if atomic.AddInt64(&calls, 1) <= 10 {
+					b.RecordSuccess("http", "warm-up", 10, 100)
+					b.RecordCustomMetric("warm-up", 1)
+					return
+				}
+				b.RecordSuccess("http", "foo", 10, 100)
+			},
+		}
+		report, err := b.RunN(50, task)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(atomic.LoadInt64(&calls)).To(BeEquivalentTo(60))
+		Expect(report.TotalTaskExecutions).To(BeEquivalentTo(50))
+		Expect(report.TotalRequests).To(BeEquivalentTo(50))
+		Expect(report.Stats).NotTo(HaveKey("warm-uphttp"))
+		Expect(report.CustomMetrics).NotTo(HaveKey("warm-up"))
+		Expect(report.Stats["foohttp"].NumRequests).To(BeEquivalentTo(50))
+	})
+
+	It("test invalid warm-up", func() {
+		b := NewStandaloneBoomer(1, 100).WithLogger(log.New(io.Discard, "", 0)).WithWarmUp(-1)
+		Expect(b.warmUpExecutions).To(BeZero())
+	})
+
+	It("test run n with concurrent users", func() {
+		calls := int64(0)
+		b := NewStandaloneBoomer(8, 1000).WithLogger(log.New(io.Discard, "", 0))
+		task := &Task{
+			Name: "foo",
+			Fn: func() {
+				atomic.AddInt64(&calls, 1)
+				time.Sleep(time.Millisecond)
+			},
+		}
+		report, err := b.RunN(100, task)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.TotalTaskExecutions).To(BeEquivalentTo(100))
+		Eventually(func() int64 { return atomic.LoadInt64(&calls) }).Should(BeEquivalentTo(100))
+		Consistently(func() int64 { return atomic.LoadInt64(&calls) }, 50*time.Millisecond).Should(BeEquivalentTo(100))
+
+		_, err = NewBoomer("127.0.0.1", 5557).RunN(100, task)
+		Expect(err).To(Equal(ErrNotStandaloneMode))
+	})
+
+	It("test with output middleware", func() {
+		var lock sync.Mutex
+		var calls []string
+		middleware := func(name string) func(next Output) Output {
+			return func(next Output) Output {
+				return &middlewareOutput{Output: next, onStart: func() {
+					lock.Lock()
+					defer lock.Unlock()
+					calls = append(calls, name)
+				}}
+			}
+		}
+		inner := &HitOutput{}
+		b := NewStandaloneBoomer(1, 100).WithLogger(log.New(io.Discard, "", 0))
+		b.AddOutput(inner)
+		b.WithOutputMiddleware(middleware("outer")).WithOutputMiddleware(nil).WithOutputMiddleware(middleware("inner"))
+		_, err := b.RunN(10, &Task{Name: "foo", Fn: func() {}})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(b.outputs).To(Equal([]Output{inner}))
+		Expect(b.localRunner.outputs).To(HaveLen(1))
+		outer := b.localRunner.outputs[0].(*middlewareOutput)
+		Expect(outer.Output.(*middlewareOutput).Output).To(BeIdenticalTo(inner))
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(calls).To(Equal([]string{"outer", "inner"}))
+		Expect(inner.started()).To(BeTrue())
+		Expect(inner.stopped()).To(BeTrue())
+	})
+
+	It("test with max requests per user", func() {
+		calls := int64(0)
+		b := NewStandaloneBoomer(10, 100).WithMaxRequestsPerUser(5).WithLogger(log.New(io.Discard, "", 0))
+		task := &Task{
+			Name: "foo",
+			Fn: func() {
+				atomic.AddInt64(&calls, 1)
+				time.Sleep(time.Millisecond)
+			},
+		}
+
+		done := make(chan bool)
+		go func() {
+			b.Run(task)
+			close(done)
+		}()
+		Eventually(done).Should(BeClosed())
+		Expect(atomic.LoadInt64(&calls)).To(BeEquivalentTo(50))
+		Expect(b.localRunner.report.TotalTaskExecutions).To(BeEquivalentTo(50))
+	})
+
+	It("test with endpoint blacklist", func() {
+		buf := gbytes.NewBuffer()
+		b := NewStandaloneBoomer(1, 100).WithLogger(log.New(buf, "", 0)).
+			WithEndpointBlacklist([]string{"GET:/health*", "re:^(GET|POST):/metrics$", "re:("})
+		Expect(buf).To(gbytes.Say("Invalid endpoint blacklist pattern re:\\("))
+		b.WithStatsSnapshotInterval(100 * time.Millisecond)
+		output := NewInMemoryOutput()
+		b.AddOutput(output)
+		task := &Task{
+			Name: "foo",
+			Fn: func() {
+				b.RecordSuccess("GET", "/health", 1, 10)
+				b.RecordSuccess("GET", "/healthz/ready", 1, 10)
+				b.RecordFailure("POST", "/metrics", 1, "error")
+				b.RecordSuccessWithTags("GET", "/metrics", 1, 10, map[string]string{"region": "us"})
+				b.RecordSuccess("POST", "/health", 1, 10)
+				b.RecordSuccess("GET", "/checkout", 10, 100)
+				time.Sleep(30 * time.Millisecond)
+			},
+		}
+		report, err := b.RunN(10, task)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.ExcludedRequestCount()).To(BeEquivalentTo(40))
+		Expect(report.TotalRequests).To(BeEquivalentTo(20))
+		Expect(report.Stats).To(HaveKey("/checkoutGET"))
+		Expect(report.Stats).To(HaveKey("/healthPOST"))
+		Expect(report.Stats).NotTo(HaveKey("/healthGET"))
+
+		Expect(output.Snapshots()).NotTo(BeEmpty())
+		for _, snapshot := range output.Snapshots() {
+			for _, stat := range snapshot.Stats {
+				Expect(stat.Name).NotTo(HavePrefix("/metrics"))
+				Expect(stat.Method + ":" + stat.Name).NotTo(HavePrefix("GET:/health"))
+			}
+		}
+	})
+
+	It("test with stats snapshot interval", func() {
+		buf := gbytes.NewBuffer()
+		b := NewStandaloneBoomer(1, 1).WithLogger(log.New(buf, "", 0))
+
+		Expect(b.WithStatsSnapshotInterval(50 * time.Millisecond)).To(BeIdenticalTo(b))
+		Expect(buf).To(gbytes.Say("Invalid stats interval 50ms"))
+		Expect(buf).To(gbytes.Say(ErrStatsIntervalTooShort.Error()))
+		Expect(b.statsInterval).To(BeZero())
+
+		b.WithStatsSnapshotInterval(time.Second)
+		Expect(b.statsInterval).To(Equal(time.Second))
+
+		b.WithStatsSnapshotInterval(2 * time.Minute)
+		Expect(buf).To(gbytes.Say("the stats may be stale"))
+		Expect(b.statsInterval).To(Equal(2 * time.Minute))
+
+		b.WithJitteredStatsInterval(time.Second, 950*time.Millisecond)
+		Expect(buf).To(gbytes.Say(ErrStatsJitterTooLarge.Error()))
+		Expect(b.statsInterval).To(Equal(2 * time.Minute))
+		Expect(b.statsJitter).To(BeZero())
+		b.WithJitteredStatsInterval(time.Second, 200*time.Millisecond)
+
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+		b.setupRunner(&b.localRunner.runner)
+		intervals := map[time.Duration]bool{}
+		for i := 0; i < 20; i++ {
+			interval := b.localRunner.stats.nextReportInterval()
+			Expect(interval).To(BeNumerically(">=", 800*time.Millisecond))
+			Expect(interval).To(BeNumerically("<=", 1200*time.Millisecond))
+			intervals[interval] = true
+		}
+		Expect(len(intervals)).To(BeNumerically(">", 1))
+	})
+
+	It("test with random seed", func() {
+		ts := NewWeighingTaskSet()
+		picked := []string{}
+		for _, name := range []string{"A", "B", "C"} {
+			name := name
+			ts.AddTask(&Task{
+				Name:   name,
+				Weight: 1,
+				Fn: func() {
+					picked = append(picked, name)
+				},
+			})
+		}
+		pick := func(ctx context.Context) []string {
+			picked = []string{}
+			for i := 0; i < 20; i++ {
+				ts.RunWithContext(ctx)
+			}
+			return picked
+		}
+		userContext := func(b *Boomer) context.Context {
+			r := newLocalRunner(nil, nil, 1, 1)
+			b.setupRunner(&r.runner)
+			Expect(r.stats.random).To(BeIdenticalTo(r.random))
+			return r.newUserContext(0)
+		}
+
+		Expect(newLocalRunner(nil, nil, 1, 1).random).To(BeIdenticalTo(random))
+		b := NewStandaloneBoomer(1, 1).WithRandomSeed(42)
+		first := pick(userContext(b))
+		Expect(pick(userContext(b))).To(Equal(first))
+		// another boomer doesn't share the random source
+		Expect(pick(userContext(NewStandaloneBoomer(1, 1).WithRandomSeed(43)))).NotTo(Equal(first))
+
+		ctx := userContext(b.WithCryptoRandom(true))
+		Expect(randomFromContext(ctx)).NotTo(BeIdenticalTo(random))
+		Expect(pick(ctx)).To(HaveLen(20))
+		Expect(pick(context.Background())).To(HaveLen(20))
+	})
+
 	It("test set mode", func() {
 		b := NewStandaloneBoomer(100, 10)
 		b.SetMode(DistributedMode)
@@ -125,7 +406,7 @@ var _ = Describe("Test Boomer", func() {
 		MockGomqDealerInstance.RecvChannel() <- serverZmtpMessage
 
 		time.Sleep(4 * time.Second)
-		Expect(count).Should(BeEquivalentTo(10))
+		Expect(atomic.LoadInt64(&count)).Should(BeEquivalentTo(10))
 	})
 
 	It("test run tasks for test", func() {
@@ -244,6 +525,80 @@ var _ = Describe("Test Boomer", func() {
 		Expect(requestSuccessMsg.responseTime).To(BeEquivalentTo(1))
 	})
 
+	It("test record success with tags", func() {
+		b := NewStandaloneBoomer(1, 1)
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+
+		var requestSuccessMsg *requestSuccess
+		b.RecordSuccessWithTags("http", "foo", int64(1), int64(10), map[string]string{"variant": "b", "region": "eu"})
+		Expect(b.localRunner.stats.requestSuccessChan).Should(Receive(&requestSuccessMsg))
+		Expect(requestSuccessMsg.name).To(Equal("foo|region=eu,variant=b"))
+
+		b.RecordSuccessWithTags("http", "foo", int64(1), int64(10), nil)
+		Expect(b.localRunner.stats.requestSuccessChan).Should(Receive(&requestSuccessMsg))
+		Expect(requestSuccessMsg.name).To(Equal("foo"))
+	})
+
+	It("test tagged name", func() {
+		b := NewStandaloneBoomer(1, 1).WithTagSeparator("#").WithMaxTagValues(2)
+
+		Expect(b.taggedName("http", "foo", map[string]string{"variant": "a"})).To(Equal("foo#variant=a"))
+		Expect(b.taggedName("http", "foo", map[string]string{"variant": "b"})).To(Equal("foo#variant=b"))
+		Expect(b.taggedName("http", "foo", map[string]string{"variant": "c"})).To(Equal("foo#<other>"))
+		Expect(b.taggedName("http", "foo", map[string]string{"variant": "a"})).To(Equal("foo#variant=a"))
+		Expect(b.taggedName("http", "bar", map[string]string{"variant": "c"})).To(Equal("bar#variant=c"))
+	})
+
+	It("test record with url normalization", func() {
+		b := NewStandaloneBoomer(1, 1).WithURLNormalization([]NamePattern{
+			{Regex: regexp.MustCompile(`/users/\d+`), Replacement: "/users/{id}"},
+		})
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+
+		for i := 0; i < 100; i++ {
+			b.RecordSuccess("GET", fmt.Sprintf("/users/%d", i), int64(1), int64(10))
+			b.localRunner.stats.logRequest(readSuccess(b))
+		}
+		b.RecordFailure("GET", "/users/100/orders", int64(1), "500 error")
+		requestFailureMsg := <-b.localRunner.stats.requestFailureChan
+		Expect(requestFailureMsg.name).To(Equal("/users/{id}/orders"))
+
+		Expect(b.localRunner.stats.entries).To(HaveLen(1))
+		Expect(b.localRunner.stats.get("/users/{id}", "GET").NumRequests).To(BeEquivalentTo(100))
+	})
+
+	It("test record with endpoint grouping and tags", func() {
+		b := NewStandaloneBoomer(1, 1).WithEndpointGrouping(func(requestType, name string) (string, string) {
+			return "http", strings.ToLower(name)
+		})
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+
+		b.RecordSuccessWithTags("GET", "/FOO", int64(1), int64(10), map[string]string{"variant": "A"})
+		requestType, name, _, _ := readSuccess(b)
+		Expect(requestType).To(Equal("http"))
+		Expect(name).To(Equal("/foo|variant=A"))
+	})
+
+	It("test record failure with details", func() {
+		b := NewStandaloneBoomer(1, 1).WithEndpointBlacklist([]string{"GET:/health"}).
+			WithEndpointGrouping(func(requestType, name string) (string, string) {
+				return "http", strings.ToLower(name)
+			})
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+
+		b.RecordFailureWithDetails("GET", "/health", int64(1), "500 error", map[string]string{"request_id": "foo"})
+		Expect(b.localRunner.stats.requestFailureChan).NotTo(Receive())
+		Expect(b.ExcludedRequestCount()).To(BeEquivalentTo(1))
+
+		b.RecordFailureWithDetails("GET", "/FOO", int64(2), "500 error", map[string]string{"request_id": "bar"})
+		var requestFailureMsg *requestFailure
+		Expect(b.localRunner.stats.requestFailureChan).To(Receive(&requestFailureMsg))
+		Expect(requestFailureMsg.requestType).To(Equal("http"))
+		Expect(requestFailureMsg.name).To(Equal("/foo"))
+		Expect(requestFailureMsg.error).To(Equal("500 error"))
+		Expect(requestFailureMsg.details).To(Equal(map[string]string{"request_id": "bar"}))
+	})
+
 	It("test record failure", func() {
 		defer func() {
 			defaultBoomer = &Boomer{logger: log.Default()}
@@ -292,3 +647,19 @@ var _ = Describe("Test Boomer", func() {
 		defaultBoomer.WithLogger(logger)
 	})
 })
+
+func readSuccess(b *Boomer) (requestType, name string, responseTime int64, responseLength int64) {
+	m := <-b.localRunner.stats.requestSuccessChan
+	return m.requestType, m.name, m.responseTime, m.responseLength
+}
+
+// middlewareOutput calls onStart before the wrapped output.
+type middlewareOutput struct {
+	Output
+	onStart func()
+}
+
+func (o *middlewareOutput) OnStart() {
+	o.onStart()
+	o.Output.OnStart()
+}
@@ -0,0 +1,103 @@
+package boomer
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"sync"
+)
+
+// DataFeedMode decides the order of rows returned by a data feed.
+type DataFeedMode int
+
+const (
+	// CircularMode returns rows in order and wraps around at the end.
+	CircularMode DataFeedMode = iota
+	// SequentialMode returns rows in order and returns ErrDataFeedExhausted at the end.
+	SequentialMode
+	// ShuffleMode shuffles rows once when loaded, and wraps around at the end.
+	ShuffleMode
+)
+
+// ErrDataFeedExhausted is the error returned by a sequential data feed when there are no more rows.
+var ErrDataFeedExhausted = errors.New("datafeed: no more rows")
+
+// ErrEmptyDataFeed is the error returned if the csv file has no rows except the header.
+var ErrEmptyDataFeed = errors.New("datafeed: no rows found")
+
+// A CSVDataFeed feeds test data from a csv file, the first line of which is the header.
+// All the rows are loaded into memory, and it's safe to be used by multiple goroutines.
+type CSVDataFeed struct {
+	mode   DataFeedMode
+	header []string
+	rows   [][]string
+	offset int
+	lock   sync.Mutex
+}
+
+// NewCSVDataFeed loads rows from a csv file and returns a CSVDataFeed.
+func NewCSVDataFeed(path string, mode DataFeedMode) (feed *CSVDataFeed, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, ErrEmptyDataFeed
+	}
+
+	feed = &CSVDataFeed{
+		mode:   mode,
+		header: records[0],
+		rows:   records[1:],
+	}
+	if mode == ShuffleMode {
+		random.Shuffle(len(feed.rows), func(i, j int) {
+			feed.rows[i], feed.rows[j] = feed.rows[j], feed.rows[i]
+		})
+	}
+	return feed, nil
+}
+
+// Next returns the next row as a map, keyed by the column names in header.
+func (feed *CSVDataFeed) Next() (row map[string]string, err error) {
+	feed.lock.Lock()
+	if feed.offset == len(feed.rows) {
+		if feed.mode == SequentialMode {
+			feed.lock.Unlock()
+			return nil, ErrDataFeedExhausted
+		}
+		feed.offset = 0
+	}
+	record := feed.rows[feed.offset]
+	feed.offset++
+	feed.lock.Unlock()
+
+	row = make(map[string]string, len(feed.header))
+	for i, column := range feed.header {
+		if i < len(record) {
+			row[column] = record[i]
+		}
+	}
+	return row, nil
+}
+
+// Len returns the number of rows in the data feed.
+func (feed *CSVDataFeed) Len() int {
+	return len(feed.rows)
+}
+
+type dataFeedContextKey struct{}
+
+// DataFeedFromContext returns the data feed registered with Boomer.WithCSVDataFeed.
+// It returns nil if there is no data feed in ctx.
+func DataFeedFromContext(ctx context.Context) *CSVDataFeed {
+	feed, _ := ctx.Value(dataFeedContextKey{}).(*CSVDataFeed)
+	return feed
+}
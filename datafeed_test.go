@@ -0,0 +1,117 @@
+package boomer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func writeCSVFile(dir string, rows int) string {
+	lines := []string{"username,password"}
+	for i := 0; i < rows; i++ {
+		lines = append(lines, fmt.Sprintf("user%d,pass%d", i, i))
+	}
+	path := filepath.Join(dir, "users.csv")
+	Expect(os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)).To(Succeed())
+	return path
+}
+
+var _ = Describe("Test data feed", func() {
+
+	It("test circular data feed", func() {
+		feed, err := NewCSVDataFeed(writeCSVFile(GinkgoT().TempDir(), 2), CircularMode)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(feed.Len()).To(Equal(2))
+
+		for _, expected := range []string{"user0", "user1", "user0"} {
+			row, err := feed.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(row["username"]).To(Equal(expected))
+		}
+	})
+
+	It("test sequential data feed", func() {
+		feed, err := NewCSVDataFeed(writeCSVFile(GinkgoT().TempDir(), 2), SequentialMode)
+		Expect(err).NotTo(HaveOccurred())
+
+		row, _ := feed.Next()
+		Expect(row).To(Equal(map[string]string{"username": "user0", "password": "pass0"}))
+		feed.Next()
+		_, err = feed.Next()
+		Expect(err).To(Equal(ErrDataFeedExhausted))
+	})
+
+	It("test shuffle data feed", func() {
+		path := writeCSVFile(GinkgoT().TempDir(), 100)
+		firstRows := map[string]bool{}
+		for i := 0; i < 10; i++ {
+			feed, err := NewCSVDataFeed(path, ShuffleMode)
+			Expect(err).NotTo(HaveOccurred())
+
+			seen := map[string]bool{}
+			for j := 0; j < feed.Len(); j++ {
+				row, _ := feed.Next()
+				if j == 0 {
+					firstRows[row["username"]] = true
+				}
+				seen[row["username"]] = true
+			}
+			Expect(seen).To(HaveLen(100))
+		}
+		Expect(len(firstRows)).To(BeNumerically(">", 1))
+	})
+
+	It("test concurrent access", func() {
+		feed, err := NewCSVDataFeed(writeCSVFile(GinkgoT().TempDir(), 10), CircularMode)
+		Expect(err).NotTo(HaveOccurred())
+
+		counts := make(map[string]int)
+		lock := sync.Mutex{}
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					row, _ := feed.Next()
+					lock.Lock()
+					counts[row["username"]]++
+					lock.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		Expect(counts).To(HaveLen(10))
+		for _, count := range counts {
+			Expect(count).To(Equal(100))
+		}
+	})
+
+	It("test invalid data feed", func() {
+		dir := GinkgoT().TempDir()
+		_, err := NewCSVDataFeed(filepath.Join(dir, "missing.csv"), CircularMode)
+		Expect(err).To(HaveOccurred())
+
+		_, err = NewCSVDataFeed(writeCSVFile(dir, 0), CircularMode)
+		Expect(err).To(Equal(ErrEmptyDataFeed))
+	})
+
+	It("test data feed from context", func() {
+		Expect(DataFeedFromContext(context.Background())).To(BeNil())
+
+		b := NewStandaloneBoomer(1, 1).WithCSVDataFeed(writeCSVFile(GinkgoT().TempDir(), 1), CircularMode)
+		r := newLocalRunner(nil, nil, 1, 1)
+		b.setupRunner(&r.runner)
+		feed := DataFeedFromContext(r.newUserContext(0))
+		Expect(feed).NotTo(BeNil())
+		row, _ := feed.Next()
+		Expect(row["password"]).To(Equal("pass0"))
+	})
+})
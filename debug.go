@@ -0,0 +1,80 @@
+package boomer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// debugMutexWaitThreshold is the wait time of a debugMutex, above which the wait is logged.
+const debugMutexWaitThreshold = 10 * time.Millisecond
+
+func newDebugLogger() *log.Logger {
+	return log.New(os.Stderr, "[boomer debug] ", log.LstdFlags|log.Lmicroseconds)
+}
+
+// debugMutex is a sync.Mutex which logs the waits longer than debugMutexWaitThreshold to logger, if it's set.
+type debugMutex struct {
+	sync.Mutex
+	name   string
+	logger *log.Logger
+}
+
+// Lock locks the mutex, and logs the wait time if it's too long.
+func (m *debugMutex) Lock() {
+	if m.logger == nil {
+		m.Mutex.Lock()
+		return
+	}
+	start := time.Now()
+	m.Mutex.Lock()
+	if wait := time.Since(start); wait >= debugMutexWaitThreshold {
+		m.logger.Printf("Waited %v for the %s lock\n", wait, m.name)
+	}
+}
+
+// setDebugLogger enables the debug logging of the runner, the call sites are guarded by r.debug,
+// so nothing is formatted if debug mode is off.
+func (r *runner) setDebugLogger(logger *log.Logger) {
+	r.debug = true
+	r.debugLogger = logger
+	r.userDataLock.name, r.userDataLock.logger = "user data", logger
+	r.abortLock.name, r.abortLock.logger = "abort", logger
+	r.spawnLock.name, r.spawnLock.logger = "spawn", logger
+}
+
+func (r *runner) debugf(format string, v ...interface{}) {
+	r.debugLogger.Printf(format, v...)
+}
+
+// goroutineStacks returns the stacks of all the goroutines, the buffer grows until it holds all of them.
+func goroutineStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// dumpGoroutines writes the stacks of all the goroutines to "goroutines_{timestamp}.txt" in outputDirectory,
+// or to the logger if outputDirectory isn't set or the file can't be written.
+func (r *runner) dumpGoroutines() {
+	stacks := goroutineStacks()
+	if r.outputDirectory != "" {
+		path := filepath.Join(r.outputDirectory, fmt.Sprintf("goroutines_%s.txt", time.Now().Format("20060102T150405.000")))
+		err := os.WriteFile(path, stacks, 0o644)
+		if err == nil {
+			r.logger.Printf("The goroutine stacks are dumped to %s\n", path)
+			return
+		}
+		r.logger.Printf("Error dumping the goroutine stacks to %s, %v\n", path, err)
+	}
+	r.logger.Printf("The goroutine stacks:\n%s", stacks)
+}
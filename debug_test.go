@@ -0,0 +1,130 @@
+package boomer
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Test debug mode", func() {
+
+	It("test debug mode options", func() {
+		b := NewStandaloneBoomer(1, 1)
+		Expect(b.debugLogger).To(BeNil())
+		Expect(b.WithDebugMode(true).debugLogger).NotTo(BeNil())
+		Expect(b.WithDebugMode(false).debugLogger).To(BeNil())
+
+		logger := log.New(gbytes.NewBuffer(), "", 0)
+		Expect(b.WithDebugLogger(nil).debugLogger).To(BeNil())
+		Expect(b.WithDebugLogger(logger).debugLogger).To(Equal(logger))
+		Expect(b.WithDebugMode(true).debugLogger).To(Equal(logger))
+	})
+
+	It("test debug logs", func() {
+		debugBuf := gbytes.NewBuffer()
+		b := NewStandaloneBoomer(2, 10).WithDebugLogger(log.New(debugBuf, "", 0)).
+			WithLogger(log.New(GinkgoWriter, "", 0)).WithRunTime(time.Second)
+		b.WithStatsSnapshotInterval(100 * time.Millisecond)
+		b.AddOutput(NewInMemoryOutput())
+		b.Run(&Task{
+			Name: "foo",
+			Fn: func() {
+				time.Sleep(10 * time.Millisecond)
+			},
+		})
+
+		Expect(debugBuf).To(gbytes.Say("User 0 is started"))
+		Expect(debugBuf).To(gbytes.Say("User 0 picked task foo"))
+		Expect(debugBuf).To(gbytes.Say(`Output \*boomer.InMemoryOutput handled the event in `))
+		Expect(debugBuf).To(gbytes.Say("Stats are dispatched to 1 outputs in "))
+		Expect(debugBuf).To(gbytes.Say("Stopping users 0 to 1"))
+		Eventually(debugBuf).Should(gbytes.Say("User [01] is stopped"))
+	})
+
+	It("test no debug logs by default", func() {
+		r := newLocalRunner(nil, nil, 1, 1)
+		NewStandaloneBoomer(1, 1).setupRunner(&r.runner)
+		Expect(r.debug).To(BeFalse())
+		Expect(r.abortLock.logger).To(BeNil())
+		// debugf isn't called, or it panics with the nil logger
+		r.setTasks([]*Task{{Name: "foo", Fn: func() {}}})
+		Expect(r.taskPicker(0)().Name).To(Equal("foo"))
+	})
+
+	It("test debug mutex logs long waits", func() {
+		buf := gbytes.NewBuffer()
+		m := &debugMutex{name: "test", logger: log.New(buf, "", 0)}
+		m.Lock()
+		m.Unlock()
+		Expect(buf.Contents()).To(BeEmpty())
+
+		m.Lock()
+		go func() {
+			time.Sleep(2 * debugMutexWaitThreshold)
+			m.Unlock()
+		}()
+		m.Lock()
+		m.Unlock()
+		Expect(buf).To(gbytes.Say(`Waited .+ for the test lock`))
+	})
+
+	It("test goroutine stack trace on abort", func() {
+		dir := GinkgoT().TempDir()
+		b := NewStandaloneBoomer(2, 100).WithWindowedFailureThreshold(time.Minute, 5).WithStopOnSpike(true).
+			WithGoroutineStackTrace(true).WithOutputDirectory(dir).WithRunTime(5 * time.Second).
+			WithLogger(log.New(io.Discard, "", 0))
+		Expect(b.goroutineStackTrace).To(BeTrue())
+		b.Run(&Task{
+			Name: "failing",
+			Fn: func() {
+				time.Sleep(10 * time.Millisecond)
+				b.RecordFailure("http", "login", 10, "500")
+			},
+		})
+		Expect(errors.Is(b.Err(), ErrFailureSpike)).To(BeTrue())
+
+		files, err := filepath.Glob(filepath.Join(dir, "goroutines_*.txt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		stacks, err := os.ReadFile(files[0])
+		Expect(err).NotTo(HaveOccurred())
+		// the goroutines of both users
+		Expect(regexp.MustCompile(`boomer\.\(\*runner\)\.addWorker\.func\d+\(`).FindAllString(string(stacks), -1)).To(HaveLen(2))
+		Expect(string(stacks)).To(ContainSubstring("boomer.(*runner).abortWithError"))
+	})
+
+	It("test goroutine stack trace is logged without output directory", func() {
+		buf := gbytes.NewBuffer()
+		r := newLocalRunner(nil, nil, 1, 1)
+		NewStandaloneBoomer(1, 1).WithGoroutineStackTrace(true).setupRunner(&r.runner)
+		r.setLogger(log.New(buf, "", 0))
+		r.abort = func() {}
+		r.abortWithError(errors.New("boom"))
+		Expect(buf).To(gbytes.Say("The goroutine stacks:\n"))
+		Expect(buf).To(gbytes.Say(`goroutine \d+ \[running\]`))
+	})
+
+	It("test no goroutine stack trace on normal stop", func() {
+		dir := GinkgoT().TempDir()
+		b := NewStandaloneBoomer(1, 10).WithGoroutineStackTrace(true).WithOutputDirectory(dir).
+			WithRunTime(200 * time.Millisecond).WithLogger(log.New(io.Discard, "", 0))
+		b.Run(&Task{
+			Name: "foo",
+			Fn: func() {
+				time.Sleep(10 * time.Millisecond)
+			},
+		})
+		Expect(b.Err()).NotTo(HaveOccurred())
+		files, err := filepath.Glob(filepath.Join(dir, "goroutines_*.txt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	})
+})
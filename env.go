@@ -0,0 +1,153 @@
+package boomer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Environment variables read by NewBoomerFromEnvironment.
+const (
+	// EnvMasterHost is the host of the locust master, boomer runs in distributed mode if it's set.
+	EnvMasterHost = "BOOMER_MASTER_HOST"
+	// EnvMasterPort is the port of the locust master, 5557 by default.
+	EnvMasterPort = "BOOMER_MASTER_PORT"
+	// EnvUsers is the number of users in standalone mode, it's required if EnvMasterHost is not set.
+	EnvUsers = "BOOMER_USERS"
+	// EnvSpawnRate is the number of users spawned per second in standalone mode, 1 by default.
+	EnvSpawnRate = "BOOMER_SPAWN_RATE"
+	// EnvTestDuration stops the test after the duration in standalone mode, like "10m".
+	EnvTestDuration = "BOOMER_TEST_DURATION"
+	// EnvTestName identifies the test in the outputs, the name of the executable by default.
+	EnvTestName = "BOOMER_TEST_NAME"
+	// EnvStatsInterval is the interval of reporting stats, like "5s", 3s by default.
+	EnvStatsInterval = "BOOMER_STATS_INTERVAL"
+	// EnvMaxRPS limits the number of task executions per second, no limit by default.
+	EnvMaxRPS = "BOOMER_MAX_RPS"
+	// EnvConcurrencyLimit limits the number of tasks running at the same time, no limit by default.
+	EnvConcurrencyLimit = "BOOMER_CONCURRENCY_LIMIT"
+	// EnvPrometheusGateway is the URL of Prometheus Pushgateway, the stats are pushed to it if it's set.
+	EnvPrometheusGateway = "BOOMER_PROMETHEUS_GATEWAY"
+	// EnvPrometheusJob is the job name of Prometheus Pushgateway, the test name by default.
+	EnvPrometheusJob = "BOOMER_PROMETHEUS_JOB"
+)
+
+// NewBoomerFromEnvironment returns a Boomer configured by the environment variables, for containerized deployments.
+//
+// If BOOMER_MASTER_HOST is set, boomer connects to the master in distributed mode, and BOOMER_MASTER_PORT is optional.
+// Otherwise, boomer runs in standalone mode with a ConsoleOutput, BOOMER_USERS is required,
+// and BOOMER_SPAWN_RATE and BOOMER_TEST_DURATION are optional.
+// BOOMER_TEST_NAME, BOOMER_STATS_INTERVAL, BOOMER_MAX_RPS, BOOMER_CONCURRENCY_LIMIT, BOOMER_PROMETHEUS_GATEWAY
+// and BOOMER_PROMETHEUS_JOB are optional in both modes. Unset variables keep the defaults of boomer.
+//
+// An error is returned if a required variable is missing, or a value is invalid.
+func NewBoomerFromEnvironment() (*Boomer, error) {
+	var b *Boomer
+	if masterHost := os.Getenv(EnvMasterHost); masterHost != "" {
+		masterPort, err := intFromEnv(EnvMasterPort, 5557)
+		if err != nil {
+			return nil, err
+		}
+		if os.Getenv(EnvTestDuration) != "" {
+			return nil, fmt.Errorf("boomer: %s only works in standalone mode, the test is stopped by master", EnvTestDuration)
+		}
+		b = NewBoomer(masterHost, masterPort)
+	} else {
+		if os.Getenv(EnvUsers) == "" {
+			return nil, fmt.Errorf("boomer: %s is required in standalone mode, or set %s to connect to master", EnvUsers, EnvMasterHost)
+		}
+		users, err := intFromEnv(EnvUsers, 0)
+		if err != nil {
+			return nil, err
+		}
+		if users <= 0 {
+			return nil, fmt.Errorf("boomer: invalid %s %d, it must be positive", EnvUsers, users)
+		}
+		spawnRate, err := floatFromEnv(EnvSpawnRate, 1)
+		if err != nil {
+			return nil, err
+		}
+		if spawnRate <= 0 {
+			return nil, fmt.Errorf("boomer: invalid %s %v, it must be positive", EnvSpawnRate, spawnRate)
+		}
+		duration, err := durationFromEnv(EnvTestDuration, 0)
+		if err != nil {
+			return nil, err
+		}
+		b = NewStandaloneBoomer(users, spawnRate).WithRunTime(duration)
+		b.AddOutput(NewConsoleOutput())
+	}
+
+	if testName := os.Getenv(EnvTestName); testName != "" {
+		b.WithTestName(testName)
+	}
+
+	interval, err := durationFromEnv(EnvStatsInterval, 0)
+	if err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		if err := validateStatsInterval(interval, 0); err != nil {
+			return nil, fmt.Errorf("boomer: invalid %s %v: %w", EnvStatsInterval, interval, err)
+		}
+		b.WithStatsSnapshotInterval(interval)
+	}
+
+	maxRPS, err := intFromEnv(EnvMaxRPS, 0)
+	if err != nil {
+		return nil, err
+	}
+	if maxRPS > 0 {
+		b.SetRateLimiter(NewStableRateLimiter(int64(maxRPS), time.Second))
+	}
+
+	concurrencyLimit, err := intFromEnv(EnvConcurrencyLimit, 0)
+	if err != nil {
+		return nil, err
+	}
+	if concurrencyLimit > 0 {
+		b.WithConcurrencyLimit(concurrencyLimit)
+	}
+
+	if gateway := os.Getenv(EnvPrometheusGateway); gateway != "" {
+		b.AddOutput(NewPrometheusPusherOutput(gateway, os.Getenv(EnvPrometheusJob)))
+	}
+	return b, nil
+}
+
+func intFromEnv(name string, defaultValue int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("boomer: invalid %s %q, it must be an integer", name, value)
+	}
+	return n, nil
+}
+
+func floatFromEnv(name string, defaultValue float64) (float64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("boomer: invalid %s %q, it must be a number", name, value)
+	}
+	return f, nil
+}
+
+func durationFromEnv(name string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("boomer: invalid %s %q, it must be a duration like \"30s\"", name, value)
+	}
+	return d, nil
+}
@@ -0,0 +1,107 @@
+package boomer
+
+import (
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test boomer from environment", func() {
+
+	setenv := func(name, value string) {
+		old, ok := os.LookupEnv(name)
+		os.Setenv(name, value)
+		DeferCleanup(func() {
+			if ok {
+				os.Setenv(name, old)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+
+	BeforeEach(func() {
+		for _, name := range []string{
+			EnvMasterHost, EnvMasterPort, EnvUsers, EnvSpawnRate, EnvTestDuration, EnvTestName,
+			EnvStatsInterval, EnvMaxRPS, EnvConcurrencyLimit, EnvPrometheusGateway, EnvPrometheusJob,
+		} {
+			setenv(name, "")
+		}
+	})
+
+	It("test standalone mode", func() {
+		setenv(EnvUsers, "10")
+		setenv(EnvSpawnRate, "2.5")
+		setenv(EnvTestDuration, "10m")
+		setenv(EnvTestName, "checkout")
+		setenv(EnvStatsInterval, "5s")
+		setenv(EnvMaxRPS, "100")
+		setenv(EnvConcurrencyLimit, "4")
+		setenv(EnvPrometheusGateway, "http://pushgateway:9091")
+		setenv(EnvPrometheusJob, "load")
+
+		b, err := NewBoomerFromEnvironment()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.mode).To(Equal(StandaloneMode))
+		Expect(b.spawnCount).To(Equal(10))
+		Expect(b.spawnRate).To(Equal(2.5))
+		Expect(b.runTime).To(Equal(10 * time.Minute))
+		Expect(b.testName).To(Equal("checkout"))
+		Expect(b.statsInterval).To(Equal(5 * time.Second))
+		Expect(b.rateLimiter).To(BeAssignableToTypeOf(&StableRateLimiter{}))
+		Expect(b.rateLimiter.(*StableRateLimiter).threshold).To(BeEquivalentTo(100))
+		Expect(b.concurrencyLimit).To(Equal(4))
+		Expect(b.outputs).To(HaveLen(2))
+		Expect(b.outputs[0]).To(BeAssignableToTypeOf(&ConsoleOutput{}))
+		Expect(b.outputs[1]).To(BeAssignableToTypeOf(&PrometheusPusherOutput{}))
+		Expect(b.outputs[1].(*PrometheusPusherOutput).gatewayURL).To(Equal("http://pushgateway:9091"))
+		Expect(b.outputs[1].(*PrometheusPusherOutput).jobName).To(Equal("load"))
+	})
+
+	It("test defaults", func() {
+		setenv(EnvUsers, "1")
+		b, err := NewBoomerFromEnvironment()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.spawnRate).To(Equal(float64(1)))
+		Expect(b.runTime).To(BeZero())
+		Expect(b.statsInterval).To(BeZero())
+		Expect(b.rateLimiter).To(BeNil())
+		Expect(b.concurrencyLimit).To(BeZero())
+		Expect(b.outputs).To(HaveLen(1))
+	})
+
+	It("test distributed mode", func() {
+		setenv(EnvMasterHost, "locust-master")
+		b, err := NewBoomerFromEnvironment()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.mode).To(Equal(DistributedMode))
+		Expect(b.masterHost).To(Equal("locust-master"))
+		Expect(b.masterPort).To(Equal(5557))
+		Expect(b.outputs).To(BeEmpty())
+
+		setenv(EnvMasterPort, "6000")
+		b, err = NewBoomerFromEnvironment()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.masterPort).To(Equal(6000))
+	})
+
+	DescribeTable("test invalid environment", func(env map[string]string, message string) {
+		for name, value := range env {
+			setenv(name, value)
+		}
+		b, err := NewBoomerFromEnvironment()
+		Expect(b).To(BeNil())
+		Expect(err).To(MatchError(ContainSubstring(message)))
+	},
+		Entry("missing users", map[string]string{}, "BOOMER_USERS is required"),
+		Entry("invalid users", map[string]string{EnvUsers: "ten"}, `invalid BOOMER_USERS "ten"`),
+		Entry("zero users", map[string]string{EnvUsers: "0"}, "invalid BOOMER_USERS 0"),
+		Entry("invalid spawn rate", map[string]string{EnvUsers: "1", EnvSpawnRate: "fast"}, `invalid BOOMER_SPAWN_RATE "fast"`),
+		Entry("invalid duration", map[string]string{EnvUsers: "1", EnvTestDuration: "10"}, `invalid BOOMER_TEST_DURATION "10"`),
+		Entry("short stats interval", map[string]string{EnvUsers: "1", EnvStatsInterval: "10ms"}, "invalid BOOMER_STATS_INTERVAL 10ms"),
+		Entry("invalid master port", map[string]string{EnvMasterHost: "master", EnvMasterPort: "port"}, `invalid BOOMER_MASTER_PORT "port"`),
+		Entry("duration in distributed mode", map[string]string{EnvMasterHost: "master", EnvTestDuration: "1m"}, "only works in standalone mode"),
+	)
+})
@@ -0,0 +1,87 @@
+package boomer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ErrorSample describes a failed request of BoomerTransport, see BoomerTransport.WithErrorSampler.
+type ErrorSample struct {
+	Timestamp    time.Time
+	Method       string
+	URL          string
+	RequestID    string
+	ResponseTime int64
+	// StatusCode is zero if no response is received.
+	StatusCode int
+	// Error is the status of the response, or the error of the request if no response is received.
+	Error string
+	// ResponseBody is the beginning of the response body, if it's captured by WithRecordResponseBody.
+	ResponseBody []byte
+}
+
+// responseBodyRecorder captures the beginning of the response bodies with the status codes accepted by predicate.
+type responseBodyRecorder struct {
+	maxBytes  int
+	predicate func(statusCode int) bool
+}
+
+// record reads up to maxBytes of the body, and replaces resp.Body, so the caller still reads the whole body.
+func (r *responseBodyRecorder) record(resp *http.Response) []byte {
+	if resp.Body == nil || resp.Body == http.NoBody || !r.predicate(resp.StatusCode) {
+		return nil
+	}
+	var buf bytes.Buffer
+	// the error is returned to the caller when it reads the rest of the body
+	buf.ReadFrom(io.LimitReader(resp.Body, int64(r.maxBytes)))
+	captured := buf.Bytes()
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(captured), resp.Body), resp.Body}
+	return captured
+}
+
+// WithErrorSampler calls fn with every failed request, which has an error or a status code >= 400,
+// e.g. to keep some of them for debugging. fn is called by the user goroutine before RoundTrip returns,
+// so it should be fast. If fn is nil, the error sampler is disabled.
+func (t *BoomerTransport) WithErrorSampler(fn func(sample *ErrorSample)) *BoomerTransport {
+	t.errorSampler = fn
+	return t
+}
+
+// WithRecordResponseBody captures the first maxBytes of the response bodies of the failed requests,
+// whose status codes are accepted by predicate, e.g. 5xx, and passes them to the error sampler as ErrorSample.ResponseBody.
+// The body is read before RoundTrip returns, and the caller still reads the whole body.
+// If maxBytes isn't positive or predicate is nil, the response bodies are not captured.
+func (t *BoomerTransport) WithRecordResponseBody(maxBytes int, predicate func(statusCode int) bool) *BoomerTransport {
+	if maxBytes <= 0 || predicate == nil {
+		t.responseBodyRecorder = nil
+		return t
+	}
+	t.responseBodyRecorder = &responseBodyRecorder{maxBytes: maxBytes, predicate: predicate}
+	return t
+}
+
+// sampleError passes the failed request to the error sampler.
+func (t *BoomerTransport) sampleError(req *http.Request, resp *http.Response, err error, start time.Time, elapsed int64, requestID string) {
+	sample := &ErrorSample{
+		Timestamp:    start,
+		Method:       req.Method,
+		URL:          req.URL.String(),
+		RequestID:    requestID,
+		ResponseTime: elapsed,
+	}
+	if err != nil {
+		sample.Error = err.Error()
+	} else {
+		sample.StatusCode = resp.StatusCode
+		sample.Error = resp.Status
+		if t.responseBodyRecorder != nil {
+			sample.ResponseBody = t.responseBodyRecorder.record(resp)
+		}
+	}
+	t.errorSampler(sample)
+}
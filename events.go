@@ -7,6 +7,18 @@ const (
 	EVENT_SPAWN     = "boomer:spawn"
 	EVENT_STOP      = "boomer:stop"
 	EVENT_QUIT      = "boomer:quit"
+
+	// EVENT_MEMORY_LIMIT_EXCEEDED is published with the heap in use and the limit, both in bytes,
+	// before the test is stopped by Boomer.WithMaxMemoryUsage.
+	EVENT_MEMORY_LIMIT_EXCEEDED = "boomer:memory_limit_exceeded"
+
+	// EVENT_FAILURE_SPIKE is published with a FailureSpikeEvent when the failures within the window exceed
+	// the threshold of Boomer.WithWindowedFailureThreshold.
+	EVENT_FAILURE_SPIKE = "boomer:failure_spike"
+
+	// EVENT_IDLE_TIMEOUT is published with an IdleTimeoutEvent when no requests are recorded for longer than
+	// Boomer.WithMaxIdleTime.
+	EVENT_IDLE_TIMEOUT = "boomer:idle_timeout"
 )
 
 // Events is the global event bus instance.
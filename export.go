@@ -0,0 +1,38 @@
+package boomer
+
+import (
+	"os"
+)
+
+// ExportFormat is a standard output added by Boomer.WithAutoExport.
+type ExportFormat string
+
+const (
+	// ConsoleExportFormat adds a ConsoleOutput.
+	ConsoleExportFormat ExportFormat = "console"
+	// PrometheusExportFormat adds a PrometheusPusherOutput, which pushes to the Pushgateway in BOOMER_PROMETHEUS_GATEWAY,
+	// with the job name in BOOMER_PROMETHEUS_JOB or the test name.
+	PrometheusExportFormat ExportFormat = "prometheus"
+)
+
+// WithAutoExport adds the standard outputs of formats with the default options, for the users who don't need
+// custom configuration. PrometheusExportFormat is skipped with a warning if BOOMER_PROMETHEUS_GATEWAY isn't set,
+// and unknown formats are ignored.
+func (b *Boomer) WithAutoExport(formats ...ExportFormat) *Boomer {
+	for _, format := range formats {
+		switch format {
+		case ConsoleExportFormat:
+			b.AddOutput(NewConsoleOutput().WithLogger(b.logger))
+		case PrometheusExportFormat:
+			gateway := os.Getenv(EnvPrometheusGateway)
+			if gateway == "" {
+				b.logger.Printf("%s is not set, skip exporting to prometheus\n", EnvPrometheusGateway)
+				continue
+			}
+			b.AddOutput(NewPrometheusPusherOutput(gateway, os.Getenv(EnvPrometheusJob)).WithLogger(b.logger))
+		default:
+			b.logger.Printf("Unsupported export format %q, ignored!\n", format)
+		}
+	}
+	return b
+}
@@ -0,0 +1,55 @@
+package boomer
+
+import (
+	"log"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Test auto export", func() {
+
+	setenv := func(name, value string) {
+		old, ok := os.LookupEnv(name)
+		os.Setenv(name, value)
+		DeferCleanup(func() {
+			if ok {
+				os.Setenv(name, old)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+
+	It("test console and prometheus", func() {
+		setenv(EnvPrometheusGateway, "http://pushgateway:9091")
+		setenv(EnvPrometheusJob, "load")
+
+		b := NewStandaloneBoomer(1, 1).WithAutoExport(ConsoleExportFormat, PrometheusExportFormat)
+		Expect(b.outputs).To(HaveLen(2))
+		Expect(b.outputs[0]).To(BeAssignableToTypeOf(&ConsoleOutput{}))
+		Expect(b.outputs[1]).To(BeAssignableToTypeOf(&PrometheusPusherOutput{}))
+		Expect(b.outputs[1].(*PrometheusPusherOutput).gatewayURL).To(Equal("http://pushgateway:9091"))
+		Expect(b.outputs[1].(*PrometheusPusherOutput).jobName).To(Equal("load"))
+	})
+
+	It("test prometheus without gateway", func() {
+		setenv(EnvPrometheusGateway, "")
+		buf := gbytes.NewBuffer()
+		b := NewStandaloneBoomer(1, 1).WithLogger(log.New(buf, "", 0))
+		b.WithAutoExport(PrometheusExportFormat, ConsoleExportFormat)
+		Expect(b.outputs).To(HaveLen(1))
+		Expect(b.outputs[0]).To(BeAssignableToTypeOf(&ConsoleOutput{}))
+		Expect(buf).To(gbytes.Say("BOOMER_PROMETHEUS_GATEWAY is not set, skip exporting to prometheus"))
+	})
+
+	It("test unsupported format", func() {
+		buf := gbytes.NewBuffer()
+		b := NewStandaloneBoomer(1, 1).WithLogger(log.New(buf, "", 0))
+		b.WithAutoExport("csv")
+		Expect(b.outputs).To(BeEmpty())
+		Expect(buf).To(gbytes.Say(`Unsupported export format "csv", ignored!`))
+	})
+})
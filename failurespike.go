@@ -0,0 +1,72 @@
+package boomer
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrFailureSpike is wrapped by the error returned by Boomer.Err if the test is stopped by Boomer.WithStopOnSpike.
+var ErrFailureSpike = errors.New("boomer: failure spike")
+
+// FailureSpikeEvent is published with EVENT_FAILURE_SPIKE when the failures within the window exceed the threshold
+// of Boomer.WithWindowedFailureThreshold.
+type FailureSpikeEvent struct {
+	Window      time.Duration
+	Failures    int64
+	MaxFailures int64
+	Time        time.Time
+}
+
+// failureSpikeDetector counts the failures of the recent window in a ring buffer of one-second buckets.
+type failureSpikeDetector struct {
+	window      time.Duration
+	maxFailures int64
+
+	seconds []int64 // the unix second of each bucket
+	counts  []int64 // the failures of each bucket
+
+	// the detector trips at most once per window
+	lastTrip int64
+	tripped  bool
+}
+
+func newFailureSpikeDetector(window time.Duration, maxFailures int64) *failureSpikeDetector {
+	size := int((window + time.Second - 1) / time.Second)
+	if size < 1 {
+		size = 1
+	}
+	return &failureSpikeDetector{
+		window:      window,
+		maxFailures: maxFailures,
+		seconds:     make([]int64, size),
+		counts:      make([]int64, size),
+	}
+}
+
+// add counts a failure at now, and returns the failures within the window if they exceed maxFailures,
+// which trips the detector. It returns zero otherwise.
+func (d *failureSpikeDetector) add(now time.Time) (failures int64) {
+	second := now.Unix()
+	size := int64(len(d.seconds))
+	i := second % size
+	if d.seconds[i] != second {
+		d.seconds[i] = second
+		d.counts[i] = 0
+	}
+	d.counts[i]++
+
+	for j, s := range d.seconds {
+		if s > second-size && s <= second {
+			failures += d.counts[j]
+		}
+	}
+	if failures <= d.maxFailures {
+		return 0
+	}
+	if d.tripped && second-d.lastTrip < size {
+		return 0
+	}
+	d.tripped = true
+	d.lastTrip = second
+	return failures
+}
@@ -0,0 +1,91 @@
+package boomer
+
+import (
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test failure spike", func() {
+
+	start := time.Unix(1700000000, 0)
+
+	It("test failures within the window", func() {
+		d := newFailureSpikeDetector(10*time.Second, 5)
+		for i := 0; i < 5; i++ {
+			Expect(d.add(start.Add(time.Duration(i) * time.Second))).To(BeZero())
+		}
+		Expect(d.add(start.Add(9 * time.Second))).To(BeEquivalentTo(6))
+		// trips at most once per window
+		Expect(d.add(start.Add(9 * time.Second))).To(BeZero())
+		Expect(d.add(start.Add(18 * time.Second))).To(BeZero())
+
+		// the window of 19s starts after 9s, so there is only one failure of 18s before
+		for i := 0; i < 4; i++ {
+			Expect(d.add(start.Add(19 * time.Second))).To(BeZero())
+		}
+		Expect(d.add(start.Add(19 * time.Second))).To(BeEquivalentTo(6))
+	})
+
+	It("test failures spread across windows", func() {
+		d := newFailureSpikeDetector(2*time.Second, 3)
+		for _, second := range []time.Duration{0, 2, 4, 6} {
+			for i := 0; i < 3; i++ {
+				Expect(d.add(start.Add(second*time.Second + time.Duration(i)*100*time.Millisecond))).To(BeZero())
+			}
+		}
+		Expect(d.add(start.Add(7 * time.Second))).To(BeEquivalentTo(4))
+	})
+
+	It("test window shorter than a second", func() {
+		d := newFailureSpikeDetector(100*time.Millisecond, 1)
+		Expect(d.seconds).To(HaveLen(1))
+		Expect(d.add(start)).To(BeZero())
+		Expect(d.add(start)).To(BeEquivalentTo(2))
+		Expect(d.add(start.Add(time.Second))).To(BeZero())
+	})
+
+	It("test options", func() {
+		b := NewStandaloneBoomer(1, 1).WithLogger(log.New(io.Discard, "", 0))
+		b.WithWindowedFailureThreshold(0, 5).WithWindowedFailureThreshold(time.Second, -1)
+		Expect(b.failureWindow).To(BeZero())
+
+		b.WithWindowedFailureThreshold(time.Minute, 5).WithStopOnSpike(true)
+		r := newLocalRunner(nil, nil, 1, 1)
+		b.setupRunner(&r.runner)
+		Expect(r.stats.failureSpike.window).To(Equal(time.Minute))
+		Expect(r.stats.failureSpike.maxFailures).To(BeEquivalentTo(5))
+		Expect(r.stopOnSpike).To(BeTrue())
+	})
+
+	It("test stop on spike", func() {
+		spikes := make(chan FailureSpikeEvent, 1)
+		handler := func(event FailureSpikeEvent) {
+			spikes <- event
+		}
+		Events.Subscribe(EVENT_FAILURE_SPIKE, handler)
+		defer Events.Unsubscribe(EVENT_FAILURE_SPIKE, handler)
+
+		b := NewStandaloneBoomer(1, 1).WithWindowedFailureThreshold(time.Minute, 5).WithStopOnSpike(true)
+		r := newLocalRunner(nil, nil, 1, 1)
+		r.setLogger(log.New(io.Discard, "", 0))
+		b.setupRunner(&r.runner)
+		r.abort = func() {}
+		r.stats.start()
+		defer r.stats.close()
+
+		for i := 0; i < 6; i++ {
+			r.stats.requestFailureChan <- &requestFailure{requestType: "http", name: "login", error: "500"}
+		}
+		var event FailureSpikeEvent
+		Eventually(spikes).Should(Receive(&event))
+		Expect(event.Window).To(Equal(time.Minute))
+		Expect(event.Failures).To(BeEquivalentTo(6))
+		Expect(event.MaxFailures).To(BeEquivalentTo(5))
+		Expect(errors.Is(r.err(), ErrFailureSpike)).To(BeTrue())
+	})
+})
@@ -0,0 +1,83 @@
+package boomer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrFaultInjected is wrapped by the errors returned by BoomerTransport for injected faults.
+var ErrFaultInjected = errors.New("boomer: fault injected")
+
+type faultKind int
+
+const (
+	dropConnectionFault faultKind = iota
+	slowResponseFault
+	emptyResponseFault
+	invalidJSONFault
+)
+
+// FaultType is a client-side failure injected by BoomerTransport, see BoomerTransport.WithFaultInjection.
+type FaultType struct {
+	kind  faultKind
+	delay time.Duration
+}
+
+var (
+	// DropConnectionFault fails the request without sending it, as if the connection is dropped.
+	DropConnectionFault = FaultType{kind: dropConnectionFault}
+	// EmptyResponseFault replaces the response body with an empty one.
+	EmptyResponseFault = FaultType{kind: emptyResponseFault}
+	// InvalidJSONFault replaces the response body with a truncated JSON document.
+	InvalidJSONFault = FaultType{kind: invalidJSONFault}
+)
+
+// SlowResponseFault delays the request by delay before it's sent.
+func SlowResponseFault(delay time.Duration) FaultType {
+	return FaultType{kind: slowResponseFault, delay: delay}
+}
+
+const invalidJSONBody = `{"injected": "invalid json`
+
+// faultInjection injects fault into a fraction of requests, which is rate.
+type faultInjection struct {
+	rate  float64
+	fault FaultType
+}
+
+// inject sends the request with inner, and injects the fault into the request or the response.
+func (f *faultInjection) inject(inner http.RoundTripper, req *http.Request) (*http.Response, error) {
+	switch f.fault.kind {
+	case dropConnectionFault:
+		return nil, fmt.Errorf("%w, connection dropped", ErrFaultInjected)
+	case slowResponseFault:
+		timer := time.NewTimer(f.fault.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		return inner.RoundTrip(req)
+	}
+
+	resp, err := inner.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	body := ""
+	if f.fault.kind == invalidJSONFault {
+		body = invalidJSONBody
+		resp.Header.Set("Content-Type", "application/json")
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(strings.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
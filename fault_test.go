@@ -0,0 +1,114 @@
+package boomer
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test fault injection", func() {
+
+	var inner http.RoundTripper
+	var sent int
+
+	BeforeEach(func() {
+		sent = 0
+		inner = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			sent++
+			body := `{"status": "ok"}`
+			return &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{"Content-Type": []string{"application/json"}},
+				Body:          io.NopCloser(strings.NewReader(body)),
+				ContentLength: int64(len(body)),
+				Request:       req,
+			}, nil
+		})
+	})
+
+	newTransport := func() *BoomerTransport {
+		b := NewStandaloneBoomer(1, 1)
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+		go func() {
+			for {
+				select {
+				case <-b.localRunner.stats.requestSuccessChan:
+				case <-b.localRunner.stats.requestFailureChan:
+				case <-b.localRunner.shutdownChan:
+					return
+				}
+			}
+		}()
+		DeferCleanup(b.localRunner.shutdown)
+		return NewBoomerTransport().WithBoomer(b).WithDefaultHTTPClient(&http.Client{Transport: inner})
+	}
+
+	It("test drop connection rate", func() {
+		client := newTransport().WithFaultInjection(0.3, DropConnectionFault).Client()
+		total, dropped := 2000, 0
+		for i := 0; i < total; i++ {
+			resp, err := client.Get("http://localhost/hello")
+			if err != nil {
+				Expect(errors.Is(err, ErrFaultInjected)).To(BeTrue())
+				dropped++
+				continue
+			}
+			resp.Body.Close()
+		}
+		Expect(float64(dropped) / float64(total)).To(BeNumerically("~", 0.3, 0.05))
+		Expect(sent).To(Equal(total - dropped))
+	})
+
+	It("test slow response", func() {
+		client := newTransport().WithFaultInjection(1, SlowResponseFault(50*time.Millisecond)).Client()
+		start := time.Now()
+		resp, err := client.Get("http://localhost/hello")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+		Expect(sent).To(Equal(1))
+	})
+
+	It("test empty response", func() {
+		client := newTransport().WithFaultInjection(1, EmptyResponseFault).Client()
+		resp, err := client.Get("http://localhost/hello")
+		Expect(err).NotTo(HaveOccurred())
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(BeEmpty())
+		Expect(resp.ContentLength).To(BeZero())
+	})
+
+	It("test invalid json", func() {
+		client := newTransport().WithFaultInjection(1, InvalidJSONFault).Client()
+		resp, err := client.Get("http://localhost/hello")
+		Expect(err).NotTo(HaveOccurred())
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		var v interface{}
+		Expect(json.Unmarshal(body, &v)).NotTo(Succeed())
+		Expect(resp.ContentLength).To(BeEquivalentTo(len(body)))
+	})
+
+	It("test endpoint fault injection", func() {
+		client := newTransport().
+			WithFaultInjection(0, DropConnectionFault).
+			WithEndpointFaultInjection("POST", "/pay", 1, DropConnectionFault).Client()
+
+		resp, err := client.Get("http://localhost/pay")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		resp, err = client.Post("http://localhost/hello", "application/json", nil)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		_, err = client.Post("http://localhost/pay", "application/json", nil)
+		Expect(err).To(MatchError(ErrFaultInjected))
+		Expect(sent).To(Equal(2))
+	})
+})
@@ -0,0 +1,168 @@
+package boomer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// GRPCStreamWaitTimeMetric is the custom metric of the time in milliseconds, which the calls wait for a stream
+// of their connection, see BoomerGRPCInterceptor.WithGRPCMaxConcurrentStreams.
+const GRPCStreamWaitTimeMetric = "boomer.grpc.stream_wait_time_ms"
+
+// BoomerGRPCInterceptor records the result of every unary gRPC call to boomer.
+// The request type is "grpc" and the name is the full method, like "/helloworld.Greeter/SayHello".
+// Calls returning an error are recorded as failures.
+//
+// Use UnaryClientInterceptor with grpc.WithUnaryInterceptor, or Dial to get a pool of connections,
+// which limits the concurrent streams of each connection.
+type BoomerGRPCInterceptor struct {
+	boomer *Boomer
+
+	// the number of connections created by Dial, and the concurrent calls allowed on each of them, zero means no limit
+	connectionCount      int
+	maxConcurrentStreams uint32
+}
+
+// NewBoomerGRPCInterceptor returns a BoomerGRPCInterceptor, which records the results to the defaultBoomer.
+func NewBoomerGRPCInterceptor() *BoomerGRPCInterceptor {
+	return &BoomerGRPCInterceptor{connectionCount: 1}
+}
+
+// WithBoomer records the results to b instead of the defaultBoomer.
+// If b is nil, it will not take effect.
+func (i *BoomerGRPCInterceptor) WithBoomer(b *Boomer) *BoomerGRPCInterceptor {
+	if b != nil {
+		i.boomer = b
+	}
+	return i
+}
+
+// WithGRPCMaxConcurrentStreams limits the unary calls running at the same time on each connection created by Dial,
+// usually to the MaxConcurrentStreams of the server. Otherwise, the calls exceeding the limit of the server
+// are queued in the gRPC client, and the time they are queued is counted in the response time.
+// The time waiting for a stream is recorded as the custom metric GRPCStreamWaitTimeMetric instead.
+// Zero means no limit, which is the default.
+func (i *BoomerGRPCInterceptor) WithGRPCMaxConcurrentStreams(n uint32) *BoomerGRPCInterceptor {
+	i.maxConcurrentStreams = n
+	return i
+}
+
+// WithGRPCConnectionCount sets the number of connections created by Dial, 1 by default.
+// The calls are sent on the connections in turn. If n isn't positive, it will not take effect.
+func (i *BoomerGRPCInterceptor) WithGRPCConnectionCount(n int) *BoomerGRPCInterceptor {
+	if n > 0 {
+		i.connectionCount = n
+	}
+	return i
+}
+
+func (i *BoomerGRPCInterceptor) getBoomer() *Boomer {
+	if i.boomer != nil {
+		return i.boomer
+	}
+	return defaultBoomer
+}
+
+// UnaryClientInterceptor returns a grpc.UnaryClientInterceptor, which records the unary calls to boomer.
+func (i *BoomerGRPCInterceptor) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		TaskSetupDone(ctx)
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		elapsed := time.Since(start).Milliseconds()
+		if TaskTimedOut(ctx) {
+			// the task is recorded as timed out already
+			return err
+		}
+		b := i.getBoomer()
+		if err != nil {
+			b.RecordFailure("grpc", method, elapsed, err.Error())
+			return err
+		}
+		size := 0
+		if m, ok := reply.(proto.Message); ok {
+			size = proto.Size(m)
+		}
+		b.RecordSuccess("grpc", method, elapsed, int64(size))
+		return nil
+	}
+}
+
+// Dial creates the connections to target with opts and the interceptor, see WithGRPCConnectionCount.
+// The returned GRPCConnPool can be passed to the generated clients, like pb.NewGreeterClient(pool).
+func (i *BoomerGRPCInterceptor) Dial(target string, opts ...grpc.DialOption) (*GRPCConnPool, error) {
+	opts = append(opts, grpc.WithChainUnaryInterceptor(i.UnaryClientInterceptor()))
+	pool := &GRPCConnPool{interceptor: i}
+	for n := 0; n < i.connectionCount; n++ {
+		conn, err := grpc.Dial(target, opts...)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		pc := &pooledConn{ClientConn: conn}
+		if i.maxConcurrentStreams > 0 {
+			pc.streams = make(chan struct{}, i.maxConcurrentStreams)
+		}
+		pool.conns = append(pool.conns, pc)
+	}
+	return pool, nil
+}
+
+// GRPCConnPool is a pool of gRPC connections created by BoomerGRPCInterceptor.Dial.
+// It implements grpc.ClientConnInterface, and sends every call on the next connection.
+// Streaming calls are not limited by WithGRPCMaxConcurrentStreams, and not recorded to boomer.
+type GRPCConnPool struct {
+	interceptor *BoomerGRPCInterceptor
+	conns       []*pooledConn
+	next        uint32
+}
+
+type pooledConn struct {
+	*grpc.ClientConn
+	streams chan struct{} // the semaphore of the concurrent calls, nil means no limit
+}
+
+var _ grpc.ClientConnInterface = (*GRPCConnPool)(nil)
+
+func (p *GRPCConnPool) pick() *pooledConn {
+	n := atomic.AddUint32(&p.next, 1)
+	return p.conns[(n-1)%uint32(len(p.conns))]
+}
+
+// Invoke sends a unary call on the next connection, after waiting for a stream of the connection.
+func (p *GRPCConnPool) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	conn := p.pick()
+	if conn.streams != nil {
+		start := time.Now()
+		select {
+		case conn.streams <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		defer func() { <-conn.streams }()
+		p.interceptor.getBoomer().RecordCustomMetric(GRPCStreamWaitTimeMetric, float64(time.Since(start))/float64(time.Millisecond))
+	}
+	return conn.Invoke(ctx, method, args, reply, opts...)
+}
+
+// NewStream creates a stream on the next connection.
+func (p *GRPCConnPool) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return p.pick().NewStream(ctx, desc, method, opts...)
+}
+
+// Close closes all the connections.
+func (p *GRPCConnPool) Close() error {
+	var errs []error
+	for _, conn := range p.conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close connection to %s: %w", conn.Target(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
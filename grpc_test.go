@@ -0,0 +1,128 @@
+package boomer
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var _ = Describe("Test gRPC interceptor", func() {
+
+	var server *grpc.Server
+	var listener net.Listener
+	var lock sync.Mutex
+	var active, maxActive int
+
+	BeforeEach(func() {
+		active, maxActive = 0, 0
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		server = grpc.NewServer(grpc.MaxConcurrentStreams(10), grpc.UnaryInterceptor(
+			func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				lock.Lock()
+				active++
+				if active > maxActive {
+					maxActive = active
+				}
+				lock.Unlock()
+				defer func() {
+					lock.Lock()
+					active--
+					lock.Unlock()
+				}()
+				time.Sleep(20 * time.Millisecond)
+				return handler(ctx, req)
+			}))
+		healthpb.RegisterHealthServer(server, health.NewServer())
+		go server.Serve(listener)
+	})
+
+	AfterEach(func() {
+		server.Stop()
+	})
+
+	newBoomer := func() *Boomer {
+		b := NewStandaloneBoomer(1, 1)
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+		return b
+	}
+
+	It("test record success and failure", func() {
+		b := newBoomer()
+		conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(NewBoomerGRPCInterceptor().WithBoomer(b).UnaryClientInterceptor()))
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+		client := healthpb.NewHealthClient(conn)
+
+		_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		var requestSuccessMsg *requestSuccess
+		Expect(b.localRunner.stats.requestSuccessChan).Should(Receive(&requestSuccessMsg))
+		Expect(requestSuccessMsg.requestType).To(Equal("grpc"))
+		Expect(requestSuccessMsg.name).To(Equal("/grpc.health.v1.Health/Check"))
+		Expect(requestSuccessMsg.responseTime).To(BeNumerically(">=", 20))
+		Expect(requestSuccessMsg.responseLength).To(BeEquivalentTo(2))
+
+		_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+		Expect(err).To(HaveOccurred())
+		var requestFailureMsg *requestFailure
+		Expect(b.localRunner.stats.requestFailureChan).Should(Receive(&requestFailureMsg))
+		Expect(requestFailureMsg.name).To(Equal("/grpc.health.v1.Health/Check"))
+		Expect(requestFailureMsg.error).To(ContainSubstring("code = NotFound"))
+	})
+
+	It("test max concurrent streams and connection pool", func() {
+		b := newBoomer()
+		interceptor := NewBoomerGRPCInterceptor().WithBoomer(b).WithGRPCMaxConcurrentStreams(10).
+			WithGRPCConnectionCount(0).WithGRPCConnectionCount(2)
+		pool, err := interceptor.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).NotTo(HaveOccurred())
+		defer pool.Close()
+		Expect(pool.conns).To(HaveLen(2))
+		client := healthpb.NewHealthClient(pool)
+
+		// 100 callers, at most 2 connections * 10 streams are sent at the same time
+		var wg sync.WaitGroup
+		errs := make(chan error, 100)
+		for n := 0; n < 100; n++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+					errs <- err
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		Expect(errs).To(BeEmpty())
+		Expect(maxActive).To(BeNumerically("<=", 20))
+		Expect(b.localRunner.stats.requestSuccessChan).To(HaveLen(100))
+		Expect(b.localRunner.stats.requestFailureChan).To(BeEmpty())
+
+		// the stream wait time is measured for every call, and isn't counted in the response time
+		Expect(b.localRunner.stats.customMetricChan).To(HaveLen(100))
+		maxWait := float64(0)
+		for n := 0; n < 100; n++ {
+			metric := <-b.localRunner.stats.customMetricChan
+			Expect(metric.name).To(Equal(GRPCStreamWaitTimeMetric))
+			if metric.value > maxWait {
+				maxWait = metric.value
+			}
+		}
+		Expect(maxWait).To(BeNumerically(">=", 20))
+		for n := 0; n < 100; n++ {
+			Expect((<-b.localRunner.stats.requestSuccessChan).responseTime).To(BeNumerically("<", 60))
+		}
+	})
+})
@@ -0,0 +1,52 @@
+package boomer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrIdleTimeout is wrapped by the error returned by Boomer.Err if the test is stopped by Boomer.WithStopOnIdle.
+var ErrIdleTimeout = errors.New("boomer: idle timeout")
+
+// IdleTimeoutEvent is published with EVENT_IDLE_TIMEOUT when no requests are recorded for longer than
+// Boomer.WithMaxIdleTime.
+type IdleTimeoutEvent struct {
+	Idle        time.Duration
+	MaxIdleTime time.Duration
+	Time        time.Time
+}
+
+// checkIdle is called with the report data of every interval, and times out if no requests are recorded
+// in maxIdleTime. It's called by the goroutine which reports the stats.
+func (r *runner) checkIdle(data map[string]interface{}) {
+	if r.maxIdleTime <= 0 {
+		return
+	}
+	now := time.Now()
+	var requests int64
+	if total, ok := data["stats_total"].(map[string]interface{}); ok {
+		requests, _ = castToInt64(total["num_requests"])
+	}
+	if requests > 0 || r.lastActivity.IsZero() {
+		r.lastActivity = now
+		r.idleTimedOut = false
+		return
+	}
+	idle := now.Sub(r.lastActivity)
+	// the timeout fires once, until the requests are recorded again
+	if idle < r.maxIdleTime || r.idleTimedOut {
+		return
+	}
+	r.idleTimedOut = true
+	event := IdleTimeoutEvent{
+		Idle:        idle,
+		MaxIdleTime: r.maxIdleTime,
+		Time:        now,
+	}
+	r.logger.Printf("No requests are recorded in %v, the tasks may be stuck\n", idle.Round(time.Millisecond))
+	Events.Publish(EVENT_IDLE_TIMEOUT, event)
+	if r.stopOnIdle {
+		r.abortWithError(fmt.Errorf("%w, no requests in %v", ErrIdleTimeout, idle.Round(time.Millisecond)))
+	}
+}
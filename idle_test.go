@@ -0,0 +1,86 @@
+package boomer
+
+import (
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Test idle timeout", func() {
+
+	newData := func(requests int64) map[string]interface{} {
+		return map[string]interface{}{
+			"stats_total": map[string]interface{}{"num_requests": requests},
+		}
+	}
+
+	It("test check idle", func() {
+		timeouts := make(chan IdleTimeoutEvent, 10)
+		handler := func(event IdleTimeoutEvent) {
+			timeouts <- event
+		}
+		Events.Subscribe(EVENT_IDLE_TIMEOUT, handler)
+		defer Events.Unsubscribe(EVENT_IDLE_TIMEOUT, handler)
+
+		buf := gbytes.NewBuffer()
+		r := newLocalRunner(nil, nil, 1, 1)
+		r.setLogger(log.New(buf, "", 0))
+		NewStandaloneBoomer(1, 1).WithMaxIdleTime(time.Second).setupRunner(&r.runner)
+		r.abort = func() {}
+
+		r.checkIdle(newData(0))
+		Expect(r.lastActivity).NotTo(BeZero())
+		r.lastActivity = time.Now().Add(-2 * time.Second)
+		r.checkIdle(newData(0))
+		var event IdleTimeoutEvent
+		Expect(timeouts).To(Receive(&event))
+		Expect(event.MaxIdleTime).To(Equal(time.Second))
+		Expect(event.Idle).To(BeNumerically(">=", 2*time.Second))
+		Expect(buf).To(gbytes.Say(`No requests are recorded in 2(\.\d+)?s, the tasks may be stuck`))
+		// the test isn't stopped without WithStopOnIdle
+		Expect(r.err()).NotTo(HaveOccurred())
+
+		// fires once, until the requests resume
+		r.checkIdle(newData(0))
+		Expect(timeouts).NotTo(Receive())
+		r.checkIdle(newData(1))
+		r.lastActivity = time.Now().Add(-2 * time.Second)
+		r.checkIdle(newData(0))
+		Expect(timeouts).To(Receive())
+	})
+
+	It("test stop on idle", func() {
+		timeouts := make(chan IdleTimeoutEvent, 1)
+		handler := func(event IdleTimeoutEvent) {
+			timeouts <- event
+		}
+		Events.Subscribe(EVENT_IDLE_TIMEOUT, handler)
+		defer Events.Unsubscribe(EVENT_IDLE_TIMEOUT, handler)
+
+		b := NewStandaloneBoomer(1, 1).WithMaxIdleTime(300 * time.Millisecond).WithStopOnIdle(true).
+			WithRunTime(5 * time.Second).WithLogger(log.New(io.Discard, "", 0))
+		b.WithStatsSnapshotInterval(100 * time.Millisecond)
+		// the task hangs without recording any requests
+		hang := make(chan struct{})
+		defer close(hang)
+		start := time.Now()
+		b.Run(&Task{
+			Name: "hung",
+			Fn: func() {
+				<-hang
+			},
+		})
+
+		var event IdleTimeoutEvent
+		Expect(timeouts).To(Receive(&event))
+		Expect(event.Idle).To(BeNumerically(">=", 300*time.Millisecond))
+		Expect(event.Time.Sub(start)).To(BeNumerically("<", 300*time.Millisecond+100*time.Millisecond+50*time.Millisecond))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Expect(errors.Is(b.Err(), ErrIdleTimeout)).To(BeTrue())
+	})
+})
@@ -3,11 +3,11 @@
 package boomer
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"math"
-	"math/rand"
 	"reflect"
 	"sync"
 	"time"
@@ -161,9 +161,18 @@ func (ts *WeighingTaskSet) GetWeight() (weight int) {
 // Run will pick up a task in the task set randomly and run.
 // It can is used as a Task.Fn.
 func (ts *WeighingTaskSet) Run() {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	roll := r.Intn(ts.offset)
+	ts.RunWithContext(context.Background())
+}
+
+// RunWithContext is like Run, but picks the task with the random source of the runner carried by ctx,
+// which is seeded by Boomer.WithRandomSeed. It can be used as a Task.FnWithContext.
+func (ts *WeighingTaskSet) RunWithContext(ctx context.Context) {
+	roll := randomFromContext(ctx).Intn(ts.offset)
 	task := ts.GetTask(roll)
+	if task.FnWithContext != nil {
+		task.FnWithContext(ctx)
+		return
+	}
 	task.Fn()
 }
 
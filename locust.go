@@ -0,0 +1,45 @@
+package boomer
+
+// LocustWorker is a Boomer running in distributed mode, which works as a worker of a locust master.
+//
+// The messages of the locust protocol supported by the worker:
+//
+//	sent to master        client_ready, spawning, spawning_complete, stats, heartbeat, exception,
+//	                      client_stopped, quit, and the custom messages sent by SendCustomMessage
+//	received from master  ack, spawn, stop, quit, heartbeat, and the custom messages published to Events
+//
+// The worker is developed against locust 2.x. Locust 2.10 and later acknowledge client_ready with ack,
+// earlier 2.x versions don't, and the worker logs a warning and goes on. The users of a locust 2.x spawn message
+// are the sum of user_classes_count, which is sent back in spawning_complete, and the users are spawned at once,
+// because the master sends a spawn message for every step of the ramp up.
+// Locust 1.x sends num_users and spawn_rate instead, the worker spawns num_users at spawn_rate by itself.
+// The hatch messages of locust before 1.0 aren't supported. Later versions of locust are expected to work
+// as long as they keep the message format of 2.x.
+//
+// The exception message is sent when a task panics, with the panic as msg and the stack trace as traceback,
+// so the panic is listed in the Exceptions tab of the web UI.
+type LocustWorker struct {
+	*Boomer
+}
+
+// NewLocustCompatibleWorker returns a LocustWorker, which connects to the locust master at masterHost:masterPort.
+func NewLocustCompatibleWorker(masterHost string, masterPort int) *LocustWorker {
+	return &LocustWorker{Boomer: NewBoomer(masterHost, masterPort)}
+}
+
+// SetClientID sets the identity of the worker, which must be unique among the workers of the master.
+// By default, it's the hostname followed by a random UUID, like locust generates.
+// It must be set before Run, and an empty id is ignored.
+func (w *LocustWorker) SetClientID(id string) {
+	if id != "" {
+		w.clientID = id
+	}
+}
+
+// ClientID returns the identity set by SetClientID, or the generated one after Run.
+func (w *LocustWorker) ClientID() string {
+	if w.slaveRunner != nil {
+		return w.slaveRunner.nodeID
+	}
+	return w.clientID
+}
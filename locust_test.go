@@ -0,0 +1,160 @@
+package boomer
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/myzhan/gomq/zmtp"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// receiveFromWorker drops the messages sent to the mock master until one of msgType is received from nodeID.
+// The mock master is shared by the tests, so messages of the other workers may be left in the channel.
+func receiveFromWorker(msgType, nodeID string) *genericMessage {
+	var received *genericMessage
+	Eventually(func() string {
+		select {
+		case raw := <-MockGomqDealerInstance.SendChannel():
+			if msg, err := newGenericMessageFromBytes(raw); err == nil && msg.NodeID == nodeID {
+				received = msg
+				return msg.Type
+			}
+			if msg, err := newClientReadyMessageFromBytes(raw); err == nil && msg.NodeID == nodeID {
+				received = newGenericMessage(msg.Type, nil, msg.NodeID)
+				return msg.Type
+			}
+		default:
+		}
+		return ""
+	}, 5*time.Second, time.Millisecond).Should(Equal(msgType))
+	return received
+}
+
+func sendToWorker(msg *genericMessage) {
+	raw, err := msg.serialize()
+	Expect(err).NotTo(HaveOccurred())
+	MockGomqDealerInstance.RecvChannel() <- &zmtp.Message{
+		MessageType: zmtp.UserMessage,
+		Body:        [][]byte{raw},
+	}
+}
+
+var _ = Describe("Test locust worker", func() {
+
+	It("test client id", func() {
+		w := NewLocustCompatibleWorker("localhost", 5557)
+		Expect(w.mode).To(Equal(DistributedMode))
+		Expect(w.ClientID()).To(BeEmpty())
+		w.SetClientID("worker-1")
+		w.SetClientID("")
+		Expect(w.ClientID()).To(Equal("worker-1"))
+	})
+
+	It("test spawn message of locust 1.x", func() {
+		runner := newSlaveRunner("localhost", 5557, []*Task{{Fn: func() { time.Sleep(time.Second) }}}, nil)
+		runner.client = newClient("localhost", 5557, runner.nodeID)
+		defer runner.shutdown()
+
+		Expect(runner.sumUsersAmount(newGenericMessage("spawn", map[string]interface{}{
+			"num_users":  int64(3),
+			"spawn_rate": float64(1),
+		}, runner.nodeID))).To(Equal(3))
+		Expect(runner.userClassesCountFromMaster).To(BeNil())
+		Expect(runner.sumUsersAmount(newGenericMessage("spawn", map[string]interface{}{}, runner.nodeID))).To(BeZero())
+		Expect(runner.sumUsersAmount(newGenericMessage("spawn", map[string]interface{}{
+			"user_classes_count": "Dummy",
+		}, runner.nodeID))).To(BeZero())
+	})
+
+	It("test exception message", func() {
+		runner := newSlaveRunner("localhost", 5557, nil, nil)
+		runner.client = newClient("localhost", 5557, runner.nodeID)
+		defer runner.shutdown()
+
+		runner.safeRun(func() {
+			panic("something is wrong")
+		})
+		var msg message
+		Eventually(runner.client.sendChannel()).Should(Receive(&msg))
+		m := msg.(*genericMessage)
+		Expect(m.Type).To(Equal("exception"))
+		Expect(m.NodeID).To(Equal(runner.nodeID))
+		Expect(m.Data["msg"]).To(Equal("something is wrong"))
+		Expect(m.Data["traceback"]).To(ContainSubstring("locust_test.go"))
+	})
+
+	It("test worker with mock master", func() {
+		w := NewLocustCompatibleWorker("mock:0.0.0.0", 10241)
+		w.SetClientID("worker-1")
+		w.Run(&Task{
+			Name: "foo",
+			Fn: func() {
+				time.Sleep(10 * time.Millisecond)
+			},
+		})
+		defer w.Quit()
+
+		receiveFromWorker("client_ready", "worker-1")
+		sendToWorker(newGenericMessage("ack", nil, "worker-1"))
+
+		start := time.Now()
+		sendToWorker(newGenericMessage("spawn", map[string]interface{}{
+			"user_classes_count": map[string]interface{}{
+				"Dummy":  int64(1),
+				"Dummy2": int64(2),
+			},
+			"spawn_rate": int64(2),
+			"timestamp":  int64(1),
+		}, "worker-1"))
+
+		receiveFromWorker("spawning", "worker-1")
+		complete := receiveFromWorker("spawning_complete", "worker-1")
+		// the spawning isn't rate limited without WithSpawnRateLimit, like the workers of locust 2.x
+		Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+		Expect(complete.Data["count"]).To(BeEquivalentTo(3))
+		Expect(complete.Data["user_classes_count"]).To(HaveLen(2))
+		Expect(w.slaveRunner.getSpawnRate()).To(BeEquivalentTo(2))
+		Expect(w.slaveRunner.getSpawnLimiter()).To(BeNil())
+
+		sendToWorker(newGenericMessage("stop", nil, "worker-1"))
+		receiveFromWorker("client_stopped", "worker-1")
+		receiveFromWorker("client_ready", "worker-1")
+	})
+
+	It("test worker handles messages while spawning with spawn rate limit", func() {
+		w := NewLocustCompatibleWorker("mock:0.0.0.0", 10242)
+		w.SetClientID("worker-2")
+		w.WithSpawnRateLimit(1)
+		w.Run(&Task{
+			Name: "foo",
+			Fn: func() {
+				time.Sleep(10 * time.Millisecond)
+			},
+		})
+		defer w.Quit()
+
+		receiveFromWorker("client_ready", "worker-2")
+		sendToWorker(newGenericMessage("ack", nil, "worker-2"))
+
+		sendToWorker(newGenericMessage("spawn", map[string]interface{}{
+			"user_classes_count": map[string]interface{}{"Dummy": int64(10)},
+			"spawn_rate":         int64(2),
+			"timestamp":          int64(1),
+		}, "worker-2"))
+		receiveFromWorker("spawning", "worker-2")
+		Eventually(func() int32 {
+			return atomic.LoadInt32(&w.slaveRunner.numClients)
+		}).Should(BeNumerically(">=", 2))
+		Expect(w.slaveRunner.getSpawnLimiter().Limit()).To(BeEquivalentTo(2))
+
+		// the stop message is handled during the ramp up, and cancels the users not spawned yet
+		start := time.Now()
+		sendToWorker(newGenericMessage("stop", nil, "worker-2"))
+		receiveFromWorker("client_stopped", "worker-2")
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Consistently(func() int32 {
+			return atomic.LoadInt32(&w.slaveRunner.numClients)
+		}, 1500*time.Millisecond).Should(BeZero())
+	})
+})
@@ -0,0 +1,87 @@
+package boomer
+
+// AggregationStrategy decides how the values of a custom metric are aggregated.
+type AggregationStrategy int
+
+const (
+	// AverageAggregation reports the average of all the values, it's the default strategy.
+	AverageAggregation AggregationStrategy = iota
+	// SumAggregation reports the sum of all the values, it fits rates like cache hits per second.
+	SumAggregation
+	// LastAggregation reports the most recent value, it fits gauges.
+	LastAggregation
+	// MaxAggregation reports the max value.
+	MaxAggregation
+	// MinAggregation reports the min value.
+	MinAggregation
+)
+
+// CustomMetricEntry holds the aggregated values of a custom metric.
+type CustomMetricEntry struct {
+	Name  string  `json:"name"`
+	Count int64   `json:"count"`
+	Sum   float64 `json:"sum"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Last  float64 `json:"last"`
+	// Value is reported by the outputs, it's calculated by the aggregation strategy.
+	Value float64 `json:"value"`
+	// Strategy is used to calculate Value, it's used again when the entries are merged by MergeReports.
+	Strategy AggregationStrategy `json:"strategy"`
+}
+
+type customMetric struct {
+	name  string
+	value float64
+}
+
+func newCustomMetricEntry(name string, value float64) *CustomMetricEntry {
+	return &CustomMetricEntry{
+		Name:  name,
+		Count: 1,
+		Sum:   value,
+		Min:   value,
+		Max:   value,
+		Last:  value,
+		Value: value,
+	}
+}
+
+// aggregate merges current into prev, which is nil for the first value.
+// current is the newer one, it can be a single value, or the values of a report interval.
+func (strategy AggregationStrategy) aggregate(prev, current *CustomMetricEntry) *CustomMetricEntry {
+	if prev == nil {
+		prev = &CustomMetricEntry{Name: current.Name, Min: current.Min, Max: current.Max}
+	}
+	merged := &CustomMetricEntry{
+		Name:     current.Name,
+		Count:    prev.Count + current.Count,
+		Sum:      prev.Sum + current.Sum,
+		Min:      prev.Min,
+		Max:      prev.Max,
+		Last:     current.Last,
+		Strategy: strategy,
+	}
+	if current.Min < merged.Min {
+		merged.Min = current.Min
+	}
+	if current.Max > merged.Max {
+		merged.Max = current.Max
+	}
+
+	switch strategy {
+	case SumAggregation:
+		merged.Value = merged.Sum
+	case LastAggregation:
+		merged.Value = merged.Last
+	case MaxAggregation:
+		merged.Value = merged.Max
+	case MinAggregation:
+		merged.Value = merged.Min
+	default:
+		if merged.Count > 0 {
+			merged.Value = merged.Sum / float64(merged.Count)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,82 @@
+package boomer
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test custom metrics", func() {
+
+	aggregate := func(strategy AggregationStrategy, values ...float64) *CustomMetricEntry {
+		var entry *CustomMetricEntry
+		for _, value := range values {
+			entry = strategy.aggregate(entry, newCustomMetricEntry("metric", value))
+		}
+		return entry
+	}
+
+	It("test aggregation strategies", func() {
+		Expect(aggregate(AverageAggregation, 1, 2, 6).Value).To(Equal(float64(3)))
+		Expect(aggregate(SumAggregation, 1, 2, 6).Value).To(Equal(float64(9)))
+		Expect(aggregate(LastAggregation, 1, 6, 2).Value).To(Equal(float64(2)))
+		Expect(aggregate(MaxAggregation, 1, 6, 2).Value).To(Equal(float64(6)))
+		Expect(aggregate(MinAggregation, 3, 1, 2).Value).To(Equal(float64(1)))
+
+		entry := aggregate(AverageAggregation, 3, -1, 2)
+		Expect(entry.Count).To(BeEquivalentTo(3))
+		Expect(entry.Min).To(Equal(float64(-1)))
+		Expect(entry.Max).To(Equal(float64(3)))
+		Expect(entry.Last).To(Equal(float64(2)))
+	})
+
+	It("test aggregation across report intervals", func() {
+		stats := newRequestStats()
+		stats.customMetricAggregators = map[string]func(prev, current *CustomMetricEntry) *CustomMetricEntry{
+			"cache_hits":  SumAggregation.aggregate,
+			"queue_depth": LastAggregation.aggregate,
+		}
+
+		stats.logCustomMetric("cache_hits", 10)
+		stats.logCustomMetric("cache_hits", 5)
+		stats.logCustomMetric("queue_depth", 7)
+		stats.logCustomMetric("queue_depth", 3)
+		metrics := stats.collectReportData()["custom_metrics"].(map[string]*CustomMetricEntry)
+		Expect(metrics["cache_hits"].Value).To(Equal(float64(15)))
+		Expect(metrics["queue_depth"].Value).To(Equal(float64(3)))
+
+		stats.logCustomMetric("cache_hits", 20)
+		stats.logCustomMetric("queue_depth", 4)
+		metrics = stats.collectReportData()["custom_metrics"].(map[string]*CustomMetricEntry)
+		Expect(metrics["cache_hits"].Value).To(Equal(float64(35)))
+		Expect(metrics["queue_depth"].Value).To(Equal(float64(4)))
+
+		// reported until cleared, even if there are no values in the interval
+		Expect(stats.collectReportData()).To(HaveKey("custom_metrics"))
+		stats.clearAll()
+		Expect(stats.collectReportData()).NotTo(HaveKey("custom_metrics"))
+	})
+
+	It("test record custom metric", func() {
+		b := NewStandaloneBoomer(1, 1).WithAggregationStrategy("cache_hits", SumAggregation).WithCustomMetricAggregator("foo", nil)
+		Expect(b.customMetricAggregators).To(HaveLen(1))
+
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+		b.setupRunner(&b.localRunner.runner)
+		b.RecordCustomMetric("cache_hits", 1)
+
+		var metric *customMetric
+		Expect(b.localRunner.stats.customMetricChan).To(Receive(&metric))
+		Expect(metric.name).To(Equal("cache_hits"))
+		Expect(metric.value).To(Equal(float64(1)))
+
+		data := map[string]interface{}{
+			"user_count":     int32(1),
+			"stats":          []interface{}{},
+			"stats_total":    b.localRunner.stats.total.serialize(),
+			"custom_metrics": map[string]*CustomMetricEntry{"cache_hits": newCustomMetricEntry("cache_hits", 1)},
+		}
+		output, err := convertData(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.CustomMetrics).To(HaveKey("cache_hits"))
+	})
+})
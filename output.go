@@ -2,12 +2,21 @@ package boomer
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/mattn/go-isatty"
 	"github.com/olekukonko/tablewriter"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/push"
@@ -32,14 +41,110 @@ type Output interface {
 	OnStop()
 }
 
+// ErrorOutput is an Output which reports the errors of OnEvent.
+// If an output implements ErrorOutput, OnEventWithError is called instead of OnEvent,
+// and the test is stopped on the first error if Boomer.WithAbortOnOutputError is enabled.
+type ErrorOutput interface {
+	Output
+
+	// OnEventWithError is the same as OnEvent, but returns the error.
+	OnEventWithError(data map[string]interface{}) error
+}
+
+// fileOutput is implemented by the outputs which write to a file, see Boomer.WithOutputDirectory.
+type fileOutput interface {
+	setOutputDirectory(dir, testName string)
+}
+
+// resolveOutputPath returns the path of a file output in dir. An empty path is replaced with
+// "{testName}_{suffix}", and an absolute path is kept as it is.
+func resolveOutputPath(path, dir, testName, suffix string) string {
+	if path == "" {
+		name := strings.NewReplacer("/", "_", "\\", "_", " ", "_").Replace(testName)
+		path = name + "_" + suffix
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// NamedOutput wraps an output with a name, which is used instead of the type of the output in logs and errors,
+// to tell apart multiple outputs of the same type.
+type NamedOutput struct {
+	Output
+	name string
+}
+
+// WithOutputNameOverride returns a NamedOutput, which wraps output with name. Add it instead of output.
+func WithOutputNameOverride(output Output, name string) *NamedOutput {
+	return &NamedOutput{Output: output, name: name}
+}
+
+// Name returns the name of the output.
+func (o *NamedOutput) Name() string {
+	return o.name
+}
+
+// OnEventWithError calls OnEventWithError of the wrapped output if it implements ErrorOutput, or OnEvent.
+func (o *NamedOutput) OnEventWithError(data map[string]interface{}) error {
+	if eo, ok := o.Output.(ErrorOutput); ok {
+		return eo.OnEventWithError(data)
+	}
+	o.Output.OnEvent(data)
+	return nil
+}
+
+// outputName returns the name of a NamedOutput, or the type of other outputs.
+func outputName(o Output) string {
+	if named, ok := o.(interface{ Name() string }); ok && named.Name() != "" {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", o)
+}
+
 // ConsoleOutput is the default output for standalone mode.
 type ConsoleOutput struct {
-	logger *log.Logger
+	statsConverter
+
+	logger            *log.Logger
+	writer            io.Writer // if set, output is written to writer directly instead of logger
+	correlationColumn bool
+	// the optional columns set by WithExtraColumns
+	stdDevColumn bool
+	iqrColumn    bool
+	// the percentile columns are shown unless hidePercentileColumns is set, see WithPercentileColumns
+	hidePercentileColumns bool
+	summaryLines          func(*dataOutput) []string
+	errorTableLimit       int // the max number of rows in the errors table
+	// the rows are colored by the failure ratio and the median response time, if color is nil,
+	// it's enabled if the output is a terminal
+	color           *bool
+	slowThresholdMs int64
 }
 
+// the median response time cell of ConsoleOutput is yellow above 500ms, and red above 1000ms by default,
+// see ConsoleOutput.WithSlowThresholdMs.
+const defaultSlowThresholdMs = 500
+
+// ANSI escape codes of the colors of ConsoleOutput.
+const (
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiReset  = "\033[0m"
+)
+
+// the errors table of ConsoleOutput shows the 10 most frequent errors by default, see ConsoleOutput.WithErrorTableLimit.
+const defaultErrorTableLimit = 10
+
 // NewConsoleOutput returns a ConsoleOutput.
 func NewConsoleOutput() *ConsoleOutput {
-	return &ConsoleOutput{logger: log.Default()}
+	return &ConsoleOutput{
+		logger:          log.Default(),
+		errorTableLimit: defaultErrorTableLimit,
+		slowThresholdMs: defaultSlowThresholdMs,
+	}
 }
 
 // WithLogger allows user to use their own logger.
@@ -47,30 +152,87 @@ func NewConsoleOutput() *ConsoleOutput {
 func (o *ConsoleOutput) WithLogger(logger *log.Logger) *ConsoleOutput {
 	if logger != nil {
 		o.logger = logger
+		o.writer = nil
+	}
+	return o
+}
+
+// WithWriter writes the output to w directly, without the prefix and flags of log.Logger.
+// WithLogger and WithWriter are mutually exclusive, the last call wins.
+// If the writer is nil, it will not take effect.
+func (o *ConsoleOutput) WithWriter(w io.Writer) *ConsoleOutput {
+	if w != nil {
+		o.writer = w
 	}
 	return o
 }
 
+// println writes a line to the writer, or the logger if the writer is not set.
+func (o *ConsoleOutput) println(line string) {
+	if o.writer != nil {
+		fmt.Fprintln(o.writer, line)
+		return
+	}
+	o.logger.Println(line)
+}
+
 func getMedianResponseTime(numRequests int64, responseTimes map[int64]int64) int64 {
-	medianResponseTime := int64(0)
-	if len(responseTimes) != 0 {
-		pos := (numRequests - 1) / 2
-		var sortedKeys []int64
-		for k := range responseTimes {
-			sortedKeys = append(sortedKeys, k)
-		}
-		sort.Slice(sortedKeys, func(i, j int) bool {
-			return sortedKeys[i] < sortedKeys[j]
-		})
-		for _, k := range sortedKeys {
-			if pos < responseTimes[k] {
-				medianResponseTime = k
-				break
-			}
-			pos -= responseTimes[k]
+	return getPercentileResponseTime(500, numRequests, responseTimes)
+}
+
+// getPercentileResponseTime returns the response time at the percentile n in thousandths, e.g. 999 for p99.9,
+// n is in [0, 1000]. It returns 0 if there are no response times.
+func getPercentileResponseTime(n int, numRequests int64, responseTimes map[int64]int64) int64 {
+	return percentileOfSortedResponseTimes(float64(n)/1000, numRequests, responseTimes, sortedResponseTimes(responseTimes))
+}
+
+func sortedResponseTimes(responseTimes map[int64]int64) []int64 {
+	sortedKeys := make([]int64, 0, len(responseTimes))
+	for k := range responseTimes {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Slice(sortedKeys, func(i, j int) bool {
+		return sortedKeys[i] < sortedKeys[j]
+	})
+	return sortedKeys
+}
+
+// percentileOfSortedResponseTimes walks the response times in the order of sortedKeys,
+// so the keys are sorted only once for all the percentiles of an entry.
+func percentileOfSortedResponseTimes(percentile float64, numRequests int64, responseTimes map[int64]int64, sortedKeys []int64) int64 {
+	pos := int64(float64(numRequests-1) * percentile)
+	for _, k := range sortedKeys {
+		if pos < responseTimes[k] {
+			return k
 		}
+		pos -= responseTimes[k]
+	}
+	return 0
+}
+
+// computeStdDev returns the population standard deviation of the response times, around their average.
+// It returns 0 if there are no requests.
+func computeStdDev(numRequests int64, totalResponseTime int64, responseTimes map[int64]int64) float64 {
+	if numRequests == 0 {
+		return 0
+	}
+	avg := getAvgResponseTime(numRequests, totalResponseTime)
+	variance := float64(0)
+	for responseTime, count := range responseTimes {
+		diff := float64(responseTime) - avg
+		variance += diff * diff * float64(count)
 	}
-	return medianResponseTime
+	return math.Sqrt(variance / float64(numRequests))
+}
+
+// computeIQR returns the interquartile range of the response times, which is the 75th minus the 25th percentile.
+func computeIQR(numRequests int64, responseTimes map[int64]int64) int64 {
+	return iqrOfSortedResponseTimes(numRequests, responseTimes, sortedResponseTimes(responseTimes))
+}
+
+func iqrOfSortedResponseTimes(numRequests int64, responseTimes map[int64]int64, sortedKeys []int64) int64 {
+	return percentileOfSortedResponseTimes(0.75, numRequests, responseTimes, sortedKeys) -
+		percentileOfSortedResponseTimes(0.25, numRequests, responseTimes, sortedKeys)
 }
 
 func getAvgResponseTime(numRequests int64, totalResponseTime int64) (avgResponseTime float64) {
@@ -89,22 +251,35 @@ func getAvgContentLength(numRequests int64, totalContentLength int64) (avgConten
 	return avgContentLength
 }
 
-func getCurrentRps(numRequests int64, numReqsPerSecond map[int64]int64) (currentRps int64) {
-	currentRps = int64(0)
-	numReqsPerSecondLength := int64(len(numReqsPerSecond))
-	if numReqsPerSecondLength != 0 {
-		currentRps = numRequests / numReqsPerSecondLength
+// the current rps and fails/sec are averaged over the last 10 seconds by default, see Boomer.WithRPSWindow.
+const defaultRPSWindowSecs = 10
+
+// getWindowedRps returns the average requests per second of the most recent windowSecs seconds in numReqsPerSec,
+// so it follows the current load rather than the average since the stats are reset.
+func getWindowedRps(numReqsPerSec map[int64]int64, windowSecs int64) int64 {
+	if len(numReqsPerSec) == 0 || windowSecs <= 0 {
+		return 0
+	}
+	seconds := make([]int64, 0, len(numReqsPerSec))
+	for k := range numReqsPerSec {
+		seconds = append(seconds, k)
 	}
-	return currentRps
+	sort.Slice(seconds, func(i, j int) bool {
+		return seconds[i] > seconds[j]
+	})
+	if int64(len(seconds)) > windowSecs {
+		seconds = seconds[:windowSecs]
+	}
+	total := int64(0)
+	for _, k := range seconds {
+		total += numReqsPerSec[k]
+	}
+	return total / int64(len(seconds))
 }
 
-func getCurrentFailPerSec(numFailures int64, numFailPerSecond map[int64]int64) (currentFailPerSec int64) {
-	currentFailPerSec = int64(0)
-	numFailPerSecondLength := int64(len(numFailPerSecond))
-	if numFailPerSecondLength != 0 {
-		currentFailPerSec = numFailures / numFailPerSecondLength
-	}
-	return currentFailPerSec
+// getWindowedFailPerSec returns the average failures per second of the most recent windowSecs seconds in numFailPerSec.
+func getWindowedFailPerSec(numFailPerSec map[int64]int64, windowSecs int64) int64 {
+	return getWindowedRps(numFailPerSec, windowSecs)
 }
 
 func getTotalFailRatio(totalRequests, totalFailures int64) (failRatio float64) {
@@ -114,6 +289,118 @@ func getTotalFailRatio(totalRequests, totalFailures int64) (failRatio float64) {
 	return float64(totalFailures) / float64(totalRequests)
 }
 
+// WithCorrelationColumn displays the lag-1 autocorrelation of response times, see Boomer.WithRequestCorrelation.
+func (o *ConsoleOutput) WithCorrelationColumn(enabled bool) *ConsoleOutput {
+	o.correlationColumn = enabled
+	return o
+}
+
+// WithExtraColumns shows the optional columns after the Average column, which are "stddev" for the standard deviation
+// and "iqr" for the interquartile range of the response times. The unknown columns are ignored.
+func (o *ConsoleOutput) WithExtraColumns(columns []string) *ConsoleOutput {
+	o.stdDevColumn = false
+	o.iqrColumn = false
+	for _, column := range columns {
+		switch strings.ToLower(column) {
+		case "stddev":
+			o.stdDevColumn = true
+		case "iqr":
+			o.iqrColumn = true
+		default:
+			o.logger.Printf("Invalid extra column %q, ignored!\n", column)
+		}
+	}
+	return o
+}
+
+// WithPercentileColumns shows the P90, P95, P99 and P999 (99.9th percentile) response time columns, which are shown by default.
+func (o *ConsoleOutput) WithPercentileColumns(enabled bool) *ConsoleOutput {
+	o.hidePercentileColumns = !enabled
+	return o
+}
+
+// WithCustomSummaryLines prints the lines returned by fn below the standard summary line on every event,
+// for domain-specific KPIs computed from the stats or custom metrics. A nil fn removes the lines.
+func (o *ConsoleOutput) WithCustomSummaryLines(fn func(*dataOutput) []string) *ConsoleOutput {
+	o.summaryLines = fn
+	return o
+}
+
+// WithErrorTableLimit shows up to n rows in the errors table, which is printed below the stats table
+// if there are errors, the most frequent errors are shown first. The default is 10, and 0 hides the errors table.
+func (o *ConsoleOutput) WithErrorTableLimit(n int) *ConsoleOutput {
+	if n < 0 {
+		o.logger.Printf("Invalid error table limit %d, ignored!\n", n)
+		return o
+	}
+	o.errorTableLimit = n
+	return o
+}
+
+// WithColor colors the output with ANSI escape codes: the rows with a failure ratio above 1% are red,
+// the rows without failures are green, and the summary line is yellow if the total failure ratio is above 0.5%.
+// The median response time is yellow above the threshold of WithSlowThresholdMs, and red above twice the threshold.
+// By default, the output is colored if it's written to a terminal, and the NO_COLOR environment variable isn't set.
+func (o *ConsoleOutput) WithColor(enabled bool) *ConsoleOutput {
+	o.color = &enabled
+	return o
+}
+
+// WithSlowThresholdMs sets the threshold of the median response time to be colored, see WithColor. The default is 500ms.
+func (o *ConsoleOutput) WithSlowThresholdMs(ms int64) *ConsoleOutput {
+	if ms <= 0 {
+		o.logger.Printf("Invalid slow threshold %dms, ignored!\n", ms)
+		return o
+	}
+	o.slowThresholdMs = ms
+	return o
+}
+
+// colorEnabled returns whether the output written to w is colored.
+func (o *ConsoleOutput) colorEnabled(w io.Writer) bool {
+	if o.color != nil {
+		return *o.color
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
+
+// colorize wraps s with the ANSI color code, the widths of the cells are calculated without the codes by tablewriter.
+func colorize(s, color string) string {
+	if color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// rowColor returns the color of the row of stat, by its failure ratio.
+func rowColor(stat *statsEntryOutput) string {
+	if stat.NumFailures == 0 {
+		return ansiGreen
+	}
+	if stat.NumFailures*100 > stat.NumRequests {
+		return ansiRed
+	}
+	return ""
+}
+
+// medianColor returns the color of the median response time, or the color of the row if it isn't slow.
+func (o *ConsoleOutput) medianColor(stat *statsEntryOutput, rowColor string) string {
+	if o.slowThresholdMs <= 0 {
+		return rowColor
+	}
+	switch {
+	case stat.medianResponseTime > 2*o.slowThresholdMs:
+		return ansiRed
+	case stat.medianResponseTime > o.slowThresholdMs:
+		return ansiYellow
+	}
+	return rowColor
+}
+
 // OnStart of ConsoleOutput has nothing to do.
 func (o *ConsoleOutput) OnStart() {
 
@@ -126,58 +413,224 @@ func (o *ConsoleOutput) OnStop() {
 
 // OnEvent will print to the console.
 func (o *ConsoleOutput) OnEvent(data map[string]interface{}) {
-	output, err := convertData(data)
+	output, err := o.convertData(data)
 	if err != nil {
-		o.logger.Printf("convert data error: %v\n", err)
+		o.println(fmt.Sprintf("convert data error: %v", err))
 		return
 	}
 
+	w := o.writer
+	if w == nil {
+		w = o.logger.Writer()
+	}
+	color := o.colorEnabled(w)
+
+	header := ""
+	if output.TestName != "" {
+		header = fmt.Sprintf("Test: %s, ", output.TestName)
+	}
 	currentTime := time.Now()
-	o.logger.Println(fmt.Sprintf("Current time: %s, Users: %d, Total RPS: %d, Total Fail Ratio: %.1f%%",
-		currentTime.Format("2006/01/02 15:04:05"), output.UserCount, output.TotalRPS, output.TotalFailRatio*100))
-	noPrefixLogger := log.New(o.logger.Writer(), "", 0)
-	table := tablewriter.NewWriter(noPrefixLogger.Writer())
-	table.Header([]string{"Type", "Name", "# requests", "# fails", "Median", "Average", "Min", "Max", "Content Size", "# reqs/sec", "# fails/sec"})
+	summary := header + fmt.Sprintf("Current time: %s, Users: %d, Total RPS: %d, Total Fail Ratio: %.1f%%",
+		currentTime.Format("2006/01/02 15:04:05"), output.UserCount, output.TotalRPS, output.TotalFailRatio*100)
+	if color && output.TotalFailRatio > 0.005 {
+		summary = colorize(summary, ansiYellow)
+	}
+	o.println(summary)
+	if o.summaryLines != nil {
+		for _, line := range o.summaryLines(output) {
+			o.println(line)
+		}
+	}
+	table := tablewriter.NewWriter(w)
+	columns := []string{"Type", "Name", "# requests", "# fails", "Median"}
+	if !o.hidePercentileColumns {
+		columns = append(columns, "P90", "P95", "P99", "P999")
+	}
+	columns = append(columns, "Average")
+	if o.stdDevColumn {
+		columns = append(columns, "StdDev")
+	}
+	if o.iqrColumn {
+		columns = append(columns, "IQR")
+	}
+	columns = append(columns, "Min", "Max", "Content Size", "# reqs/sec", "# fails/sec")
+	if o.correlationColumn {
+		columns = append(columns, "Correlation")
+	}
+	table.Header(columns)
 
 	for _, stat := range output.Stats {
-		row := make([]string, 11)
-		row[0] = stat.Method
-		row[1] = stat.Name
-		row[2] = strconv.FormatInt(stat.NumRequests, 10)
-		row[3] = strconv.FormatInt(stat.NumFailures, 10)
-		row[4] = strconv.FormatInt(stat.medianResponseTime, 10)
-		row[5] = strconv.FormatFloat(stat.avgResponseTime, 'f', 2, 64)
-		row[6] = strconv.FormatInt(stat.MinResponseTime, 10)
-		row[7] = strconv.FormatInt(stat.MaxResponseTime, 10)
-		row[8] = strconv.FormatInt(stat.avgContentLength, 10)
-		row[9] = strconv.FormatInt(stat.currentRps, 10)
-		row[10] = strconv.FormatInt(stat.currentFailPerSec, 10)
+		row := make([]string, 0, len(columns))
+		row = append(row,
+			stat.Method,
+			stat.Name,
+			strconv.FormatInt(stat.NumRequests, 10),
+			strconv.FormatInt(stat.NumFailures, 10),
+			strconv.FormatInt(stat.medianResponseTime, 10),
+		)
+		if !o.hidePercentileColumns {
+			row = append(row,
+				strconv.FormatInt(stat.P90, 10),
+				strconv.FormatInt(stat.P95, 10),
+				strconv.FormatInt(stat.P99, 10),
+				strconv.FormatInt(stat.P999, 10),
+			)
+		}
+		row = append(row, strconv.FormatFloat(stat.avgResponseTime, 'f', output.ResponseTimePrecision, 64))
+		if o.stdDevColumn {
+			row = append(row, strconv.FormatFloat(stat.StdDev, 'f', output.ResponseTimePrecision, 64))
+		}
+		if o.iqrColumn {
+			row = append(row, strconv.FormatInt(stat.IQR, 10))
+		}
+		row = append(row,
+			strconv.FormatInt(stat.MinResponseTime, 10),
+			strconv.FormatInt(stat.MaxResponseTime, 10),
+			strconv.FormatInt(stat.avgContentLength, 10),
+			strconv.FormatInt(stat.currentRps, 10),
+			strconv.FormatInt(stat.currentFailPerSec, 10),
+		)
+		if o.correlationColumn {
+			row = append(row, strconv.FormatFloat(stat.ResponseTimeCorrelation, 'f', 2, 64))
+		}
+		if color {
+			rowColor := rowColor(stat)
+			for i := range row {
+				if columns[i] == "Median" {
+					row[i] = colorize(row[i], o.medianColor(stat, rowColor))
+				} else {
+					row[i] = colorize(row[i], rowColor)
+				}
+			}
+		}
 		table.Append(row)
 	}
 	table.Render()
-	o.logger.Println()
+	o.println("")
+
+	if o.errorTableLimit > 0 && len(output.Errors) > 0 {
+		o.printErrors(w, output.Errors)
+	}
+}
+
+// printErrors prints the errors table, sorted by the occurrences in descending order.
+func (o *ConsoleOutput) printErrors(w io.Writer, statsErrors map[string]map[string]interface{}) {
+	type errorRow struct {
+		error, method, name string
+		occurrences         int64
+	}
+	rows := make([]errorRow, 0, len(statsErrors))
+	for _, e := range statsErrors {
+		row := errorRow{}
+		row.error, _ = e["error"].(string)
+		row.method, _ = e["method"].(string)
+		row.name, _ = e["name"].(string)
+		row.occurrences, _ = castToInt64(e["occurrences"])
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].occurrences != rows[j].occurrences {
+			return rows[i].occurrences > rows[j].occurrences
+		}
+		if rows[i].error != rows[j].error {
+			return rows[i].error < rows[j].error
+		}
+		if rows[i].method != rows[j].method {
+			return rows[i].method < rows[j].method
+		}
+		return rows[i].name < rows[j].name
+	})
+
+	hidden := 0
+	if len(rows) > o.errorTableLimit {
+		hidden = len(rows) - o.errorTableLimit
+		rows = rows[:o.errorTableLimit]
+	}
+	table := tablewriter.NewWriter(w)
+	table.Header([]string{"Error", "Method", "Name", "Occurrences"})
+	for _, row := range rows {
+		table.Append([]string{row.error, row.method, row.name, strconv.FormatInt(row.occurrences, 10)})
+	}
+	table.Render()
+	if hidden > 0 {
+		o.println(fmt.Sprintf("%d less frequent errors are not shown", hidden))
+	}
+	o.println("")
 }
 
 type statsEntryOutput struct {
 	statsEntry
 
+	P90  int64 `json:"p90_response_time"`  // 90th percentile response time
+	P95  int64 `json:"p95_response_time"`  // 95th percentile response time
+	P99  int64 `json:"p99_response_time"`  // 99th percentile response time
+	P999 int64 `json:"p999_response_time"` // 99.9th percentile response time
+	// standard deviation of response times, rounded to ResponseTimePrecision decimal places
+	StdDev float64 `json:"stddev_response_time"`
+	// interquartile range of response times, the 75th minus the 25th percentile
+	IQR int64 `json:"iqr_response_time"`
+
 	medianResponseTime int64   // median response time
-	avgResponseTime    float64 // average response time, round float to 2 decimal places
+	avgResponseTime    float64 // average response time, rounded to ResponseTimePrecision decimal places
 	avgContentLength   int64   // average content size
 	currentRps         int64   // # reqs/sec
 	currentFailPerSec  int64   // # fails/sec
 }
 
+// MarshalJSON adds the unexported calculated values to the JSON of statsEntry and the exported fields.
+func (o *statsEntryOutput) MarshalJSON() ([]byte, error) {
+	// output has the fields of statsEntryOutput, but not its MarshalJSON
+	type output statsEntryOutput
+	return json.Marshal(struct {
+		*output
+		MedianResponseTime int64   `json:"median_response_time"`
+		AvgResponseTime    float64 `json:"avg_response_time"`
+		AvgContentLength   int64   `json:"avg_content_length"`
+		CurrentRps         int64   `json:"current_rps"`
+		CurrentFailPerSec  int64   `json:"current_fail_per_sec"`
+	}{
+		output:             (*output)(o),
+		MedianResponseTime: o.medianResponseTime,
+		AvgResponseTime:    o.avgResponseTime,
+		AvgContentLength:   o.avgContentLength,
+		CurrentRps:         o.currentRps,
+		CurrentFailPerSec:  o.currentFailPerSec,
+	})
+}
+
 type dataOutput struct {
+	TestName       string                            `json:"test_name"`
 	UserCount      int32                             `json:"user_count"`
 	TotalStats     *statsEntryOutput                 `json:"stats_total"`
 	TotalRPS       int64                             `json:"total_rps"`
 	TotalFailRatio float64                           `json:"total_fail_ratio"`
 	Stats          []*statsEntryOutput               `json:"stats"`
 	Errors         map[string]map[string]interface{} `json:"errors"`
+	// ErrorsTruncated is true if some unique errors are counted as "[truncated]"
+	ErrorsTruncated bool `json:"errors_truncated"`
+	// ConcurrencyCurrent is the number of tasks running, ConcurrencyLimit is zero if not limited
+	ConcurrencyCurrent int32 `json:"concurrency_current"`
+	ConcurrencyLimit   int32 `json:"concurrency_limit"`
+	// StuckDetectorTriggered is the number of task executions detected as stuck since the test is started
+	StuckDetectorTriggered int64 `json:"stuck_detector_triggered"`
+	// CustomMetrics are recorded by RecordCustomMetric, keyed by name
+	CustomMetrics map[string]*CustomMetricEntry `json:"custom_metrics"`
+	// ResponseTimePrecision is the number of decimal places of the average response times
+	ResponseTimePrecision int `json:"response_time_precision"`
 }
 
+// the average response times are rounded to 2 decimal places by default, see Boomer.WithResponseTimePrecision.
+const defaultResponseTimePrecision = 2
+
 func convertData(data map[string]interface{}) (output *dataOutput, err error) {
+	return convertDataWithSerializer(data, defaultStatsSerializer)
+}
+
+// convertDataWithSerializer converts the data with serializer, nil means JSON.
+func convertDataWithSerializer(data map[string]interface{}, serializer *statsSerializer) (output *dataOutput, err error) {
+	if serializer == nil {
+		serializer = defaultStatsSerializer
+	}
 	userCount, ok := data["user_count"].(int32)
 	if !ok {
 		return nil, fmt.Errorf("user_count is not int32")
@@ -187,167 +640,352 @@ func convertData(data map[string]interface{}) (output *dataOutput, err error) {
 		return nil, fmt.Errorf("stats is not []interface{}")
 	}
 
+	rpsWindowSecs, ok := data["rps_window_secs"].(int64)
+	if !ok {
+		rpsWindowSecs = defaultRPSWindowSecs
+	}
+
 	// convert stats in total
 	statsTotal := data["stats_total"]
-	entryTotalOutput, err := deserializeStatsEntry(statsTotal)
+	entryTotalOutput, err := deserializeStatsEntryWithRPSWindow(statsTotal, rpsWindowSecs, serializer)
 	if err != nil {
 		return nil, err
 	}
 
+	testName, _ := data["test_name"].(string)
+	statsErrors, _ := data["errors"].(map[string]map[string]interface{})
+	errorsTruncated, _ := data["errors_truncated"].(bool)
+	concurrencyCurrent, _ := data["concurrency_current"].(int32)
+	concurrencyLimit, _ := data["concurrency_limit"].(int32)
+	stuckDetectorTriggered, _ := data["stuck_detector_triggered"].(int64)
+	customMetrics, _ := data["custom_metrics"].(map[string]*CustomMetricEntry)
+	precision, ok := data["response_time_precision"].(int)
+	if !ok {
+		precision = defaultResponseTimePrecision
+	}
+	entryTotalOutput.avgResponseTime = round(entryTotalOutput.avgResponseTime, .5, precision)
+	entryTotalOutput.StdDev = round(entryTotalOutput.StdDev, .5, precision)
+
 	output = &dataOutput{
-		UserCount:      userCount,
-		TotalStats:     entryTotalOutput,
-		TotalRPS:       getCurrentRps(entryTotalOutput.NumRequests, entryTotalOutput.NumReqsPerSec),
-		TotalFailRatio: getTotalFailRatio(entryTotalOutput.NumRequests, entryTotalOutput.NumFailures),
-		Stats:          make([]*statsEntryOutput, 0, len(stats)),
+		TestName:               testName,
+		UserCount:              userCount,
+		Errors:                 statsErrors,
+		ErrorsTruncated:        errorsTruncated,
+		ConcurrencyCurrent:     concurrencyCurrent,
+		ConcurrencyLimit:       concurrencyLimit,
+		StuckDetectorTriggered: stuckDetectorTriggered,
+		CustomMetrics:          customMetrics,
+		ResponseTimePrecision:  precision,
+		TotalStats:             entryTotalOutput,
+		TotalRPS:               entryTotalOutput.currentRps,
+		TotalFailRatio:         getTotalFailRatio(entryTotalOutput.NumRequests, entryTotalOutput.NumFailures),
+		Stats:                  make([]*statsEntryOutput, 0, len(stats)),
 	}
 
 	// convert stats
 	for _, stat := range stats {
-		entryOutput, err := deserializeStatsEntry(stat)
+		entryOutput, err := deserializeStatsEntryWithRPSWindow(stat, rpsWindowSecs, serializer)
 		if err != nil {
 			return nil, err
 		}
+		entryOutput.avgResponseTime = round(entryOutput.avgResponseTime, .5, precision)
+		entryOutput.StdDev = round(entryOutput.StdDev, .5, precision)
 		output.Stats = append(output.Stats, entryOutput)
 	}
 	return
 }
 
+// statsSerializer is used to convert the stats reported by runner to statsEntry, see Boomer.WithCustomSerializer.
+type statsSerializer struct {
+	marshal   func(interface{}) ([]byte, error)
+	unmarshal func([]byte, interface{}) error
+}
+
+// defaultStatsSerializer is used if no custom serializer is set.
+var defaultStatsSerializer = &statsSerializer{marshal: json.Marshal, unmarshal: json.Unmarshal}
+
+// serializedOutput is implemented by the outputs which convert the data with the serializer of
+// Boomer.WithCustomSerializer, the runner sets it before OnStart.
+type serializedOutput interface {
+	setStatsSerializer(serializer *statsSerializer)
+}
+
+// setOutputSerializer passes the serializer to o, or the output wrapped by o if it's a NamedOutput.
+func setOutputSerializer(o Output, serializer *statsSerializer) {
+	if named, ok := o.(*NamedOutput); ok {
+		o = named.Output
+	}
+	if so, ok := o.(serializedOutput); ok {
+		so.setStatsSerializer(serializer)
+	}
+}
+
+// statsConverter is embedded by the built-in outputs to convert the data with the serializer set by the runner.
+type statsConverter struct {
+	serializer *statsSerializer
+}
+
+func (c *statsConverter) setStatsSerializer(serializer *statsSerializer) {
+	c.serializer = serializer
+}
+
+func (c *statsConverter) convertData(data map[string]interface{}) (*dataOutput, error) {
+	return convertDataWithSerializer(data, c.serializer)
+}
+
+// ErrInvalidStatsEntry is wrapped by the errors of the stats entries which are corrupted or incomplete,
+// e.g. the ones reported by a broken worker.
+var ErrInvalidStatsEntry = errors.New("boomer: invalid stats entry")
+
+// validateStatsEntry checks the values of the entry are consistent, so corrupted stats are reported as an error
+// rather than nonsensical output.
+func validateStatsEntry(e *statsEntry) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w %s %s, %s", ErrInvalidStatsEntry, e.Method, e.Name, fmt.Sprintf(format, args...))
+	}
+	if e.NumRequests < 0 {
+		return invalid("num_requests %d is negative", e.NumRequests)
+	}
+	if e.NumFailures < 0 || e.NumFailures > e.NumRequests {
+		return invalid("num_failures %d is not in [0, num_requests %d]", e.NumFailures, e.NumRequests)
+	}
+	if e.TotalResponseTime < 0 {
+		return invalid("total_response_time %d is negative", e.TotalResponseTime)
+	}
+	if e.MinResponseTime < 0 || e.MinResponseTime > e.MaxResponseTime {
+		return invalid("min_response_time %d is not in [0, max_response_time %d]", e.MinResponseTime, e.MaxResponseTime)
+	}
+	for name, m := range map[string]map[int64]int64{
+		"response_times":   e.ResponseTimes,
+		"num_reqs_per_sec": e.NumReqsPerSec,
+		"num_fail_per_sec": e.NumFailPerSec,
+	} {
+		for k, v := range m {
+			if v <= 0 {
+				return invalid("%s[%d] %d is not positive", name, k, v)
+			}
+		}
+	}
+	return nil
+}
+
 func deserializeStatsEntry(stat interface{}) (entryOutput *statsEntryOutput, err error) {
-	statBytes, err := json.Marshal(stat)
+	return deserializeStatsEntryWithRPSWindow(stat, defaultRPSWindowSecs, defaultStatsSerializer)
+}
+
+// deserializeStatsEntryWithRPSWindow averages the current rps and fails/sec over the last rpsWindowSecs seconds.
+func deserializeStatsEntryWithRPSWindow(stat interface{}, rpsWindowSecs int64, serializer *statsSerializer) (entryOutput *statsEntryOutput, err error) {
+	statBytes, err := serializer.marshal(stat)
 	if err != nil {
 		return nil, err
 	}
 	entry := statsEntry{}
-	if err = json.Unmarshal(statBytes, &entry); err != nil {
+	if err = serializer.unmarshal(statBytes, &entry); err != nil {
+		return nil, err
+	}
+	if err = validateStatsEntry(&entry); err != nil {
 		return nil, err
 	}
 
 	numRequests := entry.NumRequests
+	sortedKeys := sortedResponseTimes(entry.ResponseTimes)
+	percentile := func(p float64) int64 {
+		return percentileOfSortedResponseTimes(p, numRequests, entry.ResponseTimes, sortedKeys)
+	}
 	entryOutput = &statsEntryOutput{
 		statsEntry:         entry,
-		medianResponseTime: getMedianResponseTime(numRequests, entry.ResponseTimes),
+		medianResponseTime: percentile(0.5),
+		P90:                percentile(0.9),
+		P95:                percentile(0.95),
+		P99:                percentile(0.99),
+		P999:               percentile(0.999),
 		avgResponseTime:    getAvgResponseTime(numRequests, entry.TotalResponseTime),
+		StdDev:             computeStdDev(numRequests, entry.TotalResponseTime, entry.ResponseTimes),
+		IQR:                iqrOfSortedResponseTimes(numRequests, entry.ResponseTimes, sortedKeys),
 		avgContentLength:   getAvgContentLength(numRequests, entry.TotalContentLength),
-		currentRps:         getCurrentRps(numRequests, entry.NumReqsPerSec),
-		currentFailPerSec:  getCurrentFailPerSec(entry.NumFailures, entry.NumFailPerSec),
+		currentRps:         getWindowedRps(entry.NumReqsPerSec, rpsWindowSecs),
+		currentFailPerSec:  getWindowedFailPerSec(entry.NumFailPerSec, rpsWindowSecs),
+	}
+	// the rates averaged by requestStats cover the seconds of the previous report intervals
+	if entry.CurrentRps != nil {
+		entryOutput.currentRps = *entry.CurrentRps
+	}
+	if entry.CurrentFailPerSec != nil {
+		entryOutput.currentFailPerSec = *entry.CurrentFailPerSec
 	}
 	return
 }
 
-const (
-	namespace = "boomer"
-)
+// namespace is the default namespace of the prometheus metrics, see PrometheusPusherOutput.WithNamespace.
+const namespace = "boomer"
+
+// prometheusMetrics are the metrics of a PrometheusPusherOutput, which are created with its namespace.
+type prometheusMetrics struct {
+	// gauge vectors for requests
+	numRequests             *prometheus.GaugeVec
+	numFailures             *prometheus.GaugeVec
+	medianResponseTime      *prometheus.GaugeVec
+	p90ResponseTime         *prometheus.GaugeVec
+	p95ResponseTime         *prometheus.GaugeVec
+	p99ResponseTime         *prometheus.GaugeVec
+	p999ResponseTime        *prometheus.GaugeVec
+	averageResponseTime     *prometheus.GaugeVec
+	stdDevResponseTime      *prometheus.GaugeVec
+	iqrResponseTime         *prometheus.GaugeVec
+	minResponseTime         *prometheus.GaugeVec
+	maxResponseTime         *prometheus.GaugeVec
+	averageContentLength    *prometheus.GaugeVec
+	currentRPS              *prometheus.GaugeVec
+	currentFailPerSec       *prometheus.GaugeVec
+	responseTimeCorrelation *prometheus.GaugeVec
+
+	// gauges for total
+	users              prometheus.Gauge
+	totalRPS           prometheus.Gauge
+	totalFailRatio     prometheus.Gauge
+	concurrencyCurrent prometheus.Gauge
+	concurrencyLimit   prometheus.Gauge
+	goroutineCount     prometheus.Gauge
+
+	// the counters of requests and failures, which are increased by the counts of each interval,
+	// so rate() works in PromQL, unlike the gauges of the current interval
+	requestsTotal *prometheus.CounterVec
+	failuresTotal *prometheus.CounterVec
+}
+
+func newPrometheusMetrics(ns string) *prometheusMetrics {
+	gaugeVec := func(name, help string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Name: name, Help: help}, []string{"method", "name"})
+	}
+	gauge := func(name, help string) prometheus.Gauge {
+		return prometheus.NewGauge(prometheus.GaugeOpts{Namespace: ns, Name: name, Help: help})
+	}
+	counterVec := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: ns, Name: name, Help: help}, []string{"method", "name"})
+	}
+	return &prometheusMetrics{
+		numRequests:             gaugeVec("num_requests", "The number of requests"),
+		numFailures:             gaugeVec("num_failures", "The number of failures"),
+		medianResponseTime:      gaugeVec("median_response_time", "The median response time"),
+		p90ResponseTime:         gaugeVec("p90_response_time", "The 90th percentile response time"),
+		p95ResponseTime:         gaugeVec("p95_response_time", "The 95th percentile response time"),
+		p99ResponseTime:         gaugeVec("p99_response_time", "The 99th percentile response time"),
+		p999ResponseTime:        gaugeVec("p999_response_time", "The 99.9th percentile response time"),
+		averageResponseTime:     gaugeVec("average_response_time", "The average response time"),
+		stdDevResponseTime:      gaugeVec("stddev_response_time", "The standard deviation of response times"),
+		iqrResponseTime:         gaugeVec("iqr_response_time", "The interquartile range of response times"),
+		minResponseTime:         gaugeVec("min_response_time", "The min response time"),
+		maxResponseTime:         gaugeVec("max_response_time", "The max response time"),
+		averageContentLength:    gaugeVec("average_content_length", "The average content length"),
+		currentRPS:              gaugeVec("current_rps", "The current requests per second"),
+		currentFailPerSec:       gaugeVec("current_fail_per_sec", "The current failure number per second"),
+		responseTimeCorrelation: gaugeVec("response_time_lag1_correlation", "The lag-1 autocorrelation of the recent response times"),
+
+		users:              gauge("users", "The current number of users"),
+		totalRPS:           gauge("total_rps", "The requests per second in total"),
+		totalFailRatio:     gauge("fail_ratio", "The ratio of request failures in total"),
+		concurrencyCurrent: gauge("concurrency_current", "The current number of running tasks"),
+		concurrencyLimit:   gauge("concurrency_limit", "The max number of running tasks, zero means no limit"),
+		goroutineCount:     gauge("goroutine_count", "The number of goroutines of the process, recorded by WithResourceMonitor"),
+
+		requestsTotal: counterVec("requests_total", "The total number of requests since the test started"),
+		failuresTotal: counterVec("failures_total", "The total number of failures since the test started"),
+	}
+}
 
-// gauge vectors for requests
+// collectors returns all the metrics to be registered.
+func (m *prometheusMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.numRequests, m.numFailures, m.medianResponseTime,
+		m.p90ResponseTime, m.p95ResponseTime, m.p99ResponseTime, m.p999ResponseTime,
+		m.averageResponseTime, m.stdDevResponseTime, m.iqrResponseTime, m.minResponseTime, m.maxResponseTime, m.averageContentLength,
+		m.currentRPS, m.currentFailPerSec, m.responseTimeCorrelation,
+		m.users, m.totalRPS, m.totalFailRatio, m.concurrencyCurrent, m.concurrencyLimit, m.goroutineCount,
+		m.requestsTotal, m.failuresTotal,
+	}
+}
+
+// counters for BoomerTransport
 var (
-	gaugeNumRequests = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "num_requests",
-			Help:      "The number of requests",
-		},
-		[]string{"method", "name"},
-	)
-	gaugeNumFailures = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "num_failures",
-			Help:      "The number of failures",
-		},
-		[]string{"method", "name"},
-	)
-	gaugeMedianResponseTime = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "median_response_time",
-			Help:      "The median response time",
-		},
-		[]string{"method", "name"},
-	)
-	gaugeAverageResponseTime = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "average_response_time",
-			Help:      "The average response time",
-		},
-		[]string{"method", "name"},
-	)
-	gaugeMinResponseTime = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
+	counterProtocol = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
 			Namespace: namespace,
-			Name:      "min_response_time",
-			Help:      "The min response time",
+			Name:      "protocol",
+			Help:      "The number of responses by the negotiated protocol",
 		},
-		[]string{"method", "name"},
+		[]string{"protocol"},
 	)
-	gaugeMaxResponseTime = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
+	counterTLSVersion = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
 			Namespace: namespace,
-			Name:      "max_response_time",
-			Help:      "The max response time",
+			Name:      "tls_version",
+			Help:      "The number of TLS connections by the negotiated version",
 		},
-		[]string{"method", "name"},
+		[]string{"version"},
 	)
-	gaugeAverageContentLength = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
+	counterTLSCipherSuite = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
 			Namespace: namespace,
-			Name:      "average_content_length",
-			Help:      "The average content length",
+			Name:      "tls_cipher_suite",
+			Help:      "The number of TLS connections by the negotiated cipher suite",
 		},
-		[]string{"method", "name"},
+		[]string{"suite"},
 	)
-	gaugeCurrentRPS = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
+	counterConnectionsReused = prometheus.NewCounter(
+		prometheus.CounterOpts{
 			Namespace: namespace,
-			Name:      "current_rps",
-			Help:      "The current requests per second",
+			Name:      "connections_reused_total",
+			Help:      "The number of requests sent on reused connections",
 		},
-		[]string{"method", "name"},
 	)
-	gaugeCurrentFailPerSec = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
+	counterConnectionsNew = prometheus.NewCounter(
+		prometheus.CounterOpts{
 			Namespace: namespace,
-			Name:      "current_fail_per_sec",
-			Help:      "The current failure number per second",
+			Name:      "connections_new_total",
+			Help:      "The number of requests sent on new connections",
 		},
-		[]string{"method", "name"},
 	)
 )
 
-// gauges for total
-var (
-	gaugeUsers = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "users",
-			Help:      "The current number of users",
-		},
-	)
-	gaugeTotalRPS = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "total_rps",
-			Help:      "The requests per second in total",
-		},
-	)
-	gaugeTotalFailRatio = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "fail_ratio",
-			Help:      "The ratio of request failures in total",
-		},
-	)
+// counterPushFailures counts the pushes of PrometheusPusherOutput which fail after all the retries,
+// it's pushed along with the other metrics, so the gaps in the dashboards can be explained.
+var counterPushFailures = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "push_failures_total",
+		Help:      "The number of pushes to Pushgateway which failed after all the retries",
+	},
+)
+
+const (
+	defaultMaxPushRetries   = 3
+	defaultPushRetryBackoff = 100 * time.Millisecond
+	defaultPushRetryCap     = 2 * time.Second
 )
 
 // NewPrometheusPusherOutput returns a PrometheusPusherOutput.
+// If jobName is empty, the test name is used as the job name.
 func NewPrometheusPusherOutput(gatewayURL, jobName string) *PrometheusPusherOutput {
 	return &PrometheusPusherOutput{
-		pusher: push.New(gatewayURL, jobName),
-		logger: log.Default(),
+		gatewayURL: gatewayURL,
+		jobName:    jobName,
+		pusher:     push.New(gatewayURL, jobName),
+		logger:     log.Default(),
+		namespace:  namespace,
+
+		maxPushRetries:   defaultMaxPushRetries,
+		pushRetryBackoff: defaultPushRetryBackoff,
+		pushRetryCap:     defaultPushRetryCap,
 	}
 }
 
+var invalidJobNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// sanitizeJobName replaces the characters which are not URL-safe in the job name with underscores.
+func sanitizeJobName(name string) string {
+	return invalidJobNameChars.ReplaceAllString(name, "_")
+}
+
 // WithLogger allows user to use their own logger.
 // If the logger is nil, it will not take effect.
 func (o *PrometheusPusherOutput) WithLogger(logger *log.Logger) *PrometheusPusherOutput {
@@ -359,31 +997,419 @@ func (o *PrometheusPusherOutput) WithLogger(logger *log.Logger) *PrometheusPushe
 
 // PrometheusPusherOutput pushes boomer stats to Prometheus Pushgateway.
 type PrometheusPusherOutput struct {
-	pusher *push.Pusher // Prometheus Pushgateway Pusher
-	logger *log.Logger
+	statsConverter
+
+	gatewayURL string
+	jobName    string
+	pusher     *push.Pusher // Prometheus Pushgateway Pusher
+	registry   *prometheus.Registry
+	logger     *log.Logger
+
+	// the metrics are created with namespace, and registered with constLabels by registerer,
+	// when the registry is created
+	namespace   string
+	constLabels prometheus.Labels
+	registerer  prometheus.Registerer
+	metrics     *prometheusMetrics
+
+	namespaceMapping func(method, name string) (namespace, subsystem, metric string)
+	gaugeVecs        map[string]*prometheus.GaugeVec // gauge vectors created by namespaceMapping, keyed by full name
+
+	// the response time histogram and ratios for SLO reporting, if WithSLOBuckets is set
+	sloHistogram *responseTimeHistogram
+	sloRatio     *prometheus.GaugeVec
+
+	// the response time histogram, if WithPrometheusHistogramBuckets is set
+	responseTimeHistogram *responseTimeHistogram
+	// the response time gauges are not reported if WithHistogramResponseTimes is set
+	histogramResponseTimes bool
+
+	// a failed push is retried up to maxPushRetries times, with exponential backoff from pushRetryBackoff
+	// up to pushRetryCap
+	maxPushRetries   int
+	pushRetryBackoff time.Duration
+	pushRetryCap     time.Duration
 }
 
-// OnStart will register all prometheus metric collectors
-func (o *PrometheusPusherOutput) OnStart() {
+// WithMaxPushRetries retries a failed push up to n times, the default is 3. If n is 0, the push isn't retried.
+// The pushes which fail after all the retries are counted by boomer_push_failures_total.
+func (o *PrometheusPusherOutput) WithMaxPushRetries(n int) *PrometheusPusherOutput {
+	if n < 0 {
+		o.logger.Printf("Invalid max push retries %d, ignored!\n", n)
+		return o
+	}
+	o.maxPushRetries = n
+	return o
+}
+
+// WithPushRetryBackoff sets the wait before the first retry of a push, which is doubled for every retry up to cap.
+// A random jitter of up to half of the wait is subtracted, so the workers don't retry at the same time.
+// The defaults are 100ms and 2s.
+func (o *PrometheusPusherOutput) WithPushRetryBackoff(base, cap time.Duration) *PrometheusPusherOutput {
+	if base <= 0 || cap < base {
+		o.logger.Printf("Invalid push retry backoff %v and cap %v, ignored!\n", base, cap)
+		return o
+	}
+	o.pushRetryBackoff = base
+	o.pushRetryCap = cap
+	return o
+}
+
+// pushRetryDelay returns the wait before the retry after attempt failed pushes, which is in [d/2, d],
+// where d is the exponential backoff capped by pushRetryCap.
+func (o *PrometheusPusherOutput) pushRetryDelay(attempt int) time.Duration {
+	d := o.pushRetryCap
+	if attempt < 32 && o.pushRetryBackoff<<(attempt-1) < o.pushRetryCap {
+		d = o.pushRetryBackoff << (attempt - 1)
+	}
+	return d - time.Duration(random.Int63n(int64(d/2)+1))
+}
+
+// push pushes the metrics to Pushgateway, and retries up to maxPushRetries times if it fails.
+func (o *PrometheusPusherOutput) push() error {
+	for attempt := 1; ; attempt++ {
+		err := o.pusher.Push()
+		if err == nil {
+			return nil
+		}
+		if attempt > o.maxPushRetries {
+			counterPushFailures.Inc()
+			return err
+		}
+		time.Sleep(o.pushRetryDelay(attempt))
+	}
+}
+
+// WithNamespace replaces the namespace "boomer" of the metrics with ns, e.g. checkout_num_requests,
+// to tell apart the tests of different services pushed to the same Pushgateway.
+// The counters of BoomerTransport are shared by all the outputs, they are always in the namespace "boomer".
+// Call it before OnStart and the methods registering metrics, like AddGauge. If ns is empty, it will not take effect.
+func (o *PrometheusPusherOutput) WithNamespace(ns string) *PrometheusPusherOutput {
+	if ns == "" {
+		return o
+	}
+	if o.registry != nil {
+		o.logger.Printf("The metrics are registered, namespace %s is ignored\n", ns)
+		return o
+	}
+	o.namespace = ns
+	return o
+}
+
+// reservedLabelNames are the variable labels of the built-in metrics, which can't be constant labels.
+var reservedLabelNames = map[string]bool{"method": true, "name": true, "slo": true, "protocol": true, "version": true, "suite": true}
+
+// WithConstLabels adds the static labels to all the metrics pushed by the output, including the ones
+// created by AddGauge, AddCounter and AddHistogram, e.g. prometheus.Labels{"environment": "staging"}.
+// The labels used by the built-in metrics, like method and name, are not allowed.
+// Call it before OnStart and the methods registering metrics, like AddGauge.
+func (o *PrometheusPusherOutput) WithConstLabels(labels prometheus.Labels) *PrometheusPusherOutput {
+	if o.registry != nil {
+		o.logger.Printf("The metrics are registered, const labels %v are ignored\n", labels)
+		return o
+	}
+	for label := range labels {
+		if reservedLabelNames[label] {
+			o.logger.Printf("Label %s is used by the built-in metrics, const labels %v are ignored\n", label, labels)
+			return o
+		}
+	}
+	o.constLabels = labels
+	return o
+}
+
+// WithMetricsNamespaceMapping customizes the names of the metrics for requests, by the method and name of the request.
+// The metric name is composed as {namespace}_{subsystem}_{metric}_{default name}, e.g. myapp_load_checkout_pay_num_requests.
+// If namespace is empty, the namespace of the output is used, and empty subsystem or metric are omitted.
+func (o *PrometheusPusherOutput) WithMetricsNamespaceMapping(fn func(method, name string) (namespace, subsystem, metric string)) *PrometheusPusherOutput {
+	o.namespaceMapping = fn
+	return o
+}
+
+// gaugeVec returns the gauge vector for the request, which is named by namespaceMapping.
+// The default gauge vector is returned if namespaceMapping is not set or returns empty strings.
+func (o *PrometheusPusherOutput) gaugeVec(defaultVec *prometheus.GaugeVec, defaultName, method, name string) *prometheus.GaugeVec {
+	if o.namespaceMapping == nil {
+		return defaultVec
+	}
+	ns, subsystem, metric := o.namespaceMapping(method, name)
+	if ns == "" {
+		ns = o.namespace
+	}
+	if metric == "" {
+		metric = defaultName
+	} else {
+		metric = metric + "_" + defaultName
+	}
+	fqName := prometheus.BuildFQName(ns, subsystem, metric)
+	if fqName == prometheus.BuildFQName(o.namespace, "", defaultName) {
+		return defaultVec
+	}
+
+	if vec, ok := o.gaugeVecs[fqName]; ok {
+		return vec
+	}
+	vec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: subsystem,
+			Name:      metric,
+			Help:      "The same as " + prometheus.BuildFQName(o.namespace, "", defaultName),
+		},
+		[]string{"method", "name"},
+	)
+	if o.registry != nil {
+		if err := o.registerer.Register(vec); err != nil {
+			o.logger.Printf("register prometheus metric collector %s error: %v\n", fqName, err)
+		}
+	}
+	if o.gaugeVecs == nil {
+		o.gaugeVecs = make(map[string]*prometheus.GaugeVec)
+	}
+	o.gaugeVecs[fqName] = vec
+	return vec
+}
+
+// getRegistry returns the private registry of the output, the built-in metrics are created and registered when it's created.
+func (o *PrometheusPusherOutput) getRegistry() *prometheus.Registry {
+	if o.registry != nil {
+		return o.registry
+	}
 	o.logger.Println("register prometheus metric collectors")
 	registry := prometheus.NewRegistry()
-	registry.MustRegister(
-		// gauge vectors for requests
-		gaugeNumRequests,
-		gaugeNumFailures,
-		gaugeMedianResponseTime,
-		gaugeAverageResponseTime,
-		gaugeMinResponseTime,
-		gaugeMaxResponseTime,
-		gaugeAverageContentLength,
-		gaugeCurrentRPS,
-		gaugeCurrentFailPerSec,
-		// gauges for total
-		gaugeUsers,
-		gaugeTotalRPS,
-		gaugeTotalFailRatio,
+	o.registerer = prometheus.WrapRegistererWith(o.constLabels, registry)
+	o.metrics = newPrometheusMetrics(o.namespace)
+	o.registerer.MustRegister(o.metrics.collectors()...)
+	// counters for transport
+	o.registerer.MustRegister(
+		counterProtocol,
+		counterTLSVersion,
+		counterTLSCipherSuite,
+		counterConnectionsReused,
+		counterConnectionsNew,
+	)
+	o.registerer.MustRegister(counterPushFailures)
+	o.registry = registry
+	return registry
+}
+
+// getRegisterer returns the registerer which adds the const labels to the metrics.
+func (o *PrometheusPusherOutput) getRegisterer() prometheus.Registerer {
+	o.getRegistry()
+	return o.registerer
+}
+
+// AddGauge creates a user-defined gauge vector and registers it in the private registry of the output,
+// so it's pushed to Pushgateway alongside boomer's built-in metrics. Call it before OnStart.
+func (o *PrometheusPusherOutput) AddGauge(name, help string, labelNames []string) (*prometheus.GaugeVec, error) {
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labelNames)
+	if err := o.getRegisterer().Register(vec); err != nil {
+		return nil, err
+	}
+	return vec, nil
+}
+
+// AddCounter creates a user-defined counter vector, like AddGauge.
+func (o *PrometheusPusherOutput) AddCounter(name, help string, labelNames []string) (*prometheus.CounterVec, error) {
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labelNames)
+	if err := o.getRegisterer().Register(vec); err != nil {
+		return nil, err
+	}
+	return vec, nil
+}
+
+// AddHistogram creates a user-defined histogram vector, like AddGauge.
+// If buckets is nil, prometheus.DefBuckets is used.
+func (o *PrometheusPusherOutput) AddHistogram(name, help string, labelNames []string, buckets []float64) (*prometheus.HistogramVec, error) {
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labelNames)
+	if err := o.getRegisterer().Register(vec); err != nil {
+		return nil, err
+	}
+	return vec, nil
+}
+
+// WithSLOBuckets reports the response times in the histogram boomer_response_time_slo_seconds,
+// whose upper bounds are buckets in seconds, e.g. []float64{0.1, 0.5} for 100ms and 500ms SLOs.
+// The fraction of requests completing within each bound in the current interval is reported in
+// the gauge boomer_slo_ratio, labeled like slo="100ms". Call it before OnStart.
+//
+// The response times are rounded by boomer, e.g. 123ms is counted as 120ms, so the ratios are approximate.
+func (o *PrometheusPusherOutput) WithSLOBuckets(buckets []float64) *PrometheusPusherOutput {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	o.sloHistogram = newResponseTimeHistogram(o.namespace, "response_time_slo_seconds",
+		"The response times in seconds, with the bucket boundaries for SLO reporting", sorted)
+	o.sloRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: o.namespace,
+			Name:      "slo_ratio",
+			Help:      "The fraction of requests completing within the SLO in the current interval",
+		},
+		[]string{"method", "name", "slo"},
 	)
-	o.pusher = o.pusher.Gatherer(registry)
+	o.getRegisterer().MustRegister(o.sloHistogram, o.sloRatio)
+	return o
+}
+
+// ErrInvalidHistogramBuckets is logged by WithPrometheusHistogramBuckets, if the buckets are empty, negative,
+// or not in strictly increasing order.
+var ErrInvalidHistogramBuckets = errors.New("boomer: histogram buckets must be non-negative and in strictly increasing order")
+
+// The bucket presets for WithPrometheusHistogramBuckets, in seconds.
+var (
+	// BucketsForMicrosecondAPIs is for fast APIs and caches, from 0.5ms to 250ms.
+	// Boomer records the response times in milliseconds, so the requests faster than 1ms fall in the first bucket.
+	BucketsForMicrosecondAPIs = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25}
+	// BucketsForDatabaseQueries is for database queries and typical web APIs, from 5ms to 10s.
+	BucketsForDatabaseQueries = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// BucketsForBatchProcessing is for slow requests like report generation, from 100ms to 5min.
+	BucketsForBatchProcessing = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+)
+
+// WithPrometheusHistogramBuckets reports the response times in the histogram boomer_response_time_seconds,
+// whose upper bounds are buckets in seconds, e.g. one of the presets BucketsForMicrosecondAPIs,
+// BucketsForDatabaseQueries and BucketsForBatchProcessing. Call it before OnStart.
+// If buckets are empty, negative or not in strictly increasing order, they're ignored and the buckets set before are kept.
+//
+// The buckets should be kept the same across test runs. Prometheus can't aggregate or compare histograms
+// with different bucket boundaries, so changing them breaks the queries spanning the runs, e.g. histogram_quantile over a week.
+func (o *PrometheusPusherOutput) WithPrometheusHistogramBuckets(buckets []float64) *PrometheusPusherOutput {
+	if err := o.setHistogramBuckets(buckets); err != nil {
+		o.logger.Printf("Invalid histogram buckets %v, ignored! error: %v\n", buckets, err)
+	}
+	return o
+}
+
+// setHistogramBuckets registers the response time histogram with the buckets,
+// it returns ErrInvalidHistogramBuckets if they are invalid.
+func (o *PrometheusPusherOutput) setHistogramBuckets(buckets []float64) error {
+	if len(buckets) == 0 {
+		return ErrInvalidHistogramBuckets
+	}
+	for i, bound := range buckets {
+		if bound < 0 || (i > 0 && bound <= buckets[i-1]) {
+			return ErrInvalidHistogramBuckets
+		}
+	}
+	if o.responseTimeHistogram != nil {
+		o.getRegisterer().Unregister(o.responseTimeHistogram)
+	}
+	o.responseTimeHistogram = newResponseTimeHistogram(o.namespace, "response_time_seconds",
+		"The response times in seconds", append([]float64(nil), buckets...))
+	o.getRegisterer().MustRegister(o.responseTimeHistogram)
+	return nil
+}
+
+// DefaultHistogramResponseTimeBuckets are the buckets of WithHistogramResponseTimes by default, from 1ms to 30s.
+var DefaultHistogramResponseTimeBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// WithHistogramResponseTimes reports the response times in the histogram boomer_response_time_seconds, like
+// WithPrometheusHistogramBuckets, instead of the gauges of the median, percentiles, average, standard deviation,
+// IQR, min and max response times, so the distributions of multiple workers can be aggregated by Prometheus.
+// If buckets is nil, DefaultHistogramResponseTimeBuckets is used. Call it before OnStart.
+// If the buckets are invalid, see ErrInvalidHistogramBuckets, it will not take effect.
+func (o *PrometheusPusherOutput) WithHistogramResponseTimes(buckets []float64) *PrometheusPusherOutput {
+	if buckets == nil {
+		buckets = DefaultHistogramResponseTimeBuckets
+	}
+	if err := o.setHistogramBuckets(buckets); err != nil {
+		o.logger.Printf("Invalid histogram buckets %v, ignored! error: %v\n", buckets, err)
+		return o
+	}
+	o.histogramResponseTimes = true
+	return o
+}
+
+// observeSLO adds the response times of the interval to the SLO histogram, and sets the SLO ratios.
+func (o *PrometheusPusherOutput) observeSLO(stat *statsEntryOutput) {
+	counts := o.sloHistogram.observe(stat)
+	for i, bound := range o.sloHistogram.buckets {
+		ratio := float64(0)
+		if stat.NumRequests > 0 {
+			ratio = float64(counts[i]) / float64(stat.NumRequests)
+		}
+		slo := strconv.FormatFloat(bound*1000, 'f', -1, 64) + "ms"
+		o.sloRatio.WithLabelValues(stat.Method, stat.Name, slo).Set(ratio)
+	}
+}
+
+// responseTimeHistogram is a histogram of response times with custom buckets, accumulated from the stats of each interval,
+// because boomer reports the distribution of response times, instead of each of them.
+type responseTimeHistogram struct {
+	desc    *prometheus.Desc
+	buckets []float64 // upper bounds in seconds
+
+	entries map[string]*responseTimeHistogramEntry // keyed by method and name
+	lock    sync.Mutex
+}
+
+type responseTimeHistogramEntry struct {
+	method, name string
+	counts       []uint64 // cumulative count of each bucket
+	count        uint64
+	sum          float64 // in seconds
+}
+
+func newResponseTimeHistogram(ns, name, help string, buckets []float64) *responseTimeHistogram {
+	return &responseTimeHistogram{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(ns, "", name),
+			help,
+			[]string{"method", "name"}, nil,
+		),
+		buckets: buckets,
+		entries: make(map[string]*responseTimeHistogramEntry),
+	}
+}
+
+// observe adds the response times of stat to the histogram, and returns the count of each bucket in the interval.
+func (h *responseTimeHistogram) observe(stat *statsEntryOutput) []uint64 {
+	counts := make([]uint64, len(h.buckets))
+	for responseTime, count := range stat.ResponseTimes {
+		for i, bound := range h.buckets {
+			if float64(responseTime) <= bound*1000 {
+				counts[i] += uint64(count)
+			}
+		}
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	key := stat.Method + stat.Name
+	entry, ok := h.entries[key]
+	if !ok {
+		entry = &responseTimeHistogramEntry{method: stat.Method, name: stat.Name, counts: make([]uint64, len(h.buckets))}
+		h.entries[key] = entry
+	}
+	for i := range counts {
+		entry.counts[i] += counts[i]
+	}
+	entry.count += uint64(stat.NumRequests)
+	entry.sum += float64(stat.TotalResponseTime) / 1000
+	return counts
+}
+
+// Describe implements prometheus.Collector.
+func (h *responseTimeHistogram) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.desc
+}
+
+// Collect implements prometheus.Collector.
+func (h *responseTimeHistogram) Collect(ch chan<- prometheus.Metric) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, entry := range h.entries {
+		buckets := make(map[float64]uint64, len(h.buckets))
+		for i, bound := range h.buckets {
+			buckets[bound] = entry.counts[i]
+		}
+		ch <- prometheus.MustNewConstHistogram(h.desc, entry.count, entry.sum, buckets, entry.method, entry.name)
+	}
+}
+
+// OnStart will register all prometheus metric collectors
+func (o *PrometheusPusherOutput) OnStart() {
+	o.pusher = o.pusher.Gatherer(o.getRegistry())
 }
 
 // OnStop of PrometheusPusherOutput has nothing to do.
@@ -393,36 +1419,74 @@ func (o *PrometheusPusherOutput) OnStop() {
 
 // OnEvent will push metric to Prometheus Pushgataway
 func (o *PrometheusPusherOutput) OnEvent(data map[string]interface{}) {
-	output, err := convertData(data)
+	output, err := o.convertData(data)
 	if err != nil {
 		o.logger.Printf("convert data error: %v\n", err)
 		return
 	}
 
+	if o.jobName == "" && output.TestName != "" {
+		o.jobName = sanitizeJobName(output.TestName)
+		o.pusher = push.New(o.gatewayURL, o.jobName)
+		if o.registry != nil {
+			o.pusher = o.pusher.Gatherer(o.registry)
+		}
+	}
+
+	o.getRegistry()
+	m := o.metrics
+
 	// user count
-	gaugeUsers.Set(float64(output.UserCount))
+	m.users.Set(float64(output.UserCount))
 
 	// rps in total
-	gaugeTotalRPS.Set(float64(output.TotalRPS))
+	m.totalRPS.Set(float64(output.TotalRPS))
 
 	// failure ratio in total
-	gaugeTotalFailRatio.Set(output.TotalFailRatio)
+	m.totalFailRatio.Set(output.TotalFailRatio)
+
+	// running tasks
+	m.concurrencyCurrent.Set(float64(output.ConcurrencyCurrent))
+	m.concurrencyLimit.Set(float64(output.ConcurrencyLimit))
+
+	// goroutines of the process
+	if goroutines, ok := output.CustomMetrics[ProcessGoroutinesMetric]; ok {
+		m.goroutineCount.Set(goroutines.Value)
+	}
 
 	for _, stat := range output.Stats {
 		method := stat.Method
 		name := stat.Name
-		gaugeNumRequests.WithLabelValues(method, name).Set(float64(stat.NumRequests))
-		gaugeNumFailures.WithLabelValues(method, name).Set(float64(stat.NumFailures))
-		gaugeMedianResponseTime.WithLabelValues(method, name).Set(float64(stat.medianResponseTime))
-		gaugeAverageResponseTime.WithLabelValues(method, name).Set(float64(stat.avgResponseTime))
-		gaugeMinResponseTime.WithLabelValues(method, name).Set(float64(stat.MinResponseTime))
-		gaugeMaxResponseTime.WithLabelValues(method, name).Set(float64(stat.MaxResponseTime))
-		gaugeAverageContentLength.WithLabelValues(method, name).Set(float64(stat.avgContentLength))
-		gaugeCurrentRPS.WithLabelValues(method, name).Set(float64(stat.currentRps))
-		gaugeCurrentFailPerSec.WithLabelValues(method, name).Set(float64(stat.currentFailPerSec))
-	}
-
-	if err := o.pusher.Push(); err != nil {
+		o.gaugeVec(m.numRequests, "num_requests", method, name).WithLabelValues(method, name).Set(float64(stat.NumRequests))
+		o.gaugeVec(m.numFailures, "num_failures", method, name).WithLabelValues(method, name).Set(float64(stat.NumFailures))
+		if !o.histogramResponseTimes {
+			o.gaugeVec(m.medianResponseTime, "median_response_time", method, name).WithLabelValues(method, name).Set(float64(stat.medianResponseTime))
+			o.gaugeVec(m.p90ResponseTime, "p90_response_time", method, name).WithLabelValues(method, name).Set(float64(stat.P90))
+			o.gaugeVec(m.p95ResponseTime, "p95_response_time", method, name).WithLabelValues(method, name).Set(float64(stat.P95))
+			o.gaugeVec(m.p99ResponseTime, "p99_response_time", method, name).WithLabelValues(method, name).Set(float64(stat.P99))
+			o.gaugeVec(m.p999ResponseTime, "p999_response_time", method, name).WithLabelValues(method, name).Set(float64(stat.P999))
+			o.gaugeVec(m.averageResponseTime, "average_response_time", method, name).WithLabelValues(method, name).Set(float64(stat.avgResponseTime))
+			o.gaugeVec(m.stdDevResponseTime, "stddev_response_time", method, name).WithLabelValues(method, name).Set(stat.StdDev)
+			o.gaugeVec(m.iqrResponseTime, "iqr_response_time", method, name).WithLabelValues(method, name).Set(float64(stat.IQR))
+			o.gaugeVec(m.minResponseTime, "min_response_time", method, name).WithLabelValues(method, name).Set(float64(stat.MinResponseTime))
+			o.gaugeVec(m.maxResponseTime, "max_response_time", method, name).WithLabelValues(method, name).Set(float64(stat.MaxResponseTime))
+		}
+		o.gaugeVec(m.averageContentLength, "average_content_length", method, name).WithLabelValues(method, name).Set(float64(stat.avgContentLength))
+		o.gaugeVec(m.currentRPS, "current_rps", method, name).WithLabelValues(method, name).Set(float64(stat.currentRps))
+		o.gaugeVec(m.currentFailPerSec, "current_fail_per_sec", method, name).WithLabelValues(method, name).Set(float64(stat.currentFailPerSec))
+		o.gaugeVec(m.responseTimeCorrelation, "response_time_lag1_correlation", method, name).WithLabelValues(method, name).Set(stat.ResponseTimeCorrelation)
+		// the stats are reset every interval, so the counts of the interval are the increments
+		m.requestsTotal.WithLabelValues(method, name).Add(float64(stat.NumRequests))
+		m.failuresTotal.WithLabelValues(method, name).Add(float64(stat.NumFailures))
+		if o.sloHistogram != nil {
+			o.observeSLO(stat)
+		}
+		if o.responseTimeHistogram != nil {
+			o.responseTimeHistogram.observe(stat)
+		}
+	}
+
+	if err := o.push(); err != nil {
 		o.logger.Printf("Could not push to Pushgateway: error: %v\n", err)
 	}
 }
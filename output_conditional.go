@@ -0,0 +1,78 @@
+package boomer
+
+import (
+	"sync"
+	"time"
+)
+
+// ConditionalOutput delegates to one of two outputs, according to a condition evaluated on each OnEvent.
+// For example, use ConsoleOutput during ramp-up, and PrometheusPusherOutput during steady-state.
+// When the result of the condition changes, the previous output is stopped before the next one is started,
+// so both outputs are never active at the same time.
+type ConditionalOutput struct {
+	condition       func(data map[string]interface{}) bool
+	trueOutput      Output
+	falseOutput     Output
+	transitionDelay time.Duration
+
+	active Output
+	lock   sync.Mutex
+}
+
+// NewConditionalOutput returns a ConditionalOutput.
+// condition receives the same data as Output.OnEvent.
+func NewConditionalOutput(condition func(data map[string]interface{}) bool, trueOutput, falseOutput Output) *ConditionalOutput {
+	return &ConditionalOutput{
+		condition:   condition,
+		trueOutput:  trueOutput,
+		falseOutput: falseOutput,
+	}
+}
+
+// WithTransitionDelay waits for d after stopping the previous output and before starting the next one.
+func (o *ConditionalOutput) WithTransitionDelay(d time.Duration) *ConditionalOutput {
+	o.transitionDelay = d
+	return o
+}
+
+// setStatsSerializer passes the serializer to both outputs.
+func (o *ConditionalOutput) setStatsSerializer(serializer *statsSerializer) {
+	setOutputSerializer(o.trueOutput, serializer)
+	setOutputSerializer(o.falseOutput, serializer)
+}
+
+// OnStart of ConditionalOutput has nothing to do, the output is started on the first OnEvent.
+func (o *ConditionalOutput) OnStart() {
+
+}
+
+// OnEvent will evaluate the condition and pass the data to the selected output.
+func (o *ConditionalOutput) OnEvent(data map[string]interface{}) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	next := o.falseOutput
+	if o.condition(data) {
+		next = o.trueOutput
+	}
+	if next != o.active {
+		if o.active != nil {
+			o.active.OnStop()
+			time.Sleep(o.transitionDelay)
+		}
+		o.active = next
+		o.active.OnStart()
+	}
+	o.active.OnEvent(data)
+}
+
+// OnStop will stop the active output.
+func (o *ConditionalOutput) OnStop() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.active != nil {
+		o.active.OnStop()
+		o.active = nil
+	}
+}
@@ -0,0 +1,70 @@
+package boomer
+
+import (
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// countingOutput tracks how many outputs are active at the same time.
+type countingOutput struct {
+	active *int64
+	events int
+	starts int
+	stops  int
+}
+
+func (o *countingOutput) OnStart() {
+	o.starts++
+	Expect(atomic.AddInt64(o.active, 1)).To(BeEquivalentTo(1))
+}
+
+func (o *countingOutput) OnEvent(data map[string]interface{}) {
+	o.events++
+}
+
+func (o *countingOutput) OnStop() {
+	o.stops++
+	atomic.AddInt64(o.active, -1)
+}
+
+var _ = Describe("Test conditional output", func() {
+
+	It("test transition on condition change", func() {
+		active := int64(0)
+		rampUp := &countingOutput{active: &active}
+		steady := &countingOutput{active: &active}
+
+		o := NewConditionalOutput(func(data map[string]interface{}) bool {
+			return data["user_count"].(int32) < 10
+		}, rampUp, steady).WithTransitionDelay(10 * time.Millisecond)
+
+		o.OnStart()
+		o.OnEvent(map[string]interface{}{"user_count": int32(1)})
+		o.OnEvent(map[string]interface{}{"user_count": int32(5)})
+		Expect(rampUp.starts).To(Equal(1))
+		Expect(rampUp.events).To(Equal(2))
+		Expect(steady.starts).To(Equal(0))
+
+		start := time.Now()
+		o.OnEvent(map[string]interface{}{"user_count": int32(10)})
+		Expect(time.Since(start)).To(BeNumerically(">=", 10*time.Millisecond))
+		Expect(rampUp.stops).To(Equal(1))
+		Expect(steady.starts).To(Equal(1))
+		Expect(steady.events).To(Equal(1))
+
+		o.OnEvent(map[string]interface{}{"user_count": int32(5)})
+		Expect(steady.stops).To(Equal(1))
+		Expect(rampUp.starts).To(Equal(2))
+
+		o.OnStop()
+		Expect(rampUp.stops).To(Equal(2))
+		Expect(steady.stops).To(Equal(1))
+		Expect(active).To(BeZero())
+
+		o.OnStop()
+		Expect(rampUp.stops).To(Equal(2))
+	})
+})
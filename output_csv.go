@@ -0,0 +1,192 @@
+package boomer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var csvFileOutputHeader = []string{
+	"Type", "Name", "Timestamp", "NumRequests", "NumFailures", "MedianResponseTime", "AvgResponseTime",
+	"MinResponseTime", "MaxResponseTime", "AvgContentLength", "CurrentRPS", "CurrentFailPerSec",
+}
+
+// CSVFileOutput writes the stats of every report interval to a CSV file, one row per endpoint,
+// plus an "Aggregated" row with an empty type for the total. The timestamp is in unix seconds.
+type CSVFileOutput struct {
+	statsConverter
+
+	path         string
+	maxSizeBytes int64
+
+	lock     sync.Mutex
+	file     *sizeCountingWriter
+	writer   *csv.Writer
+	sequence int // the number of rotations, it's the suffix of the current file
+	logger   *log.Logger
+}
+
+// sizeCountingWriter counts the bytes written to the file.
+type sizeCountingWriter struct {
+	*os.File
+	size int64
+}
+
+func (w *sizeCountingWriter) Write(p []byte) (int, error) {
+	n, err := w.File.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// NewCSVFileOutput returns a CSVFileOutput, which writes to path.
+// If path is empty, the stats are saved as "{testName}_stats.csv" in the directory set by Boomer.WithOutputDirectory,
+// or "stats.csv" in the working directory.
+func NewCSVFileOutput(path string) *CSVFileOutput {
+	return &CSVFileOutput{
+		path:   path,
+		logger: log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *CSVFileOutput) WithLogger(logger *log.Logger) *CSVFileOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+// WithMaxSizeBytes rotates the file once it reaches n bytes, the rows of the next intervals are written
+// to a new file with a sequence number suffix, like "stats.1.csv", "stats.2.csv" and so on.
+// Every file starts with the header row. If n isn't positive, which is the default, the file is never rotated.
+func (o *CSVFileOutput) WithMaxSizeBytes(n int64) *CSVFileOutput {
+	o.maxSizeBytes = n
+	return o
+}
+
+func (o *CSVFileOutput) setOutputDirectory(dir, testName string) {
+	o.path = resolveOutputPath(o.path, dir, testName, "stats.csv")
+}
+
+// rotatedPath returns the path of the file after sequence rotations.
+func (o *CSVFileOutput) rotatedPath(sequence int) string {
+	if sequence == 0 {
+		return o.path
+	}
+	ext := filepath.Ext(o.path)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(o.path, ext), sequence, ext)
+}
+
+// OnStart creates the file and writes the header row.
+func (o *CSVFileOutput) OnStart() {
+	if o.path == "" {
+		o.path = "stats.csv"
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.sequence = 0
+	if err := o.open(); err != nil {
+		o.logger.Printf("Error creating CSV file, %v\n", err)
+	}
+}
+
+// open creates the file of the current sequence and writes the header row.
+func (o *CSVFileOutput) open() error {
+	path := o.rotatedPath(o.sequence)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	o.file = &sizeCountingWriter{File: f}
+	o.writer = csv.NewWriter(o.file)
+	o.writer.Write(csvFileOutputHeader)
+	o.writer.Flush()
+	return o.writer.Error()
+}
+
+// close flushes and closes the current file.
+func (o *CSVFileOutput) close() error {
+	if o.file == nil {
+		return nil
+	}
+	o.writer.Flush()
+	err := o.writer.Error()
+	if closeErr := o.file.Close(); err == nil {
+		err = closeErr
+	}
+	o.file = nil
+	o.writer = nil
+	return err
+}
+
+// OnEvent appends the rows of the interval to the file.
+func (o *CSVFileOutput) OnEvent(data map[string]interface{}) {
+	if err := o.OnEventWithError(data); err != nil {
+		o.logger.Printf("Error writing CSV file, %v\n", err)
+	}
+}
+
+// OnEventWithError appends the rows of the interval to the file, and returns the error of converting or writing them.
+// The rows are flushed at the end of every interval. If the file has reached the max size, it's rotated before writing.
+func (o *CSVFileOutput) OnEventWithError(data map[string]interface{}) error {
+	output, err := o.convertData(data)
+	if err != nil {
+		return err
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.file == nil {
+		return nil
+	}
+	if o.maxSizeBytes > 0 && o.file.size >= o.maxSizeBytes {
+		if err := o.close(); err != nil {
+			return err
+		}
+		o.sequence++
+		if err := o.open(); err != nil {
+			return fmt.Errorf("rotate CSV file, %w", err)
+		}
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	for _, stat := range output.Stats {
+		o.writer.Write(csvFileOutputRow(stat, stat.Method, stat.Name, timestamp, output.ResponseTimePrecision))
+	}
+	o.writer.Write(csvFileOutputRow(output.TotalStats, "", "Aggregated", timestamp, output.ResponseTimePrecision))
+	o.writer.Flush()
+	return o.writer.Error()
+}
+
+func csvFileOutputRow(stat *statsEntryOutput, method, name, timestamp string, precision int) []string {
+	return []string{
+		method,
+		name,
+		timestamp,
+		strconv.FormatInt(stat.NumRequests, 10),
+		strconv.FormatInt(stat.NumFailures, 10),
+		strconv.FormatInt(stat.medianResponseTime, 10),
+		strconv.FormatFloat(stat.avgResponseTime, 'f', precision, 64),
+		strconv.FormatInt(stat.MinResponseTime, 10),
+		strconv.FormatInt(stat.MaxResponseTime, 10),
+		strconv.FormatInt(stat.avgContentLength, 10),
+		strconv.FormatInt(stat.currentRps, 10),
+		strconv.FormatInt(stat.currentFailPerSec, 10),
+	}
+}
+
+// OnStop flushes and closes the file.
+func (o *CSVFileOutput) OnStop() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if err := o.close(); err != nil {
+		o.logger.Printf("Error closing CSV file, %v\n", err)
+	}
+}
@@ -0,0 +1,104 @@
+package boomer
+
+import (
+	"encoding/csv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test CSV file output", func() {
+
+	newData := func() map[string]interface{} {
+		stats := newRequestStats()
+		stats.logRequest("http", "login", 10, 100)
+		stats.logRequest("http", "login", 30, 300)
+		stats.logRequest("http", "logout", 20, 10)
+		stats.logError("http", "logout", "500")
+		data := stats.collectReportData()
+		data["user_count"] = int32(1)
+		return data
+	}
+
+	readCSV := func(path string) [][]string {
+		f, err := os.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+		records, err := csv.NewReader(f).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		return records
+	}
+
+	It("test csv file output", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "stats.csv")
+		o := NewCSVFileOutput(path).WithLogger(log.New(io.Discard, "", 0))
+		o.OnStart()
+		Expect(o.OnEventWithError(newData())).To(Succeed())
+		o.OnEvent(newData())
+		o.OnStop()
+		o.OnStop()
+
+		records := readCSV(path)
+		Expect(records).To(HaveLen(1 + 2*3))
+		Expect(records[0]).To(Equal([]string{
+			"Type", "Name", "Timestamp", "NumRequests", "NumFailures", "MedianResponseTime", "AvgResponseTime",
+			"MinResponseTime", "MaxResponseTime", "AvgContentLength", "CurrentRPS", "CurrentFailPerSec",
+		}))
+		rows := map[string][]string{}
+		for _, record := range records[1:4] {
+			rows[record[1]] = record
+		}
+		Expect(rows["login"][0]).To(Equal("http"))
+		Expect(rows["login"][2]).To(MatchRegexp(`^\d{10}$`))
+		// the requests are in the current second, which isn't in the rps window yet
+		Expect(rows["login"][3:]).To(Equal([]string{"2", "0", "10", "20.00", "10", "30", "200", "0", "0"}))
+		Expect(rows["logout"][3:5]).To(Equal([]string{"1", "1"}))
+		Expect(rows["Aggregated"][0]).To(BeEmpty())
+		Expect(rows["Aggregated"][3:5]).To(Equal([]string{"3", "1"}))
+	})
+
+	It("test csv file output rotation", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "stats.csv")
+		// the header and the rows of an interval are more than 200 bytes, so every file has 2 intervals
+		o := NewCSVFileOutput(path).WithMaxSizeBytes(400).WithLogger(log.New(io.Discard, "", 0))
+		o.OnStart()
+		for i := 0; i < 5; i++ {
+			Expect(o.OnEventWithError(newData())).To(Succeed())
+		}
+		o.OnStop()
+
+		dir := filepath.Dir(path)
+		files, err := filepath.Glob(filepath.Join(dir, "stats*.csv"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(ConsistOf(path, filepath.Join(dir, "stats.1.csv"), filepath.Join(dir, "stats.2.csv")))
+		for i, file := range []string{path, filepath.Join(dir, "stats.1.csv"), filepath.Join(dir, "stats.2.csv")} {
+			records := readCSV(file)
+			Expect(records[0][0]).To(Equal("Type"))
+			if i < 2 {
+				Expect(records).To(HaveLen(1 + 2*3))
+			} else {
+				Expect(records).To(HaveLen(1 + 3))
+			}
+		}
+	})
+
+	It("test csv file output in output directory", func() {
+		dir := filepath.Join(GinkgoT().TempDir(), "results")
+		b := NewStandaloneBoomer(1, 1).WithOutputDirectory(dir).WithTestName("checkout flow")
+		b.AddOutput(NewCSVFileOutput(""))
+		b.setupRunner(&newLocalRunner(nil, nil, 1, 1).runner)
+		Expect(b.outputs[0].(*CSVFileOutput).path).To(Equal(filepath.Join(dir, "checkout_flow_stats.csv")))
+	})
+
+	It("test csv file output with invalid path", func() {
+		o := NewCSVFileOutput(filepath.Join(GinkgoT().TempDir(), "missing", "stats.csv")).WithLogger(log.New(io.Discard, "", 0))
+		o.OnStart()
+		Expect(o.file).To(BeNil())
+		Expect(o.OnEventWithError(newData())).To(Succeed())
+		o.OnStop()
+	})
+})
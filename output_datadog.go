@@ -0,0 +1,127 @@
+package boomer
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// datadogNamespace is the prefix of the metric names of DatadogOutput.
+const datadogNamespace = "dd.boomer."
+
+var datadogTagReplacer = strings.NewReplacer(",", "_", "|", "_")
+
+// DatadogOutput sends the stats of every report interval to a DogStatsD agent, with the tags method and name
+// of every endpoint, e.g. the gauge "dd.boomer.num_requests" with the tags "method:http,name:login".
+// The requests and failures are also sent as the counts "dd.boomer.requests" and "dd.boomer.failures",
+// which are stored as RATE by Datadog, so the rates per second are computed by Datadog.
+type DatadogOutput struct {
+	statsConverter
+
+	addr string
+	tags []string
+
+	lock   sync.Mutex
+	client *statsd.Client
+	logger *log.Logger
+}
+
+// NewDatadogOutput returns a DatadogOutput, which sends to the DogStatsD agent at addr, e.g. "localhost:8125",
+// or "unix:///var/run/datadog/dsd.socket".
+func NewDatadogOutput(addr string) *DatadogOutput {
+	return &DatadogOutput{
+		addr:   addr,
+		logger: log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *DatadogOutput) WithLogger(logger *log.Logger) *DatadogOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+// WithTags appends tags to all the metrics, like "env:staging" or "service:checkout".
+func (o *DatadogOutput) WithTags(tags []string) *DatadogOutput {
+	o.tags = append(o.tags, tags...)
+	return o
+}
+
+// OnStart creates the DogStatsD client.
+func (o *DatadogOutput) OnStart() {
+	client, err := statsd.New(o.addr, statsd.WithNamespace(datadogNamespace), statsd.WithTags(o.tags))
+	if err != nil {
+		o.logger.Printf("Error creating DogStatsD client, %v\n", err)
+		return
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.client = client
+}
+
+// OnEvent sends the stats to the DogStatsD agent.
+func (o *DatadogOutput) OnEvent(data map[string]interface{}) {
+	output, err := o.convertData(data)
+	if err != nil {
+		o.logger.Printf("convert data error: %v\n", err)
+		return
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.client == nil {
+		return
+	}
+	// the errors of the client are the same for every metric, so only the first one is logged
+	var errs []error
+	gauge := func(name string, value float64, tags []string) {
+		errs = append(errs, o.client.Gauge(name, value, tags, 1))
+	}
+	gauge("users", float64(output.UserCount), nil)
+	gauge("total_rps", float64(output.TotalRPS), nil)
+	gauge("fail_ratio", output.TotalFailRatio, nil)
+	for _, stat := range output.Stats {
+		tags := []string{"method:" + datadogTagReplacer.Replace(stat.Method), "name:" + datadogTagReplacer.Replace(stat.Name)}
+		gauge("num_requests", float64(stat.NumRequests), tags)
+		gauge("num_failures", float64(stat.NumFailures), tags)
+		gauge("median_response_time", float64(stat.medianResponseTime), tags)
+		gauge("p95_response_time", float64(stat.P95), tags)
+		gauge("p99_response_time", float64(stat.P99), tags)
+		gauge("average_response_time", stat.avgResponseTime, tags)
+		gauge("min_response_time", float64(stat.MinResponseTime), tags)
+		gauge("max_response_time", float64(stat.MaxResponseTime), tags)
+		gauge("average_content_length", float64(stat.avgContentLength), tags)
+		gauge("current_rps", float64(stat.currentRps), tags)
+		gauge("current_fail_per_sec", float64(stat.currentFailPerSec), tags)
+		// the stats are reset every interval, so the counts of the interval are the increments
+		errs = append(errs, o.client.Count("requests", stat.NumRequests, tags, 1))
+		errs = append(errs, o.client.Count("failures", stat.NumFailures, tags, 1))
+	}
+	for _, err := range errs {
+		if err != nil {
+			o.logger.Printf("Error sending to DogStatsD, %v\n", err)
+			break
+		}
+	}
+}
+
+// OnStop flushes the buffered metrics and closes the client.
+func (o *DatadogOutput) OnStop() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.client == nil {
+		return
+	}
+	if err := o.client.Flush(); err != nil {
+		o.logger.Printf("Error flushing DogStatsD client, %v\n", err)
+	}
+	if err := o.client.Close(); err != nil {
+		o.logger.Printf("Error closing DogStatsD client, %v\n", err)
+	}
+	o.client = nil
+}
@@ -0,0 +1,64 @@
+package boomer
+
+import (
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test Datadog output", func() {
+
+	It("test datadog output", func() {
+		agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer agent.Close()
+
+		stats := newRequestStats()
+		stats.logRequest("http", "login", 10, 100)
+		stats.logRequest("http", "login", 30, 300)
+		stats.logError("http", "login", "500")
+		data := stats.collectReportData()
+		data["user_count"] = int32(5)
+
+		o := NewDatadogOutput(agent.LocalAddr().String()).WithTags([]string{"env:staging"}).WithTags([]string{"service:checkout"})
+		o.OnStart()
+		o.OnEvent(data)
+		o.OnStop()
+		o.OnStop()
+
+		metrics := map[string]string{}
+		buf := make([]byte, 65536)
+		for {
+			agent.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, _, err := agent.ReadFrom(buf)
+			if err != nil {
+				break
+			}
+			for _, line := range strings.Split(strings.TrimSpace(string(buf[:n])), "\n") {
+				metrics[line[:strings.Index(line, ":")]] = line
+			}
+		}
+		Expect(metrics).To(HaveKeyWithValue("dd.boomer.users", "dd.boomer.users:5|g|#env:staging,service:checkout"))
+		Expect(metrics).To(HaveKeyWithValue("dd.boomer.num_requests",
+			"dd.boomer.num_requests:2|g|#env:staging,service:checkout,method:http,name:login"))
+		Expect(metrics).To(HaveKeyWithValue("dd.boomer.median_response_time",
+			"dd.boomer.median_response_time:10|g|#env:staging,service:checkout,method:http,name:login"))
+		Expect(metrics).To(HaveKeyWithValue("dd.boomer.requests",
+			"dd.boomer.requests:2|c|#env:staging,service:checkout,method:http,name:login"))
+		Expect(metrics).To(HaveKeyWithValue("dd.boomer.failures",
+			"dd.boomer.failures:1|c|#env:staging,service:checkout,method:http,name:login"))
+	})
+
+	It("test datadog output with invalid address", func() {
+		o := NewDatadogOutput("invalid address").WithLogger(log.New(io.Discard, "", 0))
+		o.OnStart()
+		Expect(o.client).To(BeNil())
+		o.OnEvent(newRequestStats().collectReportData())
+		o.OnStop()
+	})
+})
@@ -0,0 +1,146 @@
+package boomer
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+// shades from the lowest density to the highest.
+var heatmapShades = []rune{' ', '░', '▒', '▓', '█'}
+
+// LatencyHeatmapOutput renders a heat map of response times over time to the terminal.
+// Each column is a report interval, and each row is a response time bucket on a logarithmic scale.
+// The shade of a cell is the ratio of requests in the bucket during the interval.
+type LatencyHeatmapOutput struct {
+	statsConverter
+
+	width   int
+	bounds  []int64 // upper bounds of buckets in ms, the last bucket has no upper bound
+	columns *circularBuffer[[]int64]
+	lock    sync.Mutex
+	logger  *log.Logger
+}
+
+// NewLatencyHeatmapOutput returns a LatencyHeatmapOutput, which keeps the latest width intervals
+// and height response time buckets.
+func NewLatencyHeatmapOutput(width, height int) *LatencyHeatmapOutput {
+	if width <= 0 {
+		width = 1
+	}
+	if height <= 1 {
+		height = 2
+	}
+	// 1ms, 5ms, 10ms, 50ms, 100ms, 500ms, 1s ...
+	bounds := make([]int64, height-1)
+	bound := int64(1)
+	for i := range bounds {
+		bounds[i] = bound
+		if i%2 == 0 {
+			bound *= 5
+		} else {
+			bound *= 2
+		}
+	}
+	return &LatencyHeatmapOutput{
+		width:   width,
+		bounds:  bounds,
+		columns: newCircularBuffer[[]int64](width),
+		logger:  log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *LatencyHeatmapOutput) WithLogger(logger *log.Logger) *LatencyHeatmapOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+// OnStart of LatencyHeatmapOutput has nothing to do.
+func (o *LatencyHeatmapOutput) OnStart() {
+
+}
+
+// OnEvent will add a column to the heat map and render it, overwriting the previous frame.
+func (o *LatencyHeatmapOutput) OnEvent(data map[string]interface{}) {
+	output, err := o.convertData(data)
+	if err != nil {
+		o.logger.Printf("convert data error: %v\n", err)
+		return
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.addColumn(output.TotalStats.ResponseTimes)
+	// move the cursor to the top left corner and clear the screen
+	io.WriteString(o.logger.Writer(), "\033[H\033[2J"+o.render())
+}
+
+// OnStop will print the final heat map.
+func (o *LatencyHeatmapOutput) OnStop() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	io.WriteString(o.logger.Writer(), o.render())
+}
+
+func (o *LatencyHeatmapOutput) bucket(responseTime int64) int {
+	for i, bound := range o.bounds {
+		if responseTime <= bound {
+			return i
+		}
+	}
+	return len(o.bounds)
+}
+
+func (o *LatencyHeatmapOutput) addColumn(responseTimes map[int64]int64) {
+	column := make([]int64, len(o.bounds)+1)
+	for responseTime, count := range responseTimes {
+		column[o.bucket(responseTime)] += count
+	}
+	o.columns.Push(column)
+}
+
+func formatLatency(ms int64) string {
+	if ms >= 1000 && ms%1000 == 0 {
+		return fmt.Sprintf("%ds", ms/1000)
+	}
+	return fmt.Sprintf("%dms", ms)
+}
+
+func (o *LatencyHeatmapOutput) render() string {
+	labels := make([]string, len(o.bounds)+1)
+	for i, bound := range o.bounds {
+		labels[i] = formatLatency(bound)
+	}
+	labels[len(o.bounds)] = ">" + labels[len(o.bounds)-1]
+
+	var sb strings.Builder
+	// the highest response time bucket is on the top
+	for row := len(labels) - 1; row >= 0; row-- {
+		sb.WriteString(fmt.Sprintf("%7s │", labels[row]))
+		for _, column := range o.columns.Last(o.width) {
+			sb.WriteRune(heatmapShade(column, row))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("%7s └%s\n", "", strings.Repeat("─", o.width)))
+	return sb.String()
+}
+
+func heatmapShade(column []int64, row int) rune {
+	total := int64(0)
+	for _, count := range column {
+		total += count
+	}
+	if total == 0 || column[row] == 0 {
+		return heatmapShades[0]
+	}
+	// any request makes the cell visible
+	level := 1 + int(column[row]*int64(len(heatmapShades)-2)/total)
+	return heatmapShades[level]
+}
@@ -0,0 +1,63 @@
+package boomer
+
+import (
+	"log"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Test latency heatmap output", func() {
+
+	newData := func(responseTimes map[int64]int64) map[string]interface{} {
+		stats := newRequestStats()
+		for responseTime, count := range responseTimes {
+			for i := int64(0); i < count; i++ {
+				stats.logRequest("http", "foo", responseTime, 0)
+			}
+		}
+		data := stats.collectReportData()
+		data["user_count"] = int32(1)
+		return data
+	}
+
+	It("test buckets", func() {
+		o := NewLatencyHeatmapOutput(10, 8)
+		Expect(o.bounds).To(Equal([]int64{1, 5, 10, 50, 100, 500, 1000}))
+		Expect(o.bucket(0)).To(Equal(0))
+		Expect(o.bucket(3)).To(Equal(1))
+		Expect(o.bucket(1000)).To(Equal(6))
+		Expect(o.bucket(1001)).To(Equal(7))
+	})
+
+	It("test render", func() {
+		buf := gbytes.NewBuffer()
+		o := NewLatencyHeatmapOutput(2, 8).WithLogger(log.New(buf, "", 0))
+		o.OnStart()
+
+		o.OnEvent(newData(map[int64]int64{3: 10}))
+		Eventually(buf).Should(gbytes.Say("\033\\[H\033\\[2J"))
+		o.OnEvent(newData(map[int64]int64{3: 5, 2000: 5}))
+		o.OnEvent(newData(map[int64]int64{40: 1}))
+		Expect(o.columns.Len()).To(Equal(2))
+
+		buf = gbytes.NewBuffer()
+		o.WithLogger(log.New(buf, "", 0))
+		o.OnStop()
+		lines := strings.Split(string(buf.Contents()), "\n")
+		Expect(lines[0]).To(Equal("    >1s │▒ "))
+		Expect(lines[4]).To(Equal("   50ms │ █"))
+		Expect(lines[6]).To(Equal("    5ms │▒ "))
+		Expect(lines[8]).To(Equal("        └──"))
+	})
+
+	It("test invalid data", func() {
+		buf := gbytes.NewBuffer()
+		o := NewLatencyHeatmapOutput(0, 0).WithLogger(log.New(buf, "", 0))
+		o.OnEvent(map[string]interface{}{})
+		Expect(buf).To(gbytes.Say("convert data error"))
+		Expect(o.columns.Len()).To(BeZero())
+	})
+})
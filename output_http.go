@@ -0,0 +1,190 @@
+package boomer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHTTPOutputTimeout      = 5 * time.Second
+	defaultHTTPOutputRetryBackoff = 100 * time.Millisecond
+)
+
+// HTTPOutput posts the stats of every report interval to a URL as JSON, with the same fields as the other outputs,
+// e.g. to forward the stats to a dashboard without a Prometheus Pushgateway.
+// A failed post is logged, and the stats of the next interval are posted as usual.
+type HTTPOutput struct {
+	statsConverter
+
+	url          string
+	client       *http.Client
+	headers      map[string]string
+	basicAuth    bool
+	username     string
+	password     string
+	maxRetries   int
+	retryBackoff time.Duration
+
+	lock   sync.Mutex
+	ctx    context.Context // canceled by OnStop, to stop waiting for the retries
+	cancel context.CancelFunc
+	logger *log.Logger
+}
+
+// NewHTTPOutput returns an HTTPOutput, which posts to url.
+func NewHTTPOutput(url string) *HTTPOutput {
+	return &HTTPOutput{
+		url:          url,
+		client:       &http.Client{Timeout: defaultHTTPOutputTimeout},
+		retryBackoff: defaultHTTPOutputRetryBackoff,
+		logger:       log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *HTTPOutput) WithLogger(logger *log.Logger) *HTTPOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+// WithTimeout sets the timeout of every post, including reading the response. The default is 5 seconds.
+func (o *HTTPOutput) WithTimeout(d time.Duration) *HTTPOutput {
+	if d <= 0 {
+		o.logger.Printf("Invalid timeout %v, ignored!\n", d)
+		return o
+	}
+	o.client.Timeout = d
+	return o
+}
+
+// WithHeaders sets the headers of every post, in addition to "Content-Type: application/json".
+func (o *HTTPOutput) WithHeaders(headers map[string]string) *HTTPOutput {
+	o.headers = make(map[string]string, len(headers))
+	for k, v := range headers {
+		o.headers[k] = v
+	}
+	return o
+}
+
+// WithBasicAuth sets the username and password of HTTP basic authentication.
+func (o *HTTPOutput) WithBasicAuth(username, password string) *HTTPOutput {
+	o.basicAuth = true
+	o.username = username
+	o.password = password
+	return o
+}
+
+// WithMaxRetries retries a failed post up to n times, which is 0 by default.
+// The retries wait with exponential backoff from WithRetryBackoff, keep n small so the post of an interval
+// finishes before the next one.
+func (o *HTTPOutput) WithMaxRetries(n int) *HTTPOutput {
+	if n < 0 {
+		o.logger.Printf("Invalid max retries %d, ignored!\n", n)
+		return o
+	}
+	o.maxRetries = n
+	return o
+}
+
+// WithRetryBackoff sets the wait before the first retry, which is doubled for every retry. The default is 100ms.
+func (o *HTTPOutput) WithRetryBackoff(base time.Duration) *HTTPOutput {
+	if base <= 0 {
+		o.logger.Printf("Invalid retry backoff %v, ignored!\n", base)
+		return o
+	}
+	o.retryBackoff = base
+	return o
+}
+
+// OnStart is called before the test starts.
+func (o *HTTPOutput) OnStart() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.ctx, o.cancel = context.WithCancel(context.Background())
+}
+
+// OnEvent posts the stats to the URL.
+func (o *HTTPOutput) OnEvent(data map[string]interface{}) {
+	if err := o.OnEventWithError(data); err != nil {
+		o.logger.Printf("Error posting stats to %s, %v\n", o.url, err)
+	}
+}
+
+// OnEventWithError posts the stats to the URL, and returns the error of the last attempt if all of them fail.
+func (o *HTTPOutput) OnEventWithError(data map[string]interface{}) error {
+	output, err := o.convertData(data)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(output)
+	if err != nil {
+		return err
+	}
+
+	o.lock.Lock()
+	ctx := o.ctx
+	o.lock.Unlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = o.post(ctx, body)
+		if err == nil || attempt >= o.maxRetries {
+			return err
+		}
+		timer := time.NewTimer(o.retryBackoff << attempt)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// post sends body to the URL, a response with a non-2xx status is an error.
+func (o *HTTPOutput) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+	if o.basicAuth {
+		req.SetBasicAuth(o.username, o.password)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// OnStop stops waiting for the retries.
+func (o *HTTPOutput) OnStop() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.cancel != nil {
+		o.cancel()
+	}
+	o.ctx = nil
+	o.cancel = nil
+}
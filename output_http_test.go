@@ -0,0 +1,127 @@
+package boomer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test HTTP output", func() {
+
+	newData := func() map[string]interface{} {
+		stats := newRequestStats()
+		stats.logRequest("http", "login", 10, 100)
+		stats.logError("http", "login", "500")
+		data := stats.collectReportData()
+		data["user_count"] = int32(1)
+		data["test_name"] = "checkout"
+		return data
+	}
+
+	It("test http output posts the stats", func() {
+		received := make(chan map[string]interface{}, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			defer GinkgoRecover()
+			Expect(req.Method).To(Equal(http.MethodPost))
+			Expect(req.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(req.Header.Get("X-Api-Key")).To(Equal("secret"))
+			username, password, ok := req.BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(username).To(Equal("boomer"))
+			Expect(password).To(Equal("pass"))
+			body := map[string]interface{}{}
+			Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+			received <- body
+		}))
+		defer server.Close()
+
+		o := NewHTTPOutput(server.URL).
+			WithHeaders(map[string]string{"X-Api-Key": "secret"}).
+			WithBasicAuth("boomer", "pass")
+		o.OnStart()
+		Expect(o.OnEventWithError(newData())).To(Succeed())
+		o.OnStop()
+
+		body := <-received
+		Expect(body["test_name"]).To(Equal("checkout"))
+		Expect(body["user_count"]).To(BeEquivalentTo(1))
+		total := body["stats_total"].(map[string]interface{})
+		Expect(total["num_requests"]).To(BeEquivalentTo(1))
+		Expect(total["num_failures"]).To(BeEquivalentTo(1))
+	})
+
+	It("test http output retries", func() {
+		var calls, failures int32 = 0, 2
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			if atomic.AddInt32(&failures, -1) >= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+
+		o := NewHTTPOutput(server.URL).WithMaxRetries(2).WithRetryBackoff(time.Millisecond)
+		o.OnStart()
+		defer o.OnStop()
+		Expect(o.OnEventWithError(newData())).To(Succeed())
+		Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(3))
+
+		// all the attempts fail
+		atomic.StoreInt32(&calls, 0)
+		atomic.StoreInt32(&failures, 10)
+		Expect(o.OnEventWithError(newData())).To(MatchError("unexpected status 503 Service Unavailable"))
+		Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(3))
+	})
+
+	It("test http output logs the errors and posts the next event", func() {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				time.Sleep(200 * time.Millisecond)
+			}
+		}))
+		defer server.Close()
+
+		var buf bytes.Buffer
+		o := NewHTTPOutput(server.URL).WithTimeout(50 * time.Millisecond).WithLogger(log.New(&buf, "", 0))
+		o.OnStart()
+		defer o.OnStop()
+		o.OnEvent(newData())
+		Expect(buf.String()).To(ContainSubstring("Error posting stats to " + server.URL))
+		Expect(o.OnEventWithError(newData())).To(Succeed())
+		Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(2))
+	})
+
+	It("test http output stops retrying on stop", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		o := NewHTTPOutput(server.URL).WithMaxRetries(1).WithRetryBackoff(time.Hour)
+		o.OnStart()
+		done := make(chan error)
+		go func() {
+			done <- o.OnEventWithError(newData())
+		}()
+		time.Sleep(50 * time.Millisecond)
+		o.OnStop()
+		Eventually(done).Should(Receive(MatchError("unexpected status 500 Internal Server Error")))
+	})
+
+	It("test invalid options are ignored", func() {
+		o := NewHTTPOutput("http://localhost").WithLogger(log.New(io.Discard, "", 0)).
+			WithTimeout(0).WithMaxRetries(-1).WithRetryBackoff(-time.Second)
+		Expect(o.client.Timeout).To(Equal(defaultHTTPOutputTimeout))
+		Expect(o.maxRetries).To(Equal(0))
+		Expect(o.retryBackoff).To(Equal(defaultHTTPOutputRetryBackoff))
+	})
+})
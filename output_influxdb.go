@@ -0,0 +1,158 @@
+package boomer
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+const defaultInfluxDBWriteTimeout = 10 * time.Second
+
+// InfluxDBOutput writes the stats of every report interval to InfluxDB in line protocol, for the teams using
+// InfluxDB or Telegraf instead of Prometheus. Each endpoint is a point of the measurement "boomer_request"
+// with the tags method and name, and the total is a point of the measurement "boomer_total".
+type InfluxDBOutput struct {
+	statsConverter
+
+	addr      string
+	org       string
+	bucket    string
+	token     string
+	batchSize int
+
+	lock      sync.Mutex
+	client    influxdb2.Client
+	writeAPI  api.WriteAPIBlocking
+	pending   []*write.Point
+	intervals int // the number of intervals in pending
+	logger    *log.Logger
+}
+
+// NewInfluxDBOutput returns an InfluxDBOutput, which writes to bucket of org in the InfluxDB server at addr,
+// e.g. "http://localhost:8086", authenticated with token.
+func NewInfluxDBOutput(addr, org, bucket, token string) *InfluxDBOutput {
+	return &InfluxDBOutput{
+		addr:      addr,
+		org:       org,
+		bucket:    bucket,
+		token:     token,
+		batchSize: 1,
+		logger:    log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *InfluxDBOutput) WithLogger(logger *log.Logger) *InfluxDBOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+// WithBatchSize buffers the points of n intervals, and writes them in a batch, the default is 1.
+// The buffered points are written by OnStop.
+func (o *InfluxDBOutput) WithBatchSize(n int) *InfluxDBOutput {
+	if n < 1 {
+		o.logger.Printf("Invalid batch size %d, ignored!\n", n)
+		return o
+	}
+	o.batchSize = n
+	return o
+}
+
+// OnStart creates the client of InfluxDB.
+func (o *InfluxDBOutput) OnStart() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.client = influxdb2.NewClient(o.addr, o.token)
+	o.writeAPI = o.client.WriteAPIBlocking(o.org, o.bucket)
+	o.pending = nil
+	o.intervals = 0
+}
+
+// OnEvent writes the stats to InfluxDB.
+func (o *InfluxDBOutput) OnEvent(data map[string]interface{}) {
+	if err := o.OnEventWithError(data); err != nil {
+		o.logger.Printf("Error writing to InfluxDB, %v\n", err)
+	}
+}
+
+// OnEventWithError writes the stats to InfluxDB once there are the points of batchSize intervals,
+// and returns the error of converting or writing them. The points are dropped if the write fails.
+func (o *InfluxDBOutput) OnEventWithError(data map[string]interface{}) error {
+	output, err := o.convertData(data)
+	if err != nil {
+		return err
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.writeAPI == nil {
+		return nil
+	}
+	now := time.Now()
+	for _, stat := range output.Stats {
+		o.pending = append(o.pending, influxdb2.NewPoint("boomer_request",
+			map[string]string{"method": stat.Method, "name": stat.Name}, influxDBFields(stat), now))
+	}
+	if output.TotalStats != nil {
+		fields := influxDBFields(output.TotalStats)
+		fields["user_count"] = int64(output.UserCount)
+		fields["total_rps"] = output.TotalRPS
+		fields["total_fail_ratio"] = output.TotalFailRatio
+		o.pending = append(o.pending, influxdb2.NewPoint("boomer_total", nil, fields, now))
+	}
+	o.intervals++
+	if o.intervals < o.batchSize {
+		return nil
+	}
+	return o.flush()
+}
+
+// flush writes the pending points.
+func (o *InfluxDBOutput) flush() error {
+	points := o.pending
+	o.pending = nil
+	o.intervals = 0
+	if len(points) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultInfluxDBWriteTimeout)
+	defer cancel()
+	return o.writeAPI.WritePoint(ctx, points...)
+}
+
+func influxDBFields(stat *statsEntryOutput) map[string]interface{} {
+	return map[string]interface{}{
+		"num_requests":         stat.NumRequests,
+		"num_failures":         stat.NumFailures,
+		"median_response_time": stat.medianResponseTime,
+		"avg_response_time":    stat.avgResponseTime,
+		"min_response_time":    stat.MinResponseTime,
+		"max_response_time":    stat.MaxResponseTime,
+		"current_rps":          stat.currentRps,
+		"current_fail_per_sec": stat.currentFailPerSec,
+		"avg_content_length":   stat.avgContentLength,
+	}
+}
+
+// OnStop writes the buffered points, and closes the client.
+func (o *InfluxDBOutput) OnStop() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.client == nil {
+		return
+	}
+	if err := o.flush(); err != nil {
+		o.logger.Printf("Error writing to InfluxDB, %v\n", err)
+	}
+	o.client.Close()
+	o.client = nil
+	o.writeAPI = nil
+}
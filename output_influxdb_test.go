@@ -0,0 +1,108 @@
+package boomer
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test InfluxDB output", func() {
+
+	var (
+		lock    sync.Mutex
+		batches []string
+		status  int
+		server  *httptest.Server
+	)
+
+	BeforeEach(func() {
+		batches = nil
+		status = http.StatusNoContent
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			Expect(r.URL.Path).To(Equal("/api/v2/write"))
+			Expect(r.URL.Query().Get("org")).To(Equal("perf"))
+			Expect(r.URL.Query().Get("bucket")).To(Equal("loadtest"))
+			Expect(r.Header.Get("Authorization")).To(Equal("Token secret"))
+			body, err := io.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+			lock.Lock()
+			defer lock.Unlock()
+			batches = append(batches, string(body))
+			w.WriteHeader(status)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newData := func() map[string]interface{} {
+		stats := newRequestStats()
+		stats.logRequest("http", "login", 10, 100)
+		stats.logRequest("http", "login", 30, 300)
+		stats.logError("http", "login", "500")
+		data := stats.collectReportData()
+		data["user_count"] = int32(2)
+		return data
+	}
+
+	It("test influxdb output", func() {
+		o := NewInfluxDBOutput(server.URL, "perf", "loadtest", "secret")
+		o.OnStart()
+		Expect(o.OnEventWithError(newData())).To(Succeed())
+		o.OnStop()
+
+		Expect(batches).To(HaveLen(1))
+		lines := strings.Split(strings.TrimSpace(batches[0]), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(HavePrefix("boomer_request,method=http,name=login "))
+		for _, field := range []string{
+			"num_requests=2i", "num_failures=1i", "median_response_time=10i", "avg_response_time=20",
+			"min_response_time=10i", "max_response_time=30i", "avg_content_length=200i",
+			"current_rps=", "current_fail_per_sec=",
+		} {
+			Expect(lines[0]).To(ContainSubstring(field))
+		}
+		Expect(lines[1]).To(HavePrefix("boomer_total "))
+		Expect(lines[1]).To(ContainSubstring("num_requests=2i"))
+		Expect(lines[1]).To(ContainSubstring("user_count=2i"))
+	})
+
+	It("test influxdb output with batch size", func() {
+		o := NewInfluxDBOutput(server.URL, "perf", "loadtest", "secret").WithBatchSize(2)
+		o.OnStart()
+		for i := 0; i < 3; i++ {
+			o.OnEvent(newData())
+		}
+		Expect(batches).To(HaveLen(1))
+		Expect(strings.Split(strings.TrimSpace(batches[0]), "\n")).To(HaveLen(4))
+
+		// the last interval is written on stop
+		o.OnStop()
+		Expect(batches).To(HaveLen(2))
+		Expect(strings.Split(strings.TrimSpace(batches[1]), "\n")).To(HaveLen(2))
+	})
+
+	It("test influxdb output with write error", func() {
+		status = http.StatusUnauthorized
+		o := NewInfluxDBOutput(server.URL, "perf", "loadtest", "secret").WithLogger(log.New(io.Discard, "", 0))
+		o.OnStart()
+		Expect(o.OnEventWithError(newData())).To(HaveOccurred())
+		Expect(o.pending).To(BeEmpty())
+		o.OnStop()
+		Expect(batches).To(HaveLen(1))
+	})
+
+	It("test invalid batch size is ignored", func() {
+		o := NewInfluxDBOutput(server.URL, "perf", "loadtest", "secret").WithLogger(log.New(io.Discard, "", 0)).
+			WithBatchSize(0)
+		Expect(o.batchSize).To(Equal(1))
+	})
+})
@@ -0,0 +1,154 @@
+package boomer
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// JSONLinesFileOutput appends the stats of every report interval to a file as a JSON object per line,
+// with the same fields as the other outputs and a "timestamp" in RFC3339, to be parsed by other tools.
+type JSONLinesFileOutput struct {
+	statsConverter
+
+	path        string
+	prettyPrint bool
+	gzip        bool
+
+	lock    sync.Mutex
+	file    *os.File
+	gw      *gzip.Writer // wraps file if gzip is enabled
+	encoder *json.Encoder
+	logger  *log.Logger
+}
+
+// NewJSONLinesFileOutput returns a JSONLinesFileOutput, which appends to path, the file is created if it doesn't exist.
+// If path is empty, the stats are saved as "{testName}_stats.jsonl" in the directory set by Boomer.WithOutputDirectory,
+// or "stats.jsonl" in the working directory.
+func NewJSONLinesFileOutput(path string) *JSONLinesFileOutput {
+	return &JSONLinesFileOutput{
+		path:   path,
+		logger: log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *JSONLinesFileOutput) WithLogger(logger *log.Logger) *JSONLinesFileOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+// WithPrettyPrint writes indented JSON for debugging, so an object spans multiple lines.
+func (o *JSONLinesFileOutput) WithPrettyPrint(enabled bool) *JSONLinesFileOutput {
+	o.prettyPrint = enabled
+	return o
+}
+
+// WithGzip compresses the file with gzip, the stats are flushed at the end of every interval.
+// Every test appends a gzip member to the file, which is read as a whole by gzip tools and gzip.Reader.
+// It must be called before OnStart.
+func (o *JSONLinesFileOutput) WithGzip(enabled bool) *JSONLinesFileOutput {
+	o.gzip = enabled
+	return o
+}
+
+func (o *JSONLinesFileOutput) setOutputDirectory(dir, testName string) {
+	o.path = resolveOutputPath(o.path, dir, testName, "stats.jsonl")
+}
+
+// OnStart opens the file in append mode.
+func (o *JSONLinesFileOutput) OnStart() {
+	if o.path == "" {
+		o.path = "stats.jsonl"
+	}
+	f, err := os.OpenFile(o.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		o.logger.Printf("Error opening JSON lines file, %v\n", err)
+		return
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.file = f
+	var w io.Writer = f
+	if o.gzip {
+		o.gw = gzip.NewWriter(f)
+		w = o.gw
+	}
+	o.encoder = json.NewEncoder(w)
+	if o.prettyPrint {
+		o.encoder.SetIndent("", "  ")
+	}
+}
+
+// OnEvent appends the stats to the file.
+func (o *JSONLinesFileOutput) OnEvent(data map[string]interface{}) {
+	if err := o.OnEventWithError(data); err != nil {
+		o.logger.Printf("Error writing JSON lines file, %v\n", err)
+	}
+}
+
+// OnEventWithError appends the stats to the file, and returns the error of converting or writing them.
+func (o *JSONLinesFileOutput) OnEventWithError(data map[string]interface{}) error {
+	output, err := o.convertData(data)
+	if err != nil {
+		return err
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.file == nil {
+		return nil
+	}
+	line := struct {
+		Timestamp string `json:"timestamp"`
+		*dataOutput
+	}{
+		Timestamp:  time.Now().Format(time.RFC3339),
+		dataOutput: output,
+	}
+	if err := o.encoder.Encode(line); err != nil {
+		return err
+	}
+	if o.gw != nil {
+		return o.gw.Flush()
+	}
+	return nil
+}
+
+// OnStop syncs and closes the file.
+func (o *JSONLinesFileOutput) OnStop() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.file == nil {
+		return
+	}
+	if err := o.close(); err != nil {
+		o.logger.Printf("Error closing JSON lines file, %v\n", err)
+	}
+	o.file = nil
+	o.gw = nil
+	o.encoder = nil
+}
+
+func (o *JSONLinesFileOutput) close() error {
+	if o.gw != nil {
+		if err := o.gw.Close(); err != nil {
+			o.file.Close()
+			return fmt.Errorf("close gzip writer, %w", err)
+		}
+	}
+	if err := o.file.Sync(); err != nil {
+		o.file.Close()
+		return err
+	}
+	return o.file.Close()
+}
@@ -0,0 +1,134 @@
+package boomer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test JSON lines file output", func() {
+
+	newData := func() map[string]interface{} {
+		stats := newRequestStats()
+		stats.logRequest("http", "login", 10, 100)
+		stats.logRequest("http", "login", 30, 300)
+		stats.logError("http", "login", "500")
+		data := stats.collectReportData()
+		data["user_count"] = int32(2)
+		data["test_name"] = "checkout"
+		return data
+	}
+
+	decodeLines := func(r io.Reader) []map[string]interface{} {
+		var lines []map[string]interface{}
+		decoder := json.NewDecoder(r)
+		for decoder.More() {
+			line := map[string]interface{}{}
+			Expect(decoder.Decode(&line)).To(Succeed())
+			lines = append(lines, line)
+		}
+		return lines
+	}
+
+	It("test json lines file output", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "stats.jsonl")
+		// the file is appended by every test
+		for i := 0; i < 2; i++ {
+			o := NewJSONLinesFileOutput(path).WithLogger(log.New(io.Discard, "", 0))
+			o.OnStart()
+			Expect(o.OnEventWithError(newData())).To(Succeed())
+			o.OnEvent(newData())
+			o.OnStop()
+			o.OnStop()
+		}
+
+		f, err := os.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		count := 0
+		for scanner.Scan() {
+			count++
+			line := map[string]interface{}{}
+			Expect(json.Unmarshal(scanner.Bytes(), &line)).To(Succeed())
+			_, err := time.Parse(time.RFC3339, line["timestamp"].(string))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(line["test_name"]).To(Equal("checkout"))
+			Expect(line["user_count"]).To(BeEquivalentTo(2))
+			total := line["stats_total"].(map[string]interface{})
+			Expect(total["num_requests"]).To(BeEquivalentTo(2))
+			Expect(total["num_failures"]).To(BeEquivalentTo(1))
+			Expect(line["stats"]).To(HaveLen(1))
+		}
+		Expect(count).To(Equal(4))
+	})
+
+	It("test json lines file output with pretty print", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "stats.jsonl")
+		o := NewJSONLinesFileOutput(path).WithPrettyPrint(true)
+		o.OnStart()
+		o.OnEvent(newData())
+		o.OnEvent(newData())
+		o.OnStop()
+
+		content, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring("\n  \"timestamp\": "))
+		Expect(decodeLines(strings.NewReader(string(content)))).To(HaveLen(2))
+	})
+
+	It("test json lines file output with gzip", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "stats.jsonl.gz")
+		for i := 0; i < 2; i++ {
+			o := NewJSONLinesFileOutput(path).WithGzip(true)
+			o.OnStart()
+			o.OnEvent(newData())
+			// the lines are flushed at the end of every interval
+			if i == 0 {
+				f, err := os.Open(path)
+				Expect(err).NotTo(HaveOccurred())
+				gr, err := gzip.NewReader(f)
+				Expect(err).NotTo(HaveOccurred())
+				line, err := bufio.NewReader(gr).ReadString('\n')
+				Expect(err).NotTo(HaveOccurred())
+				Expect(line).To(ContainSubstring(`"test_name":"checkout"`))
+				f.Close()
+			}
+			o.OnEvent(newData())
+			o.OnStop()
+		}
+
+		f, err := os.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+		gr, err := gzip.NewReader(f)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decodeLines(gr)).To(HaveLen(4))
+	})
+
+	It("test json lines file output in output directory", func() {
+		dir := filepath.Join(GinkgoT().TempDir(), "results")
+		b := NewStandaloneBoomer(1, 1).WithOutputDirectory(dir).WithTestName("checkout flow")
+		b.AddOutput(NewJSONLinesFileOutput(""))
+		b.setupRunner(&newLocalRunner(nil, nil, 1, 1).runner)
+		Expect(b.outputs[0].(*JSONLinesFileOutput).path).To(Equal(filepath.Join(dir, "checkout_flow_stats.jsonl")))
+	})
+
+	It("test json lines file output with invalid path", func() {
+		o := NewJSONLinesFileOutput(filepath.Join(GinkgoT().TempDir(), "missing", "stats.jsonl")).
+			WithLogger(log.New(io.Discard, "", 0))
+		o.OnStart()
+		Expect(o.file).To(BeNil())
+		Expect(o.OnEventWithError(newData())).To(Succeed())
+		o.OnStop()
+	})
+})
@@ -0,0 +1,94 @@
+package boomer
+
+import (
+	"log"
+	"sync"
+)
+
+// InMemoryOutput keeps all the data received by OnEvent, so tests can assert on the stats programmatically.
+// It's safe to be used by multiple goroutines.
+type InMemoryOutput struct {
+	statsConverter
+
+	snapshots   []*dataOutput
+	startCalled bool
+	stopCalled  bool
+	lock        sync.RWMutex
+
+	logger *log.Logger
+}
+
+// NewInMemoryOutput returns an InMemoryOutput.
+func NewInMemoryOutput() *InMemoryOutput {
+	return &InMemoryOutput{
+		logger: log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *InMemoryOutput) WithLogger(logger *log.Logger) *InMemoryOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+// OnStart marks the output as started.
+func (o *InMemoryOutput) OnStart() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.startCalled = true
+}
+
+// OnEvent keeps the data as a snapshot.
+func (o *InMemoryOutput) OnEvent(data map[string]interface{}) {
+	output, err := o.convertData(data)
+	if err != nil {
+		o.logger.Printf("convert data error: %v\n", err)
+		return
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.snapshots = append(o.snapshots, output)
+}
+
+// OnStop marks the output as stopped.
+func (o *InMemoryOutput) OnStop() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.stopCalled = true
+}
+
+// Snapshots returns all the snapshots received, from the oldest to the newest.
+func (o *InMemoryOutput) Snapshots() []*dataOutput {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	snapshots := make([]*dataOutput, len(o.snapshots))
+	copy(snapshots, o.snapshots)
+	return snapshots
+}
+
+// LastSnapshot returns the most recent snapshot, or nil if there is none.
+func (o *InMemoryOutput) LastSnapshot() *dataOutput {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	if len(o.snapshots) == 0 {
+		return nil
+	}
+	return o.snapshots[len(o.snapshots)-1]
+}
+
+// StartCalled returns true if OnStart is called.
+func (o *InMemoryOutput) StartCalled() bool {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	return o.startCalled
+}
+
+// StopCalled returns true if OnStop is called.
+func (o *InMemoryOutput) StopCalled() bool {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	return o.stopCalled
+}
@@ -0,0 +1,55 @@
+package boomer
+
+import (
+	"io"
+	"log"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test in memory output", func() {
+
+	newData := func(userCount int32) map[string]interface{} {
+		stats := newRequestStats()
+		stats.logRequest("http", "foo", 10, 100)
+		data := stats.collectReportData()
+		data["user_count"] = userCount
+		return data
+	}
+
+	It("test snapshots and lifecycle", func() {
+		o := NewInMemoryOutput().WithLogger(log.New(io.Discard, "", 0))
+		Expect(o.StartCalled()).To(BeFalse())
+		Expect(o.LastSnapshot()).To(BeNil())
+
+		o.OnStart()
+		o.OnEvent(newData(1))
+		o.OnEvent(newData(2))
+		o.OnEvent(map[string]interface{}{})
+		o.OnStop()
+
+		Expect(o.StartCalled()).To(BeTrue())
+		Expect(o.StopCalled()).To(BeTrue())
+		Expect(o.Snapshots()).To(HaveLen(2))
+		Expect(o.Snapshots()[0].UserCount).To(BeEquivalentTo(1))
+		Expect(o.LastSnapshot().UserCount).To(BeEquivalentTo(2))
+		Expect(o.LastSnapshot().Stats[0].NumRequests).To(BeEquivalentTo(1))
+	})
+
+	It("test concurrent events", func() {
+		o := NewInMemoryOutput()
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				o.OnEvent(newData(1))
+				o.LastSnapshot()
+			}()
+		}
+		wg.Wait()
+		Expect(o.Snapshots()).To(HaveLen(10))
+	})
+})
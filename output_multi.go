@@ -0,0 +1,126 @@
+package boomer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// MultiOutput dispatches the calls to multiple outputs, e.g. to add ConsoleOutput and PrometheusPusherOutput
+// as a single output. The outputs are called in parallel, and each call waits for all of them.
+// The panics of an output are recovered and logged, so the other outputs are still called.
+type MultiOutput struct {
+	outputs []Output
+	lock    sync.Mutex
+	logger  *log.Logger
+}
+
+// NewMultiOutput returns a MultiOutput, which dispatches to outputs.
+func NewMultiOutput(outputs ...Output) *MultiOutput {
+	return &MultiOutput{
+		outputs: outputs,
+		logger:  log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *MultiOutput) WithLogger(logger *log.Logger) *MultiOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+// Add adds an output, which is called from the next call on.
+func (o *MultiOutput) Add(output Output) *MultiOutput {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.outputs = append(o.outputs, output)
+	return o
+}
+
+// setOutputDirectory passes the output directory to the file-based outputs.
+func (o *MultiOutput) setOutputDirectory(dir, testName string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for _, output := range o.outputs {
+		if named, ok := output.(*NamedOutput); ok {
+			output = named.Output
+		}
+		if f, ok := output.(fileOutput); ok {
+			f.setOutputDirectory(dir, testName)
+		}
+	}
+}
+
+// setStatsSerializer passes the serializer to the outputs.
+func (o *MultiOutput) setStatsSerializer(serializer *statsSerializer) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for _, output := range o.outputs {
+		setOutputSerializer(output, serializer)
+	}
+}
+
+// dispatch calls fn with every output in parallel, and waits for all of them.
+func (o *MultiOutput) dispatch(fn func(output Output)) {
+	o.lock.Lock()
+	outputs := append([]Output(nil), o.outputs...)
+	o.lock.Unlock()
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(outputs))
+	for _, output := range outputs {
+		go func(output Output) {
+			defer wg.Done()
+			defer func() {
+				if err := recover(); err != nil {
+					o.logger.Printf("Output %s panics, %v\n%s", outputName(output), err, debug.Stack())
+				}
+			}()
+			fn(output)
+		}(output)
+	}
+	wg.Wait()
+}
+
+// OnStart calls OnStart of all the outputs.
+func (o *MultiOutput) OnStart() {
+	o.dispatch(func(output Output) {
+		output.OnStart()
+	})
+}
+
+// OnEvent calls OnEvent of all the outputs, with the same data.
+func (o *MultiOutput) OnEvent(data map[string]interface{}) {
+	o.OnEventWithError(data)
+}
+
+// OnEventWithError calls all the outputs with the same data, and returns the errors of the outputs implementing ErrorOutput.
+func (o *MultiOutput) OnEventWithError(data map[string]interface{}) error {
+	var lock sync.Mutex
+	var errs []error
+	o.dispatch(func(output Output) {
+		eo, ok := output.(ErrorOutput)
+		if !ok {
+			output.OnEvent(data)
+			return
+		}
+		if err := eo.OnEventWithError(data); err != nil {
+			lock.Lock()
+			errs = append(errs, fmt.Errorf("%s: %w", outputName(output), err))
+			lock.Unlock()
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// OnStop calls OnStop of all the outputs.
+func (o *MultiOutput) OnStop() {
+	o.dispatch(func(output Output) {
+		output.OnStop()
+	})
+}
@@ -0,0 +1,93 @@
+package boomer
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"path/filepath"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type panickingOutput struct{}
+
+func (o *panickingOutput) OnStart() {
+	panic("start")
+}
+
+func (o *panickingOutput) OnEvent(data map[string]interface{}) {
+	panic("event")
+}
+
+func (o *panickingOutput) OnStop() {
+	panic("stop")
+}
+
+var _ = Describe("Test multi output", func() {
+
+	It("test all outputs are called", func() {
+		first, second, third := &HitOutput{}, &HitOutput{}, &HitOutput{}
+		o := NewMultiOutput(first, second).Add(third)
+
+		o.OnStart()
+		o.OnEvent(map[string]interface{}{})
+		o.OnStop()
+
+		for _, hit := range []*HitOutput{first, second, third} {
+			Expect(hit.started()).To(BeTrue())
+			Expect(hit.received()).To(BeTrue())
+			Expect(hit.stopped()).To(BeTrue())
+		}
+	})
+
+	It("test outputs are called in parallel", func() {
+		lock, active, maxSeen := &sync.Mutex{}, 0, 0
+		o := NewMultiOutput()
+		for i := 0; i < 3; i++ {
+			o.Add(&concurrentOutput{lock: lock, active: &active, maxSeen: &maxSeen})
+		}
+		o.OnEvent(map[string]interface{}{})
+		Expect(maxSeen).To(Equal(3))
+		Expect(active).To(Equal(0))
+	})
+
+	It("test a panicking output doesn't affect the others", func() {
+		var buf bytes.Buffer
+		hit := &HitOutput{}
+		o := NewMultiOutput(WithOutputNameOverride(&panickingOutput{}, "broken"), hit).
+			WithLogger(log.New(&buf, "", 0))
+
+		o.OnStart()
+		o.OnEvent(map[string]interface{}{})
+		o.OnStop()
+
+		Expect(hit.started()).To(BeTrue())
+		Expect(hit.received()).To(BeTrue())
+		Expect(hit.stopped()).To(BeTrue())
+		Expect(buf.String()).To(ContainSubstring("Output broken panics, start"))
+		Expect(buf.String()).To(ContainSubstring("Output broken panics, event"))
+		Expect(buf.String()).To(ContainSubstring("Output broken panics, stop"))
+	})
+
+	It("test errors of the outputs are joined", func() {
+		err := errors.New("push failed")
+		failing := &FailingOutput{err: err}
+		o := NewMultiOutput(WithOutputNameOverride(failing, "pusher"), &FailingOutput{}, &HitOutput{})
+
+		joined := o.OnEventWithError(map[string]interface{}{})
+		Expect(joined).To(MatchError(err))
+		Expect(joined.Error()).To(Equal("pusher: push failed"))
+		Expect(failing.calls).To(BeEquivalentTo(1))
+	})
+
+	It("test file outputs in output directory", func() {
+		dir := filepath.Join(GinkgoT().TempDir(), "results")
+		csvOutput := NewCSVFileOutput("")
+		b := NewStandaloneBoomer(1, 1).WithOutputDirectory(dir).WithTestName("checkout")
+		b.AddOutput(NewMultiOutput(WithOutputNameOverride(csvOutput, "csv"), NewConsoleOutput()))
+		b.setupRunner(&newLocalRunner(nil, nil, 1, 1).runner)
+		Expect(csvOutput.path).To(Equal(filepath.Join(dir, "checkout_stats.csv")))
+	})
+})
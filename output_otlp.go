@@ -0,0 +1,143 @@
+package boomer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OTLPTraceOutput exports a summary span per stats interval via the OTLP gRPC trace exporter,
+// for the teams which analyze load tests with Jaeger or Tempo.
+// Each interval is a parent span named "load_test/interval" with the total stats,
+// and each endpoint is a child span named "load_test/<method>/<name>".
+type OTLPTraceOutput struct {
+	statsConverter
+
+	endpoint string
+	exporter sdktrace.SpanExporter
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+	logger   *log.Logger
+}
+
+// NewOTLPTraceOutput returns an OTLPTraceOutput, which exports spans to the OTLP gRPC endpoint, e.g. "localhost:4317".
+func NewOTLPTraceOutput(endpoint string) *OTLPTraceOutput {
+	return &OTLPTraceOutput{
+		endpoint: endpoint,
+		logger:   log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *OTLPTraceOutput) WithLogger(logger *log.Logger) *OTLPTraceOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+// WithExporter replaces the OTLP gRPC exporter, e.g. with one created by otlptracegrpc.New with custom options,
+// or an in-memory exporter in tests.
+func (o *OTLPTraceOutput) WithExporter(exporter sdktrace.SpanExporter) *OTLPTraceOutput {
+	o.exporter = exporter
+	return o
+}
+
+// OnStart will create the exporter and the tracer provider.
+func (o *OTLPTraceOutput) OnStart() {
+	if o.exporter == nil {
+		exporter, err := otlptracegrpc.New(context.Background(), otlptracegrpc.WithEndpoint(o.endpoint))
+		if err != nil {
+			o.logger.Printf("Error creating OTLP trace exporter, %v\n", err)
+			return
+		}
+		o.exporter = exporter
+	}
+	o.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(o.exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "boomer"))),
+	)
+	o.tracer = o.provider.Tracer("github.com/myzhan/boomer")
+}
+
+// OnEvent will create the spans of the stats interval.
+func (o *OTLPTraceOutput) OnEvent(data map[string]interface{}) {
+	if o.tracer == nil {
+		return
+	}
+	output, err := o.convertData(data)
+	if err != nil {
+		o.logger.Printf("convert data error: %v\n", err)
+		return
+	}
+
+	now := time.Now()
+	attrs := []attribute.KeyValue{
+		attribute.String("test_name", output.TestName),
+		attribute.Int("user_count", int(output.UserCount)),
+		attribute.Int64("total_rps", output.TotalRPS),
+		attribute.Float64("total_fail_ratio", output.TotalFailRatio),
+	}
+	if output.TotalStats != nil {
+		attrs = append(attrs, statsEntrySpanAttributes(output.TotalStats)...)
+	}
+	ctx, parent := o.tracer.Start(context.Background(), "load_test/interval",
+		trace.WithTimestamp(intervalStartTime(output.TotalStats, now)),
+		trace.WithAttributes(attrs...),
+	)
+	for _, stat := range output.Stats {
+		_, span := o.tracer.Start(ctx, fmt.Sprintf("load_test/%s/%s", stat.Method, stat.Name),
+			trace.WithTimestamp(intervalStartTime(stat, now)),
+			trace.WithAttributes(statsEntrySpanAttributes(stat)...),
+		)
+		span.End(trace.WithTimestamp(now))
+	}
+	parent.End(trace.WithTimestamp(now))
+}
+
+// OnStop will flush the spans and shut down the tracer provider.
+func (o *OTLPTraceOutput) OnStop() {
+	if o.provider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := o.provider.Shutdown(ctx); err != nil {
+		o.logger.Printf("Error shutting down OTLP tracer provider, %v\n", err)
+	}
+	o.provider = nil
+	o.tracer = nil
+}
+
+// intervalStartTime returns the time of the first request in the interval, or now if there are no requests.
+func intervalStartTime(stat *statsEntryOutput, now time.Time) time.Time {
+	if stat == nil || stat.StartTime <= 0 {
+		return now
+	}
+	return time.Unix(stat.StartTime, 0)
+}
+
+func statsEntrySpanAttributes(stat *statsEntryOutput) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("method", stat.Method),
+		attribute.String("name", stat.Name),
+		attribute.Int64("num_requests", stat.NumRequests),
+		attribute.Int64("num_failures", stat.NumFailures),
+		attribute.Int64("median_response_time_ms", stat.medianResponseTime),
+		attribute.Float64("avg_response_time_ms", stat.avgResponseTime),
+		attribute.Int64("min_response_time_ms", stat.MinResponseTime),
+		attribute.Int64("max_response_time_ms", stat.MaxResponseTime),
+		attribute.Int64("avg_content_length", stat.avgContentLength),
+		attribute.Int64("current_rps", stat.currentRps),
+		attribute.Int64("current_fail_per_sec", stat.currentFailPerSec),
+		attribute.Float64("response_time_correlation", stat.ResponseTimeCorrelation),
+	}
+}
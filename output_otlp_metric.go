@@ -0,0 +1,277 @@
+package boomer
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// otlpResponseTimeHistogram is the name of the response time histogram of OTLPOutput, see OTLPOutput.WithHistogram.
+const otlpResponseTimeHistogram = "boomer.response_time"
+
+// otlpEntryGauges are the gauges of every endpoint, with the same names as the metrics of PrometheusPusherOutput.
+var otlpEntryGauges = []struct {
+	name        string
+	description string
+	value       func(stat *statsEntryOutput) float64
+}{
+	{"num_requests", "The number of requests", func(s *statsEntryOutput) float64 { return float64(s.NumRequests) }},
+	{"num_failures", "The number of failures", func(s *statsEntryOutput) float64 { return float64(s.NumFailures) }},
+	{"median_response_time", "The median response time", func(s *statsEntryOutput) float64 { return float64(s.medianResponseTime) }},
+	{"p90_response_time", "The 90th percentile response time", func(s *statsEntryOutput) float64 { return float64(s.P90) }},
+	{"p95_response_time", "The 95th percentile response time", func(s *statsEntryOutput) float64 { return float64(s.P95) }},
+	{"p99_response_time", "The 99th percentile response time", func(s *statsEntryOutput) float64 { return float64(s.P99) }},
+	{"p999_response_time", "The 99.9th percentile response time", func(s *statsEntryOutput) float64 { return float64(s.P999) }},
+	{"average_response_time", "The average response time", func(s *statsEntryOutput) float64 { return s.avgResponseTime }},
+	{"stddev_response_time", "The standard deviation of response times", func(s *statsEntryOutput) float64 { return s.StdDev }},
+	{"iqr_response_time", "The interquartile range of response times", func(s *statsEntryOutput) float64 { return float64(s.IQR) }},
+	{"min_response_time", "The min response time", func(s *statsEntryOutput) float64 { return float64(s.MinResponseTime) }},
+	{"max_response_time", "The max response time", func(s *statsEntryOutput) float64 { return float64(s.MaxResponseTime) }},
+	{"average_content_length", "The average content length", func(s *statsEntryOutput) float64 { return float64(s.avgContentLength) }},
+	{"current_rps", "The current requests per second", func(s *statsEntryOutput) float64 { return float64(s.currentRps) }},
+	{"current_fail_per_sec", "The current failure number per second", func(s *statsEntryOutput) float64 { return float64(s.currentFailPerSec) }},
+	{"response_time_lag1_correlation", "The lag-1 autocorrelation of the recent response times", func(s *statsEntryOutput) float64 { return s.ResponseTimeCorrelation }},
+}
+
+// otlpTotalGauges are the gauges of the total, with the same names as the metrics of PrometheusPusherOutput.
+var otlpTotalGauges = []struct {
+	name        string
+	description string
+	value       func(output *dataOutput) (float64, bool)
+}{
+	{"users", "The current number of users", func(o *dataOutput) (float64, bool) { return float64(o.UserCount), true }},
+	{"total_rps", "The requests per second in total", func(o *dataOutput) (float64, bool) { return float64(o.TotalRPS), true }},
+	{"fail_ratio", "The ratio of request failures in total", func(o *dataOutput) (float64, bool) { return o.TotalFailRatio, true }},
+	{"concurrency_current", "The current number of running tasks", func(o *dataOutput) (float64, bool) { return float64(o.ConcurrencyCurrent), true }},
+	{"concurrency_limit", "The max number of running tasks, zero means no limit", func(o *dataOutput) (float64, bool) { return float64(o.ConcurrencyLimit), true }},
+	{"goroutine_count", "The number of goroutines of the process, recorded by WithResourceMonitor", func(o *dataOutput) (float64, bool) {
+		if goroutines, ok := o.CustomMetrics[ProcessGoroutinesMetric]; ok {
+			return goroutines.Value, true
+		}
+		return 0, false
+	}},
+}
+
+// OTLPOutput exports the metrics of PrometheusPusherOutput as OTLP gauges via the OTLP gRPC metric exporter,
+// named "boomer.<metric>", e.g. "boomer.current_rps" with the attributes method and name.
+// The metrics are exported at the end of every report interval.
+type OTLPOutput struct {
+	statsConverter
+
+	endpoint           string
+	insecure           bool
+	headers            map[string]string
+	resourceAttributes []attribute.KeyValue
+	histogramBuckets   []float64
+	exporter           sdkmetric.Exporter
+
+	provider  *sdkmetric.MeterProvider
+	histogram metric.Int64Histogram
+
+	lock   sync.Mutex
+	last   *dataOutput // the stats of the last interval, which are observed by the gauges
+	logger *log.Logger
+}
+
+// NewOTLPOutput returns an OTLPOutput, which exports metrics to the OTLP gRPC endpoint, e.g. "localhost:4317".
+func NewOTLPOutput(endpoint string) *OTLPOutput {
+	return &OTLPOutput{
+		endpoint: endpoint,
+		logger:   log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *OTLPOutput) WithLogger(logger *log.Logger) *OTLPOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+// WithInsecure disables TLS of the gRPC connection, e.g. for a collector running as a sidecar.
+func (o *OTLPOutput) WithInsecure(insecure bool) *OTLPOutput {
+	o.insecure = insecure
+	return o
+}
+
+// WithHeaders sets the gRPC headers of every export, e.g. for authentication.
+func (o *OTLPOutput) WithHeaders(headers map[string]string) *OTLPOutput {
+	o.headers = make(map[string]string, len(headers))
+	for k, v := range headers {
+		o.headers[k] = v
+	}
+	return o
+}
+
+// WithResourceAttributes adds attributes to the resource of the metrics, like service.name and deployment.environment.
+// The service.name is "boomer" by default.
+func (o *OTLPOutput) WithResourceAttributes(attrs ...attribute.KeyValue) *OTLPOutput {
+	o.resourceAttributes = append(o.resourceAttributes, attrs...)
+	return o
+}
+
+// WithHistogram records the response times in the histogram "boomer.response_time", whose buckets are
+// the upper bounds in milliseconds, e.g. []float64{10, 50, 100, 500, 1000}.
+// The buckets must be sorted in increasing order, or they are ignored.
+func (o *OTLPOutput) WithHistogram(buckets []float64) *OTLPOutput {
+	if len(buckets) == 0 || !sort.Float64sAreSorted(buckets) {
+		o.logger.Printf("Invalid histogram buckets %v, ignored!\n", buckets)
+		return o
+	}
+	o.histogramBuckets = append([]float64(nil), buckets...)
+	return o
+}
+
+// WithExporter replaces the OTLP gRPC exporter, e.g. with one created by otlpmetricgrpc.New with custom options,
+// or an in-memory exporter in tests.
+func (o *OTLPOutput) WithExporter(exporter sdkmetric.Exporter) *OTLPOutput {
+	o.exporter = exporter
+	return o
+}
+
+// OnStart will create the exporter, the meter provider and the instruments.
+func (o *OTLPOutput) OnStart() {
+	if o.exporter == nil {
+		options := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(o.endpoint)}
+		if o.insecure {
+			options = append(options, otlpmetricgrpc.WithInsecure())
+		}
+		if len(o.headers) > 0 {
+			options = append(options, otlpmetricgrpc.WithHeaders(o.headers))
+		}
+		exporter, err := otlpmetricgrpc.New(context.Background(), options...)
+		if err != nil {
+			o.logger.Printf("Error creating OTLP metric exporter, %v\n", err)
+			return
+		}
+		o.exporter = exporter
+	}
+
+	attrs := append([]attribute.KeyValue{attribute.String("service.name", "boomer")}, o.resourceAttributes...)
+	options := []sdkmetric.Option{
+		// the metrics are exported by OnEvent, the interval only exports the last stats again if the test is stuck
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(o.exporter, sdkmetric.WithInterval(time.Minute))),
+		sdkmetric.WithResource(resource.NewSchemaless(attrs...)),
+	}
+	if o.histogramBuckets != nil {
+		options = append(options, sdkmetric.WithView(sdkmetric.NewView(
+			sdkmetric.Instrument{Name: otlpResponseTimeHistogram},
+			sdkmetric.Stream{Aggregation: sdkmetric.AggregationExplicitBucketHistogram{Boundaries: o.histogramBuckets}},
+		)))
+	}
+	o.provider = sdkmetric.NewMeterProvider(options...)
+	if err := o.createInstruments(o.provider.Meter("github.com/myzhan/boomer")); err != nil {
+		o.logger.Printf("Error creating OTLP instruments, %v\n", err)
+	}
+}
+
+// createInstruments creates the gauges, which observe the stats of the last interval, and the histogram.
+func (o *OTLPOutput) createInstruments(meter metric.Meter) error {
+	entryGauges := make([]metric.Float64ObservableGauge, len(otlpEntryGauges))
+	totalGauges := make([]metric.Float64ObservableGauge, len(otlpTotalGauges))
+	observables := make([]metric.Observable, 0, len(entryGauges)+len(totalGauges))
+	for i, g := range otlpEntryGauges {
+		gauge, err := meter.Float64ObservableGauge(namespace+"."+g.name, metric.WithDescription(g.description))
+		if err != nil {
+			return err
+		}
+		entryGauges[i] = gauge
+		observables = append(observables, gauge)
+	}
+	for i, g := range otlpTotalGauges {
+		gauge, err := meter.Float64ObservableGauge(namespace+"."+g.name, metric.WithDescription(g.description))
+		if err != nil {
+			return err
+		}
+		totalGauges[i] = gauge
+		observables = append(observables, gauge)
+	}
+
+	_, err := meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		o.lock.Lock()
+		output := o.last
+		o.lock.Unlock()
+		if output == nil {
+			return nil
+		}
+		for i, g := range otlpTotalGauges {
+			if value, ok := g.value(output); ok {
+				observer.ObserveFloat64(totalGauges[i], value)
+			}
+		}
+		for _, stat := range output.Stats {
+			attrs := metric.WithAttributes(attribute.String("method", stat.Method), attribute.String("name", stat.Name))
+			for i, g := range otlpEntryGauges {
+				observer.ObserveFloat64(entryGauges[i], g.value(stat), attrs)
+			}
+		}
+		return nil
+	}, observables...)
+	if err != nil {
+		return err
+	}
+
+	if o.histogramBuckets != nil {
+		o.histogram, err = meter.Int64Histogram(otlpResponseTimeHistogram,
+			metric.WithDescription("The response time of requests"), metric.WithUnit("ms"))
+	}
+	return err
+}
+
+// OnEvent will record the stats, and export the metrics.
+func (o *OTLPOutput) OnEvent(data map[string]interface{}) {
+	if o.provider == nil {
+		return
+	}
+	output, err := o.convertData(data)
+	if err != nil {
+		o.logger.Printf("convert data error: %v\n", err)
+		return
+	}
+
+	o.lock.Lock()
+	o.last = output
+	o.lock.Unlock()
+
+	ctx := context.Background()
+	if o.histogram != nil {
+		for _, stat := range output.Stats {
+			attrs := metric.WithAttributes(attribute.String("method", stat.Method), attribute.String("name", stat.Name))
+			for responseTime, count := range stat.ResponseTimes {
+				for i := int64(0); i < count; i++ {
+					o.histogram.Record(ctx, responseTime, attrs)
+				}
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := o.provider.ForceFlush(ctx); err != nil {
+		o.logger.Printf("Error exporting OTLP metrics, %v\n", err)
+	}
+}
+
+// OnStop will flush the metrics and shut down the meter provider.
+func (o *OTLPOutput) OnStop() {
+	if o.provider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := o.provider.Shutdown(ctx); err != nil {
+		o.logger.Printf("Error shutting down OTLP meter provider, %v\n", err)
+	}
+	o.provider = nil
+	o.histogram = nil
+}
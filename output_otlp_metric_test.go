@@ -0,0 +1,156 @@
+package boomer
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// memoryMetricExporter keeps the data points of the last export, by the metric name and the name attribute.
+type memoryMetricExporter struct {
+	lock       sync.Mutex
+	exports    int
+	resource   map[string]string
+	gauges     map[string]map[string]float64
+	histograms map[string]map[string]metricdata.HistogramDataPoint[int64]
+}
+
+func (e *memoryMetricExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+func (e *memoryMetricExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (e *memoryMetricExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.exports++
+	e.resource = make(map[string]string)
+	for _, kv := range rm.Resource.Attributes() {
+		e.resource[string(kv.Key)] = kv.Value.Emit()
+	}
+	e.gauges = make(map[string]map[string]float64)
+	e.histograms = make(map[string]map[string]metricdata.HistogramDataPoint[int64])
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[float64]:
+				e.gauges[m.Name] = make(map[string]float64)
+				for _, dp := range data.DataPoints {
+					name, _ := dp.Attributes.Value("name")
+					e.gauges[m.Name][name.AsString()] = dp.Value
+				}
+			case metricdata.Histogram[int64]:
+				e.histograms[m.Name] = make(map[string]metricdata.HistogramDataPoint[int64])
+				for _, dp := range data.DataPoints {
+					name, _ := dp.Attributes.Value("name")
+					dp.BucketCounts = append([]uint64(nil), dp.BucketCounts...)
+					e.histograms[m.Name][name.AsString()] = dp
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (e *memoryMetricExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (e *memoryMetricExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+var _ = Describe("Test OTLP metric output", func() {
+
+	newData := func() map[string]interface{} {
+		newStat := func(name string) map[string]interface{} {
+			entry := &statsEntry{Name: name, Method: "http"}
+			entry.reset()
+			entry.log(10, 100)
+			entry.log(30, 100)
+			entry.log(70, 100)
+			entry.logError("timeout")
+			return entry.serialize()
+		}
+		return map[string]interface{}{
+			"stats":       []interface{}{newStat("checkout"), newStat("login")},
+			"stats_total": newStat("Total"),
+			"user_count":  int32(5),
+		}
+	}
+
+	It("test gauges of a stats interval", func() {
+		exporter := &memoryMetricExporter{}
+		o := NewOTLPOutput("localhost:4317").WithExporter(exporter).
+			WithResourceAttributes(attribute.String("deployment.environment", "staging"))
+		o.OnStart()
+		o.OnEvent(newData())
+
+		Expect(exporter.exports).To(Equal(1))
+		Expect(exporter.resource).To(HaveKeyWithValue("service.name", "boomer"))
+		Expect(exporter.resource).To(HaveKeyWithValue("deployment.environment", "staging"))
+		Expect(exporter.gauges).To(HaveLen(len(otlpEntryGauges) + len(otlpTotalGauges) - 1))
+		Expect(exporter.gauges["boomer.users"]).To(Equal(map[string]float64{"": 5}))
+		Expect(exporter.gauges["boomer.num_requests"]).To(Equal(map[string]float64{"checkout": 3, "login": 3}))
+		Expect(exporter.gauges["boomer.num_failures"]).To(HaveKeyWithValue("checkout", BeEquivalentTo(1)))
+		Expect(exporter.gauges["boomer.median_response_time"]).To(HaveKeyWithValue("login", BeEquivalentTo(30)))
+		Expect(exporter.gauges["boomer.max_response_time"]).To(HaveKeyWithValue("login", BeEquivalentTo(70)))
+		Expect(exporter.histograms).To(BeEmpty())
+
+		// the endpoints missing in the next interval are not reported
+		data := newData()
+		data["stats"] = data["stats"].([]interface{})[:1]
+		o.OnEvent(data)
+		Expect(exporter.exports).To(Equal(2))
+		Expect(exporter.gauges["boomer.num_requests"]).To(Equal(map[string]float64{"checkout": 3}))
+
+		o.OnStop()
+		Expect(exporter.exports).To(Equal(3))
+		o.OnStop()
+	})
+
+	It("test response time histogram", func() {
+		exporter := &memoryMetricExporter{}
+		o := NewOTLPOutput("localhost:4317").WithExporter(exporter).WithHistogram([]float64{20, 50})
+		o.OnStart()
+		defer o.OnStop()
+		o.OnEvent(newData())
+		o.OnEvent(newData())
+
+		histogram := exporter.histograms[otlpResponseTimeHistogram]
+		Expect(histogram).To(HaveLen(2))
+		Expect(histogram["checkout"].Bounds).To(Equal([]float64{20, 50}))
+		Expect(histogram["checkout"].BucketCounts).To(Equal([]uint64{2, 2, 2}))
+		Expect(histogram["checkout"].Count).To(BeEquivalentTo(6))
+	})
+
+	It("test invalid histogram buckets are ignored", func() {
+		o := NewOTLPOutput("localhost:4317").WithLogger(log.New(io.Discard, "", 0)).WithHistogram([]float64{50, 20})
+		Expect(o.histogramBuckets).To(BeNil())
+		o.WithHistogram(nil)
+		Expect(o.histogramBuckets).To(BeNil())
+	})
+
+	It("test otlp output with insecure and headers", func() {
+		o := NewOTLPOutput("localhost:4317").WithInsecure(true).WithHeaders(map[string]string{"api-key": "secret"}).
+			WithLogger(log.New(io.Discard, "", 0))
+		// the exporter connects lazily, so it's created without a collector
+		o.OnStart()
+		Expect(o.exporter).NotTo(BeNil())
+		Expect(o.provider).NotTo(BeNil())
+		// there is no collector, don't wait for the export on shutdown
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		o.provider.Shutdown(ctx)
+	})
+})
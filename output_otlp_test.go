@@ -0,0 +1,108 @@
+package boomer
+
+import (
+	"context"
+	"io"
+	"log"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var _ = Describe("Test OTLP trace output", func() {
+
+	newData := func() map[string]interface{} {
+		newStat := func(name string) map[string]interface{} {
+			entry := &statsEntry{Name: name, Method: "http"}
+			entry.reset()
+			entry.log(10, 100)
+			entry.log(30, 100)
+			entry.logError("timeout")
+			return entry.serialize()
+		}
+		return map[string]interface{}{
+			"stats":       []interface{}{newStat("checkout"), newStat("login")},
+			"stats_total": newStat("Total"),
+			"user_count":  int32(5),
+			"test_name":   "checkout flow",
+		}
+	}
+
+	attributes := func(kvs []attribute.KeyValue) map[string]attribute.Value {
+		attrs := make(map[string]attribute.Value)
+		for _, kv := range kvs {
+			attrs[string(kv.Key)] = kv.Value
+		}
+		return attrs
+	}
+
+	It("test spans of a stats interval", func() {
+		exporter := tracetest.NewInMemoryExporter()
+		o := NewOTLPTraceOutput("localhost:4317").WithExporter(exporter).WithLogger(log.New(io.Discard, "", 0))
+		o.OnStart()
+		defer o.OnStop()
+		o.OnEvent(newData())
+		// the in-memory exporter drops the spans on shutdown, so flush them instead of calling OnStop
+		Expect(o.provider.ForceFlush(context.Background())).To(Succeed())
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(3))
+
+		byName := make(map[string]tracetest.SpanStub)
+		for _, span := range spans {
+			byName[span.Name] = span
+		}
+		Expect(byName).To(HaveKey("load_test/interval"))
+		Expect(byName).To(HaveKey("load_test/http/checkout"))
+		Expect(byName).To(HaveKey("load_test/http/login"))
+
+		parent := byName["load_test/interval"]
+		Expect(parent.Parent.IsValid()).To(BeFalse())
+		parentAttrs := attributes(parent.Attributes)
+		Expect(parentAttrs["test_name"].AsString()).To(Equal("checkout flow"))
+		Expect(parentAttrs["user_count"].AsInt64()).To(BeEquivalentTo(5))
+		Expect(parentAttrs["name"].AsString()).To(Equal("Total"))
+
+		child := byName["load_test/http/checkout"]
+		Expect(child.Parent.SpanID()).To(Equal(parent.SpanContext.SpanID()))
+		Expect(child.SpanContext.TraceID()).To(Equal(parent.SpanContext.TraceID()))
+		childAttrs := attributes(child.Attributes)
+		Expect(childAttrs["method"].AsString()).To(Equal("http"))
+		Expect(childAttrs["name"].AsString()).To(Equal("checkout"))
+		Expect(childAttrs["num_requests"].AsInt64()).To(BeEquivalentTo(2))
+		Expect(childAttrs["num_failures"].AsInt64()).To(BeEquivalentTo(1))
+		Expect(childAttrs["avg_response_time_ms"].AsFloat64()).To(BeEquivalentTo(20))
+		Expect(childAttrs["min_response_time_ms"].AsInt64()).To(BeEquivalentTo(10))
+		Expect(childAttrs["max_response_time_ms"].AsInt64()).To(BeEquivalentTo(30))
+		Expect(childAttrs["avg_content_length"].AsInt64()).To(BeEquivalentTo(100))
+		Expect(child.EndTime).To(BeTemporally(">=", child.StartTime))
+	})
+
+	It("test one trace per interval", func() {
+		exporter := tracetest.NewInMemoryExporter()
+		o := NewOTLPTraceOutput("localhost:4317").WithExporter(exporter)
+		o.OnStart()
+		defer o.OnStop()
+		o.OnEvent(newData())
+		o.OnEvent(newData())
+		Expect(o.provider.ForceFlush(context.Background())).To(Succeed())
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(6))
+		traces := make(map[string]bool)
+		for _, span := range spans {
+			traces[span.SpanContext.TraceID().String()] = true
+		}
+		Expect(traces).To(HaveLen(2))
+	})
+
+	It("test events before start are ignored", func() {
+		exporter := tracetest.NewInMemoryExporter()
+		o := NewOTLPTraceOutput("localhost:4317").WithExporter(exporter)
+		o.OnEvent(newData())
+		o.OnStop()
+		Expect(exporter.GetSpans()).To(BeEmpty())
+	})
+})
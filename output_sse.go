@@ -0,0 +1,216 @@
+package boomer
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// sseClientBuffer is the number of messages buffered for a client, messages are dropped for slow clients.
+const sseClientBuffer = 16
+
+const ssePage = `<!DOCTYPE html>
+<html>
+<head><title>boomer</title></head>
+<body>
+<pre id="stats">Waiting for stats...</pre>
+<script>
+var source = new EventSource("/stats");
+source.onmessage = function(event) {
+  document.getElementById("stats").textContent = JSON.stringify(JSON.parse(event.data), null, 2);
+};
+</script>
+</body>
+</html>
+`
+
+// SSEOutput streams the stats to browsers with Server-Sent Events, which is simpler than WebSocket
+// for dashboards, EventSource is supported by browsers out of the box.
+// It serves the stats on "/stats" as JSON messages, and a minimal demo page on "/".
+type SSEOutput struct {
+	statsConverter
+
+	addr     string
+	server   *http.Server
+	listener net.Listener
+
+	clients map[chan []byte]bool
+	eventID int64
+	lock    sync.Mutex
+
+	// the origins allowed to read the stats cross-origin, "*" allows any origin, nil means CORS is disabled
+	allowedOrigins []string
+
+	logger *log.Logger
+}
+
+// NewSSEOutput returns an SSEOutput, which listens on addr, e.g. ":8089".
+func NewSSEOutput(addr string) *SSEOutput {
+	return &SSEOutput{
+		addr:    addr,
+		clients: make(map[chan []byte]bool),
+		logger:  log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *SSEOutput) WithLogger(logger *log.Logger) *SSEOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+// WithCORS allows the dashboards served from allowedOrigins, e.g. "https://dashboard.example.com",
+// to connect to the output cross-origin. If allowedOrigins contains "*", any origin is allowed.
+// The preflight requests are answered with GET as the allowed method and Accept as the allowed header.
+// Call it before OnStart.
+func (o *SSEOutput) WithCORS(allowedOrigins []string) *SSEOutput {
+	o.allowedOrigins = allowedOrigins
+	return o
+}
+
+// allowOrigin returns the value of Access-Control-Allow-Origin for origin, or empty if origin isn't allowed.
+func (o *SSEOutput) allowOrigin(origin string) string {
+	for _, allowed := range o.allowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && allowed == origin {
+			return origin
+		}
+	}
+	return ""
+}
+
+// cors adds the CORS headers to the responses of next, and answers the preflight requests.
+func (o *SSEOutput) cors(next http.Handler) http.Handler {
+	if o.allowedOrigins == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		allowed := o.allowOrigin(req.Header.Get("Origin"))
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+		}
+		if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed != "" {
+				w.Header().Set("Access-Control-Allow-Methods", http.MethodGet)
+				w.Header().Set("Access-Control-Allow-Headers", "Accept")
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Addr returns the address the server listens on, it's empty before OnStart.
+func (o *SSEOutput) Addr() string {
+	if o.listener == nil {
+		return ""
+	}
+	return o.listener.Addr().String()
+}
+
+// OnStart will start the HTTP server.
+func (o *SSEOutput) OnStart() {
+	listener, err := net.Listen("tcp", o.addr)
+	if err != nil {
+		o.logger.Printf("Error starting SSE server, %v\n", err)
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", o.serveStats)
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(ssePage))
+	})
+	o.listener = listener
+	o.server = &http.Server{Handler: o.cors(mux)}
+	go o.server.Serve(listener)
+	o.logger.Printf("Streaming stats on http://%s/stats\n", listener.Addr())
+}
+
+// serveStats sends the stats to the client until it disconnects or the server is closed.
+func (o *SSEOutput) serveStats(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	messages := make(chan []byte, sseClientBuffer)
+	o.lock.Lock()
+	o.clients[messages] = true
+	o.lock.Unlock()
+	defer func() {
+		o.lock.Lock()
+		delete(o.clients, messages)
+		o.lock.Unlock()
+	}()
+
+	for {
+		select {
+		case message := <-messages:
+			if _, err := w.Write(message); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// OnEvent will broadcast the stats to all the connected clients.
+func (o *SSEOutput) OnEvent(data map[string]interface{}) {
+	output, err := o.convertData(data)
+	if err != nil {
+		o.logger.Printf("convert data error: %v\n", err)
+		return
+	}
+	payload, err := json.Marshal(output)
+	if err != nil {
+		o.logger.Printf("marshal data error: %v\n", err)
+		return
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.eventID++
+	message := []byte(fmt.Sprintf("id: %d\ndata: %s\n\n", o.eventID, payload))
+	for client := range o.clients {
+		select {
+		case client <- message:
+		default:
+			// the client is too slow, drop the message rather than blocking the runner
+		}
+	}
+}
+
+// OnStop will close the HTTP server and disconnect all the clients.
+func (o *SSEOutput) OnStop() {
+	if o.server == nil {
+		return
+	}
+	if err := o.server.Close(); err != nil {
+		o.logger.Printf("Error closing SSE server, %v\n", err)
+	}
+	o.server = nil
+}
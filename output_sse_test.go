@@ -0,0 +1,187 @@
+package boomer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test SSE output", func() {
+
+	var o *SSEOutput
+
+	newData := func() map[string]interface{} {
+		entry := &statsEntry{Name: "login", Method: "http"}
+		entry.reset()
+		entry.log(10, 100)
+		entry.log(30, 100)
+		return map[string]interface{}{
+			"user_count":  int32(5),
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+			"test_name":   "checkout",
+		}
+	}
+
+	clients := func() int {
+		o.lock.Lock()
+		defer o.lock.Unlock()
+		return len(o.clients)
+	}
+
+	connect := func(ctx context.Context) (*http.Response, *bufio.Reader) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+o.Addr()+"/stats", nil)
+		Expect(err).NotTo(HaveOccurred())
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(resp.Body.Close)
+		Eventually(clients).Should(Equal(1))
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	readEvent := func(reader *bufio.Reader) (id, data string) {
+		for {
+			line, err := reader.ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				return
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}
+
+	BeforeEach(func() {
+		o = NewSSEOutput("127.0.0.1:0").WithLogger(log.New(io.Discard, "", 0))
+		o.OnStart()
+		Expect(o.Addr()).NotTo(BeEmpty())
+		DeferCleanup(o.OnStop)
+	})
+
+	It("test stream stats", func() {
+		resp, reader := connect(context.Background())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+		Expect(resp.Header.Get("Cache-Control")).To(Equal("no-cache"))
+		Expect(resp.Header.Get("X-Accel-Buffering")).To(Equal("no"))
+
+		o.OnEvent(newData())
+		o.OnEvent(newData())
+
+		id, data := readEvent(reader)
+		Expect(id).To(Equal("1"))
+		stats := make(map[string]interface{})
+		Expect(json.Unmarshal([]byte(data), &stats)).To(Succeed())
+		Expect(stats["test_name"]).To(Equal("checkout"))
+		Expect(stats["user_count"]).To(BeEquivalentTo(5))
+		entry := stats["stats"].([]interface{})[0].(map[string]interface{})
+		Expect(entry["name"]).To(Equal("login"))
+		Expect(entry["num_requests"]).To(BeEquivalentTo(2))
+		Expect(entry["avg_response_time"]).To(BeEquivalentTo(20))
+
+		id, _ = readEvent(reader)
+		Expect(id).To(Equal("2"))
+	})
+
+	It("test client disconnection", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		connect(ctx)
+		cancel()
+		Eventually(clients).Should(BeZero())
+		o.OnEvent(newData())
+	})
+
+	It("test stop disconnects clients", func() {
+		_, reader := connect(context.Background())
+		o.OnStop()
+		_, err := reader.ReadString('\n')
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("test demo page", func() {
+		resp, err := http.Get("http://" + o.Addr() + "/")
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.Header.Get("Content-Type")).To(HavePrefix("text/html"))
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(ContainSubstring(`new EventSource("/stats")`))
+
+		resp, err = http.Get("http://" + o.Addr() + "/missing")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+	})
+
+	It("test cors", func() {
+		request := func(o *SSEOutput, method, origin string, header map[string]string) *http.Response {
+			ctx, cancel := context.WithCancel(context.Background())
+			DeferCleanup(cancel)
+			req, err := http.NewRequestWithContext(ctx, method, "http://"+o.Addr()+"/stats", nil)
+			Expect(err).NotTo(HaveOccurred())
+			if origin != "" {
+				req.Header.Set("Origin", origin)
+			}
+			for k, v := range header {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(resp.Body.Close)
+			return resp
+		}
+		preflight := map[string]string{"Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "accept"}
+
+		// CORS is disabled by default
+		resp := request(o, http.MethodGet, "https://dashboard.example.com", nil)
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(BeEmpty())
+
+		cors := NewSSEOutput("127.0.0.1:0").WithLogger(log.New(io.Discard, "", 0)).
+			WithCORS([]string{"https://dashboard.example.com"})
+		cors.OnStart()
+		DeferCleanup(cors.OnStop)
+
+		resp = request(cors, http.MethodGet, "https://dashboard.example.com", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("https://dashboard.example.com"))
+		Expect(resp.Header.Values("Vary")).To(ContainElement("Origin"))
+
+		resp = request(cors, http.MethodGet, "https://evil.example.com", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(BeEmpty())
+
+		resp = request(cors, http.MethodOptions, "https://dashboard.example.com", preflight)
+		Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("https://dashboard.example.com"))
+		Expect(resp.Header.Get("Access-Control-Allow-Methods")).To(Equal("GET"))
+		Expect(resp.Header.Get("Access-Control-Allow-Headers")).To(Equal("Accept"))
+
+		resp = request(cors, http.MethodOptions, "https://evil.example.com", preflight)
+		Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(BeEmpty())
+		Expect(resp.Header.Get("Access-Control-Allow-Methods")).To(BeEmpty())
+
+		wildcard := NewSSEOutput("127.0.0.1:0").WithLogger(log.New(io.Discard, "", 0)).
+			WithCORS([]string{"https://dashboard.example.com", "*"})
+		wildcard.OnStart()
+		DeferCleanup(wildcard.OnStop)
+
+		resp = request(wildcard, http.MethodGet, "https://evil.example.com", nil)
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("*"))
+		resp = request(wildcard, http.MethodOptions, "https://evil.example.com", preflight)
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("*"))
+		Expect(resp.Header.Get("Access-Control-Allow-Methods")).To(Equal("GET"))
+	})
+})
@@ -0,0 +1,152 @@
+package boomer
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxStatsDPacketSize keeps the packets below the MTU of most networks, the metrics are split into multiple packets.
+const maxStatsDPacketSize = 1432
+
+var statsDNameReplacer = strings.NewReplacer(".", "_", " ", "_", ":", "_", "|", "_", "@", "_", "/", "_")
+
+// StatsDOutput sends the stats of every report interval to a StatsD agent over UDP. For each endpoint, the gauges
+// "<prefix>.<method>.<name>.median_response_time" and "<prefix>.<method>.<name>.rps", and the counters
+// "<prefix>.<method>.<name>.requests" and "<prefix>.<method>.<name>.failures" with the counts of the interval are sent,
+// and the gauge "<prefix>.users" with the user count. The errors are logged, the stats are never retried.
+type StatsDOutput struct {
+	statsConverter
+
+	addr       string
+	prefix     string
+	sampleRate float64
+
+	lock   sync.Mutex
+	conn   net.Conn
+	logger *log.Logger
+}
+
+// NewStatsDOutput returns a StatsDOutput, which sends to the StatsD agent at addr, e.g. "localhost:8125".
+func NewStatsDOutput(addr string) *StatsDOutput {
+	return &StatsDOutput{
+		addr:       addr,
+		prefix:     "boomer",
+		sampleRate: 1,
+		logger:     log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *StatsDOutput) WithLogger(logger *log.Logger) *StatsDOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+// WithPrefix replaces the prefix "boomer" of the metric names.
+func (o *StatsDOutput) WithPrefix(p string) *StatsDOutput {
+	o.prefix = strings.TrimSuffix(p, ".")
+	return o
+}
+
+// WithSampleRate sends every metric with the probability r in (0, 1], the default is 1.
+// The counters are sent with the sample rate, so they are scaled by the StatsD agent.
+func (o *StatsDOutput) WithSampleRate(r float64) *StatsDOutput {
+	if r <= 0 || r > 1 {
+		o.logger.Printf("Invalid sample rate %v, ignored!\n", r)
+		return o
+	}
+	o.sampleRate = r
+	return o
+}
+
+// OnStart creates the UDP connection.
+func (o *StatsDOutput) OnStart() {
+	conn, err := net.Dial("udp", o.addr)
+	if err != nil {
+		o.logger.Printf("Error connecting to StatsD, %v\n", err)
+		return
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.conn = conn
+}
+
+// OnEvent sends the stats to the StatsD agent.
+func (o *StatsDOutput) OnEvent(data map[string]interface{}) {
+	output, err := o.convertData(data)
+	if err != nil {
+		o.logger.Printf("convert data error: %v\n", err)
+		return
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.conn == nil {
+		return
+	}
+	var lines []string
+	lines = o.appendMetric(lines, "users", strconv.FormatInt(int64(output.UserCount), 10), "g")
+	for _, stat := range output.Stats {
+		key := statsDNameReplacer.Replace(stat.Method) + "." + statsDNameReplacer.Replace(stat.Name)
+		lines = o.appendMetric(lines, key+".median_response_time", strconv.FormatInt(stat.medianResponseTime, 10), "g")
+		lines = o.appendMetric(lines, key+".requests", strconv.FormatInt(stat.NumRequests, 10), "c")
+		lines = o.appendMetric(lines, key+".failures", strconv.FormatInt(stat.NumFailures, 10), "c")
+		lines = o.appendMetric(lines, key+".rps", strconv.FormatInt(stat.currentRps, 10), "g")
+	}
+	o.send(lines)
+}
+
+// appendMetric appends a metric to lines, unless it's dropped by the sample rate.
+func (o *StatsDOutput) appendMetric(lines []string, name, value, metricType string) []string {
+	if o.sampleRate < 1 && random.Float64() >= o.sampleRate {
+		return lines
+	}
+	line := fmt.Sprintf("%s.%s:%s|%s", o.prefix, name, value, metricType)
+	if o.sampleRate < 1 && metricType == "c" {
+		line += "|@" + strconv.FormatFloat(o.sampleRate, 'f', -1, 64)
+	}
+	return append(lines, line)
+}
+
+// send sends lines in packets no larger than maxStatsDPacketSize, separated by newlines.
+func (o *StatsDOutput) send(lines []string) {
+	var packet bytes.Buffer
+	for i, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxStatsDPacketSize {
+			o.write(packet.Bytes())
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+		if i == len(lines)-1 {
+			o.write(packet.Bytes())
+		}
+	}
+}
+
+func (o *StatsDOutput) write(packet []byte) {
+	if _, err := o.conn.Write(packet); err != nil {
+		o.logger.Printf("Error sending to StatsD, %v\n", err)
+	}
+}
+
+// OnStop closes the UDP connection.
+func (o *StatsDOutput) OnStop() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.conn == nil {
+		return
+	}
+	o.conn.Close()
+	o.conn = nil
+}
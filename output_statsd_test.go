@@ -0,0 +1,105 @@
+package boomer
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test StatsD output", func() {
+
+	var agent net.PacketConn
+
+	BeforeEach(func() {
+		var err error
+		agent, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		agent.Close()
+	})
+
+	// receive returns the metrics of the packets received in a short time.
+	receive := func() []string {
+		var lines []string
+		buf := make([]byte, 65536)
+		for {
+			agent.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, _, err := agent.ReadFrom(buf)
+			if err != nil {
+				return lines
+			}
+			Expect(n).To(BeNumerically("<=", maxStatsDPacketSize))
+			lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+		}
+	}
+
+	newData := func(endpoints int) map[string]interface{} {
+		stats := newRequestStats()
+		for i := 0; i < endpoints; i++ {
+			name := "login"
+			if i > 0 {
+				name = fmt.Sprintf("/api/items/%d", i)
+			}
+			stats.logRequest("http", name, 10, 100)
+			stats.logRequest("http", name, 30, 300)
+			stats.logError("http", name, "500")
+		}
+		data := stats.collectReportData()
+		data["user_count"] = int32(5)
+		return data
+	}
+
+	It("test statsd output", func() {
+		o := NewStatsDOutput(agent.LocalAddr().String())
+		o.OnStart()
+		o.OnEvent(newData(1))
+		o.OnStop()
+		o.OnStop()
+
+		Expect(receive()).To(ConsistOf(
+			"boomer.users:5|g",
+			"boomer.http.login.median_response_time:10|g",
+			"boomer.http.login.requests:2|c",
+			"boomer.http.login.failures:1|c",
+			MatchRegexp(`^boomer\.http\.login\.rps:\d+\|g$`),
+		))
+	})
+
+	It("test statsd output with prefix and sample rate", func() {
+		o := NewStatsDOutput(agent.LocalAddr().String()).WithPrefix("checkout.").WithSampleRate(0.999999)
+		o.OnStart()
+		defer o.OnStop()
+		o.OnEvent(newData(2))
+
+		lines := receive()
+		Expect(lines).To(ContainElements(
+			"checkout.users:5|g",
+			"checkout.http._api_items_1.requests:2|c|@0.999999",
+			"checkout.http._api_items_1.median_response_time:10|g",
+		))
+	})
+
+	It("test statsd output splits packets", func() {
+		o := NewStatsDOutput(agent.LocalAddr().String())
+		o.OnStart()
+		defer o.OnStop()
+		o.OnEvent(newData(100))
+		Expect(receive()).To(HaveLen(1 + 100*4))
+	})
+
+	It("test statsd output without agent", func() {
+		o := NewStatsDOutput("invalid address").WithLogger(log.New(io.Discard, "", 0)).WithSampleRate(2)
+		Expect(o.sampleRate).To(BeEquivalentTo(1))
+		o.OnStart()
+		o.OnEvent(newData(1))
+		o.OnStop()
+	})
+})
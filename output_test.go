@@ -1,13 +1,117 @@
 package boomer
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/ugorji/go/codec"
 )
 
+// newBenchmarkStatsEntry returns an entry with buckets response times, and requests in each of seconds.
+func newBenchmarkStatsEntry(name string, buckets, seconds int) *statsEntry {
+	entry := &statsEntry{Name: name, Method: "http"}
+	entry.reset()
+	for i := int64(1); i <= int64(buckets); i++ {
+		entry.ResponseTimes[i] = i%7 + 1
+		entry.NumRequests += i%7 + 1
+		entry.TotalResponseTime += i * (i%7 + 1)
+	}
+	for i := int64(0); i < int64(seconds); i++ {
+		entry.NumReqsPerSec[entry.StartTime+i] = entry.NumRequests/int64(seconds) + 1
+		if entry.NumFailures < entry.NumRequests {
+			entry.NumFailPerSec[entry.StartTime+i] = 1
+			entry.NumFailures++
+		}
+	}
+	entry.MinResponseTime = 1
+	entry.MaxResponseTime = int64(buckets)
+	return entry
+}
+
+func newBenchmarkData(endpoints, buckets, seconds int) map[string]interface{} {
+	stats := make([]interface{}, 0, endpoints)
+	for i := 0; i < endpoints; i++ {
+		stats = append(stats, newBenchmarkStatsEntry(fmt.Sprintf("endpoint-%d", i), buckets, seconds).serialize())
+	}
+	return map[string]interface{}{
+		"user_count":  int32(100),
+		"stats":       stats,
+		"stats_total": newBenchmarkStatsEntry("Total", buckets, seconds).serialize(),
+	}
+}
+
+func BenchmarkGetMedianResponseTime(b *testing.B) {
+	for _, buckets := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("buckets=%d", buckets), func(b *testing.B) {
+			entry := newBenchmarkStatsEntry("median", buckets, 1)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				getMedianResponseTime(entry.NumRequests, entry.ResponseTimes)
+			}
+		})
+	}
+}
+
+func BenchmarkGetWindowedRps(b *testing.B) {
+	for _, seconds := range []int{60, 600, 3600} {
+		b.Run(fmt.Sprintf("seconds=%d", seconds), func(b *testing.B) {
+			entry := newBenchmarkStatsEntry("rps", 1, seconds)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				getWindowedRps(entry.NumReqsPerSec, defaultRPSWindowSecs)
+			}
+		})
+	}
+}
+
+func BenchmarkDeserializeStatsEntry(b *testing.B) {
+	for _, buckets := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("buckets=%d/seconds=3600", buckets), func(b *testing.B) {
+			stat := newBenchmarkStatsEntry("deserialize", buckets, 3600).serialize()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := deserializeStatsEntry(stat); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkConvertData(b *testing.B) {
+	for _, endpoints := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("endpoints=%d", endpoints), func(b *testing.B) {
+			// a report interval is a few seconds, so each endpoint has a few hundred buckets at most
+			data := newBenchmarkData(endpoints, 100, 3)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := convertData(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 var _ = Describe("test output", func() {
 
 	It("test get median response time", func() {
@@ -26,6 +130,82 @@ var _ = Describe("test output", func() {
 		Expect(medianResponseTime).To(BeEquivalentTo(0))
 	})
 
+	It("test get percentile response time", func() {
+		// a single bucket
+		Expect(getPercentileResponseTime(990, 1, map[int64]int64{42: 1})).To(BeEquivalentTo(42))
+		Expect(getPercentileResponseTime(0, 1, map[int64]int64{42: 1})).To(BeEquivalentTo(42))
+
+		// all the requests in one bucket
+		for _, n := range []int{0, 500, 900, 950, 990, 999, 1000} {
+			Expect(getPercentileResponseTime(n, 1000, map[int64]int64{100: 1000})).To(BeEquivalentTo(100))
+		}
+
+		// the boundary between two buckets, the positions of 100 are 0..89, and 200 are 90..99
+		responseTimes := map[int64]int64{100: 90, 200: 10}
+		Expect(getPercentileResponseTime(900, 100, responseTimes)).To(BeEquivalentTo(100))
+		Expect(getPercentileResponseTime(910, 100, responseTimes)).To(BeEquivalentTo(200))
+		Expect(getPercentileResponseTime(500, 100, responseTimes)).To(Equal(getMedianResponseTime(100, responseTimes)))
+
+		// p99.9 of 1000 requests is the 999th one
+		responseTimes = map[int64]int64{10: 998, 500: 1, 900: 1}
+		Expect(getPercentileResponseTime(990, 1000, responseTimes)).To(BeEquivalentTo(10))
+		Expect(getPercentileResponseTime(999, 1000, responseTimes)).To(BeEquivalentTo(500))
+		Expect(getPercentileResponseTime(1000, 1000, responseTimes)).To(BeEquivalentTo(900))
+
+		Expect(getPercentileResponseTime(990, 10, map[int64]int64{})).To(BeEquivalentTo(0))
+	})
+
+	It("test percentiles of stats entry output", func() {
+		// response times above 100ms are rounded, so 1000 requests are spread over 1..100ms
+		entry := &statsEntry{Name: "checkout", Method: "http"}
+		entry.reset()
+		for i := int64(1); i <= 1000; i++ {
+			entry.log((i-1)/10+1, 100)
+		}
+		output, err := deserializeStatsEntry(entry.serialize())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.medianResponseTime).To(BeEquivalentTo(50))
+		Expect(output.P90).To(BeEquivalentTo(90))
+		Expect(output.P95).To(BeEquivalentTo(95))
+		Expect(output.P99).To(BeEquivalentTo(99))
+		Expect(output.P999).To(BeEquivalentTo(100))
+
+		content, err := json.Marshal(output)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring(`"p99_response_time":99`))
+		Expect(string(content)).To(ContainSubstring(`"p999_response_time":100`))
+	})
+
+	It("test compute stddev and iqr", func() {
+		responseTimes := map[int64]int64{10: 1, 20: 1, 30: 1, 40: 1}
+		Expect(computeStdDev(4, 100, responseTimes)).To(BeNumerically("~", math.Sqrt(125), 1e-9))
+		Expect(computeIQR(4, responseTimes)).To(BeEquivalentTo(20))
+
+		// all the requests in one bucket
+		Expect(computeStdDev(1000, 100*1000, map[int64]int64{100: 1000})).To(BeZero())
+		Expect(computeIQR(1000, map[int64]int64{100: 1000})).To(BeZero())
+
+		Expect(computeStdDev(0, 0, map[int64]int64{})).To(BeZero())
+		Expect(computeIQR(0, map[int64]int64{})).To(BeZero())
+
+		// the fixture of the percentiles, the quartiles are 25 and 75
+		entry := &statsEntry{Name: "checkout", Method: "http"}
+		entry.reset()
+		for i := int64(1); i <= 1000; i++ {
+			entry.log((i-1)/10+1, 100)
+		}
+		output, err := deserializeStatsEntry(entry.serialize())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.IQR).To(BeEquivalentTo(50))
+		// the standard deviation of the uniform distribution over 1..100
+		Expect(output.StdDev).To(BeNumerically("~", math.Sqrt((100*100-1)/12.0), 1e-9))
+
+		content, err := json.Marshal(output)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring(`"iqr_response_time":50`))
+		Expect(string(content)).To(ContainSubstring(`"stddev_response_time":28.86`))
+	})
+
 	It("test get avg response time", func() {
 		numRequests := int64(3)
 		totalResponseTime := int64(100)
@@ -48,20 +228,109 @@ var _ = Describe("test output", func() {
 		Expect(avgContentLength).To(BeEquivalentTo(0))
 	})
 
-	It("test get current rps", func() {
-		numRequests := int64(10)
+	It("test get windowed rps", func() {
 		numReqsPerSecond := map[int64]int64{}
-
-		currentRps := getCurrentRps(numRequests, numReqsPerSecond)
-		Expect(currentRps).To(BeEquivalentTo(0))
+		Expect(getWindowedRps(numReqsPerSecond, 10)).To(BeEquivalentTo(0))
 
 		numReqsPerSecond[1] = 2
 		numReqsPerSecond[2] = 3
 		numReqsPerSecond[3] = 2
 		numReqsPerSecond[4] = 3
+		// fewer seconds than the window
+		Expect(getWindowedRps(numReqsPerSecond, 10)).To(BeEquivalentTo(2))
+		// only the last 2 seconds
+		Expect(getWindowedRps(numReqsPerSecond, 2)).To(BeEquivalentTo(2))
+		Expect(getWindowedRps(numReqsPerSecond, 1)).To(BeEquivalentTo(3))
+		Expect(getWindowedRps(numReqsPerSecond, 0)).To(BeEquivalentTo(0))
+	})
 
-		currentRps = getCurrentRps(numRequests, numReqsPerSecond)
-		Expect(currentRps).To(BeEquivalentTo(2))
+	It("test windowed rps follows the current load", func() {
+		// 100 rps for 10 minutes, then 1000 rps for 10 seconds
+		numReqsPerSecond := map[int64]int64{}
+		for second := int64(0); second < 600; second++ {
+			numReqsPerSecond[second] = 100
+		}
+		for second := int64(600); second < 610; second++ {
+			numReqsPerSecond[second] = 1000
+		}
+		Expect(getWindowedRps(numReqsPerSecond, defaultRPSWindowSecs)).To(BeEquivalentTo(1000))
+		Expect(getWindowedRps(numReqsPerSecond, 20)).To(BeEquivalentTo(550))
+		Expect(getWindowedFailPerSec(map[int64]int64{1: 4, 2: 6}, 10)).To(BeEquivalentTo(5))
+	})
+
+	It("test rps window in report data", func() {
+		entry := &statsEntry{Name: "checkout", Method: "http"}
+		entry.reset()
+		for second := int64(0); second < 20; second++ {
+			entry.NumReqsPerSec[second] = second + 1
+			entry.NumRequests += second + 1
+		}
+		data := map[string]interface{}{
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+			"user_count":  int32(1),
+		}
+		output, err := convertData(data)
+		Expect(err).NotTo(HaveOccurred())
+		// 11..20 in the last 10 seconds
+		Expect(output.TotalRPS).To(BeEquivalentTo(15))
+		Expect(output.Stats[0].currentRps).To(BeEquivalentTo(15))
+
+		r := newLocalRunner(nil, nil, 1, 1)
+		b := NewStandaloneBoomer(1, 1).WithLogger(log.New(io.Discard, "", 0))
+		Expect(b.WithRPSWindow(time.Millisecond).rpsWindowSecs).To(BeZero())
+		b.WithRPSWindow(2500 * time.Millisecond).setupRunner(&r.runner)
+		r.setReportData(data)
+		output, err = convertData(data)
+		Expect(err).NotTo(HaveOccurred())
+		// 19 and 20 in the last 2 seconds
+		Expect(output.TotalRPS).To(BeEquivalentTo(19))
+		Expect(output.Stats[0].currentRps).To(BeEquivalentTo(19))
+	})
+
+	It("test deserialize stats entry with custom serializer", func() {
+		mh := &codec.MsgpackHandle{}
+		calls := int64(0)
+		b := NewStandaloneBoomer(1, 1).WithCustomSerializer(func(v interface{}) (out []byte, err error) {
+			atomic.AddInt64(&calls, 1)
+			err = codec.NewEncoderBytes(&out, mh).Encode(v)
+			return out, err
+		}, func(data []byte, v interface{}) error {
+			atomic.AddInt64(&calls, 1)
+			return codec.NewDecoderBytes(data, mh).Decode(v)
+		})
+		r := newLocalRunner(nil, nil, 1, 1)
+		b.setupRunner(&r.runner)
+		o := NewInMemoryOutput()
+		named := NewInMemoryOutput()
+		r.addOutput(o)
+		r.addOutput(WithOutputNameOverride(named, "named"))
+		r.outputOnStart()
+
+		newStats := newRequestStats()
+		newStats.logRequest("http", "success", 10, 20)
+		newStats.logRequest("http", "success", 30, 40)
+		data := newStats.collectReportData()
+		data["user_count"] = int32(1)
+		keys := len(data)
+		// the outputs convert the data in parallel
+		r.outputOnEevent(data)
+		// the serializer is set on the outputs, the data is unchanged and the other boomers use JSON
+		Expect(data).To(HaveLen(keys))
+		Expect(atomic.LoadInt64(&calls)).To(BeEquivalentTo(8))
+		Expect(named.LastSnapshot().Stats).To(HaveLen(1))
+		_, err := convertData(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(atomic.LoadInt64(&calls)).To(BeEquivalentTo(8))
+
+		Expect(o.LastSnapshot().Stats).To(HaveLen(1))
+		entry := o.LastSnapshot().Stats[0]
+		Expect(entry.Name).To(Equal("success"))
+		Expect(entry.Method).To(Equal("http"))
+		Expect(entry.NumRequests).To(BeEquivalentTo(2))
+		Expect(entry.TotalContentLength).To(BeEquivalentTo(60))
+		Expect(entry.ResponseTimes).To(HaveKeyWithValue(int64(10), int64(1)))
+		Expect(entry.avgResponseTime).To(BeNumerically("~", 20))
 	})
 
 	It("test console output", func() {
@@ -98,6 +367,755 @@ var _ = Describe("test output", func() {
 		o.OnStop()
 	})
 
+	It("test test name in outputs", func() {
+		stat := map[string]interface{}{
+			"name":                 "http",
+			"method":               "post",
+			"num_requests":         int64(1),
+			"num_failures":         int64(0),
+			"response_times":       map[int64]int64{10: 1},
+			"total_response_time":  int64(10),
+			"min_response_time":    int64(10),
+			"max_response_time":    int64(10),
+			"total_content_length": int64(100),
+			"num_reqs_per_sec":     map[int64]int64{1: 1},
+		}
+		data := map[string]interface{}{
+			"stats":       []interface{}{stat},
+			"stats_total": stat,
+			"user_count":  int32(1),
+			"test_name":   "checkout flow",
+		}
+
+		output, err := convertData(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.TestName).To(Equal("checkout flow"))
+
+		buf := gbytes.NewBuffer()
+		o := NewConsoleOutput().WithLogger(log.New(buf, "", 0))
+		o.OnEvent(data)
+		Expect(buf).To(gbytes.Say("Test: checkout flow, Current time"))
+
+		o2 := NewPrometheusPusherOutput("http://127.0.0.1:0", "").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0)
+		o2.OnStart()
+		o2.OnEvent(data)
+		Expect(o2.jobName).To(Equal("checkout_flow"))
+
+		o3 := NewPrometheusPusherOutput("http://127.0.0.1:0", "boomer").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0)
+		o3.OnEvent(data)
+		Expect(o3.jobName).To(Equal("boomer"))
+	})
+
+	It("test zero count endpoints in outputs", func() {
+		b := NewStandaloneBoomer(1, 1).WithZeroCountEndpoints(true).WithZeroCountEndpoint("GET", "/registered")
+		b.localRunner = newLocalRunner([]*Task{{Name: "never_called", Fn: func() {}}}, nil, 1, 1)
+		b.setupRunner(&b.localRunner.runner)
+		data := b.localRunner.stats.collectReportData()
+		data["user_count"] = int32(0)
+
+		buf := gbytes.NewBuffer()
+		o := NewConsoleOutput().WithLogger(log.New(buf, "", 0))
+		o.OnEvent(data)
+		Expect(buf).To(gbytes.Say("never_called"))
+
+		o2 := NewPrometheusPusherOutput("http://127.0.0.1:0", "boomer").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0)
+		o2.OnStart()
+		o2.OnEvent(data)
+		families, err := o2.registry.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		found := false
+		for _, family := range families {
+			if family.GetName() != "boomer_num_requests" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "name" && label.GetValue() == "never_called" {
+						found = true
+						Expect(metric.GetGauge().GetValue()).To(BeZero())
+					}
+				}
+			}
+		}
+		Expect(found).To(BeTrue())
+
+		output, err := convertData(data)
+		Expect(err).NotTo(HaveOccurred())
+		methods := map[string]string{}
+		for _, stat := range output.Stats {
+			methods[stat.Name] = stat.Method
+		}
+		Expect(methods).To(Equal(map[string]string{"never_called": "", "/registered": "GET"}))
+	})
+
+	It("test console output with correlation column", func() {
+		stats := newRequestStats()
+		stats.correlationWindow = 10
+		for _, responseTime := range []int64{10, 100, 10, 100} {
+			stats.logRequest("http", "success", responseTime, 0)
+		}
+		data := stats.collectReportData()
+		data["user_count"] = int32(1)
+
+		buf := gbytes.NewBuffer()
+		NewConsoleOutput().WithLogger(log.New(buf, "", 0)).WithCorrelationColumn(true).OnEvent(data)
+		Expect(buf).To(gbytes.Say("CORRELATION"))
+		Expect(buf).To(gbytes.Say("-1.00"))
+	})
+
+	It("test console output with percentile columns", func() {
+		stats := newRequestStats()
+		for i := int64(1); i <= 1000; i++ {
+			stats.logRequest("http", "success", (i-1)/10+1, 0)
+		}
+		data := stats.collectReportData()
+		data["user_count"] = int32(1)
+
+		buf := gbytes.NewBuffer()
+		NewConsoleOutput().WithWriter(buf).OnEvent(data)
+		// the headers are title-cased by tablewriter, which separates the letters and digits
+		Expect(buf).To(gbytes.Say(`MEDIAN\s+\S\s+P 90\s+\S\s+P 95\s+\S\s+P 99\s+\S\s+P 999\s+\S\s+AVERAGE`))
+		Expect(buf).To(gbytes.Say(`50\s+\S\s+90\s+\S\s+95\s+\S\s+99\s+\S\s+100\s+\S`))
+
+		buf = gbytes.NewBuffer()
+		NewConsoleOutput().WithWriter(buf).WithPercentileColumns(false).OnEvent(data)
+		Expect(string(buf.Contents())).NotTo(ContainSubstring("P 999"))
+		Expect(buf).To(gbytes.Say(`MEDIAN\s+\S\s+AVERAGE`))
+	})
+
+	It("test prometheus metrics namespace mapping", func() {
+		newStat := func(name string) map[string]interface{} {
+			entry := &statsEntry{Name: name, Method: "http"}
+			entry.reset()
+			entry.log(10, 100)
+			return entry.serialize()
+		}
+		data := map[string]interface{}{
+			"stats":       []interface{}{newStat("checkout"), newStat("login")},
+			"stats_total": newStat("Total"),
+			"user_count":  int32(1),
+		}
+
+		o := NewPrometheusPusherOutput("http://127.0.0.1:0", "boomer").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0)
+		o.WithMetricsNamespaceMapping(func(method, name string) (string, string, string) {
+			if name == "checkout" {
+				return "myapp", "load_checkout", "pay"
+			}
+			return "", "", ""
+		})
+		o.OnStart()
+		o.OnEvent(data)
+
+		families, err := o.registry.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		buf := &bytes.Buffer{}
+		for _, family := range families {
+			_, err = expfmt.MetricFamilyToText(buf, family)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		text := buf.String()
+		Expect(text).To(ContainSubstring(`myapp_load_checkout_pay_num_requests{method="http",name="checkout"} 1`))
+		Expect(text).To(ContainSubstring(`myapp_load_checkout_pay_average_response_time{method="http",name="checkout"} 10`))
+		Expect(text).To(ContainSubstring(`boomer_num_requests{method="http",name="login"} 1`))
+		Expect(text).NotTo(ContainSubstring(`boomer_num_requests{method="http",name="checkout"}`))
+	})
+
+	It("test prometheus namespace and const labels", func() {
+		entry := &statsEntry{Name: "checkout", Method: "http"}
+		entry.reset()
+		entry.log(10, 100)
+		data := map[string]interface{}{
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+			"user_count":  int32(3),
+		}
+		gather := func(o *PrometheusPusherOutput) string {
+			families, err := o.registry.Gather()
+			Expect(err).NotTo(HaveOccurred())
+			buf := &bytes.Buffer{}
+			for _, family := range families {
+				_, err = expfmt.MetricFamilyToText(buf, family)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			return buf.String()
+		}
+
+		checkout := NewPrometheusPusherOutput("http://127.0.0.1:0", "checkout").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0).
+			WithNamespace("checkout").WithConstLabels(prometheus.Labels{"environment": "staging", "service": "checkout"})
+		custom, err := checkout.AddGauge("custom_queue_depth", "The depth of the queue", nil)
+		Expect(err).NotTo(HaveOccurred())
+		custom.WithLabelValues().Set(5)
+		checkout.OnStart()
+		checkout.OnEvent(data)
+		defaults := NewPrometheusPusherOutput("http://127.0.0.1:0", "boomer").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0)
+		defaults.OnStart()
+		defaults.OnEvent(data)
+
+		text := gather(checkout)
+		Expect(text).To(ContainSubstring(`checkout_num_requests{environment="staging",method="http",name="checkout",service="checkout"} 1`))
+		Expect(text).To(ContainSubstring(`checkout_users{environment="staging",service="checkout"} 3`))
+		Expect(text).To(ContainSubstring(`checkout_requests_total{environment="staging",method="http",name="checkout",service="checkout"} 1`))
+		Expect(text).To(ContainSubstring(`custom_queue_depth{environment="staging",service="checkout"} 5`))
+		Expect(text).NotTo(MatchRegexp(`(?m)^boomer_num_requests`))
+		// the metrics of the outputs are separated
+		text = gather(defaults)
+		Expect(text).To(ContainSubstring(`boomer_num_requests{method="http",name="checkout"} 1`))
+		Expect(text).NotTo(ContainSubstring("checkout_"))
+		Expect(text).NotTo(ContainSubstring("staging"))
+
+		// ignored after the metrics are registered, or for the labels of the built-in metrics
+		Expect(checkout.WithNamespace("other").namespace).To(Equal("checkout"))
+		Expect(NewPrometheusPusherOutput("", "").WithLogger(log.New(io.Discard, "", 0)).
+			WithConstLabels(prometheus.Labels{"method": "GET"}).constLabels).To(BeNil())
+	})
+
+	It("test user-defined prometheus metrics", func() {
+		pushed := make(chan []string, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			decoder := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+			names := []string{}
+			for {
+				family := &dto.MetricFamily{}
+				if err := decoder.Decode(family); err != nil {
+					break
+				}
+				names = append(names, family.GetName())
+			}
+			pushed <- names
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		o := NewPrometheusPusherOutput(server.URL, "boomer").WithLogger(log.New(io.Discard, "", 0))
+		gauge, err := o.AddGauge("myapp_queue_depth", "The depth of the queue", []string{"queue"})
+		Expect(err).NotTo(HaveOccurred())
+		counter, err := o.AddCounter("myapp_orders_total", "The number of orders", nil)
+		Expect(err).NotTo(HaveOccurred())
+		histogram, err := o.AddHistogram("myapp_order_amount", "The amount of orders", nil, []float64{10, 100})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = o.AddGauge("myapp_queue_depth", "duplicated", []string{"queue"})
+		Expect(err).To(HaveOccurred())
+		_, err = o.AddCounter("boomer_users", "conflicts with the built-in metric", nil)
+		Expect(err).To(HaveOccurred())
+
+		gauge.WithLabelValues("payments").Set(3)
+		counter.WithLabelValues().Inc()
+		histogram.WithLabelValues().Observe(42)
+
+		entry := &statsEntry{Name: "checkout", Method: "http"}
+		entry.reset()
+		entry.log(10, 100)
+		data := map[string]interface{}{
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+			"user_count":  int32(1),
+		}
+		o.OnStart()
+		o.OnEvent(data)
+
+		var names []string
+		Eventually(pushed).Should(Receive(&names))
+		Expect(names).To(ContainElements(
+			"myapp_queue_depth", "myapp_orders_total", "myapp_order_amount",
+			"boomer_users", "boomer_num_requests",
+		))
+	})
+
+	It("test prometheus request counters", func() {
+		newData := func(requests, failures int) map[string]interface{} {
+			entry := &statsEntry{Name: "checkout", Method: "http"}
+			entry.reset()
+			for i := 0; i < requests; i++ {
+				entry.log(50, 100)
+			}
+			for i := 0; i < failures; i++ {
+				entry.logError("timeout")
+			}
+			return map[string]interface{}{
+				"stats":       []interface{}{entry.serialize()},
+				"stats_total": entry.serialize(),
+				"user_count":  int32(1),
+			}
+		}
+
+		o := NewPrometheusPusherOutput("http://127.0.0.1:0", "boomer").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0)
+		o.OnStart()
+		requests, failures := 0, 0
+		for _, counts := range [][2]int{{10, 1}, {0, 0}, {25, 3}, {7, 2}} {
+			o.OnEvent(newData(counts[0], counts[1]))
+			requests += counts[0]
+			failures += counts[1]
+			Expect(testutil.ToFloat64(o.metrics.requestsTotal.WithLabelValues("http", "checkout"))).To(BeEquivalentTo(requests))
+			Expect(testutil.ToFloat64(o.metrics.failuresTotal.WithLabelValues("http", "checkout"))).To(BeEquivalentTo(failures))
+		}
+
+		// the counters are monotonic, they are kept after OnStop
+		o.OnStop()
+		Expect(testutil.ToFloat64(o.metrics.requestsTotal.WithLabelValues("http", "checkout"))).To(BeEquivalentTo(42))
+
+		families, err := o.registry.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		names := make([]string, 0, len(families))
+		for _, family := range families {
+			names = append(names, family.GetName())
+		}
+		Expect(names).To(ContainElements("boomer_requests_total", "boomer_failures_total"))
+	})
+
+	It("test prometheus percentile gauges", func() {
+		// response times above 100ms are rounded, so 1000 requests are spread over 1..100ms
+		entry := &statsEntry{Name: "checkout", Method: "http"}
+		entry.reset()
+		for i := int64(1); i <= 1000; i++ {
+			entry.log((i-1)/10+1, 100)
+		}
+		data := map[string]interface{}{
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+			"user_count":  int32(1),
+		}
+
+		o := NewPrometheusPusherOutput("http://127.0.0.1:0", "boomer").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0)
+		o.OnStart()
+		o.OnEvent(data)
+		o.OnStop()
+		Expect(testutil.ToFloat64(o.metrics.p90ResponseTime.WithLabelValues("http", "checkout"))).To(BeEquivalentTo(90))
+		Expect(testutil.ToFloat64(o.metrics.p95ResponseTime.WithLabelValues("http", "checkout"))).To(BeEquivalentTo(95))
+		Expect(testutil.ToFloat64(o.metrics.p99ResponseTime.WithLabelValues("http", "checkout"))).To(BeEquivalentTo(99))
+		Expect(testutil.ToFloat64(o.metrics.p999ResponseTime.WithLabelValues("http", "checkout"))).To(BeEquivalentTo(100))
+		Expect(testutil.ToFloat64(o.metrics.iqrResponseTime.WithLabelValues("http", "checkout"))).To(BeEquivalentTo(50))
+		Expect(testutil.ToFloat64(o.metrics.stdDevResponseTime.WithLabelValues("http", "checkout"))).To(BeNumerically("~", 28.87, 0.01))
+
+		families, err := o.registry.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		names := make([]string, 0, len(families))
+		for _, family := range families {
+			names = append(names, family.GetName())
+		}
+		Expect(names).To(ContainElements("boomer_p90_response_time", "boomer_p95_response_time",
+			"boomer_p99_response_time", "boomer_p999_response_time",
+			"boomer_stddev_response_time", "boomer_iqr_response_time"))
+	})
+
+	It("test prometheus slo buckets", func() {
+		entry := &statsEntry{Name: "checkout", Method: "http"}
+		entry.reset()
+		for i := 0; i < 50; i++ {
+			entry.log(50, 100)
+			entry.log(150, 100)
+		}
+		data := map[string]interface{}{
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+			"user_count":  int32(1),
+		}
+
+		o := NewPrometheusPusherOutput("http://127.0.0.1:0", "boomer").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0)
+		o.WithSLOBuckets([]float64{0.5, 0.1})
+		o.OnStart()
+		o.OnEvent(data)
+		o.OnEvent(data)
+
+		Expect(testutil.ToFloat64(o.sloRatio.WithLabelValues("http", "checkout", "100ms"))).To(BeNumerically("~", 0.5, 0.01))
+		Expect(testutil.ToFloat64(o.sloRatio.WithLabelValues("http", "checkout", "500ms"))).To(Equal(float64(1)))
+
+		families, err := o.registry.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		var histogram *dto.Histogram
+		for _, family := range families {
+			if family.GetName() == "boomer_response_time_slo_seconds" {
+				histogram = family.GetMetric()[0].GetHistogram()
+			}
+		}
+		Expect(histogram).NotTo(BeNil())
+		// accumulated from both intervals
+		Expect(histogram.GetSampleCount()).To(BeEquivalentTo(200))
+		Expect(histogram.GetSampleSum()).To(BeNumerically("~", 20, 0.001))
+		Expect(histogram.GetBucket()).To(HaveLen(2))
+		Expect(histogram.GetBucket()[0].GetUpperBound()).To(Equal(0.1))
+		Expect(histogram.GetBucket()[0].GetCumulativeCount()).To(BeEquivalentTo(100))
+		Expect(histogram.GetBucket()[1].GetCumulativeCount()).To(BeEquivalentTo(200))
+	})
+
+	It("test prometheus histogram buckets", func() {
+		entry := &statsEntry{Name: "checkout", Method: "http"}
+		entry.reset()
+		entry.log(3, 100)
+		entry.log(40, 100)
+		entry.log(40, 100)
+		data := map[string]interface{}{
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+			"user_count":  int32(1),
+		}
+
+		buf := gbytes.NewBuffer()
+		o := NewPrometheusPusherOutput("http://127.0.0.1:0", "boomer").WithLogger(log.New(buf, "", 0)).WithMaxPushRetries(0)
+		for _, buckets := range [][]float64{nil, {-1, 1}, {0.1, 0.1}, {0.5, 0.1}} {
+			Expect(o.WithPrometheusHistogramBuckets(buckets)).To(BeIdenticalTo(o))
+			Expect(buf).To(gbytes.Say("Invalid histogram buckets"))
+			Expect(o.responseTimeHistogram).To(BeNil())
+		}
+		o.WithPrometheusHistogramBuckets(BucketsForBatchProcessing)
+		// the buckets can be changed before OnStart, and invalid ones keep the buckets set before
+		o.WithPrometheusHistogramBuckets(BucketsForMicrosecondAPIs).WithPrometheusHistogramBuckets([]float64{1, 0})
+		o.OnStart()
+		o.OnEvent(data)
+
+		families, err := o.registry.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		var text bytes.Buffer
+		for _, family := range families {
+			if family.GetName() == "boomer_response_time_seconds" {
+				_, err := expfmt.MetricFamilyToText(&text, family)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
+		Expect(text.String()).To(ContainSubstring("# TYPE boomer_response_time_seconds histogram"))
+		Expect(text.String()).To(ContainSubstring(`boomer_response_time_seconds_bucket{method="http",name="checkout",le="0.0025"} 0`))
+		Expect(text.String()).To(ContainSubstring(`boomer_response_time_seconds_bucket{method="http",name="checkout",le="0.005"} 1`))
+		Expect(text.String()).To(ContainSubstring(`boomer_response_time_seconds_bucket{method="http",name="checkout",le="0.05"} 3`))
+		Expect(text.String()).To(ContainSubstring(`boomer_response_time_seconds_bucket{method="http",name="checkout",le="+Inf"} 3`))
+		Expect(text.String()).To(ContainSubstring(`boomer_response_time_seconds_count{method="http",name="checkout"} 3`))
+		Expect(strings.Count(text.String(), "boomer_response_time_seconds_bucket")).To(Equal(len(BucketsForMicrosecondAPIs) + 1))
+	})
+
+	It("test prometheus histogram response times", func() {
+		newData := func(responseTimes ...int64) map[string]interface{} {
+			entry := &statsEntry{Name: "checkout", Method: "http"}
+			entry.reset()
+			for _, responseTime := range responseTimes {
+				entry.log(responseTime, 100)
+			}
+			return map[string]interface{}{
+				"stats":       []interface{}{entry.serialize()},
+				"stats_total": entry.serialize(),
+				"user_count":  int32(1),
+			}
+		}
+		gather := func(o *PrometheusPusherOutput) string {
+			families, err := o.registry.Gather()
+			Expect(err).NotTo(HaveOccurred())
+			var text bytes.Buffer
+			for _, family := range families {
+				_, err := expfmt.MetricFamilyToText(&text, family)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			return text.String()
+		}
+
+		o := NewPrometheusPusherOutput("http://127.0.0.1:0", "boomer").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0).
+			WithHistogramResponseTimes(nil)
+		o.OnStart()
+		o.OnEvent(newData(1, 40, 40))
+		// the histogram is accumulated across the intervals
+		o.OnEvent(newData(20000))
+
+		text := gather(o)
+		Expect(text).To(ContainSubstring(`boomer_response_time_seconds_bucket{method="http",name="checkout",le="0.001"} 1`))
+		Expect(text).To(ContainSubstring(`boomer_response_time_seconds_bucket{method="http",name="checkout",le="0.05"} 3`))
+		Expect(text).To(ContainSubstring(`boomer_response_time_seconds_bucket{method="http",name="checkout",le="10"} 3`))
+		Expect(text).To(ContainSubstring(`boomer_response_time_seconds_bucket{method="http",name="checkout",le="30"} 4`))
+		Expect(text).To(ContainSubstring(`boomer_response_time_seconds_count{method="http",name="checkout"} 4`))
+		Expect(text).To(ContainSubstring(`boomer_response_time_seconds_sum{method="http",name="checkout"} 20.081`))
+		Expect(strings.Count(text, "boomer_response_time_seconds_bucket")).To(Equal(len(DefaultHistogramResponseTimeBuckets) + 1))
+		// the response time gauges are replaced by the histogram
+		for _, gauge := range []string{"median", "p90", "p99", "average", "min", "max"} {
+			Expect(text).NotTo(ContainSubstring("boomer_" + gauge + "_response_time"))
+		}
+		Expect(text).To(ContainSubstring(`boomer_num_requests{method="http",name="checkout"} 1`))
+
+		// the gauges are reported by default
+		o = NewPrometheusPusherOutput("http://127.0.0.1:0", "boomer").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0)
+		o.OnStart()
+		o.OnEvent(newData(1, 40, 40))
+		text = gather(o)
+		Expect(text).To(ContainSubstring(`boomer_median_response_time{method="http",name="checkout"} 40`))
+		Expect(text).NotTo(ContainSubstring("boomer_response_time_seconds"))
+
+		// invalid buckets are ignored
+		o = NewPrometheusPusherOutput("http://127.0.0.1:0", "boomer").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0).
+			WithHistogramResponseTimes([]float64{1, 0.5})
+		Expect(o.histogramResponseTimes).To(BeFalse())
+	})
+
+	It("test prometheus push retries", func() {
+		var calls, failures int32 = 0, 2
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			if atomic.AddInt32(&failures, -1) >= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		var buf bytes.Buffer
+		o := NewPrometheusPusherOutput(server.URL, "boomer").WithLogger(log.New(&buf, "", 0)).
+			WithPushRetryBackoff(time.Millisecond, 2*time.Millisecond)
+		entry := &statsEntry{Name: "checkout", Method: "http"}
+		entry.reset()
+		entry.log(10, 100)
+		data := map[string]interface{}{
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+			"user_count":  int32(1),
+		}
+		pushFailures := testutil.ToFloat64(counterPushFailures)
+		o.OnStart()
+		o.OnEvent(data)
+		Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(3))
+		Expect(testutil.ToFloat64(counterPushFailures)).To(Equal(pushFailures))
+		Expect(buf.String()).NotTo(ContainSubstring("Could not push to Pushgateway"))
+
+		// the retries are exhausted
+		atomic.StoreInt32(&calls, 0)
+		atomic.StoreInt32(&failures, 10)
+		o.WithMaxPushRetries(1).OnEvent(data)
+		Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(2))
+		Expect(testutil.ToFloat64(counterPushFailures)).To(Equal(pushFailures + 1))
+		Expect(buf.String()).To(ContainSubstring("Could not push to Pushgateway"))
+	})
+
+	It("test prometheus push retry delay", func() {
+		o := NewPrometheusPusherOutput("http://localhost", "boomer").WithLogger(log.New(io.Discard, "", 0)).
+			WithMaxPushRetries(-1).WithPushRetryBackoff(time.Second, time.Millisecond)
+		Expect(o.maxPushRetries).To(Equal(defaultMaxPushRetries))
+		for attempt, max := range []time.Duration{100, 200, 400, 800, 1600, 2000, 2000} {
+			for i := 0; i < 10; i++ {
+				delay := o.pushRetryDelay(attempt + 1)
+				Expect(delay).To(BeNumerically(">=", max*time.Millisecond/2))
+				Expect(delay).To(BeNumerically("<=", max*time.Millisecond))
+			}
+		}
+		Expect(o.pushRetryDelay(100)).To(BeNumerically("<=", defaultPushRetryCap))
+	})
+
+	It("test sanitize job name", func() {
+		Expect(sanitizeJobName("my test/v1?x=1")).To(Equal("my_test_v1_x_1"))
+		Expect(sanitizeJobName("load-test_1.0")).To(Equal("load-test_1.0"))
+	})
+
+	It("test console output with writer", func() {
+		entry := &statsEntry{Name: "checkout", Method: "http"}
+		entry.reset()
+		entry.log(10, 100)
+		data := map[string]interface{}{
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+			"user_count":  int32(1),
+		}
+
+		logBuf := &bytes.Buffer{}
+		buf := &bytes.Buffer{}
+		o := NewConsoleOutput().WithLogger(log.New(logBuf, "[boomer] ", log.LstdFlags)).WithWriter(buf)
+		o.OnEvent(data)
+		Expect(logBuf.Len()).To(BeZero())
+		Expect(buf.String()).To(MatchRegexp(`^Current time: \d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}, Users: 1, Total RPS: 1, Total Fail Ratio: 0\.0%\n`))
+		Expect(buf.String()).To(ContainSubstring("checkout"))
+		Expect(buf.String()).To(HaveSuffix("\n\n"))
+
+		// the last call wins
+		buf.Reset()
+		o.WithLogger(log.New(logBuf, "[boomer] ", 0))
+		o.OnEvent(data)
+		Expect(buf.Len()).To(BeZero())
+		Expect(logBuf.String()).To(HavePrefix("[boomer] Current time: "))
+
+		logBuf.Reset()
+		o.WithWriter(nil).WithWriter(buf)
+		o.OnEvent(data)
+		Expect(logBuf.Len()).To(BeZero())
+		Expect(buf.String()).To(HavePrefix("Current time: "))
+	})
+
+	It("test console output with errors table", func() {
+		stats := newRequestStats()
+		for i := 0; i < 4; i++ {
+			stats.logRequest("http", "checkout", 10, 100)
+			stats.logRequest("http", "login", 10, 100)
+		}
+		for i := 0; i < 3; i++ {
+			stats.logError("http", "checkout", "500 Internal Server Error")
+		}
+		stats.logError("http", "login", "connection refused")
+		for i := 0; i < 2; i++ {
+			stats.logError("http", "login", "timeout")
+		}
+		data := stats.collectReportData()
+		data["user_count"] = int32(1)
+
+		buf := &bytes.Buffer{}
+		o := NewConsoleOutput().WithWriter(buf)
+		o.OnEvent(data)
+		out := buf.String()
+		Expect(out).To(MatchRegexp(`(?i)error\s*│\s*method\s*│\s*name\s*│\s*occurrences`))
+		first := strings.Index(out, "500 Internal Server Error")
+		second := strings.Index(out, "timeout")
+		third := strings.Index(out, "connection refused")
+		Expect(first).To(BeNumerically(">", 0))
+		Expect(second).To(BeNumerically(">", first))
+		Expect(third).To(BeNumerically(">", second))
+		Expect(out).To(MatchRegexp(`500 Internal Server Error\s*│\s*http\s*│\s*checkout\s*│\s*3`))
+
+		buf.Reset()
+		o.WithErrorTableLimit(2).OnEvent(data)
+		Expect(buf.String()).To(ContainSubstring("timeout"))
+		Expect(buf.String()).NotTo(ContainSubstring("connection refused"))
+		Expect(buf.String()).To(ContainSubstring("1 less frequent errors are not shown"))
+
+		buf.Reset()
+		o.WithErrorTableLimit(0).OnEvent(data)
+		Expect(buf.String()).NotTo(ContainSubstring("timeout"))
+
+		// no errors table without errors
+		stats = newRequestStats()
+		stats.logRequest("http", "checkout", 10, 100)
+		data = stats.collectReportData()
+		data["user_count"] = int32(1)
+		buf.Reset()
+		NewConsoleOutput().WithWriter(buf).OnEvent(data)
+		Expect(strings.ToLower(buf.String())).NotTo(ContainSubstring("occurrences"))
+	})
+
+	It("test console output with color", func() {
+		stats := newRequestStats()
+		for i := 0; i < 10; i++ {
+			stats.logRequest("http", "healthy", 10, 100)
+			stats.logRequest("http", "failing", 10, 100)
+			stats.logRequest("http", "slow", 600, 100)
+			stats.logRequest("http", "slower", 1100, 100)
+		}
+		stats.logError("http", "failing", "500")
+		stats.logError("http", "slow", "500")
+		stats.logError("http", "slower", "500")
+		data := stats.collectReportData()
+		data["user_count"] = int32(1)
+
+		// not a terminal
+		buf := &bytes.Buffer{}
+		o := NewConsoleOutput().WithWriter(buf)
+		o.OnEvent(data)
+		Expect(buf.String()).NotTo(ContainSubstring("\033["))
+
+		buf.Reset()
+		o.WithColor(true).OnEvent(data)
+		out := buf.String()
+		Expect(out).To(MatchRegexp(`^\033\[33mCurrent time: .*Total Fail Ratio: 7\.5%\033\[0m\n`))
+		Expect(out).To(MatchRegexp(`\033\[32mhealthy\033\[0m\s*│\s*\033\[32m10\033\[0m`))
+		Expect(out).To(MatchRegexp(`\033\[31mfailing\033\[0m\s*│.*│\s*\033\[31m10\033\[0m\s*│`))
+		Expect(out).To(MatchRegexp(`\033\[31mslow\033\[0m\s*│.*│\s*\033\[33m600\033\[0m\s*│`))
+		Expect(out).To(MatchRegexp(`\033\[31mslower\033\[0m\s*│.*│\s*\033\[31m1100\033\[0m\s*│`))
+
+		buf.Reset()
+		o.WithSlowThresholdMs(1000).OnEvent(data)
+		Expect(buf.String()).To(MatchRegexp(`\033\[31mslow\033\[0m\s*│.*│\s*\033\[31m600\033\[0m\s*│`))
+		Expect(buf.String()).To(MatchRegexp(`\033\[31mslower\033\[0m\s*│.*│\s*\033\[33m1100\033\[0m\s*│`))
+
+		buf.Reset()
+		o.WithColor(false).OnEvent(data)
+		Expect(buf.String()).NotTo(ContainSubstring("\033["))
+	})
+
+	It("test console output with extra columns", func() {
+		entry := &statsEntry{Name: "checkout", Method: "http"}
+		entry.reset()
+		for _, responseTime := range []int64{10, 20, 30, 40} {
+			entry.log(responseTime, 100)
+		}
+		data := map[string]interface{}{
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+			"user_count":  int32(1),
+		}
+
+		buf := &bytes.Buffer{}
+		o := NewConsoleOutput().WithWriter(buf).WithPercentileColumns(false)
+		o.OnEvent(data)
+		Expect(strings.ToLower(buf.String())).NotTo(ContainSubstring("iqr"))
+
+		buf.Reset()
+		o.WithLogger(log.New(io.Discard, "", 0)).WithWriter(buf).WithExtraColumns([]string{"stddev", "IQR", "unknown"})
+		o.OnEvent(data)
+		Expect(buf.String()).To(MatchRegexp(`(?i)average\s*│\s*std\s*dev\s*│\s*iqr\s*│\s*min`))
+		Expect(buf.String()).To(MatchRegexp(`│\s*25\.00\s*│\s*11\.18\s*│\s*20\s*│\s*10\s*│`))
+
+		buf.Reset()
+		o.WithExtraColumns(nil).OnEvent(data)
+		Expect(strings.ToLower(buf.String())).NotTo(ContainSubstring("iqr"))
+	})
+
+	It("test console output with custom summary lines", func() {
+		entry := &statsEntry{Name: "checkout", Method: "http"}
+		entry.reset()
+		entry.log(10, 100)
+		data := map[string]interface{}{
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+			"user_count":  int32(1),
+			"custom_metrics": map[string]*CustomMetricEntry{
+				"order_value": newCustomMetricEntry("order_value", 42.5),
+			},
+		}
+
+		buf := &bytes.Buffer{}
+		o := NewConsoleOutput().WithWriter(buf).WithCustomSummaryLines(func(output *dataOutput) []string {
+			return []string{
+				fmt.Sprintf("Checkout Success Rate: %.1f%%", (1-output.TotalFailRatio)*100),
+				fmt.Sprintf("Average Order Value: $%.2f", output.CustomMetrics["order_value"].Value),
+			}
+		})
+		o.OnEvent(data)
+		lines := strings.Split(buf.String(), "\n")
+		Expect(lines[0]).To(HavePrefix("Current time: "))
+		Expect(lines[1]).To(Equal("Checkout Success Rate: 100.0%"))
+		Expect(lines[2]).To(Equal("Average Order Value: $42.50"))
+		Expect(buf.String()).To(ContainSubstring("checkout"))
+
+		buf.Reset()
+		o.WithCustomSummaryLines(nil).OnEvent(data)
+		Expect(buf.String()).NotTo(ContainSubstring("Checkout Success Rate"))
+	})
+
+	It("test response time precision", func() {
+		b := NewStandaloneBoomer(1, 1)
+		Expect(b.WithResponseTimePrecision(10).responseTimePrecision).To(BeNil())
+
+		newData := func(precision int) map[string]interface{} {
+			runner := newLocalRunner(nil, nil, 1, 1)
+			NewStandaloneBoomer(1, 1).WithResponseTimePrecision(precision).setupRunner(&runner.runner)
+			for _, responseTime := range []int64{10, 10, 11} {
+				runner.stats.logRequest("http", "checkout", responseTime, 0)
+			}
+			data := runner.stats.collectReportData()
+			data["user_count"] = int32(1)
+			runner.setReportData(data)
+			return data
+		}
+
+		output, err := convertData(newData(0))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.Stats[0].avgResponseTime).To(Equal(float64(10)))
+		Expect(output.TotalStats.avgResponseTime).To(Equal(float64(10)))
+
+		output, err = convertData(newData(3))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.Stats[0].avgResponseTime).To(Equal(10.333))
+
+		buf := &bytes.Buffer{}
+		NewConsoleOutput().WithWriter(buf).OnEvent(newData(3))
+		Expect(buf.String()).To(ContainSubstring(" 10.333 "))
+
+		// 2 decimal places by default
+		data := newData(3)
+		delete(data, "response_time_precision")
+		output, err = convertData(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.Stats[0].avgResponseTime).To(Equal(10.33))
+	})
+
 	It("test loggers", func() {
 		o := NewConsoleOutput()
 
@@ -111,3 +1129,134 @@ var _ = Describe("test output", func() {
 		o2.WithLogger(logger)
 	})
 })
+
+var _ = Describe("test convert data edge cases", func() {
+
+	It("test empty stats", func() {
+		total := &statsEntry{Name: "Total"}
+		total.reset()
+		output, err := convertData(map[string]interface{}{
+			"user_count":  int32(0),
+			"stats":       []interface{}{},
+			"stats_total": total.serialize(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.Stats).To(BeEmpty())
+		Expect(output.TotalRPS).To(BeZero())
+		Expect(output.TotalFailRatio).To(BeZero())
+		Expect(output.TotalStats.medianResponseTime).To(BeZero())
+		Expect(output.TotalStats.avgResponseTime).To(BeZero())
+		Expect(output.TotalStats.avgContentLength).To(BeZero())
+	})
+
+	It("test single endpoint", func() {
+		entry := &statsEntry{Name: "login", Method: "http"}
+		entry.reset()
+		entry.log(10, 100)
+		entry.log(20, 300)
+		entry.logError("timeout")
+		output, err := convertData(map[string]interface{}{
+			"user_count":  int32(1),
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.Stats).To(HaveLen(1))
+		stat := output.Stats[0]
+		Expect(stat.Name).To(Equal("login"))
+		Expect(stat.NumRequests).To(BeEquivalentTo(2))
+		Expect(stat.medianResponseTime).To(BeEquivalentTo(10))
+		Expect(stat.avgResponseTime).To(BeEquivalentTo(15))
+		Expect(stat.avgContentLength).To(BeEquivalentTo(200))
+		Expect(stat.currentRps).To(BeEquivalentTo(2))
+		Expect(output.TotalFailRatio).To(BeEquivalentTo(0.5))
+	})
+
+	It("test max int64 values", func() {
+		entry := &statsEntry{Name: "max", Method: "http"}
+		entry.reset()
+		entry.NumRequests = math.MaxInt64
+		entry.TotalResponseTime = math.MaxInt64
+		entry.TotalContentLength = math.MaxInt64
+		entry.MinResponseTime = math.MaxInt64
+		entry.MaxResponseTime = math.MaxInt64
+		entry.ResponseTimes[math.MaxInt64] = math.MaxInt64
+		entry.NumReqsPerSec[entry.StartTime] = math.MaxInt64
+		output, err := convertData(map[string]interface{}{
+			"user_count":  int32(math.MaxInt32),
+			"stats":       []interface{}{entry.serialize()},
+			"stats_total": entry.serialize(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.UserCount).To(BeEquivalentTo(math.MaxInt32))
+		stat := output.Stats[0]
+		Expect(stat.NumRequests).To(BeEquivalentTo(int64(math.MaxInt64)))
+		Expect(stat.MaxResponseTime).To(BeEquivalentTo(int64(math.MaxInt64)))
+		Expect(stat.medianResponseTime).To(BeEquivalentTo(int64(math.MaxInt64)))
+		Expect(stat.avgResponseTime).To(BeEquivalentTo(1))
+		Expect(stat.avgContentLength).To(BeEquivalentTo(1))
+		Expect(stat.currentRps).To(BeEquivalentTo(int64(math.MaxInt64)))
+		Expect(output.TotalRPS).To(BeEquivalentTo(int64(math.MaxInt64)))
+	})
+
+	DescribeTable("test corrupted stats entry", func(corrupt func(entry map[string]interface{}), message string) {
+		newEntry := func() map[string]interface{} {
+			entry := &statsEntry{Name: "login", Method: "http"}
+			entry.reset()
+			entry.log(10, 100)
+			entry.log(30, 100)
+			entry.logError("timeout")
+			return entry.serialize()
+		}
+		corrupted := newEntry()
+		corrupt(corrupted)
+
+		// in the stats of an endpoint
+		_, err := convertData(map[string]interface{}{
+			"user_count":  int32(1),
+			"stats":       []interface{}{newEntry(), corrupted},
+			"stats_total": newEntry(),
+		})
+		Expect(errors.Is(err, ErrInvalidStatsEntry)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring(message)))
+
+		// in the total stats
+		_, err = convertData(map[string]interface{}{
+			"user_count":  int32(1),
+			"stats":       []interface{}{newEntry()},
+			"stats_total": corrupted,
+		})
+		Expect(errors.Is(err, ErrInvalidStatsEntry)).To(BeTrue())
+	},
+		Entry("negative num_requests", func(e map[string]interface{}) {
+			e["num_requests"] = int64(-1)
+		}, "http login, num_requests -1 is negative"),
+		Entry("num_failures exceeds num_requests", func(e map[string]interface{}) {
+			e["num_failures"] = int64(3)
+		}, "num_failures 3 is not in [0, num_requests 2]"),
+		Entry("negative total_response_time", func(e map[string]interface{}) {
+			e["total_response_time"] = int64(-40)
+		}, "total_response_time -40 is negative"),
+		Entry("min_response_time exceeds max_response_time", func(e map[string]interface{}) {
+			e["min_response_time"] = int64(50)
+		}, "min_response_time 50 is not in [0, max_response_time 30]"),
+		Entry("negative count of response time", func(e map[string]interface{}) {
+			e["response_times"] = map[int64]int64{10: 1, 30: -1}
+		}, "response_times[30] -1 is not positive"),
+		Entry("zero requests per second", func(e map[string]interface{}) {
+			e["num_reqs_per_sec"] = map[int64]int64{1700000000: 0}
+		}, "num_reqs_per_sec[1700000000] 0 is not positive"),
+		Entry("negative failures per second", func(e map[string]interface{}) {
+			e["num_fail_per_sec"] = map[int64]int64{1700000000: -2}
+		}, "num_fail_per_sec[1700000000] -2 is not positive"),
+	)
+
+	It("test invalid data", func() {
+		_, err := convertData(map[string]interface{}{})
+		Expect(err).To(MatchError("user_count is not int32"))
+		_, err = convertData(map[string]interface{}{"user_count": int32(1)})
+		Expect(err).To(MatchError("stats is not []interface{}"))
+		_, err = convertData(map[string]interface{}{"user_count": int32(1), "stats": []interface{}{"invalid"}, "stats_total": "invalid"})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,82 @@
+//go:build !notrace
+// +build !notrace
+
+package boomer
+
+import (
+	"log"
+	"os"
+	"runtime/trace"
+)
+
+// TraceOutput records a Go execution trace during the test, which can be analyzed with "go tool trace".
+// Tracing has a significant performance impact, so don't use it for the real test.
+// It can be excluded from the build with the notrace build tag.
+type TraceOutput struct {
+	path   string
+	file   *os.File
+	logger *log.Logger
+}
+
+// NewTraceOutput returns a TraceOutput.
+// If path is empty, the trace is saved as "{testName}_trace.out" in the directory set by Boomer.WithOutputDirectory.
+func NewTraceOutput(path string) *TraceOutput {
+	return &TraceOutput{
+		path:   path,
+		logger: log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (o *TraceOutput) WithLogger(logger *log.Logger) *TraceOutput {
+	if logger != nil {
+		o.logger = logger
+	}
+	return o
+}
+
+func (o *TraceOutput) setOutputDirectory(dir, testName string) {
+	o.path = resolveOutputPath(o.path, dir, testName, "trace.out")
+}
+
+// OnStart will start tracing.
+func (o *TraceOutput) OnStart() {
+	if o.path == "" {
+		o.path = "trace.out"
+	}
+	f, err := os.Create(o.path)
+	if err != nil {
+		o.logger.Printf("Error creating trace file, %v\n", err)
+		return
+	}
+	if err = trace.Start(f); err != nil {
+		o.logger.Printf("Error starting trace, %v\n", err)
+		f.Close()
+		return
+	}
+	o.file = f
+	o.logger.Println("Start tracing, it will slow down the test significantly.")
+}
+
+// OnEvent of TraceOutput has nothing to do.
+func (o *TraceOutput) OnEvent(data map[string]interface{}) {
+
+}
+
+// OnStop will stop tracing and close the trace file.
+func (o *TraceOutput) OnStop() {
+	if o.file == nil {
+		return
+	}
+	trace.Stop()
+	o.file.Close()
+	o.file = nil
+	o.logger.Println("Stop tracing, the trace is saved in", o.path)
+}
+
+// WithTraceOutput records a Go execution trace in path during the test.
+func (b *Boomer) WithTraceOutput(path string) *Boomer {
+	b.AddOutput(NewTraceOutput(path).WithLogger(b.logger))
+	return b
+}
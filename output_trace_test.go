@@ -0,0 +1,57 @@
+//go:build !notrace
+// +build !notrace
+
+package boomer
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test trace output", func() {
+
+	It("test trace output", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "boomer.trace")
+		b := NewStandaloneBoomer(1, 1).WithTraceOutput(path)
+		Expect(b.outputs).To(HaveLen(1))
+
+		o := b.outputs[0].(*TraceOutput)
+		o.OnStart()
+		o.OnEvent(nil)
+		o.OnStop()
+		o.OnStop()
+
+		content, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(MatchRegexp(`^go 1\.\d+ trace\x00`))
+	})
+
+	It("test trace output with invalid path", func() {
+		o := NewTraceOutput(filepath.Join(GinkgoT().TempDir(), "missing", "boomer.trace"))
+		o.OnStart()
+		Expect(o.file).To(BeNil())
+		o.OnStop()
+	})
+	It("test trace output in output directory", func() {
+		dir := filepath.Join(GinkgoT().TempDir(), "results", "nightly")
+		b := NewStandaloneBoomer(1, 1).WithOutputDirectory(dir).WithTestName("checkout flow")
+		b.WithTraceOutput("")
+		b.AddOutput(WithOutputNameOverride(NewTraceOutput("custom.trace"), "custom"))
+		absolute := filepath.Join(GinkgoT().TempDir(), "absolute.trace")
+		b.WithTraceOutput(absolute)
+
+		b.setupRunner(&newLocalRunner(nil, nil, 1, 1).runner)
+		Expect(dir).To(BeADirectory())
+		Expect(b.outputs[0].(*TraceOutput).path).To(Equal(filepath.Join(dir, "checkout_flow_trace.out")))
+		Expect(b.outputs[1].(*NamedOutput).Output.(*TraceOutput).path).To(Equal(filepath.Join(dir, "custom.trace")))
+		Expect(b.outputs[2].(*TraceOutput).path).To(Equal(absolute))
+
+		o := b.outputs[0].(*TraceOutput)
+		o.OnStart()
+		o.OnStop()
+		Expect(filepath.Join(dir, "checkout_flow_trace.out")).To(BeARegularFile())
+	})
+})
@@ -0,0 +1,150 @@
+package boomer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const defaultRemoteConfigPollInterval = 10 * time.Second
+
+// remoteConfig polls a JSON config from endpoint, see Boomer.WithRemoteConfig.
+type remoteConfig struct {
+	endpoint     string
+	pollInterval time.Duration
+	authKey      string
+	authValue    string
+	client       *http.Client
+
+	// the body of the last applied config, the config is applied only if it's changed
+	last []byte
+}
+
+// remoteConfigData is the config returned by the endpoint, absent fields are nil and left unchanged.
+type remoteConfigData struct {
+	Users       *int     `json:"users"`
+	SpawnRate   *float64 `json:"spawn_rate"`
+	ThinkTimeMs *int64   `json:"think_time_ms"`
+	TargetRPS   *float64 `json:"target_rps"`
+}
+
+// fetch gets the config from the endpoint. It returns nil if the config isn't changed since the last call.
+func (c *remoteConfig) fetch() (*remoteConfigData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.pollInterval)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.authKey != "" {
+		req.Header.Set(c.authKey, c.authValue)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if c.last != nil && bytes.Equal(body, c.last) {
+		return nil, nil
+	}
+	data := &remoteConfigData{}
+	if err := json.Unmarshal(body, data); err != nil {
+		return nil, fmt.Errorf("invalid config, %w", err)
+	}
+	c.last = body
+	return data, nil
+}
+
+// startRemoteConfig polls the remote config every pollInterval and applies the changes until the runner is shut down.
+// scale changes the number of users, it's nil if the users are spawned by the master.
+func (r *runner) startRemoteConfig(scale func(users int)) {
+	if r.remoteConfig == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(r.remoteConfig.pollInterval)
+		defer ticker.Stop()
+		for {
+			data, err := r.remoteConfig.fetch()
+			if err != nil {
+				r.logger.Printf("Error fetching remote config from %s, %v\n", r.remoteConfig.endpoint, err)
+			} else if data != nil {
+				r.applyRemoteConfig(data, scale)
+			}
+			select {
+			case <-ticker.C:
+			case <-r.shutdownChan:
+				return
+			}
+		}
+	}()
+}
+
+// applyRemoteConfig applies the fields which are present in the remote config.
+func (r *runner) applyRemoteConfig(data *remoteConfigData, scale func(users int)) {
+	if data.ThinkTimeMs != nil {
+		atomic.StoreInt64(&r.thinkTime, *data.ThinkTimeMs)
+	}
+	if data.TargetRPS != nil {
+		limit := rate.Limit(*data.TargetRPS)
+		if *data.TargetRPS <= 0 {
+			limit = rate.Inf
+		}
+		r.targetRPSLimiter.SetLimit(limit)
+	}
+	if scale == nil {
+		if data.Users != nil || data.SpawnRate != nil {
+			r.logger.Println("The users are spawned by the master, users and spawn_rate in remote config are ignored")
+		}
+	} else {
+		if data.SpawnRate != nil && *data.SpawnRate > 0 {
+			r.setSpawnRate(*data.SpawnRate)
+		}
+		if data.Users != nil && *data.Users >= 0 && *data.Users != int(atomic.LoadInt32(&r.numClients)) {
+			scale(*data.Users)
+		}
+	}
+	r.logger.Printf("Remote config is applied, users: %d, spawn rate: %v, think time: %dms, target rps: %v\n",
+		atomic.LoadInt32(&r.numClients), r.getSpawnRate(), atomic.LoadInt64(&r.thinkTime), r.targetRPSLimiter.Limit())
+}
+
+// think sleeps for the think time between the tasks of a user, plus a random offset in ±thinkTimeJitter.
+// It returns false if ctx is canceled while sleeping.
+func (r *runner) think(ctx context.Context) bool {
+	d := time.Duration(atomic.LoadInt64(&r.thinkTime)) * time.Millisecond
+	if r.thinkTimeJitter > 0 {
+		d += time.Duration(r.random.Int63n(int64(2*r.thinkTimeJitter)+1)) - r.thinkTimeJitter
+	}
+	return r.sleep(ctx, d)
+}
+
+// sleep sleeps for d. It returns false if ctx is canceled or the runner is shut down while sleeping.
+func (r *runner) sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-r.shutdownChan:
+		return false
+	}
+}
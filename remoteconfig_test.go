@@ -0,0 +1,122 @@
+package boomer
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"golang.org/x/time/rate"
+)
+
+var _ = Describe("Test remote config", func() {
+
+	var (
+		config    atomic.Value
+		authValue atomic.Value
+		server    *httptest.Server
+	)
+
+	BeforeEach(func() {
+		config.Store(`{}`)
+		authValue.Store("")
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			authValue.Store(req.Header.Get("Authorization"))
+			w.Write([]byte(config.Load().(string)))
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	newRunner := func(b *Boomer) *localRunner {
+		r := newLocalRunner(nil, nil, 1, 1)
+		r.setLogger(b.logger)
+		b.setupRunner(&r.runner)
+		DeferCleanup(r.shutdown)
+		return r
+	}
+
+	It("test options", func() {
+		b := NewStandaloneBoomer(1, 1).WithRemoteConfigAuthHeader("Authorization", "Bearer token").WithRemoteConfig(server.URL, 0)
+		Expect(b.remoteConfig.endpoint).To(Equal(server.URL))
+		Expect(b.remoteConfig.pollInterval).To(Equal(defaultRemoteConfigPollInterval))
+		Expect(b.remoteConfig.authKey).To(Equal("Authorization"))
+		Expect(b.remoteConfig.authValue).To(Equal("Bearer token"))
+
+		r := newRunner(NewStandaloneBoomer(1, 1))
+		Expect(r.remoteConfig).To(BeNil())
+		Expect(r.targetRPSLimiter).To(BeNil())
+	})
+
+	It("test apply changing configs", func() {
+		config.Store(`{"users": 5, "spawn_rate": 2, "think_time_ms": 200, "target_rps": 10}`)
+		buf := gbytes.NewBuffer()
+		b := NewStandaloneBoomer(1, 1).WithLogger(log.New(buf, "", 0)).WithSpawnRateLimit(1).
+			WithRemoteConfig(server.URL, 50*time.Millisecond).
+			WithRemoteConfigAuthHeader("Authorization", "Bearer token")
+		r := newRunner(b)
+
+		users := make(chan int, 10)
+		r.startRemoteConfig(func(n int) { users <- n })
+		Eventually(users).Should(Receive(Equal(5)))
+		Expect(authValue.Load()).To(Equal("Bearer token"))
+		Expect(atomic.LoadInt64(&r.thinkTime)).To(BeEquivalentTo(200))
+		Expect(r.targetRPSLimiter.Limit()).To(Equal(rate.Limit(10)))
+		Expect(buf).To(gbytes.Say("Remote config is applied"))
+
+		// absent fields are left unchanged, and zero target_rps means no limit
+		config.Store(`{"users": 2, "target_rps": 0}`)
+		Eventually(users).Should(Receive(Equal(2)))
+		Expect(atomic.LoadInt64(&r.thinkTime)).To(BeEquivalentTo(200))
+		Expect(r.targetRPSLimiter.Limit()).To(Equal(rate.Inf))
+		Expect(r.spawnLimiter.Limit()).To(Equal(rate.Limit(2)))
+
+		// unchanged config isn't applied again
+		Consistently(users, 200*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("test invalid config is ignored", func() {
+		config.Store(`{"users": "many"}`)
+		buf := gbytes.NewBuffer()
+		b := NewStandaloneBoomer(1, 1).WithLogger(log.New(buf, "", 0)).WithRemoteConfig(server.URL, 50*time.Millisecond)
+		r := newRunner(b)
+
+		users := make(chan int, 10)
+		r.startRemoteConfig(func(n int) { users <- n })
+		Eventually(buf).Should(gbytes.Say("Error fetching remote config from .*, invalid config"))
+		Expect(users).NotTo(Receive())
+
+		config.Store(`{"users": 3}`)
+		Eventually(users).Should(Receive(Equal(3)))
+	})
+
+	It("test users are ignored in distributed mode", func() {
+		config.Store(`{"users": 5, "think_time_ms": 100}`)
+		buf := gbytes.NewBuffer()
+		b := NewStandaloneBoomer(1, 1).WithLogger(log.New(buf, "", 0)).WithRemoteConfig(server.URL, 50*time.Millisecond)
+		r := newRunner(b)
+
+		r.startRemoteConfig(nil)
+		Eventually(buf).Should(gbytes.Say("users and spawn_rate in remote config are ignored"))
+		Expect(atomic.LoadInt64(&r.thinkTime)).To(BeEquivalentTo(100))
+	})
+
+	It("test think time", func() {
+		r := newLocalRunner(nil, nil, 1, 1)
+		Expect(r.think(context.Background())).To(BeTrue())
+
+		atomic.StoreInt64(&r.thinkTime, 50)
+		start := time.Now()
+		Expect(r.think(context.Background())).To(BeTrue())
+		Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+
+		atomic.StoreInt64(&r.thinkTime, 60000)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(r.think(ctx)).To(BeFalse())
+	})
+})
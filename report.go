@@ -0,0 +1,136 @@
+package boomer
+
+import (
+	"time"
+)
+
+// TestReport summarizes a test, from the start to the end.
+type TestReport struct {
+	TestName  string
+	StartTime time.Time
+	EndTime   time.Time
+
+	TotalRequests int64
+	TotalFailures int64
+	// TotalTaskExecutions is the number of times that tasks are executed
+	TotalTaskExecutions int64
+
+	PeakRPS   int64
+	PeakUsers int32
+
+	// Stats of each endpoint, keyed by name and method
+	Stats map[string]*statsEntry
+	// CustomMetrics are recorded by RecordCustomMetric, keyed by name
+	CustomMetrics map[string]*CustomMetricEntry
+
+	// Err is the error which aborts the test, e.g. it wraps ErrOutput if the test is terminated by an output error.
+	// It's nil if the test ends normally.
+	Err error
+
+	// converts the data of the intervals, nil means JSON
+	serializer *statsSerializer
+}
+
+func newTestReport(testName string) *TestReport {
+	return &TestReport{
+		TestName:      testName,
+		StartTime:     time.Now(),
+		Stats:         make(map[string]*statsEntry),
+		CustomMetrics: make(map[string]*CustomMetricEntry),
+	}
+}
+
+// clearStats drops the merged stats, e.g. of the warm-up, and keeps the time and the peak users.
+func (report *TestReport) clearStats() {
+	report.TotalRequests = 0
+	report.TotalFailures = 0
+	report.PeakRPS = 0
+	report.Stats = make(map[string]*statsEntry)
+	report.CustomMetrics = make(map[string]*CustomMetricEntry)
+}
+
+// addInterval merges the data of a report interval into the report.
+func (report *TestReport) addInterval(data map[string]interface{}) error {
+	output, err := convertDataWithSerializer(data, report.serializer)
+	if err != nil {
+		return err
+	}
+
+	report.TotalRequests += output.TotalStats.NumRequests
+	report.TotalFailures += output.TotalStats.NumFailures
+	if output.TotalRPS > report.PeakRPS {
+		report.PeakRPS = output.TotalRPS
+	}
+	if output.UserCount > report.PeakUsers {
+		report.PeakUsers = output.UserCount
+	}
+
+	for _, stat := range output.Stats {
+		key := stat.Name + stat.Method
+		entry, ok := report.Stats[key]
+		if !ok {
+			entry = &statsEntry{Name: stat.Name, Method: stat.Method}
+			entry.reset()
+			entry.StartTime = stat.StartTime
+			report.Stats[key] = entry
+		}
+		entry.merge(&stat.statsEntry)
+	}
+
+	// custom metrics are merged across the intervals by requestStats already
+	for name, metric := range output.CustomMetrics {
+		report.CustomMetrics[name] = metric
+	}
+	return nil
+}
+
+// MergeReports combines the partial reports, e.g. the ones produced by each worker in distributed mode,
+// so they can be aggregated without running a master. The stats of each endpoint are merged like the master does,
+// and custom metrics are merged by their aggregation strategies. The reports are not modified.
+func MergeReports(reports ...*TestReport) *TestReport {
+	merged := &TestReport{
+		Stats:         make(map[string]*statsEntry),
+		CustomMetrics: make(map[string]*CustomMetricEntry),
+	}
+	for _, report := range reports {
+		if report == nil {
+			continue
+		}
+		if merged.TestName == "" {
+			merged.TestName = report.TestName
+		}
+		if merged.StartTime.IsZero() || (!report.StartTime.IsZero() && report.StartTime.Before(merged.StartTime)) {
+			merged.StartTime = report.StartTime
+		}
+		if report.EndTime.After(merged.EndTime) {
+			merged.EndTime = report.EndTime
+		}
+
+		merged.TotalRequests += report.TotalRequests
+		merged.TotalFailures += report.TotalFailures
+		merged.TotalTaskExecutions += report.TotalTaskExecutions
+		if report.PeakRPS > merged.PeakRPS {
+			merged.PeakRPS = report.PeakRPS
+		}
+		if report.PeakUsers > merged.PeakUsers {
+			merged.PeakUsers = report.PeakUsers
+		}
+
+		for key, stat := range report.Stats {
+			entry, ok := merged.Stats[key]
+			if !ok {
+				entry = &statsEntry{Name: stat.Name, Method: stat.Method}
+				entry.reset()
+				entry.StartTime = stat.StartTime
+				entry.LastRequestTimestamp = stat.LastRequestTimestamp
+				merged.Stats[key] = entry
+			}
+			entry.merge(stat)
+		}
+
+		for name, metric := range report.CustomMetrics {
+			merged.CustomMetrics[name] = metric.Strategy.aggregate(merged.CustomMetrics[name], metric)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,100 @@
+package boomer
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test report", func() {
+
+	It("test add interval", func() {
+		stats := newRequestStats()
+		report := newTestReport("test")
+
+		stats.logRequest("http", "foo", 10, 100)
+		stats.logRequest("http", "foo", 30, 100)
+		stats.logRequest("http", "foo", 50, 0)
+		stats.logError("http", "foo", "500 error")
+		stats.logCustomMetric("cache_hits", 1)
+		data := stats.collectReportData()
+		data["user_count"] = int32(5)
+		Expect(report.addInterval(data)).To(Succeed())
+
+		stats.logRequest("http", "foo", 5, 100)
+		stats.logRequest("http", "bar", 20, 0)
+		data = stats.collectReportData()
+		data["user_count"] = int32(10)
+		Expect(report.addInterval(data)).To(Succeed())
+
+		Expect(report.TotalRequests).To(BeEquivalentTo(5))
+		Expect(report.TotalFailures).To(BeEquivalentTo(1))
+		Expect(report.PeakUsers).To(BeEquivalentTo(10))
+		Expect(report.Stats).To(HaveLen(2))
+
+		foo := report.Stats["foohttp"]
+		Expect(foo.NumRequests).To(BeEquivalentTo(4))
+		Expect(foo.NumFailures).To(BeEquivalentTo(1))
+		Expect(foo.MinResponseTime).To(BeEquivalentTo(5))
+		Expect(foo.MaxResponseTime).To(BeEquivalentTo(50))
+		Expect(foo.TotalContentLength).To(BeEquivalentTo(300))
+		Expect(report.CustomMetrics).To(HaveKey("cache_hits"))
+
+		Expect(report.addInterval(map[string]interface{}{})).NotTo(Succeed())
+	})
+
+	It("test merge reports", func() {
+		start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		newReport := func(offset time.Duration, peakRPS int64, peakUsers int32, responseTimes ...int64) *TestReport {
+			report := newTestReport("checkout")
+			report.StartTime = start.Add(offset)
+			report.EndTime = start.Add(offset + time.Minute)
+			report.PeakRPS = peakRPS
+			report.PeakUsers = peakUsers
+			entry := &statsEntry{Name: "foo", Method: "http"}
+			entry.reset()
+			for _, responseTime := range responseTimes {
+				entry.log(responseTime, 10)
+			}
+			entry.logError("500 error")
+			report.Stats["foohttp"] = entry
+			report.TotalRequests = entry.NumRequests
+			report.TotalFailures = entry.NumFailures
+			report.TotalTaskExecutions = entry.NumRequests
+			report.CustomMetrics["cache_hits"] = SumAggregation.aggregate(nil, newCustomMetricEntry("cache_hits", float64(peakRPS)))
+			report.CustomMetrics["queue_depth"] = newCustomMetricEntry("queue_depth", float64(peakUsers))
+			return report
+		}
+		reports := []*TestReport{
+			newReport(time.Second, 100, 10, 10, 20),
+			newReport(0, 300, 5, 5, 50, 30),
+			newReport(2*time.Second, 200, 20, 40),
+		}
+
+		merged := MergeReports(append(reports, nil)...)
+		Expect(merged.TestName).To(Equal("checkout"))
+		Expect(merged.StartTime).To(Equal(start))
+		Expect(merged.EndTime).To(Equal(start.Add(2*time.Second + time.Minute)))
+		Expect(merged.TotalRequests).To(BeEquivalentTo(6))
+		Expect(merged.TotalFailures).To(BeEquivalentTo(3))
+		Expect(merged.TotalTaskExecutions).To(BeEquivalentTo(6))
+		Expect(merged.PeakRPS).To(BeEquivalentTo(300))
+		Expect(merged.PeakUsers).To(BeEquivalentTo(20))
+
+		foo := merged.Stats["foohttp"]
+		Expect(foo.NumRequests).To(BeEquivalentTo(6))
+		Expect(foo.NumFailures).To(BeEquivalentTo(3))
+		Expect(foo.MinResponseTime).To(BeEquivalentTo(5))
+		Expect(foo.MaxResponseTime).To(BeEquivalentTo(50))
+		Expect(foo.TotalResponseTime).To(BeEquivalentTo(155))
+		Expect(foo.TotalContentLength).To(BeEquivalentTo(60))
+
+		Expect(merged.CustomMetrics["cache_hits"].Value).To(Equal(float64(600)))
+		Expect(merged.CustomMetrics["queue_depth"].Value).To(Equal(float64(35) / 3))
+
+		// the reports are not modified
+		Expect(reports[0].Stats["foohttp"].NumRequests).To(BeEquivalentTo(2))
+		Expect(reports[0].CustomMetrics["cache_hits"].Value).To(Equal(float64(100)))
+	})
+})
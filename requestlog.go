@@ -0,0 +1,137 @@
+package boomer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestLogFormat is the format of the request log written by BoomerTransport.
+type RequestLogFormat int
+
+const (
+	// CLFLogFormat is the Apache Combined Log Format, followed by the response time in milliseconds,
+	// and the quoted error if any.
+	CLFLogFormat RequestLogFormat = iota
+	// JSONLogFormat writes a JSON object per line.
+	JSONLogFormat
+	// TABLogFormat writes the fields separated by tabs, in the same order as JSONLogFormat.
+	TABLogFormat
+)
+
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// requestLogEntry is a line of the request log.
+type requestLogEntry struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Method       string    `json:"method"`
+	URL          string    `json:"url"`
+	Status       int       `json:"status"`
+	ResponseTime int64     `json:"response_time"`
+	ResponseSize int64     `json:"response_size"`
+	Error        string    `json:"error,omitempty"`
+	RequestID    string    `json:"request_id,omitempty"`
+
+	host      string
+	proto     string
+	referer   string
+	userAgent string
+}
+
+func newRequestLogEntry(req *http.Request, resp *http.Response, err error, start time.Time, elapsed int64) *requestLogEntry {
+	entry := &requestLogEntry{
+		Timestamp:    start,
+		Method:       req.Method,
+		URL:          req.URL.String(),
+		ResponseTime: elapsed,
+		host:         req.URL.Host,
+		proto:        req.Proto,
+		referer:      req.Referer(),
+		userAgent:    req.UserAgent(),
+	}
+	if entry.proto == "" {
+		entry.proto = "HTTP/1.1"
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if resp != nil {
+		entry.Status = resp.StatusCode
+		if resp.ContentLength > 0 {
+			entry.ResponseSize = resp.ContentLength
+		}
+	}
+	return entry
+}
+
+// clfField returns "-" for empty fields, as Combined Log Format does.
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func (e *requestLogEntry) format(format RequestLogFormat) ([]byte, error) {
+	switch format {
+	case JSONLogFormat:
+		line, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		return append(line, '\n'), nil
+	case TABLogFormat:
+		fields := []string{
+			e.Timestamp.Format(time.RFC3339Nano),
+			e.Method,
+			e.URL,
+			strconv.Itoa(e.Status),
+			strconv.FormatInt(e.ResponseTime, 10),
+			strconv.FormatInt(e.ResponseSize, 10),
+			strings.NewReplacer("\t", " ", "\n", " ").Replace(e.Error),
+		}
+		return []byte(strings.Join(fields, "\t") + "\n"), nil
+	default:
+		status := "-"
+		if e.Status > 0 {
+			status = strconv.Itoa(e.Status)
+		}
+		size := "-"
+		if e.ResponseSize > 0 {
+			size = strconv.FormatInt(e.ResponseSize, 10)
+		}
+		line := fmt.Sprintf("%s - - [%s] %q %s %s %q %q %d",
+			clfField(e.host), e.Timestamp.Format(clfTimeFormat), e.Method+" "+e.URL+" "+e.proto,
+			status, size, clfField(e.referer), clfField(e.userAgent), e.ResponseTime)
+		if e.Error != "" {
+			line += fmt.Sprintf(" %q", e.Error)
+		}
+		return []byte(line + "\n"), nil
+	}
+}
+
+// requestLog writes the sampled requests to w, the writes are serialized.
+type requestLog struct {
+	w          io.Writer
+	format     RequestLogFormat
+	sampleRate float64
+	lock       sync.Mutex
+}
+
+func (l *requestLog) log(entry *requestLogEntry) {
+	if l.sampleRate < 1 && random.Float64() >= l.sampleRate {
+		return
+	}
+	line, err := entry.format(l.format)
+	if err != nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.w.Write(line)
+}
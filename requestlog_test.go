@@ -0,0 +1,134 @@
+package boomer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test request log", func() {
+
+	// %h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i", followed by the response time
+	clfPattern := regexp.MustCompile(`^(\S+) - - \[(\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\] "(\S+) (\S+) (HTTP/\d\.\d)" (\d{3}|-) (\d+|-) "([^"]*)" "([^"]*)" (\d+)`)
+
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newBoomer := func() *Boomer {
+		b := NewStandaloneBoomer(1, 1)
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+		go func() {
+			for {
+				select {
+				case <-b.localRunner.stats.requestSuccessChan:
+				case <-b.localRunner.stats.requestFailureChan:
+				case <-b.localRunner.shutdownChan:
+					return
+				}
+			}
+		}()
+		return b
+	}
+
+	It("test combined log format", func() {
+		buf := &bytes.Buffer{}
+		b := newBoomer()
+		defer b.localRunner.shutdown()
+		client := NewBoomerTransport().WithBoomer(b).WithRequestLog(buf, CLFLogFormat).Client()
+
+		req, _ := http.NewRequest("GET", server.URL+"/hello?id=1", nil)
+		req.Header.Set("Referer", "http://example.com/")
+		req.Header.Set("User-Agent", "boomer")
+		resp, err := client.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+
+		match := clfPattern.FindStringSubmatch(buf.String())
+		Expect(match).NotTo(BeNil())
+		Expect(match[1]).To(Equal(strings.TrimPrefix(server.URL, "http://")))
+		_, err = time.Parse(clfTimeFormat, match[2])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(match[3]).To(Equal("GET"))
+		Expect(match[4]).To(Equal(server.URL + "/hello?id=1"))
+		Expect(match[6]).To(Equal("200"))
+		Expect(match[7]).To(Equal("5"))
+		Expect(match[8]).To(Equal("http://example.com/"))
+		Expect(match[9]).To(Equal("boomer"))
+	})
+
+	It("test combined log format with error", func() {
+		req, _ := http.NewRequest("POST", "http://127.0.0.1:0/missing", nil)
+		line, err := newRequestLogEntry(req, nil, errors.New("connection refused"), time.Now(), 3).format(CLFLogFormat)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(clfPattern.Match(line)).To(BeTrue())
+		Expect(string(line)).To(HaveSuffix(`" - - "-" "-" 3 "connection refused"` + "\n"))
+	})
+
+	It("test json log format", func() {
+		buf := &bytes.Buffer{}
+		b := newBoomer()
+		defer b.localRunner.shutdown()
+		client := NewBoomerTransport().WithBoomer(b).WithRequestLog(buf, JSONLogFormat).Client()
+
+		resp, err := client.Get(server.URL + "/hello")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		Expect(lines).To(HaveLen(1))
+		entry := map[string]interface{}{}
+		Expect(json.Unmarshal([]byte(lines[0]), &entry)).To(Succeed())
+		Expect(entry).To(HaveKeyWithValue("method", "GET"))
+		Expect(entry).To(HaveKeyWithValue("url", server.URL+"/hello"))
+		Expect(entry).To(HaveKeyWithValue("status", float64(200)))
+		Expect(entry).To(HaveKeyWithValue("response_size", float64(5)))
+		Expect(entry).To(HaveKey("response_time"))
+		Expect(entry).NotTo(HaveKey("error"))
+		_, err = time.Parse(time.RFC3339Nano, entry["timestamp"].(string))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("test tab log format", func() {
+		req, _ := http.NewRequest("GET", "http://localhost/hello", nil)
+		resp := &http.Response{StatusCode: 404, ContentLength: 9}
+		line, err := newRequestLogEntry(req, resp, nil, time.Now(), 12).format(TABLogFormat)
+		Expect(err).NotTo(HaveOccurred())
+		fields := strings.Split(strings.TrimSuffix(string(line), "\n"), "\t")
+		Expect(fields).To(HaveLen(7))
+		Expect(fields[1:]).To(Equal([]string{"GET", "http://localhost/hello", "404", "12", "9", ""}))
+	})
+
+	It("test sample rate", func() {
+		buf := &bytes.Buffer{}
+		b := newBoomer()
+		defer b.localRunner.shutdown()
+		client := NewBoomerTransport().WithBoomer(b).WithRequestLog(buf, TABLogFormat).WithRequestLogSampleRate(0.2).Client()
+		for i := 0; i < 200; i++ {
+			resp, err := client.Get(server.URL + "/hello")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+		}
+		lines := strings.Count(buf.String(), "\n")
+		Expect(lines).To(BeNumerically(">", 10))
+		Expect(lines).To(BeNumerically("<", 80))
+
+		Expect(NewBoomerTransport().WithRequestLog(nil, JSONLogFormat).requestLog).To(BeNil())
+	})
+})
@@ -0,0 +1,57 @@
+package boomer
+
+import (
+	"runtime"
+	"time"
+)
+
+// The custom metrics recorded by Boomer.WithResourceMonitor.
+const (
+	ProcessHeapAllocMetric  = "boomer.process.heap_alloc_bytes"
+	ProcessHeapSysMetric    = "boomer.process.heap_sys_bytes"
+	ProcessGoroutinesMetric = "boomer.process.goroutines"
+	ProcessCPUSecondsMetric = "boomer.process.cpu_seconds_total"
+)
+
+var processMetrics = []string{ProcessHeapAllocMetric, ProcessHeapSysMetric, ProcessGoroutinesMetric, ProcessCPUSecondsMetric}
+
+// startResourceMonitor records the resource usage of the process as custom metrics every resourceMonitorInterval.
+func (r *runner) startResourceMonitor() {
+	if r.resourceMonitorInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(r.resourceMonitorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for _, metric := range readResourceUsage() {
+					select {
+					case r.stats.customMetricChan <- metric:
+					case <-r.shutdownChan:
+						return
+					}
+				}
+			case <-r.shutdownChan:
+				return
+			}
+		}
+	}()
+}
+
+// readResourceUsage returns the current resource usage of the process,
+// the CPU time is missing on the platforms which don't support it.
+func readResourceUsage() []*customMetric {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	metrics := []*customMetric{
+		{name: ProcessHeapAllocMetric, value: float64(m.HeapAlloc)},
+		{name: ProcessHeapSysMetric, value: float64(m.HeapSys)},
+		{name: ProcessGoroutinesMetric, value: float64(runtime.NumGoroutine())},
+	}
+	if cpu, ok := processCPUSeconds(); ok {
+		metrics = append(metrics, &customMetric{name: ProcessCPUSecondsMetric, value: cpu})
+	}
+	return metrics
+}
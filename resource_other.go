@@ -0,0 +1,8 @@
+//go:build !unix
+
+package boomer
+
+// the CPU time of the process is not supported on other platforms.
+func processCPUSeconds() (float64, bool) {
+	return 0, false
+}
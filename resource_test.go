@@ -0,0 +1,93 @@
+package boomer
+
+import (
+	"io"
+	"log"
+	"runtime"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Test resource monitor", func() {
+
+	It("test read resource usage", func() {
+		metrics := make(map[string]float64)
+		for _, metric := range readResourceUsage() {
+			metrics[metric.name] = metric.value
+		}
+		Expect(metrics[ProcessHeapAllocMetric]).To(BeNumerically(">", 0))
+		Expect(metrics[ProcessHeapSysMetric]).To(BeNumerically(">=", metrics[ProcessHeapAllocMetric]))
+		Expect(metrics[ProcessGoroutinesMetric]).To(BeNumerically(">", 0))
+		if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+			Expect(metrics).To(HaveKey(ProcessCPUSecondsMetric))
+		}
+	})
+
+	It("test aggregation strategies", func() {
+		b := NewStandaloneBoomer(1, 1).WithResourceMonitor(time.Second).
+			WithAggregationStrategy(ProcessGoroutinesMetric, MaxAggregation)
+		r := newLocalRunner(nil, nil, 1, 1)
+		b.setupRunner(&r.runner)
+		Expect(r.resourceMonitorInterval).To(Equal(time.Second))
+
+		prev := newCustomMetricEntry(ProcessHeapSysMetric, 10)
+		Expect(r.stats.aggregator(ProcessHeapSysMetric)(prev, newCustomMetricEntry(ProcessHeapSysMetric, 5)).Value).To(BeEquivalentTo(5))
+		prev = newCustomMetricEntry(ProcessGoroutinesMetric, 10)
+		Expect(r.stats.aggregator(ProcessGoroutinesMetric)(prev, newCustomMetricEntry(ProcessGoroutinesMetric, 5)).Value).To(BeEquivalentTo(10))
+	})
+
+	It("test resource usage increases monotonically", func() {
+		b := NewStandaloneBoomer(1, 1).WithResourceMonitor(20 * time.Millisecond)
+		r := newLocalRunner(nil, nil, 1, 1)
+		r.setLogger(log.New(io.Discard, "", 0))
+		b.setupRunner(&r.runner)
+		r.stats.start()
+		r.startResourceMonitor()
+		defer r.shutdown()
+
+		collect := func() map[string]*CustomMetricEntry {
+			var metrics map[string]*CustomMetricEntry
+			Eventually(func() map[string]*CustomMetricEntry {
+				metrics, _ = r.stats.collect()["custom_metrics"].(map[string]*CustomMetricEntry)
+				return metrics
+			}).Should(HaveKey(ProcessHeapSysMetric))
+			return metrics
+		}
+
+		first := collect()
+		// burn some CPU and grow the heap
+		garbage := make([][]byte, 0, 100)
+		deadline := time.Now().Add(100 * time.Millisecond)
+		for time.Now().Before(deadline) {
+			garbage = append(garbage[:len(garbage)%100], make([]byte, 1<<16))
+		}
+		time.Sleep(50 * time.Millisecond)
+		second := collect()
+
+		Expect(second[ProcessHeapSysMetric].Value).To(BeNumerically(">=", first[ProcessHeapSysMetric].Value))
+		Expect(second[ProcessGoroutinesMetric].Value).To(BeNumerically(">", 0))
+		if first[ProcessCPUSecondsMetric] != nil {
+			Expect(second[ProcessCPUSecondsMetric].Value).To(BeNumerically(">", first[ProcessCPUSecondsMetric].Value))
+		}
+		runtime.KeepAlive(garbage)
+	})
+
+	It("test prometheus goroutine count", func() {
+		entry := &statsEntry{Name: "Total"}
+		entry.reset()
+		o := NewPrometheusPusherOutput("http://127.0.0.1:0", "boomer").WithLogger(log.New(io.Discard, "", 0)).WithMaxPushRetries(0)
+		o.OnStart()
+		o.OnEvent(map[string]interface{}{
+			"stats":       []interface{}{},
+			"stats_total": entry.serialize(),
+			"user_count":  int32(1),
+			"custom_metrics": map[string]*CustomMetricEntry{
+				ProcessGoroutinesMetric: newCustomMetricEntry(ProcessGoroutinesMetric, 42),
+			},
+		})
+		Expect(testutil.ToFloat64(o.metrics.goroutineCount)).To(BeEquivalentTo(42))
+	})
+})
@@ -0,0 +1,20 @@
+//go:build unix
+
+package boomer
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// processCPUSeconds returns the user and system CPU time of the process.
+func processCPUSeconds() (float64, bool) {
+	var usage unix.Rusage
+	if err := unix.Getrusage(unix.RUSAGE_SELF, &usage); err != nil {
+		return 0, false
+	}
+	return time2Seconds(usage.Utime) + time2Seconds(usage.Stime), true
+}
+
+func time2Seconds(tv unix.Timeval) float64 {
+	return float64(tv.Sec) + float64(tv.Usec)/1e6
+}
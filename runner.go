@@ -2,15 +2,23 @@ package boomer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
 	"os"
+	"os/signal"
 	"runtime"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -25,6 +33,8 @@ const (
 	slaveReportInterval    = 3 * time.Second
 	heartbeatInterval      = 1 * time.Second
 	masterHeartbeatTimeout = 60 * time.Second
+
+	defaultMemoryCheckInterval = 30 * time.Second
 )
 
 type runner struct {
@@ -38,19 +48,162 @@ type runner struct {
 	rateLimitEnabled bool
 	stats            *requestStats
 
+	// per task rate limiters, keyed by the request type and name of the task
+	taskRateLimiters map[taskEndpoint]*rate.Limiter
+
+	// limits the rate of spawning users with a token bucket, nil means all the users are spawned at once.
+	// It's only set by Boomer.WithSpawnRateLimit, the spawn rate of the master or the remote config changes its limit.
+	spawnLimiter *rate.Limiter
+	// guards spawnLimiter and spawnRate, which are changed by the master or the remote config while spawning.
+	spawnRateLock sync.Mutex
+
+	// factories of per-user data, keyed by context key
+	userDataFactories map[string]func() interface{}
+	userDataLock      debugMutex
+
+	// shared by all the goroutines through context
+	dataFeed *CSVDataFeed
+
+	// returns the index of the task in tasks, which the user always runs
+	consistentHashing func(userID int, requestType, name string) int
+
 	// TODO: we save user_class_count in spawn message and send it back to master without modification, may be a bad idea?
 	userClassesCountFromMaster map[string]int64
 
+	// numClients is written with spawnLock held, and read atomically.
 	numClients int32
 	spawnRate  float64
 
 	// Cancellation method for all running workers(goroutines)
 	cancelFuncs []context.CancelFunc
+	// serializes spawning and stopping the users, which may be requested by the runner, the master
+	// and the remote config at the same time.
+	spawnLock debugMutex
+	// closed to cancel the users being spawned by spawnLimiter, guarded by spawnLock.
+	spawnCancel chan struct{}
 
 	// close this channel will stop all goroutines used in runner, including running workers.
 	shutdownChan chan bool
 
 	outputs []Output
+	// bounds the number of outputs called at the same time, nil means no limit.
+	outputFanout chan struct{}
+
+	// the test is stopped after runTime, zero means no limit.
+	runTime          time.Duration
+	startTime        time.Time
+	progressReporter func(elapsed, remaining time.Duration, progress float64)
+
+	// the test is stopped if the heap in use exceeds maxMemoryUsage, zero means no limit.
+	maxMemoryUsage      uint64
+	memoryCheckInterval time.Duration
+
+	// bounds the number of tasks running at the same time across all the users, nil means no limit.
+	concurrencySemaphore chan struct{}
+	concurrency          int32
+
+	// runs the users in userGroup if it's set, see Boomer.WithErrGroupLimit. userGroup is created by the first user,
+	// and replaced after all the users are stopped. They are guarded by spawnLock.
+	errGroupLimit  int
+	userGroup      *errgroup.Group
+	userGroupCtx   context.Context
+	closeUserGroup context.CancelFunc
+
+	// identifies the scenario in the outputs
+	testName string
+
+	// stops the test, it's set by localRunner and slaveRunner
+	abort func()
+	// the error which aborts the test, e.g. the first error returned by Task.FnWithError
+	failOnFirstTaskError bool
+	abortOnOutputError   bool
+	outputErrorHandler   func(output Output, err error)
+	abortErr             error
+	abortOnce            sync.Once
+	abortLock            debugMutex
+
+	// the number of task executions, the test is stopped after maxTaskExecutions if it's set
+	taskExecutions    int64
+	maxTaskExecutions int64
+
+	// the first warmUpExecutions task executions are not counted, warmUpDone is closed when all of them return
+	warmUpExecutions int64
+	warmUpFinished   int64
+	warmUpDone       chan struct{}
+
+	// each user exits after maxRequestsPerUser tasks if it's positive, the test is stopped when all the users exit.
+	// runningUsers counts the started users plus the ongoing spawning, usersFinished counts the users
+	// which have executed maxRequestsPerUser tasks.
+	maxRequestsPerUser int64
+	runningUsers       int32
+	usersFinished      int32
+
+	// the number of decimal places of the average response times, nil means defaultResponseTimePrecision
+	responseTimePrecision *int
+
+	// the seconds over which the current rps is averaged, zero means defaultRPSWindowSecs
+	rpsWindowSecs int64
+
+	// the current stats are reported immediately on receipt of these signals
+	statsFlushSignals []os.Signal
+
+	// polls the users, spawn rate, think time and target RPS from an endpoint, nil means disabled.
+	remoteConfig *remoteConfig
+	// limits the task executions per second across all the users, set by remoteConfig, nil means no limit.
+	targetRPSLimiter *rate.Limiter
+	// the time in milliseconds each user sleeps between the tasks, set by remoteConfig.
+	thinkTime int64
+	// each think time is offset by a random duration in ±thinkTimeJitter, so the users don't synchronize.
+	thinkTimeJitter time.Duration
+	// the random source of the runner, it's passed to the users in their contexts.
+	random *rand.Rand
+	// converts the stats for the outputs, nil means JSON, see Boomer.WithCustomSerializer.
+	serializer *statsSerializer
+	// the users spawned together start their tasks spread evenly over initialSpreadDelay, zero means at once.
+	initialSpreadDelay time.Duration
+
+	// a task fails if it doesn't finish its setup in taskSetupTimeout, zero means no limit.
+	// timedOutTasks counts the timed out tasks which are still running.
+	taskSetupTimeout time.Duration
+	timedOutTasks    int32
+
+	// the test is stopped on a failure spike detected by the stats, if stopOnSpike is enabled
+	stopOnSpike bool
+
+	// the resource usage of the process is recorded as custom metrics every resourceMonitorInterval, if it's set
+	resourceMonitorInterval time.Duration
+
+	// the tasks running longer than stuckThreshold are logged and counted in stuckTasks, zero means disabled.
+	stuckThreshold time.Duration
+	runningTasks   sync.Map // user id => *runningTask
+	stuckTasks     int64
+
+	// the test is idle if no requests are recorded in maxIdleTime, and it's stopped if stopOnIdle is enabled.
+	// lastActivity and idleTimedOut are only accessed by the goroutine which reports the stats.
+	maxIdleTime  time.Duration
+	stopOnIdle   bool
+	lastActivity time.Time
+	idleTimedOut bool
+
+	// the start, stop and scale actions are written to auditLog, if it's set
+	auditLog *auditLog
+
+	// the stacks of all the goroutines are dumped to outputDirectory, or the logger, when the test is aborted
+	goroutineStackTrace bool
+	outputDirectory     string
+
+	// the OS threads of the users are pinned to cpuAffinity, if it's set
+	cpuAffinity        []int
+	cpuAffinityWarning sync.Once
+
+	// called with the panics recovered by safeRun, if it's set
+	onPanic func(err interface{}, stackTrace []byte)
+	// called with the panics of the tasks, see Boomer.WithTaskExceptionCallback
+	taskExceptionCallbacks []func(requestType, name string, err interface{}, stack []byte)
+
+	// logs the internal state transitions to debugLogger, see Boomer.WithDebugMode
+	debug       bool
+	debugLogger *log.Logger
 
 	logger *log.Logger
 }
@@ -68,21 +221,99 @@ func (r *runner) safeRun(fn func()) {
 		// don't panic
 		err := recover()
 		if err != nil {
-			stackTrace := debug.Stack()
-			errMsg := fmt.Sprintf("%v", err)
-			os.Stderr.Write([]byte(errMsg))
-			os.Stderr.Write([]byte("\n"))
-			os.Stderr.Write(stackTrace)
+			r.handlePanic(err, debug.Stack())
 		}
 	}()
 	fn()
 }
 
+func (r *runner) handlePanic(err interface{}, stackTrace []byte) {
+	errMsg := fmt.Sprintf("%v", err)
+	os.Stderr.Write([]byte(errMsg))
+	os.Stderr.Write([]byte("\n"))
+	os.Stderr.Write(stackTrace)
+	if r.onPanic != nil {
+		r.onPanic(err, stackTrace)
+	}
+}
+
+// runTaskSafely runs the task like safeRun. If the task panics, a failure is recorded with the task name,
+// and the taskExceptionCallbacks are called in their own goroutines.
+func (r *runner) runTaskSafely(ctx context.Context, task *Task) (err error) {
+	start := time.Now()
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		stackTrace := debug.Stack()
+		r.handlePanic(recovered, stackTrace)
+		if !TaskTimedOut(ctx) {
+			r.stats.requestFailureChan <- &requestFailure{
+				requestType:  "task",
+				name:         task.Name,
+				responseTime: time.Since(start).Milliseconds(),
+				error:        fmt.Sprintf("%v", recovered),
+			}
+		}
+		for _, callback := range r.taskExceptionCallbacks {
+			go r.callTaskExceptionCallback(callback, task.Name, recovered, stackTrace)
+		}
+	}()
+	return task.run(ctx)
+}
+
+// callTaskExceptionCallback calls the callback, and logs its panic instead of passing it to the callbacks again.
+func (r *runner) callTaskExceptionCallback(callback func(requestType, name string, err interface{}, stack []byte), name string, err interface{}, stackTrace []byte) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			r.logger.Printf("Task exception callback panics, %v\n%s", recovered, debug.Stack())
+		}
+	}()
+	callback("task", name, err, stackTrace)
+}
+
 func (r *runner) addOutput(o Output) {
 	r.outputs = append(r.outputs, o)
 }
 
 func (r *runner) outputOnStart() {
+	for _, o := range r.outputs {
+		setOutputSerializer(o, r.serializer)
+	}
+	r.dispatchOutputs(func(o Output) {
+		o.OnStart()
+	})
+}
+
+func (r *runner) outputOnEevent(data map[string]interface{}) {
+	r.dispatchOutputs(func(o Output) {
+		if r.debug {
+			start := time.Now()
+			defer func() {
+				r.debugf("Output %s handled the event in %v\n", outputName(o), time.Since(start))
+			}()
+		}
+		eo, ok := o.(ErrorOutput)
+		if !ok {
+			o.OnEvent(data)
+			return
+		}
+		if err := eo.OnEventWithError(data); err != nil {
+			r.outputError(o, err)
+		}
+	})
+}
+
+func (r *runner) outputOnStop() {
+	r.dispatchOutputs(func(o Output) {
+		o.OnStop()
+	})
+}
+
+// dispatchOutputs calls fn with every output in parallel, and waits for all of them.
+// At most cap(outputFanout) calls run at the same time if outputFanout is set, see Boomer.WithOutputFanout.
+func (r *runner) dispatchOutputs(fn func(o Output)) {
 	size := len(r.outputs)
 	if size == 0 {
 		return
@@ -90,88 +321,590 @@ func (r *runner) outputOnStart() {
 	wg := sync.WaitGroup{}
 	wg.Add(size)
 	for _, output := range r.outputs {
+		if r.outputFanout != nil {
+			r.outputFanout <- struct{}{}
+		}
 		go func(o Output) {
-			o.OnStart()
-			wg.Done()
+			defer wg.Done()
+			if r.outputFanout != nil {
+				defer func() { <-r.outputFanout }()
+			}
+			fn(o)
 		}(output)
 	}
 	wg.Wait()
 }
 
-func (r *runner) outputOnEevent(data map[string]interface{}) {
-	size := len(r.outputs)
-	if size == 0 {
+// reportProgress calls progressReporter with the time elapsed since the test is started.
+// If runTime is not set, remaining is -1 and progress is 0.
+func (r *runner) reportProgress() {
+	if r.progressReporter == nil {
 		return
 	}
-	wg := sync.WaitGroup{}
-	wg.Add(size)
-	for _, output := range r.outputs {
-		go func(o Output) {
-			o.OnEvent(data)
-			wg.Done()
-		}(output)
+	elapsed := time.Since(r.startTime)
+	remaining := time.Duration(-1)
+	progress := float64(0)
+	if r.runTime > 0 {
+		remaining = r.runTime - elapsed
+		if remaining < 0 {
+			remaining = 0
+		}
+		progress = math.Min(float64(elapsed)/float64(r.runTime), 1)
 	}
-	wg.Wait()
+	r.progressReporter(elapsed, remaining, progress)
 }
 
-func (r *runner) outputOnStop() {
-	size := len(r.outputs)
-	if size == 0 {
+// ErrMemoryLimitExceeded is returned by Boomer.Err if the test is stopped by Boomer.WithMaxMemoryUsage.
+var ErrMemoryLimitExceeded = errors.New("boomer: memory limit exceeded")
+
+// startMemoryMonitor checks the heap in use periodically, and aborts the test if it exceeds maxMemoryUsage.
+func (r *runner) startMemoryMonitor() {
+	if r.maxMemoryUsage == 0 {
 		return
 	}
-	wg := sync.WaitGroup{}
-	wg.Add(size)
-	for _, output := range r.outputs {
-		go func(o Output) {
-			o.OnStop()
-			wg.Done()
-		}(output)
+	interval := r.memoryCheckInterval
+	if interval <= 0 {
+		interval = defaultMemoryCheckInterval
 	}
-	wg.Wait()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		warned := false
+		for {
+			select {
+			case <-ticker.C:
+				var m runtime.MemStats
+				runtime.ReadMemStats(&m)
+				if m.HeapInuse > r.maxMemoryUsage {
+					Events.Publish(EVENT_MEMORY_LIMIT_EXCEEDED, m.HeapInuse, r.maxMemoryUsage)
+					r.abortWithError(fmt.Errorf("%w, heap in use %d bytes, limit %d bytes", ErrMemoryLimitExceeded, m.HeapInuse, r.maxMemoryUsage))
+					return
+				}
+				if !warned && m.HeapInuse > r.maxMemoryUsage/5*4 {
+					warned = true
+					r.logger.Printf("Heap in use %d bytes exceeds 80%% of the limit %d bytes, tasks may leak memory, or try runtime.GC() in tasks.\n", m.HeapInuse, r.maxMemoryUsage)
+				}
+			case <-r.shutdownChan:
+				return
+			}
+		}
+	}()
 }
 
-// addWorkers start the goroutines and add it to cancelFuncs
+// onFailureSpike publishes EVENT_FAILURE_SPIKE and logs a warning, then aborts the test if stopOnSpike is enabled.
+// It's called by the stats goroutine.
+func (r *runner) onFailureSpike(failures int64) {
+	event := FailureSpikeEvent{
+		Window:      r.stats.failureSpike.window,
+		Failures:    failures,
+		MaxFailures: r.stats.failureSpike.maxFailures,
+		Time:        time.Now(),
+	}
+	r.logger.Printf("Failure spike detected, %d failures within %v exceed the threshold %d\n", failures, event.Window, event.MaxFailures)
+	Events.Publish(EVENT_FAILURE_SPIKE, event)
+	if r.stopOnSpike {
+		r.abortWithError(fmt.Errorf("%w, %d failures within %v", ErrFailureSpike, failures, event.Window))
+	}
+}
+
+// startStatsFlushOnSignal reports the current stats on receipt of statsFlushSignals, outside the normal interval.
+// The stats are reported as an early interval, so they are neither lost nor counted twice.
+func (r *runner) startStatsFlushOnSignal() {
+	if len(r.statsFlushSignals) == 0 {
+		return
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, r.statsFlushSignals...)
+	go func() {
+		defer signal.Stop(c)
+		for {
+			select {
+			case sig := <-c:
+				r.logger.Printf("Flushing stats on signal %s\n", signalName(sig))
+				data := r.stats.collect()
+				if data == nil {
+					return
+				}
+				r.stats.messageToRunnerChan <- data
+			case <-r.shutdownChan:
+				return
+			}
+		}
+	}()
+}
+
+// addWorkers start the goroutines and add it to cancelFuncs, spawnLock must be held.
 func (r *runner) addWorkers(gapCount int) {
+	if r.maxRequestsPerUser > 0 {
+		// the spawning counts as a running user, so the test isn't stopped before all the users are spawned
+		atomic.AddInt32(&r.runningUsers, 1)
+		defer func() { r.userExited(false, atomic.LoadInt32(&r.usersFinished) > 0) }()
+	}
 	for i := 0; i < gapCount; i++ {
 		select {
 		case <-r.shutdownChan:
 			return
 		default:
-			ctx, cancel := context.WithCancel(context.TODO())
-			r.cancelFuncs = append(r.cancelFuncs, cancel)
-			go func(ctx context.Context) {
-				index := 0
-				for {
-					select {
-					case <-ctx.Done():
-						return
-					case <-r.shutdownChan:
-						return
-					default:
-						if r.rateLimitEnabled {
-							blocked := r.rateLimiter.Acquire()
-							if !blocked {
-								task := r.getTask(index)
-								r.safeRun(task.Fn)
-								index++
-								if index == r.totalTaskWeight {
-									index = 0
-								}
-							}
-						} else {
-							task := r.getTask(index)
-							r.safeRun(task.Fn)
-							index++
-							if index == r.totalTaskWeight {
-								index = 0
+			r.addWorker(r.initialSpreadDelay * time.Duration(i) / time.Duration(gapCount))
+		}
+	}
+}
+
+// addWorkersWithLimiter starts gapCount users at the rate of spawnLimiter. spawnLock is only held while each user
+// is added, not while waiting for the limiter, so the users can be stopped or spawned again in the meantime,
+// which closes cancel. It returns false if it's canceled or the runner is shut down.
+func (r *runner) addWorkersWithLimiter(gapCount int, cancel chan struct{}) bool {
+	if r.maxRequestsPerUser > 0 {
+		atomic.AddInt32(&r.runningUsers, 1)
+		defer func() { r.userExited(false, atomic.LoadInt32(&r.usersFinished) > 0) }()
+	}
+	for i := 0; i < gapCount; i++ {
+		if !r.waitForSpawnLimiter(cancel) {
+			return false
+		}
+		r.spawnLock.Lock()
+		select {
+		case <-cancel:
+			r.spawnLock.Unlock()
+			return false
+		case <-r.shutdownChan:
+			r.spawnLock.Unlock()
+			return false
+		default:
+		}
+		r.addWorker(r.initialSpreadDelay * time.Duration(i) / time.Duration(gapCount))
+		atomic.AddInt32(&r.numClients, 1)
+		r.spawnLock.Unlock()
+	}
+	return true
+}
+
+// addWorker starts a user after delay, and adds it to cancelFuncs. spawnLock must be held.
+func (r *runner) addWorker(delay time.Duration) {
+	userID := len(r.cancelFuncs)
+	ctx, cancel := context.WithCancel(r.newUserContext(userID))
+	r.cancelFuncs = append(r.cancelFuncs, cancel)
+	if r.maxRequestsPerUser > 0 {
+		atomic.AddInt32(&r.runningUsers, 1)
+	}
+	nextTask := r.taskPicker(userID)
+	// in the user group, the first error of the tasks ends the user, and the group cancels the other users,
+	// whose errors after being canceled are ignored
+	returnErrors := r.errGroupLimit > 0 && r.failOnFirstTaskError
+	user := func() error {
+		finished := false
+		if r.maxRequestsPerUser > 0 {
+			defer func() { r.userExited(finished, finished) }()
+		}
+		if !r.sleep(ctx, delay) {
+			return nil
+		}
+		if r.debug {
+			r.debugf("User %d is started\n", userID)
+			defer r.debugf("User %d is stopped\n", userID)
+		}
+		if len(r.cpuAffinity) > 0 {
+			r.pinToCPUs()
+		}
+		requests := int64(0)
+		for {
+			if r.maxRequestsPerUser > 0 && requests >= r.maxRequestsPerUser {
+				finished = true
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-r.shutdownChan:
+				return nil
+			default:
+				if r.rateLimitEnabled {
+					blocked := r.rateLimiter.Acquire()
+					if !blocked {
+						task := nextTask()
+						if r.waitForTaskRateLimiter(ctx, task) {
+							if err := r.runLimited(ctx, task); err != nil && returnErrors && ctx.Err() == nil {
+								return err
 							}
+							requests++
+						}
+						r.think(ctx)
+					}
+				} else {
+					task := nextTask()
+					if r.waitForTaskRateLimiter(ctx, task) {
+						if err := r.runLimited(ctx, task); err != nil && returnErrors && ctx.Err() == nil {
+							return err
 						}
+						requests++
 					}
-					runtime.Gosched()
+					r.think(ctx)
+				}
+			}
+			runtime.Gosched()
+		}
+	}
+	if r.errGroupLimit > 0 {
+		r.goInUserGroup(user, cancel)
+		return
+	}
+	go user()
+}
+
+// goInUserGroup runs the user in userGroup, which is created by the first user. The context of the user is canceled
+// with the group, i.e. when a user returns an error, and the error returned by Wait of the group aborts the test.
+// spawnLock must be held.
+func (r *runner) goInUserGroup(user func() error, cancel context.CancelFunc) {
+	if r.userGroup == nil {
+		ctx, closeGroup := context.WithCancel(context.Background())
+		group, groupCtx := errgroup.WithContext(ctx)
+		group.SetLimit(r.errGroupLimit)
+		r.userGroup, r.userGroupCtx, r.closeUserGroup = group, groupCtx, closeGroup
+		go func() {
+			<-groupCtx.Done()
+			if err := group.Wait(); err != nil {
+				r.abortWithError(err)
+			}
+		}()
+	}
+	context.AfterFunc(r.userGroupCtx, cancel)
+	r.userGroup.Go(user)
+}
+
+// pinToCPUs locks the calling user goroutine to its OS thread, and pins the thread to cpuAffinity.
+// The thread exits with the user, so other goroutines never run on it.
+func (r *runner) pinToCPUs() {
+	runtime.LockOSThread()
+	if err := setCPUAffinity(r.cpuAffinity); err != nil {
+		runtime.UnlockOSThread()
+		r.cpuAffinityWarning.Do(func() {
+			r.logger.Printf("Failed to pin the users to CPUs %v, %v\n", r.cpuAffinity, err)
+		})
+	}
+}
+
+// userExited is called when a user or the spawning exits, if maxRequestsPerUser is set. finished is true
+// if the user has executed maxRequestsPerUser tasks. The test is stopped if the last one exits with stopIfLast,
+// so it isn't stopped by the users which are canceled, e.g. when the users are reduced or stopped by the master.
+func (r *runner) userExited(finished, stopIfLast bool) {
+	if finished {
+		atomic.AddInt32(&r.usersFinished, 1)
+	}
+	if atomic.AddInt32(&r.runningUsers, -1) > 0 || !stopIfLast {
+		return
+	}
+	select {
+	case <-r.shutdownChan:
+		return
+	default:
+	}
+	r.logger.Printf("The test is stopped because all the users have executed %d tasks\n", r.maxRequestsPerUser)
+	r.audit(auditActionStop, auditByRunner, map[string]interface{}{"reason": "max requests per user", "max_requests_per_user": r.maxRequestsPerUser}, nil)
+	if r.abort != nil {
+		go r.abort()
+	}
+}
+
+// runLimited runs the task after acquiring the concurrency semaphore, if the concurrency is limited.
+// It returns the error of Task.FnWithError.
+func (r *runner) runLimited(ctx context.Context, task *Task) error {
+	// the slot is released by runWithSetupTimeout when the task returns, which may be after a setup timeout
+	release := func() {}
+	if r.concurrencySemaphore != nil {
+		select {
+		case r.concurrencySemaphore <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		release = func() { <-r.concurrencySemaphore }
+	}
+	n := atomic.AddInt64(&r.taskExecutions, 1)
+	if n <= r.warmUpExecutions {
+		defer r.finishWarmUp()
+	}
+	n -= r.warmUpExecutions
+	if r.maxTaskExecutions > 0 {
+		if n > r.maxTaskExecutions {
+			release()
+			return nil
+		}
+		if n == r.maxTaskExecutions {
+			defer func() {
+				r.logger.Printf("The test is stopped after %d task executions\n", n)
+				r.audit(auditActionStop, auditByRunner, map[string]interface{}{"reason": "max task executions", "max_task_executions": n}, nil)
+				if r.abort != nil {
+					go r.abort()
 				}
-			}(ctx)
+			}()
 		}
 	}
+	atomic.AddInt32(&r.concurrency, 1)
+	defer atomic.AddInt32(&r.concurrency, -1)
+	if r.stuckThreshold > 0 {
+		defer r.trackRunningTask(ctx, task)()
+	}
+	err := r.runWithSetupTimeout(ctx, task, release)
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("task %s: %w", task.Name, err)
+	// the users in the user group return the error to the group instead
+	if r.failOnFirstTaskError && r.errGroupLimit == 0 {
+		r.abortWithError(err)
+	}
+	return err
+}
+
+// finishWarmUp counts a returned warm-up execution, and closes warmUpDone after the last one.
+func (r *runner) finishWarmUp() {
+	if atomic.AddInt64(&r.warmUpFinished, 1) == r.warmUpExecutions && r.warmUpDone != nil {
+		close(r.warmUpDone)
+	}
+}
+
+// taskSetupTimeoutError is the exception recorded when a task doesn't finish its setup in taskSetupTimeout.
+const taskSetupTimeoutError = "task setup timeout"
+
+// runWithSetupTimeout runs the task, and records a failure if it doesn't call TaskSetupDone or return in taskSetupTimeout.
+// The context of a timed out task is canceled, and the results it records afterwards are dropped, see TaskTimedOut.
+// The user moves on without waiting for it, unless there are as many timed out tasks still running as users.
+// release is called when the task returns, so a timed out task keeps its concurrency slot, it can be nil.
+func (r *runner) runWithSetupTimeout(ctx context.Context, task *Task, release func()) (err error) {
+	if release == nil {
+		release = func() {}
+	}
+	if r.taskSetupTimeout <= 0 {
+		defer release()
+		err = r.runTaskSafely(ctx, task)
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	setup := &taskSetup{done: make(chan struct{})}
+	ctx = context.WithValue(ctx, taskSetupContextKey{}, setup)
+	result := make(chan error, 1)
+	go func() {
+		defer release()
+		result <- r.runTaskSafely(ctx, task)
+	}()
+
+	timer := time.NewTimer(r.taskSetupTimeout)
+	defer timer.Stop()
+	select {
+	case err = <-result:
+		return err
+	case <-setup.done:
+		return <-result
+	case <-timer.C:
+		atomic.StoreInt32(&setup.timedOut, 1)
+		cancel()
+		r.stats.requestFailureChan <- &requestFailure{
+			requestType:  "task",
+			name:         task.Name,
+			responseTime: r.taskSetupTimeout.Milliseconds(),
+			error:        taskSetupTimeoutError,
+		}
+		if atomic.AddInt32(&r.timedOutTasks, 1) > atomic.LoadInt32(&r.numClients) {
+			<-result
+			atomic.AddInt32(&r.timedOutTasks, -1)
+			return nil
+		}
+		go func() {
+			<-result
+			atomic.AddInt32(&r.timedOutTasks, -1)
+		}()
+		return nil
+	}
+}
+
+// ErrOutput is wrapped by the error returned by Boomer.Err if the test is stopped by Boomer.WithAbortOnOutputError.
+var ErrOutput = errors.New("boomer: output error")
+
+// outputError calls outputErrorHandler with the error of an ErrorOutput, or logs it if the handler isn't set,
+// then aborts the test if abortOnOutputError is enabled.
+func (r *runner) outputError(o Output, err error) {
+	if r.outputErrorHandler != nil {
+		r.safeRun(func() { r.outputErrorHandler(o, err) })
+	} else {
+		r.logger.Printf("output %s error: %v\n", outputName(o), err)
+	}
+	if r.abortOnOutputError {
+		r.abortWithError(fmt.Errorf("%w, %s: %w", ErrOutput, outputName(o), err))
+	}
+}
+
+// abortWithError stops the test, only the first error is kept.
+// It returns false if the test has been aborted by another error.
+func (r *runner) abortWithError(err error) (aborted bool) {
+	r.abortOnce.Do(func() {
+		aborted = true
+		r.logger.Printf("The test is aborted, error: %v\n", err)
+		r.audit(auditActionStop, auditByRunner, map[string]interface{}{"reason": err.Error()}, nil)
+		r.abortLock.Lock()
+		r.abortErr = err
+		r.abortLock.Unlock()
+		if r.goroutineStackTrace {
+			r.dumpGoroutines()
+		}
+		if r.abort != nil {
+			go r.abort()
+		}
+	})
+	return aborted
+}
+
+// err returns the error which aborts the test.
+func (r *runner) err() error {
+	r.abortLock.Lock()
+	defer r.abortLock.Unlock()
+	return r.abortErr
+}
+
+// setReportData adds the test name, the current number of running tasks and the limit,
+// the precision of the response times and the rps window to the report data.
+func (r *runner) setReportData(data map[string]interface{}) {
+	data["test_name"] = r.testName
+	data["concurrency_current"] = atomic.LoadInt32(&r.concurrency)
+	data["concurrency_limit"] = int32(cap(r.concurrencySemaphore))
+	data["stuck_detector_triggered"] = atomic.LoadInt64(&r.stuckTasks)
+	if r.responseTimePrecision != nil {
+		data["response_time_precision"] = *r.responseTimePrecision
+	}
+	if r.rpsWindowSecs > 0 {
+		data["rps_window_secs"] = r.rpsWindowSecs
+	}
+}
+
+// taskPicker returns a function which picks the next task for the user.
+// Tasks are picked according to their weights, unless consistentHashing sticks the user to one of them.
+func (r *runner) taskPicker(userID int) func() *Task {
+	pick := r.newTaskPicker(userID)
+	if !r.debug {
+		return pick
+	}
+	return func() *Task {
+		task := pick()
+		r.debugf("User %d picked task %s\n", userID, task.Name)
+		return task
+	}
+}
+
+func (r *runner) newTaskPicker(userID int) func() *Task {
+	if r.consistentHashing != nil && len(r.tasks) > 0 {
+		task := r.tasks[consistentHashIndex(r.consistentHashing, userID, "user", strconv.Itoa(userID), len(r.tasks))]
+		return func() *Task {
+			return task
+		}
+	}
+
+	index := 0
+	return func() *Task {
+		task := r.getTask(index)
+		index++
+		if index == r.totalTaskWeight {
+			index = 0
+		}
+		return task
+	}
+}
+
+// consistentHashIndex returns the index of the task of the user in a list of n tasks, see Boomer.WithConsistentHashing.
+func consistentHashIndex(fn func(userID int, requestType, name string) int, userID int, requestType, name string, n int) int {
+	i := fn(userID, requestType, name) % n
+	if i < 0 {
+		i += n
+	}
+	return i
+}
+
+// newUserContext returns the context of a new user goroutine, carrying the user id, data feed, consistent hashing
+// and per-user data.
+// Factories are called one at a time, so they can safely share state.
+func (r *runner) newUserContext(userID int) context.Context {
+	ctx := context.WithValue(context.TODO(), userIDContextKey{}, userID)
+	ctx = context.WithValue(ctx, randomContextKey{}, r.random)
+	if r.dataFeed != nil {
+		ctx = context.WithValue(ctx, dataFeedContextKey{}, r.dataFeed)
+	}
+	if r.consistentHashing != nil {
+		ctx = context.WithValue(ctx, consistentHashingContextKey{}, r.consistentHashing)
+	}
+	if len(r.userDataFactories) == 0 {
+		return ctx
+	}
+	r.userDataLock.Lock()
+	defer r.userDataLock.Unlock()
+	for key, factory := range r.userDataFactories {
+		ctx = context.WithValue(ctx, userDataContextKey(key), factory())
+	}
+	return ctx
+}
+
+// waitForTaskRateLimiter blocks until the task is allowed to run by targetRPSLimiter, its own rate limiter
+// and its global throttle. It returns false if ctx is canceled while waiting.
+func (r *runner) waitForTaskRateLimiter(ctx context.Context, task *Task) bool {
+	if r.targetRPSLimiter != nil && r.targetRPSLimiter.Wait(ctx) != nil {
+		return false
+	}
+	if task.throttle != nil && task.throttle.Wait(ctx) != nil {
+		return false
+	}
+	limiter, ok := r.taskRateLimiters[taskEndpoint{requestType: task.RequestType, name: task.Name}]
+	if !ok {
+		return true
+	}
+	return limiter.Wait(ctx) == nil
+}
+
+// waitForSpawnLimiter blocks until a user is allowed to be spawned by spawnLimiter.
+// It returns false if cancel is closed or the runner is shut down while waiting.
+func (r *runner) waitForSpawnLimiter(cancel chan struct{}) bool {
+	limiter := r.getSpawnLimiter()
+	if limiter == nil {
+		return true
+	}
+	reservation := limiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-cancel:
+		reservation.Cancel()
+		return false
+	case <-r.shutdownChan:
+		reservation.Cancel()
+		return false
+	}
+}
+
+// getSpawnLimiter returns spawnLimiter, which is nil unless Boomer.WithSpawnRateLimit is set.
+func (r *runner) getSpawnLimiter() *rate.Limiter {
+	r.spawnRateLock.Lock()
+	defer r.spawnRateLock.Unlock()
+	return r.spawnLimiter
+}
+
+// setSpawnRate spawns the users at spawnRate from now on, if the spawning is rate limited by spawnLimiter.
+func (r *runner) setSpawnRate(spawnRate float64) {
+	r.spawnRateLock.Lock()
+	defer r.spawnRateLock.Unlock()
+	r.spawnRate = spawnRate
+	if r.spawnLimiter != nil {
+		r.spawnLimiter.SetLimit(rate.Limit(spawnRate))
+	}
+}
+
+// getSpawnRate returns the current spawn rate.
+func (r *runner) getSpawnRate() float64 {
+	r.spawnRateLock.Lock()
+	defer r.spawnRateLock.Unlock()
+	return r.spawnRate
 }
 
 // reduceWorkers Stop the goroutines and remove it from the cancelFuncs
@@ -180,6 +913,9 @@ func (r *runner) reduceWorkers(gapCount int) {
 		return
 	}
 	num := len(r.cancelFuncs) - gapCount
+	if r.debug {
+		r.debugf("Stopping users %d to %d\n", num, len(r.cancelFuncs)-1)
+	}
 	for _, cancelFunc := range r.cancelFuncs[num:] {
 		cancelFunc()
 	}
@@ -188,27 +924,58 @@ func (r *runner) reduceWorkers(gapCount int) {
 
 }
 
+// spawnWorkers adds or removes users to reach spawnCount. If the spawning is rate limited by spawnLimiter, the users
+// are added by a goroutine, so the caller, e.g. the handler of the messages from master, isn't blocked for the whole
+// ramp up. It's canceled by the next spawning, stop or shutdown, and spawnCompleteFunc is called only if it completes.
 func (r *runner) spawnWorkers(spawnCount int, spawnCompleteFunc func()) {
 	r.logger.Println("The total number of clients required is ", spawnCount)
 
+	if r.errGroupLimit > 0 && spawnCount > r.errGroupLimit {
+		r.logger.Printf("The number of clients is limited to %d by the error group\n", r.errGroupLimit)
+		spawnCount = r.errGroupLimit
+	}
+
+	r.spawnLock.Lock()
+	r.cancelSpawning()
 	var gapCount int
-	if spawnCount > int(r.numClients) {
-		gapCount = spawnCount - int(r.numClients)
-		r.logger.Printf("The current number of clients is %v, %v clients will be added\n", r.numClients, gapCount)
+	numClients := int(atomic.LoadInt32(&r.numClients))
+	if spawnCount > numClients {
+		gapCount = spawnCount - numClients
+		r.logger.Printf("The current number of clients is %v, %v clients will be added\n", numClients, gapCount)
+		if r.getSpawnLimiter() != nil {
+			cancel := make(chan struct{})
+			r.spawnCancel = cancel
+			r.spawnLock.Unlock()
+			go func() {
+				if r.addWorkersWithLimiter(gapCount, cancel) && spawnCompleteFunc != nil {
+					spawnCompleteFunc()
+				}
+			}()
+			return
+		}
 		r.addWorkers(gapCount)
 	} else {
-		gapCount = int(r.numClients) - spawnCount
-		r.logger.Printf("The current number of clients is %v, %v clients will be removed\n", r.numClients, gapCount)
+		gapCount = numClients - spawnCount
+		r.logger.Printf("The current number of clients is %v, %v clients will be removed\n", numClients, gapCount)
 		r.reduceWorkers(gapCount)
 	}
 
-	r.numClients = int32(spawnCount)
+	atomic.StoreInt32(&r.numClients, int32(spawnCount))
+	r.spawnLock.Unlock()
 
 	if spawnCompleteFunc != nil {
 		go spawnCompleteFunc() //For faster time
 	}
 }
 
+// cancelSpawning cancels the users being spawned by spawnLimiter, spawnLock must be held.
+func (r *runner) cancelSpawning() {
+	if r.spawnCancel != nil {
+		close(r.spawnCancel)
+		r.spawnCancel = nil
+	}
+}
+
 // setTasks will set the runner's task list AND the total task weight
 // which is used to get a task later
 func (r *runner) setTasks(t []*Task) {
@@ -257,14 +1024,30 @@ func (r *runner) stop() {
 	// user's code can subscribe to this event and do thins like cleaning up
 	Events.Publish(EVENT_STOP)
 
-	r.reduceWorkers(int(r.numClients)) //Stop all goroutines
-	r.numClients = 0
+	r.spawnLock.Lock()
+	defer r.spawnLock.Unlock()
+	r.cancelSpawning()
+	r.reduceWorkers(int(atomic.LoadInt32(&r.numClients))) //Stop all goroutines
+	atomic.StoreInt32(&r.numClients, 0)
+	if r.userGroup != nil {
+		// the users spawned later run in a new group
+		r.closeUserGroup()
+		r.userGroup, r.userGroupCtx, r.closeUserGroup = nil, nil, nil
+	}
 }
 
 type localRunner struct {
 	runner
 
 	spawnCount int
+	// doubles the number of clients every spawnDoubleEvery until spawnCount is reached, if set.
+	spawnDoubleEvery time.Duration
+
+	shutdownOnce sync.Once
+
+	// report summarizes the test, the last report interval is collected when shutting down
+	report          *TestReport
+	finalReportData map[string]interface{}
 }
 
 func newLocalRunner(tasks []*Task, rateLimiter RateLimiter, spawnCount int, spawnRate float64) (r *localRunner) {
@@ -274,6 +1057,7 @@ func newLocalRunner(tasks []*Task, rateLimiter RateLimiter, spawnCount int, spaw
 	r.spawnRate = spawnRate
 	r.spawnCount = spawnCount
 	r.shutdownChan = make(chan bool)
+	r.random = random
 
 	if rateLimiter != nil {
 		r.rateLimitEnabled = true
@@ -286,8 +1070,17 @@ func newLocalRunner(tasks []*Task, rateLimiter RateLimiter, spawnCount int, spaw
 
 func (r *localRunner) run() {
 	r.state = stateInit
+	r.startTime = time.Now()
+	r.lastActivity = r.startTime
 	r.stats.start()
+	r.report = newTestReport(r.testName)
+	r.report.serializer = r.serializer
 	r.outputOnStart()
+	var warmUpDone chan struct{}
+	if r.warmUpExecutions > 0 {
+		warmUpDone = make(chan struct{})
+		r.warmUpDone = warmUpDone
+	}
 
 	wg := sync.WaitGroup{}
 	wg.Add(1)
@@ -295,34 +1088,151 @@ func (r *localRunner) run() {
 		for {
 			select {
 			case data := <-r.stats.messageToRunnerChan:
-				data["user_count"] = r.numClients
-				r.outputOnEevent(data)
+				r.dispatchReportData(data, true)
+			case <-warmUpDone:
+				r.endWarmUp()
+				warmUpDone = nil
 			case <-r.shutdownChan:
 				Events.Publish(EVENT_QUIT)
 				r.stop()
-				wg.Done()
+				r.reportProgress()
+				if r.finalReportData != nil {
+					r.addReportInterval(r.finalReportData)
+				}
+				r.report.EndTime = time.Now()
+				r.report.Err = r.err()
+				r.report.TotalTaskExecutions = atomic.LoadInt64(&r.taskExecutions) - r.warmUpExecutions
+				if r.report.TotalTaskExecutions < 0 {
+					r.report.TotalTaskExecutions = 0
+				}
+				if r.maxTaskExecutions > 0 && r.report.TotalTaskExecutions > r.maxTaskExecutions {
+					r.report.TotalTaskExecutions = r.maxTaskExecutions
+				}
+				// run returns after the outputs are stopped, so their files and buffers are flushed
 				r.outputOnStop()
+				wg.Done()
 				return
 			}
 		}
 	}()
 
+	r.abort = r.shutdown
+	r.startMemoryMonitor()
+	r.startStatsFlushOnSignal()
+	r.startResourceMonitor()
+	r.startStuckDetector()
+
+	if r.runTime > 0 {
+		time.AfterFunc(r.runTime, func() {
+			r.logger.Printf("Time limit reached, the test is stopped after %v\n", r.runTime)
+			r.audit(auditActionStop, auditByRunner, map[string]interface{}{"reason": "time limit", "run_time": r.runTime.String()}, nil)
+			r.shutdown()
+		})
+	}
+
 	if r.rateLimitEnabled {
 		r.rateLimiter.Start()
 	}
-	r.startSpawning(r.spawnCount, r.spawnRate, nil)
+	r.audit(auditActionStart, auditByProgram, map[string]interface{}{"users": r.spawnCount, "spawn_rate": r.spawnRate}, nil)
+	if r.spawnDoubleEvery > 0 {
+		r.spawnExponentially(r.spawnCount, r.spawnDoubleEvery)
+	} else {
+		r.startSpawning(r.spawnCount, r.spawnRate, nil)
+	}
+	r.startRemoteConfig(func(users int) {
+		r.audit(auditActionScale, auditByRemoteConfig, map[string]interface{}{"users": users}, nil)
+		r.startSpawning(users, r.getSpawnRate(), nil)
+	})
 
 	wg.Wait()
 }
 
-func (r *localRunner) shutdown() {
-	if r.stats != nil {
-		r.stats.close()
+// dispatchReportData passes the data of a report interval to the outputs, and merges it into the report if addToReport.
+func (r *localRunner) dispatchReportData(data map[string]interface{}, addToReport bool) {
+	start := time.Now()
+	data["user_count"] = atomic.LoadInt32(&r.numClients)
+	r.setReportData(data)
+	r.checkIdle(data)
+	r.reportProgress()
+	if addToReport {
+		r.addReportInterval(data)
 	}
-	if r.rateLimitEnabled {
-		r.rateLimiter.Stop()
+	r.outputOnEevent(data)
+	if r.debug {
+		r.debugf("Stats are dispatched to %d outputs in %v\n", len(r.outputs), time.Since(start))
 	}
-	close(r.shutdownChan)
+}
+
+// endWarmUp clears the stats recorded during the warm-up. The intervals which are collected before the stats are
+// cleared are still passed to the outputs, but not merged into the report.
+func (r *localRunner) endWarmUp() {
+	for cleared := false; !cleared; {
+		select {
+		case r.stats.clearStatsChan <- true:
+			cleared = true
+		case data := <-r.stats.messageToRunnerChan:
+			r.dispatchReportData(data, false)
+		}
+	}
+	for drained := false; !drained; {
+		select {
+		case data := <-r.stats.messageToRunnerChan:
+			r.dispatchReportData(data, false)
+		default:
+			drained = true
+		}
+	}
+	r.report.clearStats()
+	r.logger.Printf("The warm-up is finished after %d task executions\n", r.warmUpExecutions)
+}
+
+func (r *localRunner) addReportInterval(data map[string]interface{}) {
+	if err := r.report.addInterval(data); err != nil {
+		r.logger.Printf("convert data error: %v\n", err)
+	}
+}
+
+// spawnExponentially starts with one client and doubles the number of clients
+// every doubleEvery, until spawnCount is reached.
+func (r *localRunner) spawnExponentially(spawnCount int, doubleEvery time.Duration) {
+	Events.Publish(EVENT_SPAWN, spawnCount, r.spawnRate)
+
+	clients := 1
+	for {
+		if clients > spawnCount {
+			clients = spawnCount
+		}
+		r.logger.Printf("Doubling the number of clients to %d\n", clients)
+		r.spawnWorkers(clients, nil)
+		if clients >= spawnCount {
+			return
+		}
+		select {
+		case <-r.shutdownChan:
+			return
+		case <-time.After(doubleEvery):
+		}
+		clients *= 2
+	}
+}
+
+// shutdown can be called more than once, by the user and when the run time is reached.
+func (r *localRunner) shutdown() {
+	r.shutdownOnce.Do(func() {
+		if r.stats != nil {
+			if r.report != nil {
+				r.finalReportData = r.stats.collect()
+				if r.finalReportData != nil {
+					r.finalReportData["user_count"] = atomic.LoadInt32(&r.numClients)
+				}
+			}
+			r.stats.close()
+		}
+		if r.rateLimitEnabled {
+			r.rateLimiter.Stop()
+		}
+		close(r.shutdownChan)
+	})
 }
 
 func (r *localRunner) sendCustomMessage(messageType string, data interface{}) {
@@ -354,6 +1264,8 @@ func newSlaveRunner(masterHost string, masterPort int, tasks []*Task, rateLimite
 	r.waitForAck = sync.WaitGroup{}
 	r.nodeID = getNodeID()
 	r.shutdownChan = make(chan bool)
+	r.onPanic = r.sendException
+	r.random = random
 
 	if rateLimiter != nil {
 		r.rateLimitEnabled = true
@@ -366,7 +1278,7 @@ func newSlaveRunner(masterHost string, masterPort int, tasks []*Task, rateLimite
 
 func (r *slaveRunner) spawnComplete() {
 	data := make(map[string]interface{})
-	data["count"] = r.numClients
+	data["count"] = atomic.LoadInt32(&r.numClients)
 	data["user_classes_count"] = r.userClassesCountFromMaster
 	r.client.sendChannel() <- newGenericMessage("spawning_complete", data, r.nodeID)
 	r.state = stateRunning
@@ -388,14 +1300,30 @@ func (r *slaveRunner) shutdown() {
 	if r.rateLimitEnabled {
 		r.rateLimiter.Stop()
 	}
-	r.cancelFuncs = nil
-	r.numClients = 0
+	// the spawning stops once shutdownChan is closed, so the lock isn't held for the whole ramp up
 	close(r.shutdownChan)
+	r.spawnLock.Lock()
+	r.cancelFuncs = nil
+	atomic.StoreInt32(&r.numClients, 0)
+	r.spawnLock.Unlock()
 }
 
 func (r *slaveRunner) sumUsersAmount(msg *genericMessage) int {
-	userClassesCount := msg.Data["user_classes_count"]
-	userClassesCountMap := userClassesCount.(map[interface{}]interface{})
+	userClassesCount, ok := msg.Data["user_classes_count"]
+	if !ok {
+		// locust 1.x sends the total number of users
+		r.userClassesCountFromMaster = nil
+		if numUsers, ok := castToInt64(msg.Data["num_users"]); ok {
+			return int(numUsers)
+		}
+		r.logger.Println("Neither user_classes_count nor num_users is found in spawn message, no users are spawned")
+		return 0
+	}
+	userClassesCountMap, ok := userClassesCount.(map[interface{}]interface{})
+	if !ok {
+		r.logger.Printf("user_classes_count in spawn message can't be casted to map, current type is %T, ignored!\n", userClassesCount)
+		return 0
+	}
 
 	// Save the original field and send it back to master in spawnComplete message.
 	r.userClassesCountFromMaster = make(map[string]int64)
@@ -431,7 +1359,30 @@ func (r *slaveRunner) onSpawnMessage(msg *genericMessage) {
 
 	r.client.sendChannel() <- newGenericMessage("spawning", nil, r.nodeID)
 	workers := r.sumUsersAmount(msg)
-	r.startSpawning(workers, float64(workers), r.spawnComplete)
+	spawnRate := float64(workers)
+	// locust 1.x leaves the spawn rate to the workers, locust 2.x doesn't send it
+	if masterSpawnRate, ok := castToFloat64(msg.Data["spawn_rate"]); ok && masterSpawnRate > 0 {
+		spawnRate = masterSpawnRate
+		r.setSpawnRate(masterSpawnRate)
+	}
+	action := auditActionScale
+	if atomic.LoadInt32(&r.numClients) == 0 {
+		action = auditActionStart
+	}
+	r.audit(action, auditByMaster, map[string]interface{}{"users": workers, "spawn_rate": spawnRate}, nil)
+	r.startSpawning(workers, spawnRate, r.spawnComplete)
+}
+
+// sendException reports a panic recovered from a task to the master, like locust workers report the exceptions.
+func (r *slaveRunner) sendException(err interface{}, stackTrace []byte) {
+	if r.client == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"msg":       fmt.Sprintf("%v", err),
+		"traceback": string(stackTrace),
+	}
+	r.client.sendChannel() <- newGenericMessage("exception", data, r.nodeID)
 }
 
 // TODO: consider to add register_message instead of publishing any unknown type as custom_message.
@@ -514,6 +1465,7 @@ func (r *slaveRunner) onMessage(msgInterface message) {
 			r.state = stateSpawning
 			r.onSpawnMessage(genericMsg)
 		case "stop":
+			r.audit(auditActionStop, auditByMaster, map[string]interface{}{"message": "stop"}, nil)
 			r.stop()
 			r.state = stateStopped
 			r.logger.Println("Recv stop message from master, all the goroutines are stopped")
@@ -521,6 +1473,7 @@ func (r *slaveRunner) onMessage(msgInterface message) {
 			r.sendClientReadyAndWaitForAck()
 			r.state = stateInit
 		case "quit":
+			r.audit(auditActionStop, auditByMaster, map[string]interface{}{"message": "quit"}, nil)
 			r.stop()
 			r.logger.Println("Recv quit message from master, all the goroutines are stopped")
 			Events.Publish(EVENT_QUIT)
@@ -578,6 +1531,7 @@ func (r *slaveRunner) run() {
 	// listen to master
 	r.startListener()
 
+	r.startTime = time.Now()
 	r.stats.start()
 	r.outputOnStart()
 
@@ -587,18 +1541,37 @@ func (r *slaveRunner) run() {
 
 	r.sendClientReadyAndWaitForAck()
 
+	r.abort = func() {
+		r.stop()
+		Events.Publish(EVENT_QUIT)
+	}
+	r.startMemoryMonitor()
+	r.startStatsFlushOnSignal()
+	r.startResourceMonitor()
+	r.startStuckDetector()
+	r.startRemoteConfig(nil)
+
 	// report to master
 	go func() {
 		for {
 			select {
 			case data := <-r.stats.messageToRunnerChan:
 				if r.state == stateInit || r.state == stateStopped {
+					// the idle time is counted from the next spawning
+					r.lastActivity = time.Time{}
 					continue
 				}
-				data["user_count"] = r.numClients
+				start := time.Now()
+				data["user_count"] = atomic.LoadInt32(&r.numClients)
 				data["user_classes_count"] = r.userClassesCountFromMaster
+				r.setReportData(data)
+				r.checkIdle(data)
 				r.client.sendChannel() <- newGenericMessage("stats", data, r.nodeID)
+				r.reportProgress()
 				r.outputOnEevent(data)
+				if r.debug {
+					r.debugf("Stats are dispatched to the master and %d outputs in %v\n", len(r.outputs), time.Since(start))
+				}
 			case <-r.shutdownChan:
 				r.outputOnStop()
 				return
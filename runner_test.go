@@ -1,7 +1,14 @@
 package boomer
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -9,26 +16,87 @@ import (
 	"github.com/myzhan/gomq/zmtp"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"golang.org/x/time/rate"
 )
 
 type HitOutput struct {
+	lock    sync.Mutex
 	onStart bool
 	onEvent bool
 	onStop  bool
 }
 
 func (o *HitOutput) OnStart() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
 	o.onStart = true
 }
 
 func (o *HitOutput) OnEvent(data map[string]interface{}) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
 	o.onEvent = true
 }
 
 func (o *HitOutput) OnStop() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
 	o.onStop = true
 }
 
+func (o *HitOutput) started() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.onStart
+}
+
+func (o *HitOutput) received() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.onEvent
+}
+
+func (o *HitOutput) stopped() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.onStop
+}
+
+type FailingOutput struct {
+	HitOutput
+	calls int32
+	err   error
+}
+
+func (o *FailingOutput) OnEventWithError(data map[string]interface{}) error {
+	atomic.AddInt32(&o.calls, 1)
+	return o.err
+}
+
+// concurrentOutput tracks the number of the outputs sharing it, which are in OnEvent at the same time.
+type concurrentOutput struct {
+	HitOutput
+	lock    *sync.Mutex
+	active  *int
+	maxSeen *int
+}
+
+func (o *concurrentOutput) OnEvent(data map[string]interface{}) {
+	o.lock.Lock()
+	*o.active++
+	if *o.active > *o.maxSeen {
+		*o.maxSeen = *o.active
+	}
+	o.lock.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	o.lock.Lock()
+	*o.active--
+	o.lock.Unlock()
+}
+
 var _ = Describe("Test runner", func() {
 
 	It("test saferun", func() {
@@ -41,6 +109,69 @@ var _ = Describe("Test runner", func() {
 		}).Should(Not(Panic()))
 	})
 
+	It("test task exception callback", func() {
+		type exception struct {
+			requestType, name string
+			err               interface{}
+			stack             []byte
+		}
+		exceptions := make(chan exception, 1)
+		buf := gbytes.NewBuffer()
+		b := NewStandaloneBoomer(1, 1).WithLogger(log.New(buf, "", 0)).
+			WithTaskExceptionCallback(nil).
+			WithTaskExceptionCallback(func(requestType, name string, err interface{}, stack []byte) {
+				time.Sleep(time.Second)
+				exceptions <- exception{requestType, name, err, stack}
+			}).
+			WithTaskExceptionCallback(func(requestType, name string, err interface{}, stack []byte) {
+				panic("callback is broken")
+			})
+		runner := newLocalRunner(nil, nil, 1, 1)
+		runner.setLogger(b.logger)
+		b.setupRunner(&runner.runner)
+		Expect(runner.taskExceptionCallbacks).To(HaveLen(2))
+
+		start := time.Now()
+		err := runner.runTaskSafely(context.Background(), &Task{
+			Name: "foo",
+			Fn: func() {
+				panic("something is wrong")
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		// the slow callback doesn't block the task
+		Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+
+		var failure *requestFailure
+		Expect(runner.stats.requestFailureChan).To(Receive(&failure))
+		Expect(failure.requestType).To(Equal("task"))
+		Expect(failure.name).To(Equal("foo"))
+		Expect(failure.error).To(Equal("something is wrong"))
+
+		var e exception
+		Eventually(exceptions, 2*time.Second).Should(Receive(&e))
+		Expect(e.requestType).To(Equal("task"))
+		Expect(e.name).To(Equal("foo"))
+		Expect(e.err).To(Equal("something is wrong"))
+		Expect(string(e.stack)).To(ContainSubstring("runner_test.go"))
+		Eventually(buf).Should(gbytes.Say("Task exception callback panics, callback is broken"))
+	})
+
+	It("test task panic without callbacks", func() {
+		runner := newLocalRunner(nil, nil, 1, 1)
+		err := runner.runTaskSafely(context.Background(), &Task{
+			Name: "foo",
+			Fn: func() {
+				panic("something is wrong")
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		var failure *requestFailure
+		Expect(runner.stats.requestFailureChan).To(Receive(&failure))
+		Expect(failure.name).To(Equal("foo"))
+		Expect(failure.error).To(Equal("something is wrong"))
+	})
+
 	It("test output onStart", func() {
 		hitOutput := &HitOutput{}
 		hitOutput2 := &HitOutput{}
@@ -49,8 +180,8 @@ var _ = Describe("Test runner", func() {
 		runner.addOutput(hitOutput)
 		runner.addOutput(hitOutput2)
 		runner.outputOnStart()
-		Expect(hitOutput.onStart).To(BeTrue())
-		Expect(hitOutput2.onStart).To(BeTrue())
+		Expect(hitOutput.started()).To(BeTrue())
+		Expect(hitOutput2.started()).To(BeTrue())
 	})
 
 	It("test output onEvent", func() {
@@ -61,8 +192,8 @@ var _ = Describe("Test runner", func() {
 		runner.addOutput(hitOutput)
 		runner.addOutput(hitOutput2)
 		runner.outputOnEevent(nil)
-		Expect(hitOutput.onEvent).To(BeTrue())
-		Expect(hitOutput2.onEvent).To(BeTrue())
+		Expect(hitOutput.received()).To(BeTrue())
+		Expect(hitOutput2.received()).To(BeTrue())
 	})
 
 	It("test output onStop", func() {
@@ -73,8 +204,32 @@ var _ = Describe("Test runner", func() {
 		runner.addOutput(hitOutput)
 		runner.addOutput(hitOutput2)
 		runner.outputOnStop()
-		Expect(hitOutput.onStop).To(BeTrue())
-		Expect(hitOutput2.onStop).To(BeTrue())
+		Expect(hitOutput.stopped()).To(BeTrue())
+		Expect(hitOutput2.stopped()).To(BeTrue())
+	})
+
+	It("test output fanout", func() {
+		newRunner := func(b *Boomer) (*localRunner, *int) {
+			lock := &sync.Mutex{}
+			active, maxSeen := 0, 0
+			runner := newLocalRunner(nil, nil, 1, 1)
+			b.setupRunner(&runner.runner)
+			for i := 0; i < 10; i++ {
+				runner.addOutput(&concurrentOutput{lock: lock, active: &active, maxSeen: &maxSeen})
+			}
+			return runner, &maxSeen
+		}
+
+		runner, maxSeen := newRunner(NewStandaloneBoomer(1, 1).WithOutputFanout(3))
+		Expect(cap(runner.outputFanout)).To(Equal(3))
+		runner.outputOnEevent(nil)
+		Expect(*maxSeen).To(Equal(3))
+		Expect(runner.outputFanout).To(BeEmpty())
+
+		runner, maxSeen = newRunner(NewStandaloneBoomer(1, 1).WithOutputFanout(0))
+		Expect(runner.outputFanout).To(BeNil())
+		runner.outputOnEevent(nil)
+		Expect(*maxSeen).To(Equal(10))
 	})
 
 	It("test add workers", func() {
@@ -117,6 +272,34 @@ var _ = Describe("Test runner", func() {
 		Expect(currentClients).To(BeEquivalentTo(3))
 	})
 
+	It("test running users with max requests per user", func() {
+		taskA := &Task{
+			Fn: func() {
+				time.Sleep(10 * time.Millisecond)
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 10, 10)
+		runner.maxRequestsPerUser = 1000
+		aborted := int32(0)
+		runner.abort = func() { atomic.AddInt32(&aborted, 1) }
+		defer runner.shutdown()
+
+		// the canceled users don't stop the test
+		runner.addWorkers(10)
+		Expect(atomic.LoadInt32(&runner.runningUsers)).To(BeEquivalentTo(10))
+		runner.reduceWorkers(10)
+		Eventually(func() int32 { return atomic.LoadInt32(&runner.runningUsers) }).Should(BeZero())
+		Consistently(func() int32 { return atomic.LoadInt32(&aborted) }, 100*time.Millisecond).Should(BeZero())
+
+		// the last finished user stops the test
+		runner.maxRequestsPerUser = 2
+		runner.addWorkers(3)
+		Eventually(func() int32 { return atomic.LoadInt32(&aborted) }).Should(BeEquivalentTo(1))
+		Expect(atomic.LoadInt32(&runner.runningUsers)).To(BeZero())
+		Expect(atomic.LoadInt32(&runner.usersFinished)).To(BeEquivalentTo(3))
+	})
+
 	It("test localrunner", func() {
 		taskA := &Task{
 			Weight: 10,
@@ -136,6 +319,570 @@ var _ = Describe("Test runner", func() {
 		Expect(currentClients).To(BeEquivalentTo(2))
 	})
 
+	It("test localrunner with exponential spawn rate", func() {
+		taskA := &Task{
+			Fn: func() {
+				time.Sleep(time.Second)
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 10, 1)
+		runner.spawnDoubleEvery = 100 * time.Millisecond
+		buf := gbytes.NewBuffer()
+		runner.setLogger(log.New(buf, "", 0))
+
+		go runner.run()
+		defer runner.shutdown()
+
+		for _, clients := range []int{1, 2, 4, 8, 10} {
+			Eventually(buf).Should(gbytes.Say(fmt.Sprintf("Doubling the number of clients to %d\n", clients)))
+		}
+		Eventually(func() int32 { return atomic.LoadInt32(&runner.numClients) }).Should(BeEquivalentTo(10))
+		Consistently(buf, 300*time.Millisecond).ShouldNot(gbytes.Say("Doubling"))
+	})
+
+	It("test localrunner with run time and progress reporter", func() {
+		taskA := &Task{
+			Fn: func() {
+				time.Sleep(10 * time.Millisecond)
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 1, 1)
+		runner.runTime = 500 * time.Millisecond
+
+		var lock sync.Mutex
+		progresses := []float64{}
+		remainings := []time.Duration{}
+		runner.progressReporter = func(elapsed, remaining time.Duration, progress float64) {
+			lock.Lock()
+			defer lock.Unlock()
+			progresses = append(progresses, progress)
+			remainings = append(remainings, remaining)
+		}
+
+		done := make(chan bool)
+		go func() {
+			runner.run()
+			close(done)
+		}()
+		defer runner.shutdown()
+
+		// two stats intervals
+		runner.stats.messageToRunnerChan <- map[string]interface{}{}
+		runner.stats.messageToRunnerChan <- map[string]interface{}{}
+		Eventually(done).Should(BeClosed())
+
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(progresses).To(HaveLen(3))
+		Expect(progresses[0]).To(BeNumerically("<", 1))
+		Expect(progresses[2]).To(BeEquivalentTo(1))
+		Expect(remainings[2]).To(BeZero())
+	})
+
+	It("test report progress without run time", func() {
+		runner := &runner{startTime: time.Now()}
+		runner.reportProgress()
+
+		var remaining time.Duration
+		progress := float64(-1)
+		runner.progressReporter = func(e, r time.Duration, p float64) {
+			remaining = r
+			progress = p
+		}
+		runner.reportProgress()
+		Expect(remaining).To(BeEquivalentTo(-1))
+		Expect(progress).To(BeZero())
+	})
+
+	It("test localrunner with max memory usage", func() {
+		var lock sync.Mutex
+		var leaked [][]byte
+		taskA := &Task{
+			Fn: func() {
+				lock.Lock()
+				if len(leaked) < 10 {
+					leaked = append(leaked, make([]byte, 10<<20))
+				}
+				lock.Unlock()
+				time.Sleep(10 * time.Millisecond)
+			},
+			Name: "TaskA",
+		}
+		defer func() {
+			lock.Lock()
+			leaked = nil
+			lock.Unlock()
+		}()
+
+		exceeded := make(chan uint64, 1)
+		handler := func(heapInuse, limit uint64) {
+			exceeded <- limit
+		}
+		Events.Subscribe(EVENT_MEMORY_LIMIT_EXCEEDED, handler)
+		defer Events.Unsubscribe(EVENT_MEMORY_LIMIT_EXCEEDED, handler)
+
+		runner := newLocalRunner([]*Task{taskA}, nil, 1, 1)
+		runner.maxMemoryUsage = 50 << 20
+		runner.memoryCheckInterval = 50 * time.Millisecond
+
+		done := make(chan bool)
+		go func() {
+			runner.run()
+			close(done)
+		}()
+		defer runner.shutdown()
+
+		Eventually(done).Should(BeClosed())
+		Expect(exceeded).To(Receive(BeEquivalentTo(50 << 20)))
+		Expect(errors.Is(runner.err(), ErrMemoryLimitExceeded)).To(BeTrue())
+	})
+
+	It("test localrunner fails on first task error", func() {
+		calls := int64(0)
+		taskA := &Task{
+			FnWithError: func(ctx context.Context) error {
+				n := atomic.AddInt64(&calls, 1)
+				time.Sleep(time.Millisecond)
+				if n >= 5 {
+					return fmt.Errorf("unexpected error %d", n)
+				}
+				return nil
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 2, 100)
+		runner.failOnFirstTaskError = true
+
+		done := make(chan bool)
+		go func() {
+			runner.run()
+			close(done)
+		}()
+		defer runner.shutdown()
+
+		Eventually(done).Should(BeClosed())
+		Expect(runner.err()).To(MatchError(HavePrefix("task TaskA: unexpected error")))
+	})
+
+	It("test localrunner ignores task errors by default", func() {
+		calls := int64(0)
+		taskA := &Task{
+			FnWithError: func(ctx context.Context) error {
+				atomic.AddInt64(&calls, 1)
+				time.Sleep(time.Millisecond)
+				return errors.New("unexpected error")
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 1, 100)
+		go runner.run()
+		defer runner.shutdown()
+
+		Eventually(func() int64 { return atomic.LoadInt64(&calls) }).Should(BeNumerically(">", 10))
+		Expect(runner.err()).To(BeNil())
+	})
+
+	It("test localrunner with error group returns the first task error", func() {
+		calls := int64(0)
+		taskA := &Task{
+			FnWithError: func(ctx context.Context) error {
+				if atomic.AddInt64(&calls, 1) == 5 {
+					return errors.New("unexpected error")
+				}
+				// the other users are canceled by the group, and their errors are ignored
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Millisecond):
+					return nil
+				}
+			},
+			Name: "TaskA",
+		}
+		buf := gbytes.NewBuffer()
+		runner := newLocalRunner([]*Task{taskA}, nil, 5, 100)
+		runner.setLogger(log.New(buf, "", 0))
+		runner.errGroupLimit = 3
+		runner.failOnFirstTaskError = true
+
+		done := make(chan bool)
+		go func() {
+			runner.run()
+			close(done)
+		}()
+		defer runner.shutdown()
+
+		Eventually(done).Should(BeClosed())
+		Expect(buf).To(gbytes.Say("The number of clients is limited to 3 by the error group"))
+		Expect(runner.err()).To(MatchError("task TaskA: unexpected error"))
+	})
+
+	It("test localrunner with error group ignores task errors by default", func() {
+		calls := int64(0)
+		taskA := &Task{
+			FnWithError: func(ctx context.Context) error {
+				atomic.AddInt64(&calls, 1)
+				time.Sleep(time.Millisecond)
+				return errors.New("unexpected error")
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 2, 100)
+		runner.errGroupLimit = 2
+		go runner.run()
+		defer runner.shutdown()
+
+		Eventually(func() int64 { return atomic.LoadInt64(&calls) }).Should(BeNumerically(">", 10))
+		Expect(atomic.LoadInt32(&runner.numClients)).To(BeEquivalentTo(2))
+		Expect(runner.err()).To(BeNil())
+	})
+
+	It("test localrunner aborts on output error", func() {
+		taskA := &Task{
+			Fn: func() {
+				time.Sleep(time.Millisecond)
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 1, 100)
+		runner.setLogger(log.New(gbytes.NewBuffer(), "", 0))
+		runner.stats.reportInterval = 50 * time.Millisecond
+		runner.abortOnOutputError = true
+		hitOutput := &HitOutput{}
+		failingOutput := &FailingOutput{err: errors.New("disk full")}
+		failingOutput2 := &FailingOutput{err: errors.New("connection reset")}
+		runner.addOutput(hitOutput)
+		runner.addOutput(failingOutput)
+		runner.addOutput(failingOutput2)
+
+		done := make(chan bool)
+		go func() {
+			runner.run()
+			close(done)
+		}()
+		defer runner.shutdown()
+
+		Eventually(done).Should(BeClosed())
+		Expect(hitOutput.received()).To(BeTrue())
+		Expect(failingOutput.received()).To(BeFalse())
+		Expect(atomic.LoadInt32(&failingOutput.calls)).To(BeNumerically(">=", 1))
+		Expect(runner.err()).To(MatchError(ErrOutput))
+		Expect(runner.err()).To(Or(
+			MatchError(ContainSubstring("disk full")),
+			MatchError(ContainSubstring("connection reset")),
+		))
+		Expect(runner.report.Err).To(Equal(runner.err()))
+	})
+
+	It("test localrunner logs output errors by default", func() {
+		taskA := &Task{
+			Fn: func() {
+				time.Sleep(time.Millisecond)
+			},
+			Name: "TaskA",
+		}
+		buf := gbytes.NewBuffer()
+		runner := newLocalRunner([]*Task{taskA}, nil, 1, 100)
+		runner.setLogger(log.New(buf, "", 0))
+		runner.stats.reportInterval = 50 * time.Millisecond
+		failingOutput := &FailingOutput{err: errors.New("disk full")}
+		runner.addOutput(failingOutput)
+		done := make(chan bool)
+		go func() {
+			runner.run()
+			close(done)
+		}()
+
+		Eventually(func() int32 { return atomic.LoadInt32(&failingOutput.calls) }).Should(BeNumerically(">=", 2))
+		Eventually(buf).Should(gbytes.Say("output \\*boomer.FailingOutput error: disk full"))
+		Expect(runner.err()).To(BeNil())
+		runner.shutdown()
+		Eventually(done).Should(BeClosed())
+		Expect(runner.report.Err).To(BeNil())
+	})
+
+	It("test output error handler", func() {
+		runner := &runner{}
+		runner.setLogger(log.New(gbytes.NewBuffer(), "", 0))
+		failingOutput := &FailingOutput{err: errors.New("disk full")}
+		hitOutput := &HitOutput{}
+		runner.addOutput(failingOutput)
+		runner.addOutput(hitOutput)
+
+		var lock sync.Mutex
+		var outputs []Output
+		var errs []error
+		runner.outputErrorHandler = func(output Output, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			outputs = append(outputs, output)
+			errs = append(errs, err)
+		}
+		runner.outputOnEevent(map[string]interface{}{})
+		Expect(outputs).To(HaveLen(1))
+		Expect(outputs[0]).To(BeIdenticalTo(failingOutput))
+		Expect(errs[0]).To(BeIdenticalTo(failingOutput.err))
+		Expect(hitOutput.received()).To(BeTrue())
+		Expect(runner.err()).To(BeNil())
+
+		runner.outputErrorHandler = func(output Output, err error) {
+			panic("handler panics")
+		}
+		Expect(func() { runner.outputOnEevent(map[string]interface{}{}) }).NotTo(Panic())
+		Expect(atomic.LoadInt32(&failingOutput.calls)).To(BeEquivalentTo(2))
+	})
+
+	It("test output name override", func() {
+		buf := gbytes.NewBuffer()
+		runner := &runner{}
+		runner.setLogger(log.New(buf, "", 0))
+		auditA := WithOutputNameOverride(&FailingOutput{err: errors.New("disk full")}, "audit-a")
+		auditB := WithOutputNameOverride(&FailingOutput{err: errors.New("disk full")}, "audit-b")
+		hitOutput := &HitOutput{}
+		named := WithOutputNameOverride(hitOutput, "hit")
+		runner.addOutput(auditA)
+		runner.addOutput(auditB)
+		runner.addOutput(named)
+
+		runner.outputOnStart()
+		runner.outputOnEevent(map[string]interface{}{})
+		Expect(hitOutput.started()).To(BeTrue())
+		Expect(hitOutput.received()).To(BeTrue())
+		Expect(string(buf.Contents())).To(ContainSubstring("output audit-a error: disk full"))
+		Expect(string(buf.Contents())).To(ContainSubstring("output audit-b error: disk full"))
+
+		runner.abortOnOutputError = true
+		runner.outputOnEevent(map[string]interface{}{})
+		Expect(runner.err()).To(MatchError(MatchRegexp(`output error, audit-[ab]: disk full`)))
+
+		Expect(outputName(&HitOutput{})).To(Equal("*boomer.HitOutput"))
+		Expect(outputName(WithOutputNameOverride(&HitOutput{}, ""))).To(Equal("*boomer.NamedOutput"))
+	})
+
+	It("test spawn workers with spawn rate limit", func() {
+		taskA := &Task{
+			Fn: func() {
+				time.Sleep(10 * time.Millisecond)
+			},
+			Name: "TaskA",
+		}
+		b := NewStandaloneBoomer(20, 20).WithSpawnRateLimit(50).WithSpawnBurst(10)
+		runner := newLocalRunner([]*Task{taskA}, nil, 20, 20)
+		b.setupRunner(&runner.runner)
+		defer runner.shutdown()
+
+		start := time.Now()
+		spawned := make(chan time.Duration, 2)
+		complete := func() { spawned <- time.Since(start) }
+		runner.spawnWorkers(10, complete)
+		// the bucket is full at the beginning
+		Eventually(spawned).Should(Receive(BeNumerically("<", 50*time.Millisecond)))
+		Expect(atomic.LoadInt32(&runner.numClients)).To(BeEquivalentTo(10))
+
+		// the users are spawned in the background, the other 10 users at 50 per second
+		runner.spawnWorkers(20, complete)
+		Expect(time.Since(start)).To(BeNumerically("<", 100*time.Millisecond))
+		var elapsed time.Duration
+		Eventually(spawned).Should(Receive(&elapsed))
+		Expect(elapsed).To(BeNumerically(">=", 150*time.Millisecond))
+		Expect(elapsed).To(BeNumerically("<", 500*time.Millisecond))
+		Expect(atomic.LoadInt32(&runner.numClients)).To(BeEquivalentTo(20))
+	})
+
+	It("test spawn rate limit is canceled by stop", func() {
+		taskA := &Task{
+			Fn: func() {
+				time.Sleep(10 * time.Millisecond)
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 10, 10)
+		runner.spawnLimiter = rate.NewLimiter(10, 1)
+		defer runner.shutdown()
+
+		completed := make(chan bool, 1)
+		runner.spawnWorkers(10, func() { completed <- true })
+		Eventually(func() int32 { return atomic.LoadInt32(&runner.numClients) }).Should(BeNumerically(">=", 2))
+		runner.stop()
+		Consistently(func() int32 { return atomic.LoadInt32(&runner.numClients) }, 300*time.Millisecond).Should(BeZero())
+		Expect(completed).NotTo(Receive())
+
+		// a new spawning cancels the previous one, and starts from the users spawned so far
+		runner.spawnWorkers(3, func() { completed <- true })
+		runner.spawnWorkers(2, func() { completed <- true })
+		Eventually(completed).Should(Receive())
+		Consistently(func() int32 { return atomic.LoadInt32(&runner.numClients) }, 300*time.Millisecond).Should(BeEquivalentTo(2))
+	})
+
+	It("test spawn rate limit is interrupted by shutdown", func() {
+		taskA := &Task{
+			Fn: func() {
+				time.Sleep(10 * time.Millisecond)
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 10, 10)
+		runner.spawnLimiter = rate.NewLimiter(1, 1)
+
+		completed := make(chan bool, 1)
+		runner.spawnWorkers(10, func() { completed <- true })
+		time.Sleep(100 * time.Millisecond)
+		runner.shutdown()
+		Consistently(completed, 1500*time.Millisecond).ShouldNot(Receive())
+		Expect(atomic.LoadInt32(&runner.numClients)).To(BeNumerically("<", 10))
+	})
+
+	It("test task setup timeout", func() {
+		canceled := make(chan bool, 1)
+		taskA := &Task{
+			FnWithContext: func(ctx context.Context) {
+				<-ctx.Done()
+				canceled <- true
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 1, 1)
+		runner.taskSetupTimeout = 50 * time.Millisecond
+
+		start := time.Now()
+		Expect(runner.runWithSetupTimeout(context.Background(), taskA, nil)).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+		Eventually(canceled).Should(Receive())
+
+		var failure *requestFailure
+		Expect(runner.stats.requestFailureChan).To(Receive(&failure))
+		Expect(failure.requestType).To(Equal("task"))
+		Expect(failure.name).To(Equal("TaskA"))
+		Expect(failure.responseTime).To(BeEquivalentTo(50))
+		Expect(failure.error).To(Equal("task setup timeout"))
+	})
+
+	It("test timed out task keeps its concurrency slot", func() {
+		timedOut := int32(0)
+		taskA := &Task{
+			FnWithContext: func(ctx context.Context) {
+				<-ctx.Done()
+				time.Sleep(100 * time.Millisecond)
+				if TaskTimedOut(ctx) {
+					atomic.StoreInt32(&timedOut, 1)
+				}
+				// the late failure is dropped
+				panic("late failure")
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 1, 1)
+		runner.taskSetupTimeout = 50 * time.Millisecond
+		runner.concurrencySemaphore = make(chan struct{}, 1)
+		atomic.StoreInt32(&runner.numClients, 1)
+
+		runner.runLimited(context.Background(), taskA)
+		Expect(runner.stats.requestFailureChan).To(Receive())
+		// the user moves on, but the slot is released when the task returns
+		Expect(runner.concurrencySemaphore).To(HaveLen(1))
+		Eventually(runner.concurrencySemaphore).Should(BeEmpty())
+		Expect(atomic.LoadInt32(&timedOut)).To(BeEquivalentTo(1))
+		Eventually(func() int32 { return atomic.LoadInt32(&runner.timedOutTasks) }).Should(BeZero())
+		Expect(runner.stats.requestFailureChan).NotTo(Receive())
+
+		// the user waits for the task if there are as many timed out tasks as users
+		atomic.StoreInt32(&runner.numClients, 0)
+		start := time.Now()
+		runner.runLimited(context.Background(), taskA)
+		Expect(time.Since(start)).To(BeNumerically(">=", 150*time.Millisecond))
+		Expect(runner.concurrencySemaphore).To(BeEmpty())
+		Expect(TaskTimedOut(context.Background())).To(BeFalse())
+	})
+
+	It("test task setup done before timeout", func() {
+		taskErr := errors.New("task error")
+		taskA := &Task{
+			FnWithError: func(ctx context.Context) error {
+				TaskSetupDone(ctx)
+				TaskSetupDone(ctx)
+				time.Sleep(100 * time.Millisecond)
+				return ctx.Err()
+			},
+			Name: "TaskA",
+		}
+		taskB := &Task{
+			FnWithError: func(ctx context.Context) error {
+				return taskErr
+			},
+			Name: "TaskB",
+		}
+		runner := newLocalRunner([]*Task{taskA, taskB}, nil, 1, 1)
+		runner.taskSetupTimeout = 50 * time.Millisecond
+
+		Expect(runner.runWithSetupTimeout(context.Background(), taskA, nil)).To(Succeed())
+		Expect(runner.runWithSetupTimeout(context.Background(), taskB, nil)).To(MatchError(taskErr))
+		Expect(runner.stats.requestFailureChan).NotTo(Receive())
+
+		// it does nothing without the timeout
+		TaskSetupDone(context.Background())
+	})
+
+	It("test task setup timeout with boomer transport", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		}))
+		defer server.Close()
+		client := &http.Client{Transport: NewBoomerTransport()}
+		taskA := &Task{
+			FnWithError: func(ctx context.Context) error {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+				if err != nil {
+					return err
+				}
+				resp, err := client.Do(req)
+				if err != nil {
+					return err
+				}
+				return resp.Body.Close()
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 1, 1)
+		runner.taskSetupTimeout = 50 * time.Millisecond
+
+		Expect(runner.runWithSetupTimeout(context.Background(), taskA, nil)).To(Succeed())
+		Expect(runner.stats.requestFailureChan).NotTo(Receive())
+	})
+
+	It("test localrunner with concurrency limit", func() {
+		var current, max int32
+		taskA := &Task{
+			Fn: func() {
+				n := atomic.AddInt32(&current, 1)
+				for {
+					m := atomic.LoadInt32(&max)
+					if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&current, -1)
+			},
+			Name: "TaskA",
+		}
+		runner := newLocalRunner([]*Task{taskA}, nil, 10, 100)
+		runner.concurrencySemaphore = make(chan struct{}, 2)
+		defer runner.shutdown()
+
+		go runner.run()
+		Eventually(func() int32 { return atomic.LoadInt32(&runner.numClients) }).Should(BeEquivalentTo(10))
+		time.Sleep(100 * time.Millisecond)
+
+		Expect(atomic.LoadInt32(&max)).To(BeEquivalentTo(2))
+		Expect(atomic.LoadInt32(&runner.concurrency)).To(BeNumerically("<=", 2))
+
+		data := map[string]interface{}{}
+		runner.setReportData(data)
+		Expect(data["concurrency_limit"]).To(BeEquivalentTo(2))
+	})
+
 	It("test local runner send custom message", func() {
 		Events.SubscribeOnce("TestLocalRunnerSendCustomMessage", func(customMessage *CustomMessage) {
 			Expect(customMessage.NodeID).To(Equal("local"))
@@ -240,6 +987,179 @@ var _ = Describe("Test runner", func() {
 		Expect(actPercentage).To(BeNumerically(">", 0.5*expectedPercentage))
 	})
 
+	It("test task rate limit", func() {
+		limitedCalls := int64(0)
+		freeCalls := int64(0)
+
+		limitedTask := &Task{
+			Name:        "limited",
+			RequestType: "http",
+			Fn: func() {
+				atomic.AddInt64(&limitedCalls, 1)
+			},
+		}
+		freeTask := &Task{
+			Name: "free",
+			Fn: func() {
+				atomic.AddInt64(&freeCalls, 1)
+				time.Sleep(time.Millisecond)
+			},
+		}
+
+		b := NewStandaloneBoomer(10, 10).WithTaskRateLimit("http", "limited", 50)
+		runner := newLocalRunner([]*Task{limitedTask, freeTask}, nil, 10, 10)
+		runner.taskRateLimiters = b.taskRateLimiters
+		defer runner.shutdown()
+
+		runner.spawnWorkers(10, nil)
+		time.Sleep(2 * time.Second)
+		runner.stop()
+
+		Expect(atomic.LoadInt64(&limitedCalls)).To(BeNumerically("~", 100, 5))
+		Expect(atomic.LoadInt64(&freeCalls)).To(BeNumerically(">", 0))
+	})
+
+	It("test task rate limits of the same name and different request types", func() {
+		httpCalls := int64(0)
+		grpcCalls := int64(0)
+		httpTask := &Task{
+			Name:        "login",
+			RequestType: "http",
+			Fn: func() {
+				atomic.AddInt64(&httpCalls, 1)
+			},
+		}
+		grpcTask := &Task{
+			Name:        "login",
+			RequestType: "grpc",
+			Fn: func() {
+				atomic.AddInt64(&grpcCalls, 1)
+			},
+		}
+
+		// half of the users run each task, so the slower limiter doesn't hold up the other task
+		b := NewStandaloneBoomer(10, 10).WithTaskRateLimit("http", "login", 50).WithTaskRateLimit("grpc", "login", 20).
+			WithConsistentHashing(func(userID int, requestType, name string) int {
+				return userID
+			})
+		runner := newLocalRunner([]*Task{httpTask, grpcTask}, nil, 10, 10)
+		b.setupRunner(&runner.runner)
+		defer runner.shutdown()
+
+		runner.spawnWorkers(10, nil)
+		time.Sleep(2 * time.Second)
+		runner.stop()
+
+		Expect(atomic.LoadInt64(&httpCalls)).To(BeNumerically("~", 100, 5))
+		Expect(atomic.LoadInt64(&grpcCalls)).To(BeNumerically("~", 40, 2))
+	})
+
+	It("test task with global throttle", func() {
+		var lock sync.Mutex
+		var calls []time.Time
+		throttledTask := (&Task{
+			Fn: func() {
+				lock.Lock()
+				defer lock.Unlock()
+				calls = append(calls, time.Now())
+			},
+			Name: "reset",
+		}).WithGlobalThrottle(time.Second)
+		runner := newLocalRunner([]*Task{throttledTask}, nil, 100, 100)
+		defer runner.shutdown()
+
+		runner.spawnWorkers(100, nil)
+		time.Sleep(2500 * time.Millisecond)
+		runner.stop()
+
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(calls).To(HaveLen(3))
+		for i := 1; i < len(calls); i++ {
+			Expect(calls[i].Sub(calls[i-1])).To(BeNumerically(">=", 990*time.Millisecond))
+		}
+		Expect((&Task{}).WithGlobalThrottle(0).throttle).To(BeNil())
+	})
+
+	It("test user data", func() {
+		users := []string{"alice", "bob", "carol"}
+		seen := sync.Map{}
+
+		taskA := &Task{
+			Name: "TaskA",
+			FnWithContext: func(ctx context.Context) {
+				seen.Store(UserDataFromContext(ctx, "username"), true)
+				Expect(UserDataFromContext(ctx, "password")).To(BeNil())
+				time.Sleep(10 * time.Millisecond)
+			},
+		}
+
+		b := NewStandaloneBoomer(3, 3).WithUserData("username", func() interface{} {
+			user := users[0]
+			users = users[1:]
+			return user
+		})
+		runner := newLocalRunner([]*Task{taskA}, nil, 3, 3)
+		b.setupRunner(&runner.runner)
+		defer runner.shutdown()
+
+		runner.spawnWorkers(3, nil)
+		defer runner.stop()
+
+		Eventually(func() int {
+			count := 0
+			seen.Range(func(key, value interface{}) bool {
+				count++
+				return true
+			})
+			return count
+		}).Should(Equal(3))
+		Expect(users).To(BeEmpty())
+		_, ok := seen.Load("alice")
+		Expect(ok).To(BeTrue())
+	})
+
+	It("test consistent hashing", func() {
+		var lock sync.Mutex
+		usersOfTasks := map[string]map[int]bool{}
+		newTask := func(name string) *Task {
+			usersOfTasks[name] = map[int]bool{}
+			return &Task{
+				Name:   name,
+				Weight: 10,
+				FnWithContext: func(ctx context.Context) {
+					userID, ok := UserIDFromContext(ctx)
+					Expect(ok).To(BeTrue())
+					lock.Lock()
+					usersOfTasks[name][userID] = true
+					lock.Unlock()
+					time.Sleep(time.Millisecond)
+				},
+			}
+		}
+		tasks := []*Task{newTask("A"), newTask("B"), newTask("C")}
+
+		b := NewStandaloneBoomer(10, 10).WithConsistentHashing(func(userID int, requestType, name string) int {
+			defer GinkgoRecover()
+			Expect(requestType).To(Equal("user"))
+			Expect(name).To(Equal(strconv.Itoa(userID)))
+			return userID % 3
+		})
+		runner := newLocalRunner(tasks, nil, 10, 10)
+		b.setupRunner(&runner.runner)
+		defer runner.shutdown()
+
+		runner.spawnWorkers(10, nil)
+		time.Sleep(200 * time.Millisecond)
+		runner.stop()
+
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(usersOfTasks["A"]).To(Equal(map[int]bool{0: true, 3: true, 6: true, 9: true}))
+		Expect(usersOfTasks["B"]).To(Equal(map[int]bool{1: true, 4: true, 7: true}))
+		Expect(usersOfTasks["C"]).To(Equal(map[int]bool{2: true, 5: true, 8: true}))
+	})
+
 	It("test spawn and stop", func() {
 		taskA := &Task{
 			Fn: func() {
@@ -506,3 +1426,105 @@ var _ = Describe("Test runner", func() {
 		MockGomqDealerInstance.RecvChannel() <- ackZmtpMessage
 	})
 })
+
+var _ = Describe("Test think time jitter and initial spread delay", func() {
+
+	// coefficientOfVariation runs 20 users with 100ms think time for a second, and returns the coefficient of variation
+	// of the task executions per 10ms.
+	coefficientOfVariation := func(jitter, spread time.Duration) float64 {
+		start := time.Now()
+		buckets := make([]int64, 100)
+		task := &Task{
+			Name: "TaskA",
+			Fn: func() {
+				if bucket := int(time.Since(start) / (10 * time.Millisecond)); bucket < len(buckets) {
+					atomic.AddInt64(&buckets[bucket], 1)
+				}
+			},
+		}
+		runner := newSlaveRunner("localhost", 5557, []*Task{task}, nil)
+		runner.client = newClient("localhost", 5557, runner.nodeID)
+		runner.thinkTime = 100
+		runner.thinkTimeJitter = jitter
+		runner.initialSpreadDelay = spread
+		runner.addWorkers(20)
+		time.Sleep(time.Second)
+		runner.shutdown()
+
+		// skip the first 200ms, in which the users are being spread
+		sum, sumOfSquares := 0.0, 0.0
+		counts := buckets[20:]
+		for i := range counts {
+			count := float64(atomic.LoadInt64(&counts[i]))
+			sum += count
+			sumOfSquares += count * count
+		}
+		mean := sum / float64(len(counts))
+		return math.Sqrt(sumOfSquares/float64(len(counts))-mean*mean) / mean
+	}
+
+	It("test options", func() {
+		b := NewStandaloneBoomer(1, 1).WithUserThinkTimeJitter(50 * time.Millisecond).WithInitialSpreadDelay(time.Second)
+		runner := newLocalRunner(nil, nil, 1, 1)
+		b.setupRunner(&runner.runner)
+		Expect(runner.thinkTimeJitter).To(Equal(50 * time.Millisecond))
+		Expect(runner.initialSpreadDelay).To(Equal(time.Second))
+	})
+
+	It("test think time jitter", func() {
+		runner := newLocalRunner(nil, nil, 1, 1)
+		runner.thinkTime = 20
+		runner.thinkTimeJitter = 20 * time.Millisecond
+		var shortest, longest time.Duration
+		for i := 0; i < 20; i++ {
+			start := time.Now()
+			Expect(runner.think(context.Background())).To(BeTrue())
+			elapsed := time.Since(start)
+			if i == 0 || elapsed < shortest {
+				shortest = elapsed
+			}
+			if elapsed > longest {
+				longest = elapsed
+			}
+		}
+		Expect(longest).To(BeNumerically("<", 100*time.Millisecond))
+		Expect(longest - shortest).To(BeNumerically(">", 5*time.Millisecond))
+	})
+
+	It("test initial spread delay", func() {
+		var lock sync.Mutex
+		var starts []time.Time
+		task := &Task{
+			Name: "TaskA",
+			Fn: func() {
+				lock.Lock()
+				starts = append(starts, time.Now())
+				lock.Unlock()
+				time.Sleep(time.Second)
+			},
+		}
+		runner := newSlaveRunner("localhost", 5557, []*Task{task}, nil)
+		runner.client = newClient("localhost", 5557, runner.nodeID)
+		defer runner.shutdown()
+		runner.initialSpreadDelay = 200 * time.Millisecond
+
+		start := time.Now()
+		runner.addWorkers(4)
+		Expect(runner.cancelFuncs).To(HaveLen(4))
+		Eventually(func() int {
+			lock.Lock()
+			defer lock.Unlock()
+			return len(starts)
+		}).Should(Equal(4))
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(starts[0].Sub(start)).To(BeNumerically("<", 40*time.Millisecond))
+		Expect(starts[3].Sub(start)).To(BeNumerically(">=", 150*time.Millisecond))
+	})
+
+	It("test jitter and spread smooth the load", func() {
+		synchronized := coefficientOfVariation(0, 0)
+		smoothed := coefficientOfVariation(50*time.Millisecond, 100*time.Millisecond)
+		Expect(smoothed).To(BeNumerically("<=", synchronized*0.7))
+	})
+})
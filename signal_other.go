@@ -0,0 +1,14 @@
+//go:build !unix
+
+package boomer
+
+import (
+	"os"
+)
+
+// there is no SIGUSR1 on other platforms, so the signals must be given explicitly.
+var defaultStatsFlushSignals []os.Signal
+
+func signalName(sig os.Signal) string {
+	return sig.String()
+}
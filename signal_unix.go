@@ -0,0 +1,22 @@
+//go:build unix
+
+package boomer
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var defaultStatsFlushSignals = []os.Signal{unix.SIGUSR1}
+
+// signalName returns names like "SIGUSR1", instead of "user defined signal 1".
+func signalName(sig os.Signal) string {
+	if s, ok := sig.(syscall.Signal); ok {
+		if name := unix.SignalName(s); name != "" {
+			return name
+		}
+	}
+	return sig.String()
+}
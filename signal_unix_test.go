@@ -0,0 +1,67 @@
+//go:build unix
+
+package boomer
+
+import (
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Test stats flush on signal", func() {
+
+	It("test signal name", func() {
+		Expect(signalName(syscall.SIGUSR1)).To(Equal("SIGUSR1"))
+		Expect(signalName(os.Interrupt)).To(Equal("SIGINT"))
+	})
+
+	It("test flush stats on SIGUSR1", func() {
+		// keep the default action of SIGUSR1 from killing the test process before the runner is notified
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGUSR1)
+		defer signal.Stop(c)
+
+		buf := gbytes.NewBuffer()
+		b := NewStandaloneBoomer(1, 1).WithStatsFlushOnSignal().WithLogger(log.New(io.MultiWriter(buf, GinkgoWriter), "", 0))
+		Expect(b.statsFlushSignals).To(Equal([]os.Signal{syscall.SIGUSR1}))
+
+		output := NewInMemoryOutput()
+		b.AddOutput(output)
+		task := &Task{
+			Name: "foo",
+			Fn: func() {
+				b.RecordSuccess("http", "foo", 10, 100)
+				time.Sleep(10 * time.Millisecond)
+			},
+		}
+		done := make(chan bool)
+		go func() {
+			b.Run(task)
+			close(done)
+		}()
+		defer func() {
+			b.Quit()
+			Eventually(done).Should(BeClosed())
+		}()
+
+		// the normal interval is 3 seconds, so the snapshot is reported by the signal,
+		// the first signal may arrive before any request is recorded
+		process, err := os.FindProcess(os.Getpid())
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(func() int64 {
+			process.Signal(syscall.SIGUSR1)
+			if snapshot := output.LastSnapshot(); snapshot != nil {
+				return snapshot.TotalStats.NumRequests
+			}
+			return 0
+		}, time.Second, 100*time.Millisecond).Should(BeNumerically(">", 0))
+		Expect(buf).To(gbytes.Say("Flushing stats on signal SIGUSR1"))
+	})
+})
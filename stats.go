@@ -1,6 +1,10 @@
 package boomer
 
 import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
 	"time"
 )
 
@@ -16,6 +20,7 @@ type requestFailure struct {
 	name         string
 	responseTime int64
 	error        string
+	details      map[string]string
 }
 
 type requestStats struct {
@@ -24,9 +29,43 @@ type requestStats struct {
 	total     *statsEntry
 	startTime int64
 
+	// the max number of unique errors tracked in a report interval, zero means no limit.
+	maxErrorsTracked int
+	errorsTruncated  bool
+
+	// names of the endpoints which are reported even if there are no requests, and their methods.
+	// An empty method is replaced by the one of the first request to the endpoint.
+	zeroCountEndpoints map[string]string
+
+	// the stats are reported every reportInterval, plus a random jitter in [-reportJitter, +reportJitter]
+	reportInterval time.Duration
+	reportJitter   time.Duration
+	// the random source of the jitter, it's the one of the runner.
+	random *rand.Rand
+
+	// the number of recent response times per endpoint to calculate the lag-1 autocorrelation, zero means disabled.
+	correlationWindow int
+
+	// the seconds over which the current rps and fails/sec are averaged, see Boomer.WithRPSWindow.
+	rpsWindowSecs int64
+
+	// custom metrics of the current report interval, and the ones merged from all the intervals
+	customMetrics           map[string]*CustomMetricEntry
+	customMetricsTotal      map[string]*CustomMetricEntry
+	customMetricAggregators map[string]func(prev, current *CustomMetricEntry) *CustomMetricEntry
+
+	// onFailureSpike is called with the failures within the window when failureSpike trips, nil means disabled.
+	failureSpike   *failureSpikeDetector
+	onFailureSpike func(failures int64)
+
+	// the requests are recorded to store instead of the channels if it's set, see Boomer.WithConcurrentStats.
+	store *ConcurrentStatsStore
+
 	requestSuccessChan  chan *requestSuccess
 	requestFailureChan  chan *requestFailure
+	customMetricChan    chan *customMetric
 	clearStatsChan      chan bool
+	collectChan         chan chan map[string]interface{}
 	messageToRunnerChan chan map[string]interface{}
 	shutdownChan        chan bool
 }
@@ -36,36 +75,75 @@ func newRequestStats() (stats *requestStats) {
 	errors := make(map[string]*statsError)
 
 	stats = &requestStats{
-		entries: entries,
-		errors:  errors,
+		entries:            entries,
+		errors:             errors,
+		customMetrics:      make(map[string]*CustomMetricEntry),
+		customMetricsTotal: make(map[string]*CustomMetricEntry),
+		rpsWindowSecs:      defaultRPSWindowSecs,
+		random:             random,
 	}
 	stats.requestSuccessChan = make(chan *requestSuccess, 100)
 	stats.requestFailureChan = make(chan *requestFailure, 100)
+	stats.customMetricChan = make(chan *customMetric, 100)
 	stats.clearStatsChan = make(chan bool)
+	stats.collectChan = make(chan chan map[string]interface{})
 	stats.messageToRunnerChan = make(chan map[string]interface{}, 10)
 	stats.shutdownChan = make(chan bool)
 
-	stats.total = &statsEntry{
-		Name:   "Total",
-		Method: "",
-	}
-	stats.total.reset()
+	stats.total = stats.newEntry("Total", "")
 
 	return stats
 }
 
+// setRPSWindow sets the seconds over which the current rps and fails/sec are averaged.
+// It must be called before the stats are started.
+func (s *requestStats) setRPSWindow(secs int64) {
+	if secs <= 0 {
+		secs = defaultRPSWindowSecs
+	}
+	s.rpsWindowSecs = secs
+	s.total = s.newEntry("Total", "")
+}
+
+// newEntry returns an empty statsEntry, which keeps the recent rates across report intervals.
+func (s *requestStats) newEntry(name, method string) *statsEntry {
+	entry := &statsEntry{
+		Name:             name,
+		Method:           method,
+		recentReqsPerSec: newRateHistory(s.rpsWindowSecs),
+		recentFailPerSec: newRateHistory(s.rpsWindowSecs),
+	}
+	entry.reset()
+	return entry
+}
+
 func (s *requestStats) logRequest(method, name string, responseTime int64, contentLength int64) {
 	s.total.log(responseTime, contentLength)
 	s.get(name, method).log(responseTime, contentLength)
 }
 
 func (s *requestStats) logError(method, name, err string) {
+	s.logErrorWithDetails(method, name, err, nil)
+}
+
+// logErrorWithDetails is like logError, and keeps the details of the failure in the errors map.
+func (s *requestStats) logErrorWithDetails(method, name, err string, details map[string]string) {
 	s.total.logError(err)
 	s.get(name, method).logError(err)
+	s.trackError(method, name, err, 1, details)
+}
 
-	// store error in errors map
+// trackError adds the occurrences of the error to the errors map, and keeps the details if they are not nil.
+func (s *requestStats) trackError(method, name, err string, occurrences int64, details map[string]string) {
 	key := MD5(method, name, err)
 	entry, ok := s.errors[key]
+	if !ok && s.maxErrorsTracked > 0 && len(s.errors) >= s.maxErrorsTracked {
+		// too many unique errors, count it in the catch-all entry of this endpoint
+		s.errorsTruncated = true
+		err = truncatedError
+		key = MD5(method, name, err)
+		entry, ok = s.errors[key]
+	}
 	if !ok {
 		entry = &statsError{
 			name:   name,
@@ -74,19 +152,55 @@ func (s *requestStats) logError(method, name, err string) {
 		}
 		s.errors[key] = entry
 	}
-	entry.occured()
+	entry.occurrences += occurrences
+	if details != nil {
+		entry.details = details
+	}
+}
+
+// aggregator returns the function to aggregate the custom metric, AverageAggregation is used by default.
+func (s *requestStats) aggregator(name string) func(prev, current *CustomMetricEntry) *CustomMetricEntry {
+	if fn, ok := s.customMetricAggregators[name]; ok {
+		return fn
+	}
+	return AverageAggregation.aggregate
+}
+
+func (s *requestStats) logCustomMetric(name string, value float64) {
+	s.customMetrics[name] = s.aggregator(name)(s.customMetrics[name], newCustomMetricEntry(name, value))
 }
 
+// serializeCustomMetrics merges the custom metrics of the current report interval into the total ones,
+// and returns a copy of them.
+func (s *requestStats) serializeCustomMetrics() map[string]*CustomMetricEntry {
+	for name, entry := range s.customMetrics {
+		s.customMetricsTotal[name] = s.aggregator(name)(s.customMetricsTotal[name], entry)
+	}
+	s.customMetrics = make(map[string]*CustomMetricEntry)
+
+	metrics := make(map[string]*CustomMetricEntry, len(s.customMetricsTotal))
+	for name, entry := range s.customMetricsTotal {
+		copied := *entry
+		metrics[name] = &copied
+	}
+	return metrics
+}
+
+// truncatedError is used as the error of the catch-all entry when the errors map is full.
+const truncatedError = "[truncated]"
+
 func (s *requestStats) get(name string, method string) (entry *statsEntry) {
 	entry, ok := s.entries[name+method]
 	if !ok {
-		newEntry := &statsEntry{
-			Name:          name,
-			Method:        method,
-			NumReqsPerSec: make(map[int64]int64),
-			ResponseTimes: make(map[int64]int64),
+		if registered, ok := s.zeroCountEndpoints[name]; ok && registered == "" && method != "" {
+			// the endpoint is reported with the method of its requests from now on
+			s.zeroCountEndpoints[name] = method
+			delete(s.entries, name)
+		}
+		newEntry := s.newEntry(name, method)
+		if s.correlationWindow > 0 {
+			newEntry.recentResponseTimes = newCircularBuffer[int64](s.correlationWindow)
 		}
-		newEntry.reset()
 		s.entries[name+method] = newEntry
 		return newEntry
 	}
@@ -94,22 +208,32 @@ func (s *requestStats) get(name string, method string) (entry *statsEntry) {
 }
 
 func (s *requestStats) clearAll() {
-	s.total = &statsEntry{
-		Name:   "Total",
-		Method: "",
-	}
-	s.total.reset()
+	s.total = s.newEntry("Total", "")
 
 	s.entries = make(map[string]*statsEntry)
 	s.errors = make(map[string]*statsError)
+	s.errorsTruncated = false
+	s.customMetrics = make(map[string]*CustomMetricEntry)
+	s.customMetricsTotal = make(map[string]*CustomMetricEntry)
 	s.startTime = time.Now().Unix()
+	if s.store != nil {
+		s.store.take(func(*statsEntry, map[string]int64) {})
+	}
 }
 
 func (s *requestStats) serializeStats() []interface{} {
 	entries := make([]interface{}, 0, len(s.entries))
+	reported := make(map[string]bool)
 	for _, v := range s.entries {
-		if !(v.NumRequests == 0 && v.NumFailures == 0) {
+		if _, ok := s.zeroCountEndpoints[v.Name]; ok || !(v.NumRequests == 0 && v.NumFailures == 0) {
 			entries = append(entries, v.getStrippedReport())
+			reported[v.Name] = true
+		}
+	}
+	// endpoints which are never called are reported with zero values
+	for name, method := range s.zeroCountEndpoints {
+		if !reported[name] {
+			entries = append(entries, s.get(name, method).getStrippedReport())
 		}
 	}
 	return entries
@@ -124,30 +248,45 @@ func (s *requestStats) serializeErrors() map[string]map[string]interface{} {
 }
 
 func (s *requestStats) collectReportData() map[string]interface{} {
+	s.mergeStore()
 	data := make(map[string]interface{})
 	data["stats"] = s.serializeStats()
 	data["stats_total"] = s.total.getStrippedReport()
 	data["errors"] = s.serializeErrors()
+	data["errors_truncated"] = s.errorsTruncated
+	if len(s.customMetrics) > 0 || len(s.customMetricsTotal) > 0 {
+		data["custom_metrics"] = s.serializeCustomMetrics()
+	}
 	s.errors = make(map[string]*statsError)
+	s.errorsTruncated = false
 	return data
 }
 
 func (s *requestStats) start() {
 	go func() {
-		var ticker = time.NewTicker(slaveReportInterval)
+		var timer = time.NewTimer(s.nextReportInterval())
 		for {
 			select {
 			case m := <-s.requestSuccessChan:
 				s.logRequest(m.requestType, m.name, m.responseTime, m.responseLength)
 			case n := <-s.requestFailureChan:
 				s.logRequest(n.requestType, n.name, n.responseTime, 0)
-				s.logError(n.requestType, n.name, n.error)
+				s.logErrorWithDetails(n.requestType, n.name, n.error, n.details)
+				s.detectFailureSpike()
+			case c := <-s.customMetricChan:
+				s.logCustomMetric(c.name, c.value)
 			case <-s.clearStatsChan:
+				// the requests recorded before clearing are in the channels, so drain them first
+				s.drain()
 				s.clearAll()
-			case <-ticker.C:
+			case reply := <-s.collectChan:
+				s.drain()
+				reply <- s.collectReportData()
+			case <-timer.C:
 				data := s.collectReportData()
 				// send data to channel, no network IO in this goroutine
 				s.messageToRunnerChan <- data
+				timer.Reset(s.nextReportInterval())
 			case <-s.shutdownChan:
 				return
 			}
@@ -155,6 +294,58 @@ func (s *requestStats) start() {
 	}()
 }
 
+// detectFailureSpike counts a failure in failureSpike, and calls onFailureSpike if it trips.
+func (s *requestStats) detectFailureSpike() {
+	if s.failureSpike == nil || s.onFailureSpike == nil {
+		return
+	}
+	if failures := s.failureSpike.add(time.Now()); failures > 0 {
+		s.onFailureSpike(failures)
+	}
+}
+
+// drain logs the requests and custom metrics which are still in the channels.
+func (s *requestStats) drain() {
+	for {
+		select {
+		case m := <-s.requestSuccessChan:
+			s.logRequest(m.requestType, m.name, m.responseTime, m.responseLength)
+		case n := <-s.requestFailureChan:
+			s.logRequest(n.requestType, n.name, n.responseTime, 0)
+			s.logErrorWithDetails(n.requestType, n.name, n.error, n.details)
+			s.detectFailureSpike()
+		case c := <-s.customMetricChan:
+			s.logCustomMetric(c.name, c.value)
+		default:
+			return
+		}
+	}
+}
+
+// nextReportInterval returns the interval to the next report, which is slaveReportInterval by default.
+func (s *requestStats) nextReportInterval() time.Duration {
+	interval := s.reportInterval
+	if interval <= 0 {
+		interval = slaveReportInterval
+	}
+	if s.reportJitter > 0 {
+		interval += time.Duration(s.random.Int63n(int64(2*s.reportJitter)+1)) - s.reportJitter
+	}
+	return interval
+}
+
+// collect returns the report data since the last report interval, without waiting for the ticker.
+// It returns nil if the stats goroutine is closed.
+func (s *requestStats) collect() map[string]interface{} {
+	reply := make(chan map[string]interface{}, 1)
+	select {
+	case s.collectChan <- reply:
+		return <-reply
+	case <-s.shutdownChan:
+		return nil
+	}
+}
+
 // close is used by unit tests to avoid leakage of goroutines
 func (s *requestStats) close() {
 	close(s.shutdownChan)
@@ -194,6 +385,88 @@ type statsEntry struct {
 	// Boomer doesn't allow None response time for requests like locust.
 	// num_none_requests is added to keep compatible with locust.
 	NumNoneRequests int64 `json:"num_none_requests"`
+	// The lag-1 autocorrelation of the recent response times, positive if slow responses cluster together
+	ResponseTimeCorrelation float64 `json:"response_time_correlation"`
+	// The requests and failures per second averaged over the rps window, nil if they are not reported,
+	// then they are averaged over NumReqsPerSec and NumFailPerSec of the report interval
+	CurrentRps        *int64 `json:"current_rps,omitempty"`
+	CurrentFailPerSec *int64 `json:"current_fail_per_sec,omitempty"`
+
+	// the recent response times, which are kept across report intervals, nil if correlation is disabled
+	recentResponseTimes *circularBuffer[int64]
+	// the requests and failures per second, which are kept across report intervals,
+	// nil if the entry isn't created by requestStats
+	recentReqsPerSec *rateHistory
+	recentFailPerSec *rateHistory
+}
+
+// rateHistory keeps the counts per second of the recent seconds. NumReqsPerSec and NumFailPerSec are reset
+// every report interval, which is usually shorter than the rps window.
+type rateHistory struct {
+	// the counts of the completed seconds, the seconds without any count are filled with zeros
+	completed  *circularBuffer[int64]
+	windowSecs int64
+	// the second being counted, zero before the first count
+	second int64
+	count  int64
+}
+
+func newRateHistory(windowSecs int64) *rateHistory {
+	return &rateHistory{
+		completed:  newCircularBuffer[int64](int(windowSecs)),
+		windowSecs: windowSecs,
+	}
+}
+
+// add counts one in the second.
+func (h *rateHistory) add(second int64) {
+	h.addN(second, 1)
+}
+
+// addN counts n in the second.
+func (h *rateHistory) addN(second, n int64) {
+	h.advance(second)
+	h.count += n
+}
+
+// advance completes the seconds before second.
+func (h *rateHistory) advance(second int64) {
+	if h.second == 0 {
+		h.second = second
+		return
+	}
+	if second <= h.second {
+		return
+	}
+	h.completed.Push(h.count)
+	// the older seconds are overwritten anyway
+	gap := second - h.second - 1
+	if gap > h.windowSecs {
+		gap = h.windowSecs
+	}
+	for i := int64(0); i < gap; i++ {
+		h.completed.Push(0)
+	}
+	h.second = second
+	h.count = 0
+}
+
+// rate returns the average count per second of the completed seconds in the window before now.
+// The current second is excluded, because it's still being counted.
+func (h *rateHistory) rate(now int64) int64 {
+	if h.second == 0 {
+		return 0
+	}
+	h.advance(now)
+	counts := h.completed.Last(int(h.windowSecs))
+	if len(counts) == 0 {
+		return 0
+	}
+	total := int64(0)
+	for _, count := range counts {
+		total += count
+	}
+	return total / int64(len(counts))
 }
 
 func (s *statsEntry) reset() {
@@ -229,11 +502,18 @@ func (s *statsEntry) logTimeOfRequest() {
 	}
 
 	s.LastRequestTimestamp = key
+	if s.recentReqsPerSec != nil {
+		s.recentReqsPerSec.add(key)
+	}
 }
 
 func (s *statsEntry) logResponseTime(responseTime int64) {
 	s.TotalResponseTime += responseTime
 
+	if s.recentResponseTimes != nil {
+		s.recentResponseTimes.Push(responseTime)
+	}
+
 	if s.MinResponseTime == 0 {
 		s.MinResponseTime = responseTime
 	}
@@ -270,6 +550,36 @@ func (s *statsEntry) logResponseTime(responseTime int64) {
 	}
 }
 
+// merge adds the requests of other to this entry.
+func (s *statsEntry) merge(other *statsEntry) {
+	if other.NumRequests > 0 && (s.NumRequests == 0 || other.MinResponseTime < s.MinResponseTime) {
+		s.MinResponseTime = other.MinResponseTime
+	}
+	if other.MaxResponseTime > s.MaxResponseTime {
+		s.MaxResponseTime = other.MaxResponseTime
+	}
+	if other.StartTime < s.StartTime {
+		s.StartTime = other.StartTime
+	}
+	if other.LastRequestTimestamp > s.LastRequestTimestamp {
+		s.LastRequestTimestamp = other.LastRequestTimestamp
+	}
+	s.NumRequests += other.NumRequests
+	s.NumFailures += other.NumFailures
+	s.NumNoneRequests += other.NumNoneRequests
+	s.TotalResponseTime += other.TotalResponseTime
+	s.TotalContentLength += other.TotalContentLength
+	for k, v := range other.ResponseTimes {
+		s.ResponseTimes[k] += v
+	}
+	for k, v := range other.NumReqsPerSec {
+		s.NumReqsPerSec[k] += v
+	}
+	for k, v := range other.NumFailPerSec {
+		s.NumFailPerSec[k] += v
+	}
+}
+
 func (s *statsEntry) logError(err string) {
 	s.NumFailures++
 	key := time.Now().Unix()
@@ -279,6 +589,67 @@ func (s *statsEntry) logError(err string) {
 	} else {
 		s.NumFailPerSec[key]++
 	}
+	if s.recentFailPerSec != nil {
+		s.recentFailPerSec.add(key)
+	}
+}
+
+// Percentile returns the response time at the target percentile, target is in [0, 1].
+// It returns 0 if target is out of range.
+func (s *statsEntry) Percentile(target float64) int64 {
+	result, err := s.Percentiles([]float64{target})
+	if err != nil {
+		return 0
+	}
+	return result[target]
+}
+
+// Percentiles returns the response times at the target percentiles, the keys of ResponseTimes are sorted only once.
+// It returns an error if the targets are not in [0, 1] or not sorted in ascending order.
+func (s *statsEntry) Percentiles(targets []float64) (map[float64]int64, error) {
+	for i, target := range targets {
+		if target < 0 || target > 1 {
+			return nil, fmt.Errorf("percentile %v is not in [0, 1]", target)
+		}
+		if i > 0 && target < targets[i-1] {
+			return nil, fmt.Errorf("percentiles %v are not sorted", targets)
+		}
+	}
+
+	result := make(map[float64]int64, len(targets))
+	if len(s.ResponseTimes) == 0 || len(targets) == 0 {
+		for _, target := range targets {
+			result[target] = 0
+		}
+		return result, nil
+	}
+
+	sortedKeys := make([]int64, 0, len(s.ResponseTimes))
+	for k := range s.ResponseTimes {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Slice(sortedKeys, func(i, j int) bool {
+		return sortedKeys[i] < sortedKeys[j]
+	})
+
+	// the same position as getMedianResponseTime, so Percentile(0.5) equals to the median
+	i := 0
+	processed := int64(0)
+	for _, k := range sortedKeys {
+		processed += s.ResponseTimes[k]
+		for i < len(targets) && int64(float64(s.NumRequests-1)*targets[i]) < processed {
+			result[targets[i]] = k
+			i++
+		}
+		if i == len(targets) {
+			break
+		}
+	}
+	// NumRequests is larger than the sum of ResponseTimes, use the max response time
+	for ; i < len(targets); i++ {
+		result[targets[i]] = sortedKeys[len(sortedKeys)-1]
+	}
+	return result, nil
 }
 
 func (s *statsEntry) serialize() map[string]interface{} {
@@ -299,9 +670,41 @@ func (s *statsEntry) serialize() map[string]interface{} {
 	result["response_times"] = s.ResponseTimes
 	result["num_reqs_per_sec"] = s.NumReqsPerSec
 	result["num_fail_per_sec"] = s.NumFailPerSec
+	if s.recentResponseTimes != nil {
+		result["response_time_correlation"] = lag1Correlation(s.recentResponseTimes.Last(s.recentResponseTimes.Len()))
+	}
+	if s.recentReqsPerSec != nil && s.recentFailPerSec != nil {
+		now := time.Now().Unix()
+		result["current_rps"] = s.recentReqsPerSec.rate(now)
+		result["current_fail_per_sec"] = s.recentFailPerSec.rate(now)
+	}
 	return result
 }
 
+// lag1Correlation returns the correlation of each value with the next one, using Welford's online algorithm
+// for the means and the co-moments of the pairs. It returns 0 if there are not enough values or no variance.
+func lag1Correlation(values []int64) float64 {
+	if len(values) < 3 {
+		return 0
+	}
+	var n, meanX, meanY, m2X, m2Y, coMoment float64
+	for i := 1; i < len(values); i++ {
+		x, y := float64(values[i-1]), float64(values[i])
+		n++
+		dx := x - meanX
+		meanX += dx / n
+		dy := y - meanY
+		meanY += dy / n
+		coMoment += dx * (y - meanY)
+		m2X += dx * (x - meanX)
+		m2Y += dy * (y - meanY)
+	}
+	if m2X == 0 || m2Y == 0 {
+		return 0
+	}
+	return coMoment / math.Sqrt(m2X*m2Y)
+}
+
 func (s *statsEntry) getStrippedReport() map[string]interface{} {
 	report := s.serialize()
 	s.reset()
@@ -313,10 +716,8 @@ type statsError struct {
 	method      string
 	error       string
 	occurrences int64
-}
-
-func (err *statsError) occured() {
-	err.occurrences++
+	// the details of the last failure, see Boomer.RecordFailureWithDetails
+	details map[string]string
 }
 
 func (err *statsError) toMap() map[string]interface{} {
@@ -325,5 +726,8 @@ func (err *statsError) toMap() map[string]interface{} {
 	m["name"] = err.name
 	m["error"] = err.error
 	m["occurrences"] = err.occurrences
+	if len(err.details) > 0 {
+		m["details"] = err.details
+	}
 	return m
 }
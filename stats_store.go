@@ -0,0 +1,127 @@
+package boomer
+
+import (
+	"sort"
+	"sync"
+)
+
+// ConcurrentStatsStore records the stats of requests from many goroutines without a single owner goroutine.
+// The entries are kept in a sync.Map, and each entry has its own lock, so requests of different endpoints
+// don't contend with each other. It's an alternative to the stats goroutine of the runner, which serializes
+// all the requests through channels, see Boomer.WithConcurrentStats.
+type ConcurrentStatsStore struct {
+	entries sync.Map // name+method => *lockedStatsEntry, the same key as requestStats.entries
+}
+
+type lockedStatsEntry struct {
+	sync.Mutex
+	entry *statsEntry
+	// the occurrences of the errors of the entry, keyed by the error message
+	errors map[string]int64
+}
+
+// NewConcurrentStatsStore returns an empty ConcurrentStatsStore.
+func NewConcurrentStatsStore() *ConcurrentStatsStore {
+	return &ConcurrentStatsStore{}
+}
+
+// Record logs a request, like Boomer.RecordSuccess, or Boomer.RecordFailure if isFailure is true.
+// Failures are logged without content length, and exception is the error message.
+func (s *ConcurrentStatsStore) Record(requestType, name string, responseTime, responseLength int64, isFailure bool, exception string) {
+	e := s.get(name, requestType)
+	e.Lock()
+	defer e.Unlock()
+	if isFailure {
+		e.entry.log(responseTime, 0)
+		e.entry.logError(exception)
+		e.errors[exception]++
+		return
+	}
+	e.entry.log(responseTime, responseLength)
+}
+
+func (s *ConcurrentStatsStore) get(name, method string) *lockedStatsEntry {
+	key := name + method
+	if e, ok := s.entries.Load(key); ok {
+		return e.(*lockedStatsEntry)
+	}
+	entry := &statsEntry{Name: name, Method: method}
+	entry.reset()
+	e, _ := s.entries.LoadOrStore(key, &lockedStatsEntry{entry: entry, errors: make(map[string]int64)})
+	return e.(*lockedStatsEntry)
+}
+
+// Snapshot returns copies of the entries, keyed by name+method. The store isn't reset.
+func (s *ConcurrentStatsStore) Snapshot() map[string]*statsEntry {
+	snapshot := make(map[string]*statsEntry)
+	s.entries.Range(func(key, value interface{}) bool {
+		e := value.(*lockedStatsEntry)
+		e.Lock()
+		copied := &statsEntry{Name: e.entry.Name, Method: e.entry.Method}
+		copied.reset()
+		copied.merge(e.entry)
+		e.Unlock()
+		snapshot[key.(string)] = copied
+		return true
+	})
+	return snapshot
+}
+
+// take calls fn with every entry and its errors recorded since the last call, and resets them.
+// fn is called without holding the lock of the entry.
+func (s *ConcurrentStatsStore) take(fn func(entry *statsEntry, errors map[string]int64)) {
+	s.entries.Range(func(_, value interface{}) bool {
+		e := value.(*lockedStatsEntry)
+		e.Lock()
+		entry, errors := e.entry, e.errors
+		if entry.NumRequests == 0 {
+			e.Unlock()
+			return true
+		}
+		e.entry = &statsEntry{Name: entry.Name, Method: entry.Method}
+		e.entry.reset()
+		e.errors = make(map[string]int64)
+		e.Unlock()
+		fn(entry, errors)
+		return true
+	})
+}
+
+// mergeStore merges the requests recorded to store since the last report interval, like they are logged by
+// the stats goroutine, so the failures are checked by the failure spike detector too.
+func (s *requestStats) mergeStore() {
+	if s.store == nil {
+		return
+	}
+	s.store.take(func(entry *statsEntry, errors map[string]int64) {
+		s.total.mergeRecorded(entry)
+		s.get(entry.Name, entry.Method).mergeRecorded(entry)
+		for err, occurrences := range errors {
+			s.trackError(entry.Method, entry.Name, err, occurrences, nil)
+			for i := int64(0); i < occurrences; i++ {
+				s.detectFailureSpike()
+			}
+		}
+	})
+}
+
+// mergeRecorded merges the requests of other into the entry, including the rate history.
+func (s *statsEntry) mergeRecorded(other *statsEntry) {
+	s.merge(other)
+	for _, h := range []struct {
+		history   *rateHistory
+		perSecond map[int64]int64
+	}{{s.recentReqsPerSec, other.NumReqsPerSec}, {s.recentFailPerSec, other.NumFailPerSec}} {
+		if h.history == nil {
+			continue
+		}
+		seconds := make([]int64, 0, len(h.perSecond))
+		for second := range h.perSecond {
+			seconds = append(seconds, second)
+		}
+		sort.Slice(seconds, func(i, j int) bool { return seconds[i] < seconds[j] })
+		for _, second := range seconds {
+			h.history.addN(second, h.perSecond[second])
+		}
+	}
+}
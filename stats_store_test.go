@@ -0,0 +1,217 @@
+package boomer
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// benchmarkStatsParallelism runs more than 1000 goroutines with b.RunParallel.
+const benchmarkStatsParallelism = 1024
+
+var benchmarkStatsNames = func() []string {
+	names := make([]string, 100)
+	for i := range names {
+		names[i] = fmt.Sprintf("endpoint-%d", i)
+	}
+	return names
+}()
+
+// BenchmarkRequestStatsChannel is the current implementation, all the requests are sent to the stats goroutine.
+func BenchmarkRequestStatsChannel(b *testing.B) {
+	stats := newRequestStats()
+	stats.start()
+	defer stats.close()
+	var counter uint64
+	b.SetParallelism(benchmarkStatsParallelism)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			name := benchmarkStatsNames[atomic.AddUint64(&counter, 1)%uint64(len(benchmarkStatsNames))]
+			stats.requestSuccessChan <- &requestSuccess{requestType: "http", name: name, responseTime: 10, responseLength: 100}
+		}
+	})
+}
+
+func BenchmarkConcurrentStatsStore(b *testing.B) {
+	store := NewConcurrentStatsStore()
+	var counter uint64
+	b.SetParallelism(benchmarkStatsParallelism)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			name := benchmarkStatsNames[atomic.AddUint64(&counter, 1)%uint64(len(benchmarkStatsNames))]
+			store.Record("http", name, 10, 100, false, "")
+		}
+	})
+}
+
+// benchmarkRecordParallelisms are the multipliers of GOMAXPROCS passed to b.SetParallelism,
+// to show how the throughput of the stats system scales with the goroutines recording to it.
+var benchmarkRecordParallelisms = []int{1, 2, 4, 8, 16, 32}
+
+// BenchmarkConcurrentRecordSuccess measures the throughput ceiling of the Record* methods of Boomer,
+// which send to the stats goroutine, or record to ConcurrentStatsStore with WithConcurrentStats.
+// A change to the stats system shouldn't make any of the sub-benchmarks more than 5% slower.
+//
+// RecordSuccessWithTags is measured with fixed tags, since there's no RecordSuccessWithTimestamp.
+func BenchmarkConcurrentRecordSuccess(b *testing.B) {
+	tags := map[string]string{"region": "us-east-1"}
+	recorders := []struct {
+		name            string
+		concurrentStats bool
+		record          func(boomer *Boomer, store *ConcurrentStatsStore, name string)
+	}{
+		{"RecordSuccess", false, func(boomer *Boomer, _ *ConcurrentStatsStore, name string) {
+			boomer.RecordSuccess("http", name, 10, 100)
+		}},
+		{"RecordSuccessWithTags", false, func(boomer *Boomer, _ *ConcurrentStatsStore, name string) {
+			boomer.RecordSuccessWithTags("http", name, 10, 100, tags)
+		}},
+		{"RecordFailure", false, func(boomer *Boomer, _ *ConcurrentStatsStore, name string) {
+			boomer.RecordFailure("http", name, 10, "timeout")
+		}},
+		{"RecordCustomMetric", false, func(boomer *Boomer, _ *ConcurrentStatsStore, name string) {
+			boomer.RecordCustomMetric(name, 10)
+		}},
+		{"ConcurrentStatsStore", false, func(_ *Boomer, store *ConcurrentStatsStore, name string) {
+			store.Record("http", name, 10, 100, false, "")
+		}},
+		{"RecordSuccessWithConcurrentStats", true, func(boomer *Boomer, _ *ConcurrentStatsStore, name string) {
+			boomer.RecordSuccess("http", name, 10, 100)
+		}},
+		{"RecordFailureWithConcurrentStats", true, func(boomer *Boomer, _ *ConcurrentStatsStore, name string) {
+			boomer.RecordFailure("http", name, 10, "timeout")
+		}},
+	}
+
+	for _, recorder := range recorders {
+		for _, parallelism := range benchmarkRecordParallelisms {
+			b.Run(fmt.Sprintf("%s/parallelism-%d", recorder.name, parallelism), func(b *testing.B) {
+				boomer := NewStandaloneBoomer(1, 1).WithConcurrentStats(recorder.concurrentStats)
+				boomer.localRunner = newLocalRunner(nil, nil, 1, 1)
+				boomer.setupRunner(&boomer.localRunner.runner)
+				stats := boomer.localRunner.stats
+				stats.start()
+				defer stats.close()
+				store := NewConcurrentStatsStore()
+
+				var counter uint64
+				b.SetParallelism(parallelism)
+				b.ReportAllocs()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						name := benchmarkStatsNames[atomic.AddUint64(&counter, 1)%uint64(len(benchmarkStatsNames))]
+						recorder.record(boomer, store, name)
+					}
+				})
+			})
+		}
+	}
+}
+
+var _ = Describe("Test concurrent stats store", func() {
+
+	It("test record", func() {
+		store := NewConcurrentStatsStore()
+		store.Record("http", "login", 10, 100, false, "")
+		store.Record("http", "login", 30, 300, false, "")
+		store.Record("http", "login", 20, 500, true, "timeout")
+		store.Record("http", "logout", 5, 10, false, "")
+
+		snapshot := store.Snapshot()
+		Expect(snapshot).To(HaveLen(2))
+		login := snapshot["loginhttp"]
+		Expect(login.Name).To(Equal("login"))
+		Expect(login.Method).To(Equal("http"))
+		Expect(login.NumRequests).To(BeEquivalentTo(3))
+		Expect(login.NumFailures).To(BeEquivalentTo(1))
+		Expect(login.TotalResponseTime).To(BeEquivalentTo(60))
+		Expect(login.MinResponseTime).To(BeEquivalentTo(10))
+		Expect(login.MaxResponseTime).To(BeEquivalentTo(30))
+		Expect(login.TotalContentLength).To(BeEquivalentTo(400))
+		Expect(login.ResponseTimes).To(Equal(map[int64]int64{10: 1, 20: 1, 30: 1}))
+		Expect(snapshot["logouthttp"].NumRequests).To(BeEquivalentTo(1))
+	})
+
+	It("test snapshot is a copy", func() {
+		store := NewConcurrentStatsStore()
+		store.Record("http", "login", 10, 100, false, "")
+		snapshot := store.Snapshot()
+		store.Record("http", "login", 10, 100, false, "")
+		Expect(snapshot["loginhttp"].NumRequests).To(BeEquivalentTo(1))
+		Expect(snapshot["loginhttp"].ResponseTimes[10]).To(BeEquivalentTo(1))
+		Expect(store.Snapshot()["loginhttp"].NumRequests).To(BeEquivalentTo(2))
+	})
+
+	It("test concurrent record", func() {
+		store := NewConcurrentStatsStore()
+		wg := sync.WaitGroup{}
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					store.Record("http", benchmarkStatsNames[j%10], int64(i), 1, j%2 == 0, "error")
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		snapshot := store.Snapshot()
+		Expect(snapshot).To(HaveLen(10))
+		total := &statsEntry{}
+		total.reset()
+		for _, entry := range snapshot {
+			total.merge(entry)
+		}
+		Expect(total.NumRequests).To(BeEquivalentTo(10000))
+		Expect(total.NumFailures).To(BeEquivalentTo(5000))
+		Expect(total.TotalContentLength).To(BeEquivalentTo(5000))
+	})
+
+	It("test records of WithConcurrentStats are reported", func() {
+		b := NewStandaloneBoomer(1, 1).WithConcurrentStats(true)
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+		b.setupRunner(&b.localRunner.runner)
+		stats := b.localRunner.stats
+		Expect(stats.store).NotTo(BeNil())
+		spikes := 0
+		stats.failureSpike = newFailureSpikeDetector(time.Minute, 1)
+		stats.onFailureSpike = func(int64) { spikes++ }
+
+		b.RecordSuccess("http", "login", 10, 100)
+		b.RecordSuccess("http", "login", 30, 100)
+		b.RecordFailure("http", "login", 20, "timeout")
+		b.RecordFailure("http", "login", 20, "timeout")
+
+		data := stats.collectReportData()
+		entries := data["stats"].([]interface{})
+		Expect(entries).To(HaveLen(1))
+		login := entries[0].(map[string]interface{})
+		Expect(login["name"]).To(Equal("login"))
+		Expect(login["method"]).To(Equal("http"))
+		Expect(login["num_requests"]).To(BeEquivalentTo(4))
+		Expect(login["num_failures"]).To(BeEquivalentTo(2))
+		Expect(login["min_response_time"]).To(BeEquivalentTo(10))
+		Expect(login["max_response_time"]).To(BeEquivalentTo(30))
+		Expect(data["stats_total"].(map[string]interface{})["num_requests"]).To(BeEquivalentTo(4))
+		errors := data["errors"].(map[string]map[string]interface{})
+		Expect(errors).To(HaveLen(1))
+		for _, err := range errors {
+			Expect(err["error"]).To(Equal("timeout"))
+			Expect(err["occurrences"]).To(BeEquivalentTo(2))
+		}
+		Expect(spikes).To(BeNumerically(">", 0))
+
+		// the store is reset after each report interval
+		data = stats.collectReportData()
+		Expect(data["stats"].([]interface{})).To(BeEmpty())
+	})
+})
@@ -24,6 +24,36 @@ func BenchmarkLogError(b *testing.B) {
 	}
 }
 
+func newPercentileBenchmarkEntry() *statsEntry {
+	entry := &statsEntry{Name: "percentile", Method: "http"}
+	entry.reset()
+	for i := int64(1); i <= 10000; i++ {
+		entry.ResponseTimes[i] = i % 7
+		entry.NumRequests += i % 7
+	}
+	return entry
+}
+
+var benchmarkPercentiles = []float64{0.5, 0.9, 0.95, 0.99, 0.999}
+
+func BenchmarkPercentileSeparately(b *testing.B) {
+	entry := newPercentileBenchmarkEntry()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, target := range benchmarkPercentiles {
+			entry.Percentile(target)
+		}
+	}
+}
+
+func BenchmarkPercentilesInBatch(b *testing.B) {
+	entry := newPercentileBenchmarkEntry()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entry.Percentiles(benchmarkPercentiles)
+	}
+}
+
 var _ = Describe("Test states", func() {
 
 	It("test log request", func() {
@@ -81,6 +111,29 @@ var _ = Describe("Test states", func() {
 		Expect(err400.occurrences).To(BeEquivalentTo(2))
 	})
 
+	It("test max errors tracked", func() {
+		newStats := newRequestStats()
+		newStats.maxErrorsTracked = 2
+		newStats.logError("http", "failure", "500 error")
+		newStats.logError("http", "failure", "400 error")
+		newStats.logError("http", "failure", "400 error")
+		Expect(newStats.errors).To(HaveLen(2))
+		Expect(newStats.errorsTruncated).To(BeFalse())
+
+		newStats.logError("http", "failure", "connection 1 reset")
+		newStats.logError("http", "failure", "connection 2 reset")
+		Expect(newStats.errors).To(HaveLen(3))
+		Expect(newStats.total.NumFailures).To(BeEquivalentTo(5))
+
+		truncated := newStats.errors[MD5("http", "failure", "[truncated]")]
+		Expect(truncated.occurrences).To(BeEquivalentTo(2))
+
+		result := newStats.collectReportData()
+		Expect(result).To(HaveKeyWithValue("errors_truncated", true))
+		Expect(newStats.errors).To(BeEmpty())
+		Expect(newStats.errorsTruncated).To(BeFalse())
+	})
+
 	It("test clear all", func() {
 		newStats := newRequestStats()
 		newStats.logRequest("http", "success", 1, 20)
@@ -94,7 +147,9 @@ var _ = Describe("Test states", func() {
 		defer newStats.close()
 		newStats.logRequest("http", "success", 1, 20)
 		newStats.clearStatsChan <- true
-		Expect(newStats.total.NumRequests).To(BeEquivalentTo(0))
+		// collected by the stats goroutine after clearing
+		data := newStats.collect()
+		Expect(data["stats_total"]).To(HaveKeyWithValue("num_requests", int64(0)))
 	})
 
 	It("test serialize stats", func() {
@@ -114,6 +169,135 @@ var _ = Describe("Test states", func() {
 		Expect(entry.NumFailures).To(BeEquivalentTo(0))
 	})
 
+	It("test serialize stats with zero count endpoints", func() {
+		newStats := newRequestStats()
+		newStats.zeroCountEndpoints = map[string]string{"success": "", "never": "", "registered": "GET"}
+		newStats.logRequest("http", "success", 1, 20)
+
+		names := func() map[string]int64 {
+			result := make(map[string]int64)
+			for _, stat := range newStats.serializeStats() {
+				entry, err := deserializeStatsEntry(stat)
+				Expect(err).NotTo(HaveOccurred())
+				result[entry.Method+" "+entry.Name] = entry.NumRequests
+			}
+			return result
+		}
+		Expect(names()).To(Equal(map[string]int64{"http success": 1, " never": 0, "GET registered": 0}))
+		// reported again with zero values after the entries are reset
+		Expect(names()).To(Equal(map[string]int64{"http success": 0, " never": 0, "GET registered": 0}))
+		// the method of the first request replaces the unknown one, so the endpoint isn't reported twice
+		newStats.logRequest("http", "never", 1, 20)
+		newStats.logRequest("POST", "registered", 1, 20)
+		Expect(names()).To(Equal(map[string]int64{"http success": 0, "http never": 1, "GET registered": 0, "POST registered": 1}))
+		Expect(names()).To(Equal(map[string]int64{"http success": 0, "http never": 0, "GET registered": 0, "POST registered": 0}))
+	})
+
+	It("test percentiles", func() {
+		newStats := newRequestStats()
+		for i := int64(1); i <= 100; i++ {
+			newStats.logRequest("http", "success", i, 0)
+		}
+		entry := newStats.get("success", "http")
+
+		result, err := entry.Percentiles([]float64{0, 0.5, 0.9, 0.99, 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(map[float64]int64{0: 1, 0.5: 50, 0.9: 90, 0.99: 99, 1: 100}))
+		Expect(entry.Percentile(0.5)).To(Equal(getMedianResponseTime(entry.NumRequests, entry.ResponseTimes)))
+
+		empty := &statsEntry{}
+		result, err = empty.Percentiles([]float64{0.5})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(map[float64]int64{0.5: 0}))
+		result, err = entry.Percentiles(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(BeEmpty())
+
+		_, err = entry.Percentiles([]float64{0.5, 1.5})
+		Expect(err).To(MatchError("percentile 1.5 is not in [0, 1]"))
+		_, err = entry.Percentiles([]float64{-0.1, 0.5})
+		Expect(err).To(MatchError("percentile -0.1 is not in [0, 1]"))
+		_, err = entry.Percentiles([]float64{0.9, 0.5})
+		Expect(err).To(MatchError("percentiles [0.9 0.5] are not sorted"))
+		Expect(entry.Percentile(2)).To(BeZero())
+	})
+
+	It("test lag-1 correlation", func() {
+		Expect(lag1Correlation([]int64{10, 100, 10, 100, 10, 100})).To(BeNumerically("~", -1, 0.01))
+		Expect(lag1Correlation([]int64{10, 20, 30, 40, 50, 60})).To(BeNumerically("~", 1, 0.01))
+		Expect(lag1Correlation([]int64{10, 10, 10, 10})).To(BeZero())
+		Expect(lag1Correlation([]int64{10, 20})).To(BeZero())
+	})
+
+	It("test serialize stats with request correlation", func() {
+		newStats := newRequestStats()
+		newStats.correlationWindow = 4
+		for _, responseTime := range []int64{1, 2, 3, 100, 10, 100, 10} {
+			newStats.logRequest("http", "success", responseTime, 0)
+		}
+
+		entry, err := deserializeStatsEntry(newStats.serializeStats()[0])
+		Expect(err).NotTo(HaveOccurred())
+		// only the last 4 response times are used
+		Expect(entry.ResponseTimeCorrelation).To(BeNumerically("~", -1, 0.01))
+
+		newStats.correlationWindow = 0
+		newStats.logRequest("http", "other", 1, 0)
+		Expect(newStats.get("other", "http").serialize()).NotTo(HaveKey("response_time_correlation"))
+	})
+
+	It("test rate history", func() {
+		h := newRateHistory(4)
+		Expect(h.rate(100)).To(BeZero())
+
+		h.add(100)
+		h.add(100)
+		// the current second is still being counted
+		Expect(h.rate(100)).To(BeZero())
+
+		h.add(101)
+		h.add(103)
+		h.add(103)
+		h.add(103)
+		// 2, 1 and 0 in the seconds 100..102
+		Expect(h.rate(103)).To(BeEquivalentTo(1))
+		// 2, 1, 0 and 3 in the seconds 100..103
+		Expect(h.rate(104)).To(BeEquivalentTo(1))
+		Expect(h.rate(110)).To(BeZero())
+	})
+
+	It("test windowed rps across report intervals", func() {
+		newStats := newRequestStats()
+		newStats.setRPSWindow(10)
+		now := time.Now().Unix()
+		// 30 requests per second for 3 seconds, then no requests for 6 seconds, in 3 report intervals
+		for second := now - 9; second < now-6; second++ {
+			for i := 0; i < 30; i++ {
+				newStats.total.recentReqsPerSec.add(second)
+			}
+		}
+		newStats.collectReportData()
+		newStats.collectReportData()
+		data := newStats.collectReportData()
+		data["user_count"] = int32(1)
+
+		output, err := convertData(data)
+		Expect(err).NotTo(HaveOccurred())
+		// 90 requests in 9 seconds, or 10 seconds if the clock ticks
+		Expect(output.TotalRPS).To(BeNumerically("~", 10, 1))
+	})
+
+	It("test report interval", func() {
+		newStats := newRequestStats()
+		Expect(newStats.nextReportInterval()).To(Equal(slaveReportInterval))
+
+		newStats.reportInterval = 100 * time.Millisecond
+		newStats.start()
+		defer newStats.close()
+		Eventually(newStats.messageToRunnerChan).WithTimeout(time.Second).Should(Receive())
+		Eventually(newStats.messageToRunnerChan).WithTimeout(time.Second).Should(Receive())
+	})
+
 	It("test serialize errors", func() {
 		newStats := newRequestStats()
 		newStats.logError("http", "failure", "500 error")
@@ -130,6 +314,20 @@ var _ = Describe("Test states", func() {
 		}))
 	})
 
+	It("test serialize errors with details", func() {
+		newStats := newRequestStats()
+		newStats.logErrorWithDetails("http", "failure", "500 error", map[string]string{"request_id": "foo"})
+		newStats.logErrorWithDetails("http", "failure", "500 error", map[string]string{"request_id": "bar"})
+		newStats.logError("http", "failure", "500 error")
+		serialized := newStats.serializeErrors()
+
+		Expect(serialized).To(HaveLen(1))
+		for _, e := range serialized {
+			Expect(e["occurrences"]).To(BeEquivalentTo(3))
+			Expect(e["details"]).To(Equal(map[string]string{"request_id": "bar"}))
+		}
+	})
+
 	It("test collect report data", func() {
 		newStats := newRequestStats()
 		newStats.logRequest("http", "success", 2, 30)
@@ -141,6 +339,30 @@ var _ = Describe("Test states", func() {
 		Expect(result).To(HaveKey("errors"))
 	})
 
+	It("test drain", func() {
+		newStats := newRequestStats()
+		newStats.requestSuccessChan <- &requestSuccess{requestType: "http", name: "success", responseTime: 2, responseLength: 30}
+		newStats.requestFailureChan <- &requestFailure{requestType: "http", name: "failure", responseTime: 1, error: "500 error"}
+		newStats.customMetricChan <- &customMetric{name: "queue_size", value: 10}
+		// the drained failures are checked by the failure spike detector too
+		var spikes []int64
+		newStats.failureSpike = newFailureSpikeDetector(time.Minute, 0)
+		newStats.onFailureSpike = func(failures int64) {
+			spikes = append(spikes, failures)
+		}
+
+		newStats.drain()
+		Expect(spikes).To(Equal([]int64{1}))
+		Expect(newStats.requestSuccessChan).To(BeEmpty())
+		Expect(newStats.requestFailureChan).To(BeEmpty())
+		Expect(newStats.customMetricChan).To(BeEmpty())
+		Expect(newStats.total.NumRequests).To(BeEquivalentTo(2))
+		Expect(newStats.total.NumFailures).To(BeEquivalentTo(1))
+		metrics := newStats.collectReportData()["custom_metrics"].(map[string]*CustomMetricEntry)
+		Expect(metrics).To(HaveKey("queue_size"))
+		Expect(metrics["queue_size"].Count).To(BeEquivalentTo(1))
+	})
+
 	It("test stats start", func() {
 		newStats := newRequestStats()
 		newStats.start()
@@ -0,0 +1,63 @@
+package boomer
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// runningTask is the task which a user is executing, tracked by the stuck detector.
+type runningTask struct {
+	name      string
+	startedAt time.Time
+	reported  bool // only accessed by the stuck detector goroutine
+}
+
+// trackRunningTask records the task as running for the user in ctx, and returns a function to call when it's done.
+func (r *runner) trackRunningTask(ctx context.Context, task *Task) func() {
+	userID, ok := UserIDFromContext(ctx)
+	if !ok {
+		return func() {}
+	}
+	running := &runningTask{name: task.Name, startedAt: time.Now()}
+	r.runningTasks.Store(userID, running)
+	return func() {
+		// the id may be reused by a new user, if this one is stopped while running the task
+		r.runningTasks.CompareAndDelete(userID, running)
+	}
+}
+
+// startStuckDetector checks every half of stuckThreshold if any user has been executing the same task
+// for longer than stuckThreshold. Each stuck task execution is logged and counted once.
+func (r *runner) startStuckDetector() {
+	if r.stuckThreshold <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(r.stuckThreshold / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.detectStuckTasks()
+			case <-r.shutdownChan:
+				return
+			}
+		}
+	}()
+}
+
+func (r *runner) detectStuckTasks() {
+	r.runningTasks.Range(func(key, value interface{}) bool {
+		task := value.(*runningTask)
+		if task.reported {
+			return true
+		}
+		if d := time.Since(task.startedAt); d > r.stuckThreshold {
+			task.reported = true
+			atomic.AddInt64(&r.stuckTasks, 1)
+			r.logger.Printf("User %d is stuck in task %s for %v\n", key.(int), task.name, d.Truncate(time.Millisecond))
+		}
+		return true
+	})
+}
@@ -0,0 +1,61 @@
+package boomer
+
+import (
+	"context"
+	"io"
+	"log"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Test stuck detector", func() {
+
+	It("test detect stuck tasks", func() {
+		buf := gbytes.NewBuffer()
+		r := newLocalRunner(nil, nil, 1, 1)
+		r.setLogger(log.New(buf, "", 0))
+		NewStandaloneBoomer(1, 1).WithStuckDetector(time.Second).setupRunner(&r.runner)
+
+		done := r.trackRunningTask(context.WithValue(context.Background(), userIDContextKey{}, 3), &Task{Name: "slow"})
+		r.trackRunningTask(context.WithValue(context.Background(), userIDContextKey{}, 4), &Task{Name: "fast"})
+		// a task without user id isn't tracked
+		r.trackRunningTask(context.Background(), &Task{Name: "orphan"})()
+		value, _ := r.runningTasks.Load(3)
+		value.(*runningTask).startedAt = time.Now().Add(-2 * time.Second)
+
+		r.detectStuckTasks()
+		Expect(buf).To(gbytes.Say(`User 3 is stuck in task slow for 2(\.\d+)?s`))
+		Expect(r.stuckTasks).To(BeEquivalentTo(1))
+
+		// logged once
+		r.detectStuckTasks()
+		Expect(buf.Contents()).NotTo(ContainSubstring("fast"))
+		Expect(r.stuckTasks).To(BeEquivalentTo(1))
+
+		done()
+		_, ok := r.runningTasks.Load(3)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("test stuck detector", func() {
+		buf := gbytes.NewBuffer()
+		b := NewStandaloneBoomer(1, 1).WithStuckDetector(50 * time.Millisecond).WithRunTime(500 * time.Millisecond).
+			WithLogger(log.New(io.MultiWriter(buf, GinkgoWriter), "", 0))
+		b.WithStatsSnapshotInterval(100 * time.Millisecond)
+		output := NewInMemoryOutput()
+		b.AddOutput(output)
+		b.Run(&Task{
+			Name: "sleepy",
+			Fn: func() {
+				time.Sleep(200 * time.Millisecond)
+			},
+		})
+
+		Expect(buf).To(gbytes.Say(`User 0 is stuck in task sleepy for \d+ms`))
+		Expect(output.LastSnapshot().StuckDetectorTriggered).To(BeNumerically(">=", 1))
+		Expect(output.LastSnapshot().StuckDetectorTriggered).To(BeNumerically("<=", 3))
+	})
+})
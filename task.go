@@ -1,5 +1,14 @@
 package boomer
 
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
 // Task is like the "Locust object" in locust, the python version.
 // When boomer receives a start message from master, it will spawn several goroutines to run Task.Fn.
 // But users can keep some information in the python version, they can't do the same things in boomer.
@@ -8,6 +17,97 @@ type Task struct {
 	// The weight is used to distribute goroutines over multiple tasks.
 	Weight int
 	// Fn is called by the goroutines allocated to this task, in a loop.
-	Fn   func()
-	Name string
+	Fn func()
+	// FnWithContext is like Fn, but receives the context of the goroutine, which carries per-user data.
+	// If FnWithContext is set, it is called instead of Fn.
+	FnWithContext func(ctx context.Context)
+	// FnWithError is like FnWithContext, but returns the unexpected errors, which are not recorded by RecordFailure.
+	// If FnWithError is set, it is called instead of FnWithContext and Fn.
+	// See also Boomer.WithFailOnFirstTaskError.
+	FnWithError func(ctx context.Context) error
+	Name        string
+	// RequestType is the request type of the endpoint hit by the task, it's matched with Name by
+	// Boomer.WithTaskRateLimit.
+	RequestType string
+
+	// limits the executions of the task across all the users, nil means no limit.
+	throttle *rate.Limiter
+}
+
+// taskEndpoint identifies the tasks by their request type and name, see Boomer.WithTaskRateLimit.
+type taskEndpoint struct {
+	requestType string
+	name        string
+}
+
+// WithGlobalThrottle runs the task at most once every d, no matter how many users are running it.
+// Users wait for their turn, which fits tasks like resetting the database state.
+// It limits how often the task starts, so the task may still overlap with itself if it takes longer than d.
+// It must be called before the test is started.
+func (t *Task) WithGlobalThrottle(d time.Duration) *Task {
+	if d <= 0 {
+		t.throttle = nil
+		return t
+	}
+	t.throttle = rate.NewLimiter(rate.Every(d), 1)
+	return t
+}
+
+func (t *Task) run(ctx context.Context) error {
+	if t.FnWithError != nil {
+		return t.FnWithError(ctx)
+	}
+	if t.FnWithContext != nil {
+		t.FnWithContext(ctx)
+		return nil
+	}
+	t.Fn()
+	return nil
+}
+
+type userDataContextKey string
+
+// UserDataFromContext returns the data created by the factory registered with Boomer.WithUserData for key.
+// It returns nil if there is no such data in ctx.
+func UserDataFromContext(ctx context.Context, key string) interface{} {
+	return ctx.Value(userDataContextKey(key))
+}
+
+type userIDContextKey struct{}
+
+// UserIDFromContext returns the id of the user goroutine, starting from 0.
+// The ids of stopped users are reused by users spawned later.
+func UserIDFromContext(ctx context.Context) (userID int, ok bool) {
+	userID, ok = ctx.Value(userIDContextKey{}).(int)
+	return userID, ok
+}
+
+// consistentHashingContextKey carries the fn of Boomer.WithConsistentHashing to the task sets run by the user.
+type consistentHashingContextKey struct{}
+
+// taskSetup is closed when the task finishes its setup, see Boomer.WithTaskSetupTimeout.
+type taskSetup struct {
+	done chan struct{}
+	once sync.Once
+	// set to 1 when the setup times out, before the context of the task is canceled
+	timedOut int32
+}
+
+type taskSetupContextKey struct{}
+
+// TaskSetupDone marks the end of the setup phase of the task, which is limited by Boomer.WithTaskSetupTimeout.
+// It's called by BoomerTransport when a request is sent with the context of the task, so tasks using
+// BoomerTransport don't need to call it. It can be called more than once, and does nothing if the timeout isn't set.
+func TaskSetupDone(ctx context.Context) {
+	if setup, ok := ctx.Value(taskSetupContextKey{}).(*taskSetup); ok {
+		setup.once.Do(func() { close(setup.done) })
+	}
+}
+
+// TaskTimedOut reports whether the task of ctx has timed out in its setup, see Boomer.WithTaskSetupTimeout.
+// The timeout is recorded as a failure, so the results recorded by the task afterwards are counted twice,
+// BoomerTransport and BoomerGRPCInterceptor drop them, and tasks recording their own results should check it.
+func TaskTimedOut(ctx context.Context) bool {
+	setup, ok := ctx.Value(taskSetupContextKey{}).(*taskSetup)
+	return ok && atomic.LoadInt32(&setup.timedOut) == 1
 }
@@ -1,7 +1,12 @@
 package boomer
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
 	"sync"
+	"time"
 )
 
 // TaskSet is an experimental feature, the API is not stabilized.
@@ -28,8 +33,11 @@ type roundRobinTask struct {
 func newRoundRobinTask(task *Task) *roundRobinTask {
 	rrTask := &roundRobinTask{}
 	rrTask.Fn = task.Fn
+	rrTask.FnWithContext = task.FnWithContext
+	rrTask.FnWithError = task.FnWithError
 	rrTask.Weight = task.Weight
 	rrTask.Name = task.Name
+	rrTask.RequestType = task.RequestType
 	rrTask.currentWeight = 0
 	rrTask.effectiveWeight = task.Weight
 	return rrTask
@@ -108,10 +116,159 @@ func (ts *SmoothRoundRobinTaskSet) GetWeight() (weight int) {
 }
 
 // Run will pick up a task in the task set smoothly and run.
-// It can is used as a Task.Fn.
+// It can be used as a Task.Fn.
 func (ts *SmoothRoundRobinTaskSet) Run() {
 	task := ts.GetTask()
 	if task != nil {
-		task.Fn()
+		task.run(context.Background())
+	}
+}
+
+// ConcurrentTaskSet runs all of its tasks concurrently in one Run, like a browser which fetches
+// resources with several parallel connections.
+// The number of tasks running at the same time is limited by parallelism.
+type ConcurrentTaskSet struct {
+	// The weight of taskset
+	weight int
+
+	parallelism int
+	tasks       []*Task
+	lock        sync.RWMutex
+}
+
+// NewConcurrentTaskSet returns a new ConcurrentTaskSet.
+// If parallelism is <= 0, it will be set to 1.
+func NewConcurrentTaskSet(parallelism int, tasks ...*Task) *ConcurrentTaskSet {
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	ts := &ConcurrentTaskSet{
+		parallelism: parallelism,
+		tasks:       make([]*Task, 0, len(tasks)),
+	}
+	for _, task := range tasks {
+		ts.AddTask(task)
+	}
+	return ts
+}
+
+// AddTask add a Task to the Concurrent TaskSet.
+func (ts *ConcurrentTaskSet) AddTask(task *Task) {
+	ts.lock.Lock()
+	ts.tasks = append(ts.tasks, task)
+	ts.lock.Unlock()
+}
+
+// SetWeight sets the weight of the task set.
+func (ts *ConcurrentTaskSet) SetWeight(weight int) {
+	ts.weight = weight
+}
+
+// GetWeight returns the weight of the task set.
+func (ts *ConcurrentTaskSet) GetWeight() (weight int) {
+	return ts.weight
+}
+
+// Run will run all the tasks in the task set, at most parallelism of them at the same time,
+// and wait for all of them to complete.
+// It can be used as a Task.Fn, use RunWithContext as a Task.FnWithContext.
+func (ts *ConcurrentTaskSet) Run() {
+	ts.RunWithContext(context.Background())
+}
+
+// RunWithContext is like Run, but passes ctx to the tasks.
+// If a task panics, the panic is recovered and recorded as a failure with the task name by RecordFailure,
+// so the other tasks keep running.
+func (ts *ConcurrentTaskSet) RunWithContext(ctx context.Context) {
+	ts.lock.RLock()
+	tasks := ts.tasks
+	ts.lock.RUnlock()
+
+	semaphore := make(chan struct{}, ts.parallelism)
+	wg := sync.WaitGroup{}
+	wg.Add(len(tasks))
+	for _, task := range tasks {
+		semaphore <- struct{}{}
+		go func(t *Task) {
+			start := time.Now()
+			defer func() {
+				if err := recover(); err != nil {
+					os.Stderr.Write([]byte(fmt.Sprintf("%v\n", err)))
+					os.Stderr.Write(debug.Stack())
+					RecordFailure("task", t.Name, time.Since(start).Milliseconds(), fmt.Sprintf("%v", err))
+				}
+				<-semaphore
+				wg.Done()
+			}()
+			t.run(ctx)
+		}(task)
+	}
+	wg.Wait()
+}
+
+// SequentialTaskSet runs all of its tasks one after another in one Run, like the steps of a user journey.
+// With Boomer.WithConsistentHashing, RunWithContext sticks each user to one of the tasks, and runs only that task.
+type SequentialTaskSet struct {
+	// The weight of taskset
+	weight int
+
+	name  string
+	tasks []*Task
+	lock  sync.RWMutex
+}
+
+// NewSequentialTaskSet returns a new SequentialTaskSet, name is passed to the consistent hashing.
+func NewSequentialTaskSet(name string, tasks ...*Task) *SequentialTaskSet {
+	ts := &SequentialTaskSet{
+		name:  name,
+		tasks: make([]*Task, 0, len(tasks)),
+	}
+	for _, task := range tasks {
+		ts.AddTask(task)
+	}
+	return ts
+}
+
+// AddTask add a Task to the Sequential TaskSet.
+func (ts *SequentialTaskSet) AddTask(task *Task) {
+	ts.lock.Lock()
+	ts.tasks = append(ts.tasks, task)
+	ts.lock.Unlock()
+}
+
+// SetWeight sets the weight of the task set.
+func (ts *SequentialTaskSet) SetWeight(weight int) {
+	ts.weight = weight
+}
+
+// GetWeight returns the weight of the task set.
+func (ts *SequentialTaskSet) GetWeight() (weight int) {
+	return ts.weight
+}
+
+// Run will run all the tasks in the task set in order.
+// It can be used as a Task.Fn, use RunWithContext as a Task.FnWithContext.
+func (ts *SequentialTaskSet) Run() {
+	ts.RunWithContext(context.Background())
+}
+
+// RunWithContext is like Run, but passes ctx to the tasks. If ctx is the context of a user and
+// Boomer.WithConsistentHashing is set, only the task picked for the user is run.
+func (ts *SequentialTaskSet) RunWithContext(ctx context.Context) {
+	ts.lock.RLock()
+	tasks := ts.tasks
+	ts.lock.RUnlock()
+	if len(tasks) == 0 {
+		return
+	}
+
+	fn, hashing := ctx.Value(consistentHashingContextKey{}).(func(userID int, requestType, name string) int)
+	userID, ok := UserIDFromContext(ctx)
+	if hashing && ok {
+		tasks[consistentHashIndex(fn, userID, "taskset", ts.name, len(tasks))].run(ctx)
+		return
+	}
+	for _, task := range tasks {
+		task.run(ctx)
 	}
 }
@@ -1,6 +1,11 @@
 package boomer
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -107,4 +112,118 @@ var _ = Describe("Test taskset", func() {
 		Expect(results).To(BeEquivalentTo(expected))
 	})
 
+	It("test concurrent taskset run", func() {
+		calls := int64(0)
+		newTask := func(name string) *Task {
+			return &Task{
+				Name: name,
+				Fn: func() {
+					atomic.AddInt64(&calls, 1)
+					time.Sleep(100 * time.Millisecond)
+				},
+			}
+		}
+
+		elapsed := func(ts *ConcurrentTaskSet) time.Duration {
+			start := time.Now()
+			ts.Run()
+			return time.Since(start)
+		}
+
+		serial := elapsed(NewConcurrentTaskSet(1, newTask("A"), newTask("B")))
+		parallel := elapsed(NewConcurrentTaskSet(2, newTask("A"), newTask("B")))
+
+		Expect(atomic.LoadInt64(&calls)).To(BeEquivalentTo(4))
+		Expect(float64(serial) / float64(parallel)).To(BeNumerically("~", 2, 0.4))
+	})
+
+	It("test concurrent taskset recovers panics and passes the context", func() {
+		type ctxKey struct{}
+		var got interface{}
+		ts := NewConcurrentTaskSet(2,
+			&Task{Name: "panic", Fn: func() { panic("boom") }},
+			&Task{Name: "ctx", FnWithContext: func(ctx context.Context) { got = ctx.Value(ctxKey{}) }},
+		)
+		Expect(func() {
+			ts.RunWithContext(context.WithValue(context.Background(), ctxKey{}, "user-1"))
+		}).NotTo(Panic())
+		Expect(got).To(Equal("user-1"))
+	})
+
+	It("test concurrent taskset weight", func() {
+		ts := NewConcurrentTaskSet(0)
+		ts.SetWeight(3)
+		Expect(ts.GetWeight()).To(Equal(3))
+		Expect(ts.parallelism).To(Equal(1))
+
+		ts.Run()
+	})
+
+	It("test sequential taskset run", func() {
+		var results []string
+		newTask := func(name string) *Task {
+			return &Task{
+				Name: name,
+				FnWithContext: func(ctx context.Context) {
+					results = append(results, name)
+				},
+			}
+		}
+		ts := NewSequentialTaskSet("journey", newTask("A"), newTask("B"))
+		ts.AddTask(newTask("C"))
+		ts.SetWeight(2)
+		Expect(ts.GetWeight()).To(Equal(2))
+
+		ts.Run()
+		ts.RunWithContext(context.WithValue(context.Background(), userIDContextKey{}, 1))
+		Expect(results).To(Equal([]string{"A", "B", "C", "A", "B", "C"}))
+	})
+
+	It("test sequential taskset with consistent hashing", func() {
+		var lock sync.Mutex
+		usersOfTasks := map[string]map[int]int{}
+		newTask := func(name string) *Task {
+			usersOfTasks[name] = map[int]int{}
+			return &Task{
+				Name: name,
+				FnWithContext: func(ctx context.Context) {
+					userID, _ := UserIDFromContext(ctx)
+					lock.Lock()
+					usersOfTasks[name][userID]++
+					lock.Unlock()
+					time.Sleep(time.Millisecond)
+				},
+			}
+		}
+		ts := NewSequentialTaskSet("shards", newTask("A"), newTask("B"), newTask("C"))
+
+		b := NewStandaloneBoomer(10, 10).WithConsistentHashing(func(userID int, requestType, name string) int {
+			if requestType == "taskset" && name == "shards" {
+				return userID % 3
+			}
+			return 0
+		})
+		runner := newLocalRunner([]*Task{{Name: "shards", Weight: 1, FnWithContext: ts.RunWithContext}}, nil, 10, 10)
+		b.setupRunner(&runner.runner)
+		defer runner.shutdown()
+
+		runner.spawnWorkers(10, nil)
+		time.Sleep(200 * time.Millisecond)
+		runner.stop()
+
+		lock.Lock()
+		defer lock.Unlock()
+		users := func(name string) []int {
+			ids := []int{}
+			for userID, runs := range usersOfTasks[name] {
+				// each user runs the set again and again, but always lands on the same task
+				Expect(runs).To(BeNumerically(">", 1))
+				ids = append(ids, userID)
+			}
+			return ids
+		}
+		Expect(users("A")).To(ConsistOf(0, 3, 6, 9))
+		Expect(users("B")).To(ConsistOf(1, 4, 7))
+		Expect(users("C")).To(ConsistOf(2, 5, 8))
+	})
 })
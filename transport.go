@@ -0,0 +1,659 @@
+package boomer
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quic-go/quic-go"
+	"golang.org/x/net/http2"
+)
+
+// BoomerTransport is a http.RoundTripper which records the result of every request to boomer.
+// The request type is the HTTP method and the name is the URL path.
+// Requests with a status code >= 400 are recorded as failures.
+type BoomerTransport struct {
+	inner  http.RoundTripper
+	client *http.Client
+	boomer *Boomer
+
+	tcpFastOpen       bool
+	tcpFastOpenHits   int64
+	tcpFastOpenMisses int64
+
+	// the negotiated protocol is tracked if http2, alpn or http3 is enabled
+	http2            bool
+	alpn             bool
+	http2FallbackLog int32
+	http3            bool
+	quicConfig       *quic.Config
+
+	tlsFingerprinting bool
+
+	connectionReuseTracking bool
+	connectionsReused       int64
+	connectionsNew          int64
+
+	requestLog *requestLog
+
+	faultInjection         *faultInjection
+	endpointFaultInjection map[string]*faultInjection // keyed by method and path
+
+	// adds a unique id header to every request if requestIDGenerator is set
+	requestIDGenerator func() string
+	requestIDHeader    string
+
+	// the number of connections established to prewarmURL before the users are spawned
+	prewarmConnections int
+	prewarmURL         string
+
+	// requests with retryStatusCodes are sent up to retryMaxAttempts times, with exponential backoff from retryBackoff
+	retryStatusCodes map[int]bool
+	retryMaxAttempts int
+	retryBackoff     time.Duration
+
+	// called with the failed requests, with the response bodies captured by responseBodyRecorder if it's set
+	errorSampler         func(sample *ErrorSample)
+	responseBodyRecorder *responseBodyRecorder
+
+	logger *log.Logger
+}
+
+const defaultRequestIDHeader = "X-Request-ID"
+
+// FailureDetailRequestID is the key of the request id in the details of the failures recorded by BoomerTransport,
+// see Boomer.RecordFailureWithDetails.
+const FailureDetailRequestID = "request_id"
+
+const defaultHTTPRetryBackoff = 100 * time.Millisecond
+
+// HTTPRetryTotalTimeMetric is the custom metric of the total time in milliseconds of the requests retried
+// by BoomerTransport.WithHTTPRetry, including all the attempts and the backoff between them.
+const HTTPRetryTotalTimeMetric = "boomer.http.retry_total_time_ms"
+
+// NewBoomerTransport returns a BoomerTransport, which sends requests with http.DefaultTransport
+// and records the results to the defaultBoomer.
+func NewBoomerTransport() *BoomerTransport {
+	return &BoomerTransport{
+		inner:  http.DefaultTransport,
+		logger: log.Default(),
+	}
+}
+
+// WithLogger allows user to use their own logger.
+// If the logger is nil, it will not take effect.
+func (t *BoomerTransport) WithLogger(logger *log.Logger) *BoomerTransport {
+	if logger != nil {
+		t.logger = logger
+	}
+	return t
+}
+
+// WithBoomer records the results to b instead of the defaultBoomer.
+// If b is nil, it will not take effect.
+func (t *BoomerTransport) WithBoomer(b *Boomer) *BoomerTransport {
+	if b != nil {
+		t.boomer = b
+	}
+	return t
+}
+
+// WithDefaultHTTPClient wraps the transport of a pre-configured client, so that its settings are preserved.
+// If client.Transport is nil, http.DefaultTransport is used.
+// Use Client to get a copy of the client, which sends requests with BoomerTransport.
+func (t *BoomerTransport) WithDefaultHTTPClient(client *http.Client) *BoomerTransport {
+	if client == nil {
+		return t
+	}
+	t.client = client
+	t.inner = client.Transport
+	if t.inner == nil {
+		t.inner = http.DefaultTransport
+	}
+	return t
+}
+
+// WithTCPFastOpen sends the first request of a new connection in the SYN packet, which saves a round trip
+// for tests with many short-lived connections. It must be called after WithDefaultHTTPClient,
+// and takes effect only if the transport is a *http.Transport.
+// The default dialer is replaced by the same one with TCPFastOpenControl. A DialContext set by the user is kept,
+// set the Control of its net.Dialer to TCPFastOpenControl to enable TCP Fast Open.
+// The hits and misses are recorded as the custom metric "boomer_tcp_fast_open_hit_rate", see TCPFastOpenStats.
+//
+// TCP Fast Open requires Linux 4.11 or later, with the client bit set in net.ipv4.tcp_fastopen,
+// and the server must support it too. The first connection to a server always misses,
+// because the cookie is not cached yet. On other platforms, it falls back to a normal handshake silently.
+func (t *BoomerTransport) WithTCPFastOpen(enabled bool) *BoomerTransport {
+	inner, ok := t.inner.(*http.Transport)
+	if !ok {
+		return t
+	}
+	t.tcpFastOpen = enabled
+	if !enabled {
+		return t
+	}
+	switch {
+	case inner.DialContext == nil && inner.Dial == nil:
+		// http.Transport dials with a zero net.Dialer
+		inner = inner.Clone()
+		inner.DialContext = (&net.Dialer{Control: TCPFastOpenControl}).DialContext
+	case t.client == nil || t.client.Transport == nil:
+		// the same dialer as http.DefaultTransport
+		inner = inner.Clone()
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   TCPFastOpenControl,
+		}
+		inner.DialContext = dialer.DialContext
+	default:
+		t.logger.Println("The dialer of the transport is kept, TCP Fast Open is enabled only if its Control is TCPFastOpenControl")
+	}
+	t.inner = inner
+	return t
+}
+
+// WithHTTP2 configures the transport with golang.org/x/net/http2, so HTTPS requests are sent with HTTP/2.
+// It must be called after WithDefaultHTTPClient, and takes effect only if the transport is a *http.Transport.
+// If the server doesn't support HTTP/2, requests fall back to HTTP/1.1 and a warning is logged.
+// The negotiated protocol is counted in the boomer_protocol metric of PrometheusPusherOutput.
+func (t *BoomerTransport) WithHTTP2(enabled bool) *BoomerTransport {
+	t.http2 = false
+	if !enabled {
+		return t
+	}
+	inner, ok := t.inner.(*http.Transport)
+	if !ok {
+		t.logger.Println("HTTP/2 is not enabled, because the transport is not a *http.Transport")
+		return t
+	}
+	inner = inner.Clone()
+	if err := http2.ConfigureTransport(inner); err != nil {
+		t.logger.Printf("HTTP/2 is not enabled, error: %v\n", err)
+		return t
+	}
+	t.inner = inner
+	t.http2 = true
+	return t
+}
+
+// WithALPN lets the client and the server negotiate HTTP/2 or HTTP/1.1 with ALPN, without a warning on HTTP/1.1.
+// It must be called after WithDefaultHTTPClient, and takes effect only if the transport is a *http.Transport.
+// The negotiated protocol is counted in the boomer_protocol metric of PrometheusPusherOutput.
+func (t *BoomerTransport) WithALPN(enabled bool) *BoomerTransport {
+	t.alpn = false
+	if !enabled {
+		return t
+	}
+	inner, ok := t.inner.(*http.Transport)
+	if !ok {
+		return t
+	}
+	inner = inner.Clone()
+	inner.ForceAttemptHTTP2 = true
+	t.inner = inner
+	t.alpn = true
+	return t
+}
+
+// trackProtocol counts the negotiated protocol, and warns once if HTTP/2 is expected but not used.
+func (t *BoomerTransport) trackProtocol(resp *http.Response) {
+	protocol := "h1.1"
+	switch resp.ProtoMajor {
+	case 2:
+		protocol = "h2"
+	case 3:
+		protocol = "h3"
+	}
+	counterProtocol.WithLabelValues(protocol).Inc()
+
+	if t.http2 && resp.ProtoMajor < 2 && atomic.CompareAndSwapInt32(&t.http2FallbackLog, 0, 1) {
+		t.logger.Printf("HTTP/2 is enabled, but %s is used, the server may not support HTTP/2\n", resp.Proto)
+	}
+}
+
+// WithTLSFingerprinting counts the TLS version and cipher suite negotiated by new connections,
+// in the boomer_tls_version and boomer_tls_cipher_suite metrics of PrometheusPusherOutput,
+// e.g. to verify the TLS parameters for security compliance testing.
+func (t *BoomerTransport) WithTLSFingerprinting(enabled bool) *BoomerTransport {
+	t.tlsFingerprinting = enabled
+	return t
+}
+
+// trackTLS counts the negotiated TLS version and cipher suite.
+func trackTLS(state tls.ConnectionState) {
+	// tls.VersionName returns names like "TLS 1.3"
+	counterTLSVersion.WithLabelValues(strings.ReplaceAll(tls.VersionName(state.Version), " ", "")).Inc()
+	counterTLSCipherSuite.WithLabelValues(tls.CipherSuiteName(state.CipherSuite)).Inc()
+}
+
+// WithConnectionReuseTracking counts whether the connection of each request is reused or new,
+// because a high rate of new connections means keep-alive isn't working, which inflates the latency.
+// The counts are in the boomer_connections_reused_total and boomer_connections_new_total metrics
+// of PrometheusPusherOutput, and the reuse rate is recorded as the custom metric boomer_connection_reuse_rate.
+func (t *BoomerTransport) WithConnectionReuseTracking(enabled bool) *BoomerTransport {
+	t.connectionReuseTracking = enabled
+	return t
+}
+
+// ConnectionReuseStats returns the number of requests sent on reused connections and new connections.
+func (t *BoomerTransport) ConnectionReuseStats() (reused, opened int64) {
+	return atomic.LoadInt64(&t.connectionsReused), atomic.LoadInt64(&t.connectionsNew)
+}
+
+// ConnectionReuseRate returns reused / (reused + new), or zero if there are no requests.
+func (t *BoomerTransport) ConnectionReuseRate() float64 {
+	reused, opened := t.ConnectionReuseStats()
+	if reused+opened == 0 {
+		return 0
+	}
+	return float64(reused) / float64(reused+opened)
+}
+
+// trackTCPFastOpen counts the new connection, and records 1 for a hit or 0 for a miss,
+// so the average of the custom metric is the hit rate.
+func (t *BoomerTransport) trackTCPFastOpen(b *Boomer, used bool) {
+	value := float64(0)
+	if used {
+		atomic.AddInt64(&t.tcpFastOpenHits, 1)
+		value = 1
+	} else {
+		atomic.AddInt64(&t.tcpFastOpenMisses, 1)
+	}
+	b.RecordCustomMetric("boomer_tcp_fast_open_hit_rate", value)
+}
+
+// trackConnectionReuse counts the connection, and records 1 for a reused connection or 0 for a new one,
+// so the average of the custom metric is the reuse rate.
+func (t *BoomerTransport) trackConnectionReuse(b *Boomer, reused bool) {
+	value := float64(0)
+	if reused {
+		atomic.AddInt64(&t.connectionsReused, 1)
+		counterConnectionsReused.Inc()
+		value = 1
+	} else {
+		atomic.AddInt64(&t.connectionsNew, 1)
+		counterConnectionsNew.Inc()
+	}
+	b.RecordCustomMetric("boomer_connection_reuse_rate", value)
+}
+
+// WithRequestIDGenerator adds a unique id header to every request, so the requests can be found in the server logs.
+// fn is called once per request, if fn is nil, a UUID v4 is generated. The header is X-Request-ID by default,
+// and it's not overwritten if the request has one already. The id is in the JSON request log as request_id,
+// and in the details of the failures as FailureDetailRequestID.
+func (t *BoomerTransport) WithRequestIDGenerator(fn func() string) *BoomerTransport {
+	if fn == nil {
+		fn = func() string { return uuid.New().String() }
+	}
+	t.requestIDGenerator = fn
+	return t
+}
+
+// WithRequestIDHeader changes the header of the request id, and enables the default generator
+// if WithRequestIDGenerator is not called.
+func (t *BoomerTransport) WithRequestIDHeader(header string) *BoomerTransport {
+	t.requestIDHeader = header
+	if t.requestIDGenerator == nil {
+		t.WithRequestIDGenerator(nil)
+	}
+	return t
+}
+
+// recordFailure records a failed request, with the request id in the details if it's set.
+func (t *BoomerTransport) recordFailure(b *Boomer, req *http.Request, elapsed int64, exception, requestID string) {
+	if requestID == "" {
+		b.RecordFailure(req.Method, req.URL.Path, elapsed, exception)
+		return
+	}
+	b.RecordFailureWithDetails(req.Method, req.URL.Path, elapsed, exception, map[string]string{FailureDetailRequestID: requestID})
+}
+
+// setRequestID returns a copy of the request with the id header, the request passed to RoundTrip must not be modified.
+func (t *BoomerTransport) setRequestID(req *http.Request) (*http.Request, string) {
+	header := t.requestIDHeader
+	if header == "" {
+		header = defaultRequestIDHeader
+	}
+	if id := req.Header.Get(header); id != "" {
+		return req, id
+	}
+	id := t.requestIDGenerator()
+	req = req.Clone(req.Context())
+	req.Header.Set(header, id)
+	return req, id
+}
+
+// WithFaultInjection injects fault into a fraction of the requests, which is rate between 0 and 1,
+// to verify the circuit breakers and retry logic of tasks without a faulty server.
+// The faults are recorded like real failures, and the errors of the dropped connections wrap ErrFaultInjected.
+func (t *BoomerTransport) WithFaultInjection(rate float64, fault FaultType) *BoomerTransport {
+	t.faultInjection = &faultInjection{rate: rate, fault: fault}
+	return t
+}
+
+// WithEndpointFaultInjection injects fault into the requests of an endpoint only, like WithFaultInjection.
+// It takes precedence over WithFaultInjection for the endpoint.
+func (t *BoomerTransport) WithEndpointFaultInjection(method, path string, rate float64, fault FaultType) *BoomerTransport {
+	if t.endpointFaultInjection == nil {
+		t.endpointFaultInjection = make(map[string]*faultInjection)
+	}
+	t.endpointFaultInjection[method+" "+path] = &faultInjection{rate: rate, fault: fault}
+	return t
+}
+
+// getFaultInjection returns the fault injection of the request, or nil if no fault is injected this time.
+func (t *BoomerTransport) getFaultInjection(req *http.Request) *faultInjection {
+	f, ok := t.endpointFaultInjection[req.Method+" "+req.URL.Path]
+	if !ok {
+		f = t.faultInjection
+	}
+	if f == nil || random.Float64() >= f.rate {
+		return nil
+	}
+	return f
+}
+
+// WithRequestLog writes a line for every request to w, like an access log, for debugging individual requests.
+// Each line has the timestamp, method, URL, status, response time, response size and the error if any.
+// If w is nil, the request log is disabled.
+func (t *BoomerTransport) WithRequestLog(w io.Writer, format RequestLogFormat) *BoomerTransport {
+	if w == nil {
+		t.requestLog = nil
+		return t
+	}
+	sampleRate := float64(1)
+	if t.requestLog != nil {
+		sampleRate = t.requestLog.sampleRate
+	}
+	t.requestLog = &requestLog{w: w, format: format, sampleRate: sampleRate}
+	return t
+}
+
+// WithRequestLogSampleRate logs only a fraction of the requests, e.g. 0.01 logs 1% of them.
+// It must be called after WithRequestLog.
+func (t *BoomerTransport) WithRequestLogSampleRate(rate float64) *BoomerTransport {
+	if t.requestLog != nil {
+		t.requestLog.sampleRate = rate
+	}
+	return t
+}
+
+// WithPrewarmConnections establishes n idle connections before the users are spawned, so the latencies
+// of the first seconds are not skewed by connection establishment. The connections are established
+// by concurrent GET requests to the URL set by WithPrewarmURL, which are not recorded.
+// The transport must keep enough idle connections, e.g. http.Transport.MaxIdleConnsPerHost is 2 by default.
+// Failures are logged, and the test goes on. Prewarm can also be called manually.
+func (t *BoomerTransport) WithPrewarmConnections(n int) *BoomerTransport {
+	if n <= 0 {
+		return t
+	}
+	if t.prewarmConnections == 0 {
+		Events.SubscribeOnce(EVENT_SPAWN, func(spawnCount int, spawnRate float64) {
+			t.Prewarm()
+		})
+	}
+	t.prewarmConnections = n
+	return t
+}
+
+// WithPrewarmURL sets the health check URL requested by WithPrewarmConnections, e.g. "https://example.com/health".
+// If the URL has no path, "/" is requested.
+func (t *BoomerTransport) WithPrewarmURL(url string) *BoomerTransport {
+	t.prewarmURL = url
+	return t
+}
+
+// Prewarm establishes the connections of WithPrewarmConnections, and returns the number of new connections.
+func (t *BoomerTransport) Prewarm() (established int) {
+	if t.prewarmConnections <= 0 {
+		return 0
+	}
+	if t.prewarmURL == "" {
+		t.logger.Println("Prewarm URL is not set, skip prewarming connections")
+		return 0
+	}
+	if inner, ok := t.inner.(*http.Transport); ok {
+		idle := inner.MaxIdleConnsPerHost
+		if idle <= 0 {
+			idle = http.DefaultMaxIdleConnsPerHost
+		}
+		if idle < t.prewarmConnections {
+			t.logger.Printf("MaxIdleConnsPerHost %d is less than %d prewarm connections, some of them will be closed\n", idle, t.prewarmConnections)
+		}
+	}
+
+	var count int64
+	wg := sync.WaitGroup{}
+	for i := 0; i < t.prewarmConnections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reused, err := t.prewarm()
+			if err != nil {
+				t.logger.Printf("Error prewarming connection to %s, %v\n", t.prewarmURL, err)
+				return
+			}
+			if !reused {
+				atomic.AddInt64(&count, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	established = int(count)
+	t.logger.Printf("%d of %d prewarm connections are established to %s\n", established, t.prewarmConnections, t.prewarmURL)
+	return established
+}
+
+// prewarm sends a request to prewarmURL with the inner transport, and returns whether the connection is reused.
+func (t *BoomerTransport) prewarm() (reused bool, err error) {
+	req, err := http.NewRequest(http.MethodGet, t.prewarmURL, nil)
+	if err != nil {
+		return false, err
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = info.Reused
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(context.Background(), trace))
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		return false, err
+	}
+	// the connection is reused only if the body is read to the end
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return reused, nil
+}
+
+// WithHTTPRetry sends a request again if the response has one of statusCodes, like 429 and 503,
+// until it's sent maxAttempts times in total. The backoff before each retry starts at 100ms and doubles.
+// Only the last attempt is recorded to boomer, with its own response time, and the total time of the retried requests
+// is recorded as the custom metric HTTPRetryTotalTimeMetric. Requests with a body are retried only if
+// http.Request.GetBody is set, which is the case for the requests created by http.NewRequest.
+// If maxAttempts is less than 2, or statusCodes is empty, requests are not retried.
+func (t *BoomerTransport) WithHTTPRetry(statusCodes []int, maxAttempts int) *BoomerTransport {
+	t.retryStatusCodes = nil
+	t.retryMaxAttempts = 0
+	if maxAttempts < 2 || len(statusCodes) == 0 {
+		return t
+	}
+	t.retryStatusCodes = make(map[int]bool, len(statusCodes))
+	for _, code := range statusCodes {
+		t.retryStatusCodes[code] = true
+	}
+	t.retryMaxAttempts = maxAttempts
+	if t.retryBackoff <= 0 {
+		t.retryBackoff = defaultHTTPRetryBackoff
+	}
+	return t
+}
+
+// retry waits for the backoff after the attempt, and returns a copy of req to be sent again.
+// It returns nil if resp shouldn't be retried, or req is canceled while waiting.
+func (t *BoomerTransport) retry(req *http.Request, resp *http.Response, err error, attempt int) *http.Request {
+	if err != nil || attempt >= t.retryMaxAttempts || !t.retryStatusCodes[resp.StatusCode] {
+		return nil
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return nil
+	}
+	timer := time.NewTimer(t.retryBackoff << (attempt - 1))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-req.Context().Done():
+		return nil
+	}
+	retried := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil
+		}
+		retried.Body = body
+	}
+	return retried
+}
+
+// TCPFastOpenStats returns the number of new connections, which the server accepts data in SYN or not.
+func (t *BoomerTransport) TCPFastOpenStats() (hits, misses int64) {
+	return atomic.LoadInt64(&t.tcpFastOpenHits), atomic.LoadInt64(&t.tcpFastOpenMisses)
+}
+
+// Client returns a http.Client which sends requests with BoomerTransport.
+// The timeout, cookie jar and redirect policy of the client passed to WithDefaultHTTPClient are preserved.
+func (t *BoomerTransport) Client() *http.Client {
+	client := &http.Client{}
+	if t.client != nil {
+		*client = *t.client
+	}
+	client.Transport = t
+	return client
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *BoomerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	TaskSetupDone(req.Context())
+
+	var requestID string
+	if t.requestIDGenerator != nil {
+		req, requestID = t.setRequestID(req)
+	}
+
+	var conn net.Conn
+	var gotConn, reused bool
+	if t.tcpFastOpen || t.tlsFingerprinting || t.connectionReuseTracking {
+		trace := &httptrace.ClientTrace{}
+		if t.tcpFastOpen || t.connectionReuseTracking {
+			trace.GotConn = func(info httptrace.GotConnInfo) {
+				gotConn, reused = true, info.Reused
+				if t.tcpFastOpen && !info.Reused {
+					conn = info.Conn
+				}
+			}
+		}
+		if t.tlsFingerprinting {
+			trace.TLSHandshakeDone = func(state tls.ConnectionState, err error) {
+				if err == nil {
+					trackTLS(state)
+				}
+			}
+		}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	}
+
+	start := time.Now()
+	firstStart := start
+	var retryTotalTime time.Duration
+	var resp *http.Response
+	var err error
+	for attempt := 1; ; attempt++ {
+		if f := t.getFaultInjection(req); f != nil {
+			resp, err = f.inject(t.inner, req)
+		} else {
+			resp, err = t.inner.RoundTrip(req)
+		}
+		if t.retryMaxAttempts == 0 {
+			break
+		}
+		retried := t.retry(req, resp, err, attempt)
+		if retried == nil {
+			if attempt > 1 {
+				retryTotalTime = time.Since(firstStart)
+			}
+			break
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		req = retried
+		start = time.Now()
+	}
+	elapsed := time.Since(start).Milliseconds()
+
+	var fastOpenUsed, fastOpenTracked bool
+	if conn != nil && err == nil {
+		fastOpenUsed, fastOpenTracked = tcpFastOpenUsed(conn)
+	}
+
+	if err == nil && (t.http2 || t.alpn || t.http3) {
+		t.trackProtocol(resp)
+	}
+
+	if t.requestLog != nil {
+		entry := newRequestLogEntry(req, resp, err, start, elapsed)
+		entry.RequestID = requestID
+		t.requestLog.log(entry)
+	}
+
+	if t.errorSampler != nil && (err != nil || resp.StatusCode >= http.StatusBadRequest) {
+		t.sampleError(req, resp, err, start, elapsed, requestID)
+	}
+
+	if TaskTimedOut(req.Context()) {
+		// the task is recorded as timed out already
+		return resp, err
+	}
+
+	b := t.boomer
+	if b == nil {
+		b = defaultBoomer
+	}
+	if gotConn && t.connectionReuseTracking {
+		t.trackConnectionReuse(b, reused)
+	}
+	if fastOpenTracked {
+		t.trackTCPFastOpen(b, fastOpenUsed)
+	}
+	if retryTotalTime > 0 {
+		b.RecordCustomMetric(HTTPRetryTotalTimeMetric, float64(retryTotalTime)/float64(time.Millisecond))
+	}
+	if err != nil {
+		t.recordFailure(b, req, elapsed, err.Error(), requestID)
+		return resp, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		t.recordFailure(b, req, elapsed, resp.Status, requestID)
+		return resp, err
+	}
+
+	contentLength := resp.ContentLength
+	if contentLength < 0 {
+		contentLength = 0
+	}
+	b.RecordSuccess(req.Method, req.URL.Path, elapsed, contentLength)
+	return resp, err
+}
@@ -0,0 +1,117 @@
+package boomer
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// http3Transport sends HTTPS requests with HTTP/3, and falls back to HTTP/2 for the hosts which don't support it.
+// Requests of other schemes are sent by fallback.
+type http3Transport struct {
+	h3         *http3.RoundTripper
+	fallback   http.RoundTripper
+	onFallback func(host string, err error)
+
+	// the transport before WithHTTP3, which is restored if HTTP/3 is disabled
+	previous http.RoundTripper
+
+	// whether the hosts support HTTP/3, keyed by host
+	hosts sync.Map
+}
+
+func (t *http3Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.fallback.RoundTrip(req)
+	}
+	host := req.URL.Host
+	supported, known := t.hosts.Load(host)
+	if known && !supported.(bool) {
+		return t.fallback.RoundTrip(req)
+	}
+	resp, err := t.h3.RoundTrip(req)
+	if err == nil {
+		if !known {
+			t.hosts.Store(host, true)
+		}
+		return resp, nil
+	}
+	// errors of the hosts which support HTTP/3 are failures of the requests
+	if known || req.Context().Err() != nil {
+		return resp, err
+	}
+	retried := req
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return resp, err
+		}
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return resp, err
+		}
+		retried = req.Clone(req.Context())
+		retried.Body = body
+	}
+	if _, loaded := t.hosts.LoadOrStore(host, false); !loaded && t.onFallback != nil {
+		t.onFallback(host, err)
+	}
+	return t.fallback.RoundTrip(retried)
+}
+
+// WithHTTP3 sends HTTPS requests with HTTP/3 over QUIC, with github.com/quic-go/quic-go/http3.
+// It must be called after WithDefaultHTTPClient, and the TLS config of the client is preserved
+// if its transport is a *http.Transport. Other options which configure the *http.Transport, like WithHTTP2,
+// must be called before WithHTTP3, they only take effect on the fallback transport.
+//
+// If the first request to a host fails with HTTP/3, e.g. the server doesn't listen on UDP, a warning is logged,
+// and the requests to the host are sent with HTTP/2 from then on, or HTTP/1.1 if the server doesn't support HTTP/2.
+// The negotiated protocol is counted in the boomer_protocol metric of PrometheusPusherOutput, as h3 for HTTP/3.
+func (t *BoomerTransport) WithHTTP3(enabled bool) *BoomerTransport {
+	if h3, ok := t.inner.(*http3Transport); ok {
+		h3.h3.Close()
+		t.inner = h3.previous
+	}
+	t.http3 = false
+	if !enabled {
+		return t
+	}
+
+	var tlsConfig *tls.Config
+	fallback := t.inner
+	if inner, ok := t.inner.(*http.Transport); ok {
+		if inner.TLSClientConfig != nil {
+			tlsConfig = inner.TLSClientConfig.Clone()
+		}
+		if !t.http2 {
+			inner = inner.Clone()
+			inner.ForceAttemptHTTP2 = true
+			fallback = inner
+		}
+	}
+	t.inner = &http3Transport{
+		h3: &http3.RoundTripper{
+			TLSClientConfig: tlsConfig,
+			QuicConfig:      t.quicConfig,
+		},
+		fallback: fallback,
+		previous: t.inner,
+		onFallback: func(host string, err error) {
+			t.logger.Printf("HTTP/3 is not supported by %s, fall back to HTTP/2, error: %v\n", host, err)
+		},
+	}
+	t.http3 = true
+	return t
+}
+
+// WithQUICConfig tunes the QUIC connections of WithHTTP3, e.g. the handshake and idle timeouts.
+// If the config is nil, the defaults of quic-go are used.
+func (t *BoomerTransport) WithQUICConfig(config *quic.Config) *BoomerTransport {
+	t.quicConfig = config
+	if h3, ok := t.inner.(*http3Transport); ok {
+		h3.h3.QuicConfig = config
+	}
+	return t
+}
@@ -0,0 +1,100 @@
+package boomer
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+var _ = Describe("Test transport with HTTP/3", func() {
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Proto", r.Proto)
+		w.Write(append([]byte("hello "), body...))
+	})
+
+	newBoomer := func() *Boomer {
+		b := NewStandaloneBoomer(1, 1)
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+		return b
+	}
+
+	It("test with http3", func() {
+		// the TLS server only provides the certificate and the client trusting it
+		tlsServer := httptest.NewTLSServer(handler)
+		defer tlsServer.Close()
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		h3Server := &http3.Server{
+			Handler:   handler,
+			TLSConfig: http3.ConfigureTLSConfig(tlsServer.TLS.Clone()),
+		}
+		go h3Server.Serve(conn)
+		defer h3Server.Close()
+
+		b := newBoomer()
+		transport := NewBoomerTransport().WithBoomer(b).WithDefaultHTTPClient(tlsServer.Client()).
+			WithQUICConfig(&quic.Config{HandshakeIdleTimeout: 2 * time.Second}).WithHTTP3(true)
+		Expect(transport.inner.(*http3Transport).h3.QuicConfig.HandshakeIdleTimeout).To(Equal(2 * time.Second))
+		client := transport.Client()
+
+		h3Count := testutil.ToFloat64(counterProtocol.WithLabelValues("h3"))
+		url := "https://" + conn.LocalAddr().String() + "/hello"
+		resp, err := client.Post(url, "text/plain", bytes.NewBufferString("h3"))
+		Expect(err).NotTo(HaveOccurred())
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		Expect(resp.ProtoMajor).To(Equal(3))
+		Expect(resp.Header.Get("X-Proto")).To(Equal("HTTP/3.0"))
+		Expect(string(body)).To(Equal("hello h3"))
+		Expect(testutil.ToFloat64(counterProtocol.WithLabelValues("h3"))).To(Equal(h3Count + 1))
+
+		var requestSuccessMsg *requestSuccess
+		Expect(b.localRunner.stats.requestSuccessChan).Should(Receive(&requestSuccessMsg))
+		Expect(requestSuccessMsg.requestType).To(Equal("POST"))
+		Expect(requestSuccessMsg.name).To(Equal("/hello"))
+		Expect(requestSuccessMsg.responseLength).To(BeEquivalentTo(len("hello h3")))
+
+		// disabling HTTP/3 restores the previous transport
+		transport.WithHTTP3(false)
+		Expect(transport.inner).To(Equal(tlsServer.Client().Transport))
+	})
+
+	It("test with http3 falls back to http2", func() {
+		tlsServer := httptest.NewUnstartedServer(handler)
+		tlsServer.EnableHTTP2 = true
+		tlsServer.StartTLS()
+		defer tlsServer.Close()
+
+		buf := gbytes.NewBuffer()
+		b := newBoomer()
+		client := NewBoomerTransport().WithBoomer(b).WithLogger(log.New(buf, "", 0)).
+			WithDefaultHTTPClient(tlsServer.Client()).WithHTTP3(true).
+			WithQUICConfig(&quic.Config{HandshakeIdleTimeout: 200 * time.Millisecond}).Client()
+
+		for i := 0; i < 2; i++ {
+			resp, err := client.Post(tlsServer.URL+"/hello", "text/plain", bytes.NewBufferString("h2"))
+			Expect(err).NotTo(HaveOccurred())
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			Expect(resp.ProtoMajor).To(Equal(2))
+			Expect(string(body)).To(Equal("hello h2"))
+		}
+		// the warning is logged once for the host
+		Expect(buf).To(gbytes.Say("HTTP/3 is not supported by 127.0.0.1:\\d+, fall back to HTTP/2"))
+		Expect(buf).NotTo(gbytes.Say("HTTP/3 is not supported"))
+		Expect(b.localRunner.stats.requestSuccessChan).Should(HaveLen(2))
+	})
+})
@@ -0,0 +1,563 @@
+package boomer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Test transport", func() {
+
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			io.WriteString(w, "hello")
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newBoomer := func() *Boomer {
+		b := NewStandaloneBoomer(1, 1)
+		b.localRunner = newLocalRunner(nil, nil, 1, 1)
+		return b
+	}
+
+	It("test record success and failure", func() {
+		b := newBoomer()
+		client := NewBoomerTransport().WithBoomer(b).Client()
+
+		resp, err := client.Get(server.URL + "/hello")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+
+		var requestSuccessMsg *requestSuccess
+		Expect(b.localRunner.stats.requestSuccessChan).Should(Receive(&requestSuccessMsg))
+		Expect(requestSuccessMsg.requestType).To(Equal("GET"))
+		Expect(requestSuccessMsg.name).To(Equal("/hello"))
+		Expect(requestSuccessMsg.responseLength).To(BeEquivalentTo(5))
+
+		resp, err = client.Get(server.URL + "/missing")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+
+		var requestFailureMsg *requestFailure
+		Expect(b.localRunner.stats.requestFailureChan).Should(Receive(&requestFailureMsg))
+		Expect(requestFailureMsg.name).To(Equal("/missing"))
+		Expect(requestFailureMsg.error).To(Equal("404 Not Found"))
+	})
+
+	It("test record error", func() {
+		b := newBoomer()
+		client := NewBoomerTransport().WithBoomer(b).Client()
+
+		_, err := client.Get("http://127.0.0.1:0/unreachable")
+		Expect(err).To(HaveOccurred())
+
+		var requestFailureMsg *requestFailure
+		Expect(b.localRunner.stats.requestFailureChan).Should(Receive(&requestFailureMsg))
+		Expect(requestFailureMsg.name).To(Equal("/unreachable"))
+	})
+
+	It("test with default http client", func() {
+		dials := int64(0)
+		dialer := &net.Dialer{Timeout: 3 * time.Second}
+		userClient := &http.Client{
+			Timeout: 5 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					atomic.AddInt64(&dials, 1)
+					return dialer.DialContext(ctx, network, addr)
+				},
+			},
+		}
+
+		b := newBoomer()
+		t := NewBoomerTransport().WithBoomer(b).WithDefaultHTTPClient(userClient)
+		Expect(t.inner).To(BeIdenticalTo(userClient.Transport))
+
+		client := t.Client()
+		Expect(client.Timeout).To(Equal(5 * time.Second))
+		Expect(client.Transport).To(BeIdenticalTo(t))
+		Expect(userClient.Transport).NotTo(BeIdenticalTo(t))
+
+		resp, err := client.Get(server.URL + "/hello")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(atomic.LoadInt64(&dials)).To(BeEquivalentTo(1))
+		Expect(b.localRunner.stats.requestSuccessChan).Should(Receive())
+
+		t = NewBoomerTransport().WithDefaultHTTPClient(&http.Client{})
+		Expect(t.inner).To(BeIdenticalTo(http.DefaultTransport))
+	})
+
+	It("test with tcp fast open", func() {
+		b := newBoomer()
+		t := NewBoomerTransport().WithBoomer(b).WithTCPFastOpen(true)
+		Expect(t.inner).NotTo(BeIdenticalTo(http.DefaultTransport))
+
+		for i := 0; i < 2; i++ {
+			resp, err := t.Client().Get(server.URL + "/hello")
+			Expect(err).NotTo(HaveOccurred())
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			Expect(b.localRunner.stats.requestSuccessChan).Should(Receive())
+		}
+
+		hits, misses := t.TCPFastOpenStats()
+		if runtime.GOOS == "linux" {
+			// the connection is reused by the second request
+			Expect(hits + misses).To(BeEquivalentTo(1))
+			var metric *customMetric
+			Expect(b.localRunner.stats.customMetricChan).Should(Receive(&metric))
+			Expect(metric.name).To(Equal("boomer_tcp_fast_open_hit_rate"))
+		} else {
+			Expect(hits + misses).To(BeZero())
+		}
+
+		t = NewBoomerTransport().WithDefaultHTTPClient(&http.Client{Transport: roundTripperFunc(nil)}).WithTCPFastOpen(true)
+		Expect(t.tcpFastOpen).To(BeFalse())
+	})
+
+	It("test with tcp fast open keeps the dialer of the user", func() {
+		dials := int64(0)
+		dialer := &net.Dialer{Timeout: 3 * time.Second, Control: TCPFastOpenControl}
+		userClient := &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					atomic.AddInt64(&dials, 1)
+					return dialer.DialContext(ctx, network, addr)
+				},
+			},
+		}
+
+		b := newBoomer()
+		t := NewBoomerTransport().WithBoomer(b).WithLogger(log.New(io.Discard, "", 0)).
+			WithDefaultHTTPClient(userClient).WithTCPFastOpen(true)
+		Expect(t.tcpFastOpen).To(BeTrue())
+		Expect(t.inner).To(BeIdenticalTo(userClient.Transport))
+
+		resp, err := t.Client().Get(server.URL + "/hello")
+		Expect(err).NotTo(HaveOccurred())
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		Expect(atomic.LoadInt64(&dials)).To(BeEquivalentTo(1))
+		Expect(b.localRunner.stats.requestSuccessChan).Should(Receive())
+	})
+
+	It("test with http2", func() {
+		h2Server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.Proto)
+		}))
+		h2Server.EnableHTTP2 = true
+		h2Server.StartTLS()
+		defer h2Server.Close()
+
+		b := newBoomer()
+		client := NewBoomerTransport().WithBoomer(b).WithDefaultHTTPClient(h2Server.Client()).WithHTTP2(true).Client()
+		resp, err := client.Get(h2Server.URL + "/hello")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.ProtoMajor).To(Equal(2))
+		Expect(testutil.ToFloat64(counterProtocol.WithLabelValues("h2"))).To(BeNumerically(">=", 1))
+	})
+
+	It("test with http2 falls back to http/1.1", func() {
+		h1Server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.Proto)
+		}))
+		defer h1Server.Close()
+
+		buf := gbytes.NewBuffer()
+		b := newBoomer()
+		t := NewBoomerTransport().WithBoomer(b).WithLogger(log.New(buf, "", 0)).
+			WithDefaultHTTPClient(h1Server.Client()).WithHTTP2(true)
+		h1Count := testutil.ToFloat64(counterProtocol.WithLabelValues("h1.1"))
+		for i := 0; i < 2; i++ {
+			resp, err := t.Client().Get(h1Server.URL + "/hello")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.ProtoMajor).To(Equal(1))
+		}
+		Expect(testutil.ToFloat64(counterProtocol.WithLabelValues("h1.1"))).To(Equal(h1Count + 2))
+		Expect(buf).To(gbytes.Say("HTTP/2 is enabled, but HTTP/1.1 is used"))
+		Expect(buf).NotTo(gbytes.Say("HTTP/2 is enabled"))
+	})
+
+	It("test with alpn", func() {
+		h2Server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		h2Server.EnableHTTP2 = true
+		h2Server.StartTLS()
+		defer h2Server.Close()
+
+		transport := h2Server.Client().Transport.(*http.Transport).Clone()
+		transport.ForceAttemptHTTP2 = false
+		t := NewBoomerTransport().WithBoomer(newBoomer()).WithDefaultHTTPClient(&http.Client{Transport: transport}).WithALPN(true)
+		Expect(t.alpn).To(BeTrue())
+		resp, err := t.Client().Get(h2Server.URL + "/hello")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.ProtoMajor).To(Equal(2))
+	})
+
+	It("test with tls fingerprinting", func() {
+		tlsServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		tlsServer.TLS = &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			MaxVersion:   tls.VersionTLS12,
+			CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		}
+		tlsServer.StartTLS()
+		defer tlsServer.Close()
+
+		version := counterTLSVersion.WithLabelValues("TLS1.2")
+		suite := counterTLSCipherSuite.WithLabelValues("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+		versionCount := testutil.ToFloat64(version)
+		suiteCount := testutil.ToFloat64(suite)
+
+		t := NewBoomerTransport().WithBoomer(newBoomer()).WithDefaultHTTPClient(tlsServer.Client()).WithTLSFingerprinting(true)
+		for i := 0; i < 3; i++ {
+			resp, err := t.Client().Get(tlsServer.URL + "/hello")
+			Expect(err).NotTo(HaveOccurred())
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		// the connection is reused, so the handshake is counted once
+		Expect(testutil.ToFloat64(version)).To(Equal(versionCount + 1))
+		Expect(testutil.ToFloat64(suite)).To(Equal(suiteCount + 1))
+
+		tls13Server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		tls13Server.TLS = &tls.Config{MinVersion: tls.VersionTLS13}
+		tls13Server.StartTLS()
+		defer tls13Server.Close()
+
+		tls13Count := testutil.ToFloat64(counterTLSVersion.WithLabelValues("TLS1.3"))
+		resp, err := NewBoomerTransport().WithBoomer(newBoomer()).WithDefaultHTTPClient(tls13Server.Client()).
+			WithTLSFingerprinting(true).Client().Get(tls13Server.URL + "/hello")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(testutil.ToFloat64(counterTLSVersion.WithLabelValues("TLS1.3"))).To(Equal(tls13Count + 1))
+	})
+
+	It("test with connection reuse tracking", func() {
+		b := newBoomer()
+		t := NewBoomerTransport().WithBoomer(b).WithConnectionReuseTracking(true)
+		Expect(t.ConnectionReuseRate()).To(BeZero())
+
+		reusedCount := testutil.ToFloat64(counterConnectionsReused)
+		newCount := testutil.ToFloat64(counterConnectionsNew)
+		client := t.Client()
+		for i := 0; i < 20; i++ {
+			resp, err := client.Get(server.URL + "/hello")
+			Expect(err).NotTo(HaveOccurred())
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		reused, opened := t.ConnectionReuseStats()
+		Expect(reused + opened).To(BeEquivalentTo(20))
+		Expect(opened).To(BeNumerically(">=", 1))
+		Expect(t.ConnectionReuseRate()).To(BeNumerically(">=", 0.9))
+		Expect(testutil.ToFloat64(counterConnectionsReused)).To(Equal(reusedCount + float64(reused)))
+		Expect(testutil.ToFloat64(counterConnectionsNew)).To(Equal(newCount + float64(opened)))
+
+		Expect(b.localRunner.stats.customMetricChan).To(HaveLen(20))
+		sum := float64(0)
+		for i := 0; i < 20; i++ {
+			metric := <-b.localRunner.stats.customMetricChan
+			Expect(metric.name).To(Equal("boomer_connection_reuse_rate"))
+			sum += metric.value
+		}
+		Expect(sum).To(Equal(float64(reused)))
+	})
+
+	It("test with prewarm connections", func() {
+		var connections, healthChecks int64
+		prewarmServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/" {
+				atomic.AddInt64(&healthChecks, 1)
+				// keep the requests in flight, so each of them opens a connection
+				time.Sleep(50 * time.Millisecond)
+			}
+			io.WriteString(w, "ok")
+		}))
+		prewarmServer.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				atomic.AddInt64(&connections, 1)
+			}
+		}
+		prewarmServer.Start()
+		defer prewarmServer.Close()
+
+		b := newBoomer()
+		buf := gbytes.NewBuffer()
+		inner := &http.Transport{MaxConnsPerHost: 5, MaxIdleConnsPerHost: 5}
+		defer inner.CloseIdleConnections()
+		t := NewBoomerTransport().WithBoomer(b).WithLogger(log.New(buf, "", 0)).
+			WithDefaultHTTPClient(&http.Client{Transport: inner}).
+			WithPrewarmConnections(5).WithPrewarmURL(prewarmServer.URL)
+
+		// prewarm before the users are spawned
+		Events.Publish(EVENT_SPAWN, 5, float64(5))
+		Expect(atomic.LoadInt64(&connections)).To(BeEquivalentTo(5))
+		Expect(atomic.LoadInt64(&healthChecks)).To(BeEquivalentTo(5))
+		Expect(buf).To(gbytes.Say("5 of 5 prewarm connections are established"))
+		// the health checks are not recorded
+		Expect(b.localRunner.stats.requestSuccessChan).To(BeEmpty())
+
+		// the users don't establish connections
+		client := t.Client()
+		wg := sync.WaitGroup{}
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				resp, err := client.Get(prewarmServer.URL + "/hello")
+				Expect(err).NotTo(HaveOccurred())
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+		Expect(atomic.LoadInt64(&connections)).To(BeEquivalentTo(5))
+		Expect(b.localRunner.stats.requestSuccessChan).To(HaveLen(5))
+	})
+
+	It("test prewarm connections failure", func() {
+		buf := gbytes.NewBuffer()
+		t := NewBoomerTransport().WithLogger(log.New(buf, "", 0))
+		Expect(t.Prewarm()).To(BeZero())
+
+		t.WithPrewarmConnections(2)
+		Expect(t.Prewarm()).To(BeZero())
+		Expect(buf).To(gbytes.Say("Prewarm URL is not set"))
+
+		t.WithPrewarmURL("http://127.0.0.1:1/health")
+		Expect(t.Prewarm()).To(BeZero())
+		Expect(buf).To(gbytes.Say("Error prewarming connection to http://127.0.0.1:1/health"))
+		Expect(buf).To(gbytes.Say("0 of 2 prewarm connections are established"))
+
+		// http.DefaultTransport keeps 2 idle connections per host
+		t.WithPrewarmConnections(3).WithPrewarmURL(server.URL)
+		t.Prewarm()
+		Expect(buf).To(gbytes.Say("MaxIdleConnsPerHost 2 is less than 3 prewarm connections"))
+	})
+
+	It("test with request id", func() {
+		var lock sync.Mutex
+		var ids []string
+		idServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			ids = append(ids, r.Header.Get("X-Request-ID")+r.Header.Get("X-Trace"))
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer idServer.Close()
+
+		buf := &bytes.Buffer{}
+		client := NewBoomerTransport().WithBoomer(newBoomer()).WithRequestIDGenerator(nil).
+			WithRequestLog(buf, JSONLogFormat).Client()
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest("GET", idServer.URL+"/hello", nil)
+			resp, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(req.Header.Get("X-Request-ID")).To(BeEmpty())
+		}
+		Expect(ids).To(HaveLen(2))
+		Expect(ids[0]).To(MatchRegexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`))
+		Expect(ids[1]).NotTo(Equal(ids[0]))
+		Expect(buf.String()).To(ContainSubstring(`"status":500`))
+		Expect(buf.String()).To(ContainSubstring(`"request_id":"` + ids[0] + `"`))
+
+		n := 0
+		b := newBoomer()
+		client = NewBoomerTransport().WithBoomer(b).WithRequestIDGenerator(func() string {
+			n++
+			return fmt.Sprintf("load-%d", n)
+		}).WithRequestIDHeader("X-Trace").Client()
+		resp, err := client.Get(idServer.URL + "/hello")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		req, _ := http.NewRequest("GET", idServer.URL+"/hello", nil)
+		req.Header.Set("X-Trace", "preset")
+		resp, err = client.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(ids[2:]).To(Equal([]string{"load-1", "preset"}))
+
+		// the request ids are in the details of the failures, which are still aggregated by the status
+		for _, id := range []string{"load-1", "preset"} {
+			var requestFailureMsg *requestFailure
+			Expect(b.localRunner.stats.requestFailureChan).To(Receive(&requestFailureMsg))
+			Expect(requestFailureMsg.error).To(Equal("500 Internal Server Error"))
+			Expect(requestFailureMsg.details).To(Equal(map[string]string{FailureDetailRequestID: id}))
+		}
+	})
+
+	It("test with http retry", func() {
+		var lock sync.Mutex
+		var hits []time.Time
+		var bodies []string
+		failures := 2
+		retryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			hits = append(hits, time.Now())
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			if len(hits) <= failures {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			io.WriteString(w, "hello")
+		}))
+		defer retryServer.Close()
+
+		b := newBoomer()
+		transport := NewBoomerTransport().WithBoomer(b).WithHTTPRetry([]int{http.StatusTooManyRequests, http.StatusServiceUnavailable}, 3)
+		transport.retryBackoff = 50 * time.Millisecond
+		client := transport.Client()
+
+		resp, err := client.Post(retryServer.URL+"/hello", "text/plain", bytes.NewBufferString("payload"))
+		Expect(err).NotTo(HaveOccurred())
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		Expect(string(body)).To(Equal("hello"))
+
+		// the backoff doubles
+		Expect(hits).To(HaveLen(3))
+		Expect(hits[1].Sub(hits[0])).To(BeNumerically(">=", 50*time.Millisecond))
+		Expect(hits[2].Sub(hits[1])).To(BeNumerically(">=", 100*time.Millisecond))
+		Expect(bodies).To(Equal([]string{"payload", "payload", "payload"}))
+
+		// only the last attempt is recorded
+		var requestSuccessMsg *requestSuccess
+		Expect(b.localRunner.stats.requestSuccessChan).Should(Receive(&requestSuccessMsg))
+		Expect(requestSuccessMsg.name).To(Equal("/hello"))
+		Expect(requestSuccessMsg.responseTime).To(BeNumerically("<", 50))
+		Expect(b.localRunner.stats.requestSuccessChan).ShouldNot(Receive())
+		Expect(b.localRunner.stats.requestFailureChan).ShouldNot(Receive())
+		var metric *customMetric
+		Expect(b.localRunner.stats.customMetricChan).Should(Receive(&metric))
+		Expect(metric.name).To(Equal(HTTPRetryTotalTimeMetric))
+		Expect(metric.value).To(BeNumerically(">=", 150))
+
+		// the last response is recorded as a failure if all the attempts fail
+		lock.Lock()
+		hits, failures = nil, 10
+		lock.Unlock()
+		resp, err = client.Get(retryServer.URL + "/hello")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		Expect(hits).To(HaveLen(3))
+		var requestFailureMsg *requestFailure
+		Expect(b.localRunner.stats.requestFailureChan).Should(Receive(&requestFailureMsg))
+		Expect(requestFailureMsg.error).To(Equal("503 Service Unavailable"))
+		Expect(b.localRunner.stats.requestFailureChan).ShouldNot(Receive())
+
+		// other status codes aren't retried, and retry is disabled with less than 2 attempts
+		resp, err = client.Get(server.URL + "/missing")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(b.localRunner.stats.requestFailureChan).Should(Receive(&requestFailureMsg))
+		Expect(requestFailureMsg.error).To(Equal("404 Not Found"))
+
+		lock.Lock()
+		hits = nil
+		lock.Unlock()
+		transport.WithHTTPRetry([]int{http.StatusServiceUnavailable}, 1)
+		resp, err = client.Get(retryServer.URL + "/hello")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(hits).To(HaveLen(1))
+	})
+
+	It("test with record response body", func() {
+		errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, "no such page")
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, "upstream connect error or disconnect/reset before headers")
+		}))
+		defer errorServer.Close()
+
+		var samples []*ErrorSample
+		b := newBoomer()
+		client := NewBoomerTransport().WithBoomer(b).WithRequestIDGenerator(func() string { return "foo" }).
+			WithErrorSampler(func(sample *ErrorSample) {
+				samples = append(samples, sample)
+			}).
+			WithRecordResponseBody(16, func(statusCode int) bool {
+				return statusCode == http.StatusServiceUnavailable
+			}).Client()
+
+		resp, err := client.Get(errorServer.URL + "/hello")
+		Expect(err).NotTo(HaveOccurred())
+		// the caller reads the whole body
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(string(body)).To(Equal("upstream connect error or disconnect/reset before headers"))
+		Expect(samples).To(HaveLen(1))
+		Expect(samples[0].Method).To(Equal("GET"))
+		Expect(samples[0].URL).To(Equal(errorServer.URL + "/hello"))
+		Expect(samples[0].RequestID).To(Equal("foo"))
+		Expect(samples[0].StatusCode).To(Equal(http.StatusServiceUnavailable))
+		Expect(samples[0].Error).To(Equal("503 Service Unavailable"))
+		Expect(string(samples[0].ResponseBody)).To(Equal("upstream connect"))
+
+		// the body isn't captured if the predicate returns false
+		resp, err = client.Get(errorServer.URL + "/missing")
+		Expect(err).NotTo(HaveOccurred())
+		body, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		Expect(string(body)).To(Equal("no such page"))
+		Expect(samples).To(HaveLen(2))
+		Expect(samples[1].StatusCode).To(Equal(http.StatusNotFound))
+		Expect(samples[1].ResponseBody).To(BeNil())
+
+		// successful requests aren't sampled, and errors are sampled without status code
+		resp, err = client.Get(server.URL + "/hello")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		_, err = client.Get("http://127.0.0.1:0/unreachable")
+		Expect(err).To(HaveOccurred())
+		Expect(samples).To(HaveLen(3))
+		Expect(samples[2].StatusCode).To(BeZero())
+		Expect(samples[2].Error).NotTo(BeEmpty())
+	})
+})
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
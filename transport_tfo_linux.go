@@ -0,0 +1,43 @@
+//go:build linux
+// +build linux
+
+package boomer
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpiOptSynData is set in tcp_info.tcpi_options if the data sent in SYN is acknowledged by the server.
+const tcpiOptSynData = 0x20
+
+// TCPFastOpenControl enables TCP Fast Open on the socket before connecting, it's used as the Control of a net.Dialer.
+// If the kernel doesn't support it, the error is ignored and a normal handshake is used.
+func TCPFastOpenControl(network, address string, c syscall.RawConn) error {
+	return c.Control(func(fd uintptr) {
+		_ = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT, 1)
+	})
+}
+
+// tcpFastOpenUsed reports whether the data sent in SYN is accepted by the server, which means the cookie hits.
+// ok is false if it can't be told.
+func tcpFastOpenUsed(conn net.Conn) (used, ok bool) {
+	sc, isSyscallConn := conn.(syscall.Conn)
+	if !isSyscallConn {
+		return false, false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false, false
+	}
+	var info *unix.TCPInfo
+	err = raw.Control(func(fd uintptr) {
+		info, err = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	if err != nil || info == nil {
+		return false, false
+	}
+	return info.Options&tcpiOptSynData != 0, true
+}
@@ -0,0 +1,43 @@
+//go:build linux
+// +build linux
+
+package boomer
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"syscall"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/sys/unix"
+)
+
+var _ = Describe("Test tcp fast open on linux", func() {
+
+	It("test the socket option is set", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer listener.Close()
+
+		t := NewBoomerTransport().WithTCPFastOpen(true)
+		conn, err := t.inner.(*http.Transport).DialContext(context.Background(), "tcp", listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		raw, err := conn.(syscall.Conn).SyscallConn()
+		Expect(err).NotTo(HaveOccurred())
+		var value int
+		raw.Control(func(fd uintptr) {
+			value, err = unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT)
+		})
+		if err != nil {
+			Skip("TCP_FASTOPEN_CONNECT is not supported by the kernel")
+		}
+		Expect(value).To(Equal(1))
+
+		_, ok := tcpFastOpenUsed(conn)
+		Expect(ok).To(BeTrue())
+	})
+})
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package boomer
+
+import (
+	"net"
+	"syscall"
+)
+
+// TCPFastOpenControl enables TCP Fast Open on the socket before connecting, it's used as the Control of a net.Dialer.
+// TCP Fast Open is only supported on Linux, a normal handshake is used on other platforms.
+func TCPFastOpenControl(network, address string, c syscall.RawConn) error {
+	return nil
+}
+
+func tcpFastOpenUsed(conn net.Conn) (used, ok bool) {
+	return false, false
+}
@@ -1,11 +1,15 @@
 package boomer
 
 import (
+	"context"
 	"crypto/md5"
+	crand "crypto/rand"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"log"
 	"math"
+	"math/rand"
 	"os"
 	"runtime"
 	"runtime/pprof"
@@ -29,6 +33,19 @@ func castToInt64(num interface{}) (ret int64, ok bool) {
 	return int64(0), false
 }
 
+func castToFloat64(num interface{}) (ret float64, ok bool) {
+	switch n := num.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	}
+	if i, ok := castToInt64(num); ok {
+		return float64(i), true
+	}
+	return float64(0), false
+}
+
 func round(val float64, roundOn float64, places int) (newVal float64) {
 	var round float64
 	pow := math.Pow(10, float64(places))
@@ -43,6 +60,12 @@ func round(val float64, roundOn float64, places int) (newVal float64) {
 	return
 }
 
+// RoundToMillisecond truncates the sub-millisecond part of a response time in milliseconds,
+// for reporting in whole milliseconds.
+func RoundToMillisecond(v float64) int64 {
+	return int64(math.Trunc(v))
+}
+
 // MD5 returns the md5 hash of strings.
 func MD5(slice ...string) string {
 	h := md5.New()
@@ -143,3 +166,129 @@ func GetCurrentMemUsage() uint64 {
 	runtime.ReadMemStats(&m)
 	return m.Alloc
 }
+
+// circularBuffer keeps the latest items up to its capacity, it's safe to be used by multiple goroutines.
+type circularBuffer[T any] struct {
+	items []T
+	next  int // the index to write the next item
+	full  bool
+	lock  sync.RWMutex
+}
+
+func newCircularBuffer[T any](capacity int) *circularBuffer[T] {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &circularBuffer[T]{
+		items: make([]T, capacity),
+	}
+}
+
+// Push adds an item, overwriting the oldest one if the buffer is full.
+func (b *circularBuffer[T]) Push(v T) {
+	b.lock.Lock()
+	b.items[b.next] = v
+	b.next++
+	if b.next == len(b.items) {
+		b.next = 0
+		b.full = true
+	}
+	b.lock.Unlock()
+}
+
+// Len returns the number of items in the buffer.
+func (b *circularBuffer[T]) Len() int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.len()
+}
+
+func (b *circularBuffer[T]) len() int {
+	if b.full {
+		return len(b.items)
+	}
+	return b.next
+}
+
+// Last returns the latest n items, from the oldest to the newest.
+func (b *circularBuffer[T]) Last(n int) []T {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	size := b.len()
+	if n > size {
+		n = size
+	}
+	if n <= 0 {
+		return []T{}
+	}
+	result := make([]T, n)
+	start := b.next - n
+	if start < 0 {
+		start += len(b.items)
+	}
+	for i := range result {
+		result[i] = b.items[(start+i)%len(b.items)]
+	}
+	return result
+}
+
+// random is the default random source used by boomer for stochastic behavior, like shuffling data feeds.
+// It's never replaced, the runners use their own source if Boomer.WithRandomSeed or WithCryptoRandom is set.
+var random = rand.New(newLockedSource(time.Now().UnixNano()))
+
+type randomContextKey struct{}
+
+// randomFromContext returns the random source of the runner carried by the context of a user,
+// or the default one if there isn't any.
+func randomFromContext(ctx context.Context) *rand.Rand {
+	if r, ok := ctx.Value(randomContextKey{}).(*rand.Rand); ok {
+		return r
+	}
+	return random
+}
+
+// lockedSource is a rand.Source which is safe to be used by multiple goroutines.
+type lockedSource struct {
+	lock sync.Mutex
+	src  rand.Source64
+}
+
+func newLockedSource(seed int64) *lockedSource {
+	return &lockedSource{src: rand.NewSource(seed).(rand.Source64)}
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.src.Seed(seed)
+}
+
+// cryptoSource is a rand.Source backed by crypto/rand, it can't be seeded.
+type cryptoSource struct{}
+
+func (s cryptoSource) Int63() int64 {
+	return int64(s.Uint64() & (1<<63 - 1))
+}
+
+func (s cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand is unavailable, %v", err))
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+func (s cryptoSource) Seed(seed int64) {}
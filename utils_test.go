@@ -3,6 +3,7 @@ package boomer
 import (
 	"os"
 	"regexp"
+	"sync"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -29,6 +30,14 @@ var _ = Describe("Test utils", func() {
 		Entry("3432.5002", float64(3432.5002), .5, -2, float64(3400)),
 	)
 
+	DescribeTable("test round to millisecond", func(value float64, expect int64) {
+		Expect(RoundToMillisecond(value)).To(Equal(expect))
+	},
+		Entry("12.999", float64(12.999), int64(12)),
+		Entry("12", float64(12), int64(12)),
+		Entry("0.4", float64(0.4), int64(0)),
+	)
+
 	It("test md5", func() {
 		hashValue := MD5("Hello", "World!")
 		Expect(hashValue).To(Equal("06e0e6637d27b2622ab52022db713ce2"))
@@ -66,4 +75,45 @@ var _ = Describe("Test utils", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Eventually("cpu.pprof").WithTimeout(3 * time.Second).Should(BeAnExistingFile())
 	})
+
+	It("test circular buffer", func() {
+		b := newCircularBuffer[int](3)
+		Expect(b.Len()).To(Equal(0))
+		Expect(b.Last(2)).To(BeEmpty())
+
+		b.Push(1)
+		b.Push(2)
+		Expect(b.Len()).To(Equal(2))
+		Expect(b.Last(5)).To(Equal([]int{1, 2}))
+
+		b.Push(3)
+		b.Push(4)
+		b.Push(5)
+		Expect(b.Len()).To(Equal(3))
+		Expect(b.Last(3)).To(Equal([]int{3, 4, 5}))
+		Expect(b.Last(2)).To(Equal([]int{4, 5}))
+		Expect(b.Last(0)).To(BeEmpty())
+	})
+
+	It("test circular buffer concurrently", func() {
+		b := newCircularBuffer[int](10)
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					b.Push(i*100 + j)
+				}
+			}(i)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					Expect(len(b.Last(5))).To(BeNumerically("<=", 5))
+				}
+			}()
+		}
+		wg.Wait()
+		Expect(b.Len()).To(Equal(10))
+	})
 })